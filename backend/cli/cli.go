@@ -0,0 +1,350 @@
+// Package cli runs the registered tools from the command line without the HTTP server.
+// Each invocation builds the same JSON request the API would receive and calls the tool's
+// transform, so output is identical to the corresponding endpoint.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"digi-leatherman/backend/handlers"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK           = 0 // tool succeeded
+	ExitInvalidInput = 1 // tool rejected the input (or validation reported it invalid)
+	ExitUsage        = 2 // unknown tool, bad flag or missing argument
+	ExitInternal     = 3 // I/O or unexpected server-side failure
+)
+
+// categoryAliases maps short command names to registry category ids.
+var categoryAliases = map[string]string{
+	"lorem": "lorem-ipsum",
+}
+
+// field is a request property that can be set from a --flag.
+type field struct {
+	path []string // JSON property names, e.g. ["options", "vocabulary"]
+	typ  reflect.Type
+}
+
+// usageError marks a command-line mistake (exit code 2).
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// Run executes one command, e.g. ["string", "snake-case", "Hello World"], reading input
+// from stdin when no positional value is given. It returns the process exit code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return ExitUsage
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return ExitOK
+	}
+	if len(args) < 2 {
+		fmt.Fprintf(stderr, "dl: missing tool name for %q\n", args[0])
+		printUsage(stderr)
+		return ExitUsage
+	}
+	category := args[0]
+	if alias, ok := categoryAliases[category]; ok {
+		category = alias
+	}
+	tool, ok := handlers.LookupTool(category, args[1])
+	if !ok {
+		fmt.Fprintf(stderr, "dl: unknown tool %q %q (run \"dl help\" for a list)\n", args[0], args[1])
+		return ExitUsage
+	}
+	fields := requestFields(reflect.TypeOf(tool.Request))
+	for _, a := range args[2:] {
+		if a == "-h" || a == "--help" {
+			printToolUsage(stdout, args[0], tool, fields)
+			return ExitOK
+		}
+	}
+	body, err := buildRequest(tool, fields, args[2:], stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "dl: %v\n", err)
+		if _, ok := err.(usageError); ok {
+			return ExitUsage
+		}
+		return ExitInternal
+	}
+	resp, err := tool.Transform(context.Background(), body)
+	if err != nil {
+		fmt.Fprintf(stderr, "dl: %v\n", err)
+		if handlers.IsInputError(err) {
+			return ExitInvalidInput
+		}
+		return ExitInternal
+	}
+	return writeResponse(stdout, stderr, resp)
+}
+
+// buildRequest turns flags and the piped value into the tool's JSON request body.
+func buildRequest(tool *handlers.Tool, fields map[string]field, args []string, stdin io.Reader) (json.RawMessage, error) {
+	body := make(map[string]interface{})
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(a, "--") {
+			positional = append(positional, a)
+			continue
+		}
+		name, val, hasVal := strings.Cut(a[2:], "=")
+		f, ok := fields[normalizeFlag(name)]
+		if !ok {
+			return nil, usageError(fmt.Sprintf("unknown flag --%s for %s", name, tool.Key()))
+		}
+		if !hasVal {
+			if f.typ.Kind() == reflect.Bool {
+				val = "true"
+			} else if i+1 < len(args) {
+				i++
+				val = args[i]
+			} else {
+				return nil, usageError(fmt.Sprintf("flag --%s needs a value", name))
+			}
+		}
+		if err := setField(body, f, val); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeField := tool.PipeField
+	if pipeField == "" {
+		pipeField = "value"
+	}
+	if _, ok := fields[normalizeFlag(pipeField)]; !ok {
+		if len(positional) > 0 {
+			return nil, usageError(fmt.Sprintf("%s takes no input value; use flags", tool.Key()))
+		}
+		return json.Marshal(body)
+	}
+	if _, set := body[pipeField]; !set {
+		if len(positional) > 0 {
+			body[pipeField] = strings.Join(positional, " ")
+		} else {
+			in, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, fmt.Errorf("read stdin: %w", err)
+			}
+			body[pipeField] = trimNewline(string(in))
+		}
+	} else if len(positional) > 0 {
+		return nil, usageError(fmt.Sprintf("input given both as --%s and as an argument", pipeField))
+	}
+	return json.Marshal(body)
+}
+
+// setField stores val (converted to the field's type) at f.path in body.
+// String values starting with "@" are read from the named file.
+func setField(body map[string]interface{}, f field, val string) error {
+	flag := strings.Join(f.path, ".")
+	var v interface{}
+	switch f.typ.Kind() {
+	case reflect.String:
+		if strings.HasPrefix(val, "@") && len(val) > 1 {
+			data, err := os.ReadFile(val[1:])
+			if err != nil {
+				return fmt.Errorf("--%s: %w", flag, err)
+			}
+			val = trimNewline(string(data))
+		}
+		v = val
+	case reflect.Int:
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return usageError(fmt.Sprintf("--%s: %q is not an integer", flag, val))
+		}
+		v = n
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return usageError(fmt.Sprintf("--%s: %q is not a number", flag, val))
+		}
+		v = n
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return usageError(fmt.Sprintf("--%s: %q is not a boolean", flag, val))
+		}
+		v = b
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		m := containerFor(body, f.path)
+		if prev, ok := m[f.path[len(f.path)-1]].([]string); ok {
+			items = append(prev, items...)
+		}
+		v = items
+	default:
+		return usageError(fmt.Sprintf("--%s cannot be set from the command line", flag))
+	}
+	containerFor(body, f.path)[f.path[len(f.path)-1]] = v
+	return nil
+}
+
+// containerFor returns the map that holds the last element of path, creating nested maps.
+func containerFor(body map[string]interface{}, path []string) map[string]interface{} {
+	m := body
+	for _, p := range path[:len(path)-1] {
+		child, ok := m[p].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[p] = child
+		}
+		m = child
+	}
+	return m
+}
+
+// requestFields lists the settable properties of a request type keyed by normalized flag
+// name. Properties of nested option structs are included unless a top-level one has the same name.
+func requestFields(t reflect.Type) map[string]field {
+	fields := make(map[string]field)
+	var nested []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := handlers.JSONFieldName(f)
+		if !ok {
+			continue
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			for j := 0; j < ft.NumField(); j++ {
+				sub := ft.Field(j)
+				subName, ok := handlers.JSONFieldName(sub)
+				if !ok {
+					continue
+				}
+				nested = append(nested, field{path: []string{name, subName}, typ: sub.Type})
+			}
+			continue
+		}
+		fields[normalizeFlag(name)] = field{path: []string{name}, typ: ft}
+	}
+	for _, f := range nested {
+		fields[normalizeFlag(strings.Join(f.path, "."))] = f
+		key := normalizeFlag(f.path[1])
+		if _, taken := fields[key]; !taken {
+			fields[key] = f
+		}
+	}
+	return fields
+}
+
+// normalizeFlag makes --list-style, --listStyle and --liststyle equivalent.
+func normalizeFlag(s string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(s))
+}
+
+// trimNewline drops one trailing newline, as shell command substitution does.
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// writeResponse prints string results as plain text and anything else as indented JSON.
+func writeResponse(stdout, stderr io.Writer, resp interface{}) int {
+	if r, ok := resp.(handlers.YAMLToJSONResponse); ok {
+		printYAMLWarnings(stderr, r.Warnings)
+	}
+	if s, ok := handlers.ResultText(resp); ok {
+		fmt.Fprintln(stdout, s)
+		return ExitOK
+	}
+	switch r := resp.(type) {
+	case handlers.ValidateResponse:
+		for _, w := range r.Warnings {
+			fmt.Fprintf(stderr, "dl: warning: line %d, column %d: %s\n", w.Line, w.Column, w.Message)
+		}
+		if !r.Valid {
+			fmt.Fprintf(stderr, "dl: invalid JSON: %s\n%s\n", r.Error, r.Syntax.Snippet)
+			return ExitInvalidInput
+		}
+		fmt.Fprintln(stdout, "valid")
+		return ExitOK
+	case handlers.YAMLValidateResponse:
+		printYAMLWarnings(stderr, r.Warnings)
+		if !r.Valid {
+			fmt.Fprintf(stderr, "dl: invalid YAML: %s\n", r.Error)
+			return ExitInvalidInput
+		}
+		fmt.Fprintln(stdout, "valid")
+		return ExitOK
+	}
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "dl: %v\n", err)
+		return ExitInternal
+	}
+	fmt.Fprintln(stdout, string(out))
+	return ExitOK
+}
+
+func printYAMLWarnings(stderr io.Writer, warnings []handlers.YAMLWarning) {
+	for _, w := range warnings {
+		fmt.Fprintf(stderr, "dl: warning: line %d, column %d: %s\n", w.Line, w.Column, w.Message)
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: dl <category> <tool> [--flag value ...] [input]")
+	fmt.Fprintln(w, "Input is taken from the arguments or, if none are given, from stdin.")
+	fmt.Fprintln(w, "Exit codes: 0 ok, 1 invalid input, 2 usage error, 3 internal error.")
+	fmt.Fprintln(w)
+	current := ""
+	for _, t := range handlers.Tools() {
+		if t.Category != current {
+			current = t.Category
+			name := current
+			for alias, cat := range categoryAliases {
+				if cat == current {
+					name = alias
+				}
+			}
+			fmt.Fprintf(w, "%s\n", name)
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", t.ID, t.Description)
+	}
+}
+
+func printToolUsage(w io.Writer, category string, tool *handlers.Tool, fields map[string]field) {
+	fmt.Fprintf(w, "usage: dl %s %s [flags] [input]\n%s\n\nflags:\n", category, tool.ID, tool.Description)
+	seen := make(map[string]bool)
+	var names []string
+	for _, f := range fields {
+		name := strings.Join(f.path, ".")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  --%s\n", name)
+	}
+}
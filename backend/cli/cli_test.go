@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name     string
+		stdin    string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"no args", "", nil, ExitUsage, ""},
+		{"help", "", []string{"help"}, ExitOK, ""},
+		{"missing tool", "", []string{"string"}, ExitUsage, ""},
+		{"unknown tool", "", []string{"string", "nope"}, ExitUsage, ""},
+		{"unknown flag", "", []string{"string", "trim", "--bogus", "x"}, ExitUsage, ""},
+		{"args input", "", []string{"string", "snake-case", "Hello", "World"}, ExitOK, "hello_world\n"},
+		{"stdin input", "Hello World\n", []string{"string", "kebab-case"}, ExitOK, "hello-world\n"},
+		{"stdin keeps inner newlines", "a\nb\n", []string{"string", "url-encode"}, ExitOK, "a%0Ab\n"},
+		{"invalid input", "!!", []string{"string", "base64-decode"}, ExitInvalidInput, ""},
+		{"json format", `{"a":1}`, []string{"json", "format"}, ExitOK, "{\n  \"a\": 1\n}\n"},
+		{"json minify lenient", `{a: 1, /* b */}`, []string{"json", "minify", "--lenient"}, ExitOK, "{\"a\":1}\n"},
+		{"json repair", `{'a': [1,],}`, []string{"json", "repair"}, ExitOK, "{\n  \"a\": [\n    1\n  ]\n}\n"},
+		{"json format options", `{"b":[1,2],"a":1}`, []string{"json", "format", "--sort-keys", "--compact-arrays", "--indent", "1"}, ExitOK, "{\n \"a\": 1,\n \"b\": [1, 2]\n}\n"},
+		{"json path flag", `{"a":{"b":[1,2]}}`, []string{"json", "path", "--path", "a.b.1"}, ExitOK, "2\n"},
+		{"json path equals flag", `{"a":5}`, []string{"json", "path", "--path=a"}, ExitOK, "5\n"},
+		{"json path not found", `{"a":5}`, []string{"json", "path", "--path", "b"}, ExitInvalidInput, ""},
+		{"json validate invalid", `{`, []string{"json", "validate"}, ExitInvalidInput, ""},
+		{"json validate valid", `[]`, []string{"json", "validate"}, ExitOK, "valid\n"},
+		{"json validate warnings", `{"a":1,"a":2}`, []string{"json", "validate"}, ExitOK, "valid\n"},
+		{"yaml to-json", "a: 1\n---\nb: on\n", []string{"yaml", "to-json", "--version", "1.1"}, ExitOK, "[\n  {\n    \"a\": 1\n  },\n  {\n    \"b\": true\n  }\n]\n"},
+		{"yaml from-json", `{"b":"yes","a":[1]}`, []string{"yaml", "from-json"}, ExitOK, "b: \"yes\"\na:\n  - 1\n"},
+		{"yaml validate invalid", "a: 1\na: 2\n", []string{"yaml", "validate"}, ExitInvalidInput, ""},
+		{"yaml validate valid", "a: on\n", []string{"yaml", "validate"}, ExitOK, "valid\n"},
+		{"json diff", `{"a":1}`, []string{"json", "diff", "--value-b", `{"a":1}`}, ExitOK, "(no differences)\n"},
+		{"json diff tolerance", `{"a":1}`, []string{"json", "diff", "--value-b", `{"a":1.01}`, "--tolerance", "0.1"}, ExitOK, "(no differences)\n"},
+		{"json diff tolerance not a number", `{"a":1}`, []string{"json", "diff", "--value-b", `{"a":1}`, "--tolerance", "x"}, ExitUsage, ""},
+		{"spell out alphabet", "", []string{"string", "spell-out", "--alphabet", "nato", "ab"}, ExitOK, "A for Alpha, B for Bravo\n"},
+		{"lorem count not integer", "", []string{"lorem", "generate", "--type", "words", "--count", "x"}, ExitUsage, ""},
+		{"lorem out of range", "", []string{"lorem", "generate", "--type", "words", "--count", "0"}, ExitInvalidInput, ""},
+		{"lorem takes no input", "", []string{"lorem", "generate", "--count", "1", "extra"}, ExitUsage, ""},
+		{"flag missing value", "", []string{"json", "path", "--path"}, ExitUsage, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, out, errOut := runCLI(t, tc.stdin, tc.args...)
+			if code != tc.wantCode {
+				t.Errorf("exit code = %d, want %d; stderr: %s", code, tc.wantCode, errOut)
+			}
+			if tc.wantOut != "" && out != tc.wantOut {
+				t.Errorf("stdout = %q, want %q", out, tc.wantOut)
+			}
+		})
+	}
+}
+
+func TestRunLorem(t *testing.T) {
+	code, out, errOut := runCLI(t, "", "lorem", "generate", "--type", "words", "--count", "4", "--vocabulary", "bacon", "--start-with-classic=false")
+	if code != ExitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, errOut)
+	}
+	if words := strings.Fields(out); len(words) != 4 {
+		t.Errorf("got %d words, want 4: %q", len(words), out)
+	}
+
+	code, out, _ = runCLI(t, "", "lorem", "generate", "--tool", "json", "--keys", "a,b", "--keys", "c")
+	if code != ExitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+	for _, k := range []string{`"a"`, `"b"`, `"c"`} {
+		if !strings.Contains(out, k) {
+			t.Errorf("json output should contain key %s, got %s", k, out)
+		}
+	}
+}
+
+func TestRunFileFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "b.json")
+	if err := os.WriteFile(path, []byte(`{"a":2}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, out, errOut := runCLI(t, `{"a":1}`, "json", "diff", "--valueB", "@"+path)
+	if code != ExitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, errOut)
+	}
+	if out != "a: 1 -> 2\n" {
+		t.Errorf("stdout = %q, want %q", out, "a: 1 -> 2\n")
+	}
+}
+
+func TestNormalizeFlag(t *testing.T) {
+	for _, in := range []string{"list-style", "listStyle", "liststyle", "list_style"} {
+		if got := normalizeFlag(in); got != "liststyle" {
+			t.Errorf("normalizeFlag(%q) = %q, want %q", in, got, "liststyle")
+		}
+	}
+}
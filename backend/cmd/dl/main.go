@@ -0,0 +1,16 @@
+// Command dl runs the Digi Leatherman tools from the shell, e.g.
+//
+//	dl string snake-case "Hello World"
+//	dl json format < file.json
+//	dl lorem generate --type paragraphs --count 3
+package main
+
+import (
+	"os"
+
+	"digi-leatherman/backend/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
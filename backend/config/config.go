@@ -0,0 +1,502 @@
+// Package config loads the server configuration from defaults, an optional YAML, TOML or
+// JSON file, DL_* environment variables and command-line flags (in increasing precedence).
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"digi-leatherman/backend/handlers"
+	"digi-leatherman/backend/middleware"
+)
+
+// envPrefix is prepended to every environment variable name, e.g. DL_ADDR.
+const envPrefix = "DL_"
+
+// Config is the complete server configuration. Field names in config files match the
+// json tags, e.g. "corsOrigins" or "limits: {lorem: {words: 2000}}".
+type Config struct {
+	Addr         string           `json:"addr"`
+	CORSOrigins  []string         `json:"corsOrigins"`
+	LogLevel     string           `json:"logLevel"`  // debug, info, warn, error
+	LogFormat    string           `json:"logFormat"` // text, json
+	MaxBodyBytes int64            `json:"maxBodyBytes"`
+	BodyLimits   map[string]int64 `json:"bodyLimits"` // per-route overrides of MaxBodyBytes, keyed by path
+	Timeouts     Timeouts         `json:"timeouts"`
+	Limits       handlers.Limits  `json:"limits"`
+}
+
+// Timeouts bounds how long the server spends on a connection or request. Request is the
+// context deadline given to each handler; transforms such as JSON diff stop when it passes
+// and respond 503, so it must be shorter than Write. Shutdown is how long in-flight
+// requests may drain after SIGINT/SIGTERM before connections are closed.
+type Timeouts struct {
+	ReadHeader Duration `json:"readHeader"`
+	Read       Duration `json:"read"`
+	Write      Duration `json:"write"`
+	Idle       Duration `json:"idle"`
+	Request    Duration `json:"request"`
+	Shutdown   Duration `json:"shutdown"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default returns the built-in configuration: localhost CORS on :8100.
+func Default() Config {
+	return Config{
+		Addr:         ":8100",
+		CORSOrigins:  append([]string(nil), middleware.DefaultCORSOrigins...),
+		LogLevel:     "info",
+		LogFormat:    "text",
+		MaxBodyBytes: 5 << 20,
+		BodyLimits: map[string]int64{
+			"/api/lorem-ipsum/generate": 64 << 10,
+		},
+		Timeouts: Timeouts{
+			ReadHeader: Duration(5 * time.Second),
+			Read:       Duration(30 * time.Second),
+			Write:      Duration(60 * time.Second),
+			Idle:       Duration(120 * time.Second),
+			Request:    Duration(15 * time.Second),
+			Shutdown:   Duration(20 * time.Second),
+		},
+		Limits: handlers.DefaultLimits(),
+	}
+}
+
+// Load parses args (without the program name) and getenv into a validated Config.
+// printConfig is true when --print-config was given. flag.ErrHelp is returned for -h.
+func Load(args []string, getenv func(string) string) (cfg Config, printConfig bool, err error) {
+	fs := flag.NewFlagSet("digi-leatherman", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML, TOML or JSON config file (env DL_CONFIG)")
+	addr := fs.String("addr", "", "listen address, e.g. :8100 (env DL_ADDR)")
+	origins := fs.String("cors-origins", "", "comma-separated allowed CORS origins; * and port wildcards allowed (env DL_CORS_ORIGINS)")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error (env DL_LOG_LEVEL)")
+	logFormat := fs.String("log-format", "", "text or json (env DL_LOG_FORMAT)")
+	maxBody := fs.Int64("max-body-bytes", 0, "maximum request body size in bytes (env DL_MAX_BODY_BYTES)")
+	var limitFlags []string
+	fs.Func("limit", "override a tool limit, e.g. lorem.words=2000 (repeatable; env DL_LIMITS_LOREM_WORDS)", func(s string) error {
+		limitFlags = append(limitFlags, s)
+		return nil
+	})
+	var bodyLimitFlags []string
+	fs.Func("body-limit", "override the body size limit of one route, e.g. /api/json/diff=10485760 (repeatable; env DL_BODY_LIMITS, comma-separated)", func(s string) error {
+		bodyLimitFlags = append(bodyLimitFlags, s)
+		return nil
+	})
+	timeoutFlags := make(map[string]string)
+	for _, t := range timeoutSettings {
+		name := t.name
+		fs.Func(name+"-timeout", fmt.Sprintf("%s, e.g. 30s (env %s)", t.usage, t.env()), func(s string) error {
+			timeoutFlags[name] = s
+			return nil
+		})
+	}
+	fs.BoolVar(&printConfig, "print-config", false, "print the effective configuration as JSON and exit")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, false, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	cfg = Default()
+	path := *configPath
+	if path == "" {
+		path = getenv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, false, err
+		}
+	}
+	if err := cfg.applyEnv(getenv); err != nil {
+		return Config{}, false, err
+	}
+
+	if set["addr"] {
+		cfg.Addr = *addr
+	}
+	if set["cors-origins"] {
+		cfg.CORSOrigins = splitList(*origins)
+	}
+	if set["log-level"] {
+		cfg.LogLevel = *logLevel
+	}
+	if set["log-format"] {
+		cfg.LogFormat = *logFormat
+	}
+	if set["max-body-bytes"] {
+		cfg.MaxBodyBytes = *maxBody
+	}
+	for _, kv := range bodyLimitFlags {
+		if err := cfg.setBodyLimit(kv); err != nil {
+			return Config{}, false, fmt.Errorf("--body-limit: %w", err)
+		}
+	}
+	for _, t := range timeoutSettings {
+		if v, ok := timeoutFlags[t.name]; ok {
+			if err := t.field(&cfg.Timeouts).UnmarshalText([]byte(v)); err != nil {
+				return Config{}, false, fmt.Errorf("--%s-timeout: %w", t.name, err)
+			}
+		}
+	}
+	for _, kv := range limitFlags {
+		key, val, ok := strings.Cut(kv, "=")
+		if !ok {
+			return Config{}, false, fmt.Errorf("--limit %q: want key=value", kv)
+		}
+		if err := cfg.setLimit(strings.TrimSpace(key), strings.TrimSpace(val)); err != nil {
+			return Config{}, false, fmt.Errorf("--limit: %w", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, printConfig, nil
+}
+
+// loadFile merges the file at path into c; the format is chosen by extension.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var raw interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var m map[string]interface{}
+		_, err = toml.Decode(string(data), &m)
+		raw = m
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("config %s: unsupported extension (want .yaml, .yml, .toml or .json)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if raw == nil {
+		return nil // empty file
+	}
+	// Round-trip through JSON so the json tags are the single source of field names.
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides c from DL_* variables.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	if v := getenv(envPrefix + "ADDR"); v != "" {
+		c.Addr = v
+	}
+	if v := getenv(envPrefix + "CORS_ORIGINS"); v != "" {
+		c.CORSOrigins = splitList(v)
+	}
+	if v := getenv(envPrefix + "LOG_LEVEL"); v != "" {
+		c.LogLevel = v
+	}
+	if v := getenv(envPrefix + "LOG_FORMAT"); v != "" {
+		c.LogFormat = v
+	}
+	if v := getenv(envPrefix + "MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%sMAX_BODY_BYTES: %q is not an integer", envPrefix, v)
+		}
+		c.MaxBodyBytes = n
+	}
+	if v := getenv(envPrefix + "BODY_LIMITS"); v != "" {
+		for _, kv := range splitList(v) {
+			if err := c.setBodyLimit(kv); err != nil {
+				return fmt.Errorf("%sBODY_LIMITS: %w", envPrefix, err)
+			}
+		}
+	}
+	for _, t := range timeoutSettings {
+		if v := getenv(t.env()); v != "" {
+			if err := t.field(&c.Timeouts).UnmarshalText([]byte(v)); err != nil {
+				return fmt.Errorf("%s: %w", t.env(), err)
+			}
+		}
+	}
+	keys, err := c.limitKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		name := limitEnvName(key)
+		if v := getenv(name); v != "" {
+			if err := c.setLimit(key, v); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// timeoutSettings names each field of Timeouts for flags (--read-header-timeout) and
+// environment variables (DL_READ_HEADER_TIMEOUT).
+var timeoutSettings = []timeoutSetting{
+	{"read-header", "time allowed to read request headers", func(t *Timeouts) *Duration { return &t.ReadHeader }},
+	{"read", "time allowed to read a whole request", func(t *Timeouts) *Duration { return &t.Read }},
+	{"write", "time allowed to write a response, counted from the end of the headers", func(t *Timeouts) *Duration { return &t.Write }},
+	{"idle", "keep-alive time between requests", func(t *Timeouts) *Duration { return &t.Idle }},
+	{"request", "processing deadline for each request", func(t *Timeouts) *Duration { return &t.Request }},
+	{"shutdown", "time allowed for in-flight requests to finish after SIGINT/SIGTERM", func(t *Timeouts) *Duration { return &t.Shutdown }},
+}
+
+type timeoutSetting struct {
+	name  string // flag prefix, e.g. "read-header"
+	usage string
+	field func(*Timeouts) *Duration
+}
+
+// env returns the variable name, e.g. DL_READ_HEADER_TIMEOUT.
+func (t timeoutSetting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(t.name, "-", "_")) + "_TIMEOUT"
+}
+
+// key returns the config file key under timeouts, e.g. readHeader.
+func (t timeoutSetting) key() string {
+	first, rest, _ := strings.Cut(t.name, "-")
+	if rest == "" {
+		return first
+	}
+	return first + strings.ToUpper(rest[:1]) + rest[1:]
+}
+
+// setBodyLimit applies one "path=bytes" body limit override.
+func (c *Config) setBodyLimit(kv string) error {
+	path, v, ok := strings.Cut(kv, "=")
+	if !ok {
+		return fmt.Errorf("%q: want path=bytes", kv)
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q: %q is not an integer", kv, v)
+	}
+	if c.BodyLimits == nil {
+		c.BodyLimits = make(map[string]int64)
+	}
+	c.BodyLimits[strings.TrimSpace(path)] = n
+	return nil
+}
+
+// BodyLimit returns the maximum request body size for the route at path.
+func (c *Config) BodyLimit(path string) int64 {
+	if n, ok := c.BodyLimits[path]; ok {
+		return n
+	}
+	return c.MaxBodyBytes
+}
+
+// limitKeys lists the dotted limit keys, e.g. "lorem.words", in sorted order.
+func (c *Config) limitKeys() ([]string, error) {
+	m, err := limitsMap(c.Limits)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for section, v := range m {
+		for key := range v {
+			keys = append(keys, section+"."+key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// setLimit sets the limit named by a dotted key such as "lorem.words".
+func (c *Config) setLimit(key, value string) error {
+	section, name, ok := strings.Cut(key, ".")
+	m, err := limitsMap(c.Limits)
+	if err != nil {
+		return err
+	}
+	if _, known := m[section][name]; !ok || !known {
+		return fmt.Errorf("unknown limit %q", key)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("limit %s: %q is not an integer", key, value)
+	}
+	m[section][name] = n
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &c.Limits)
+}
+
+func limitsMap(l handlers.Limits) (map[string]map[string]interface{}, error) {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]map[string]interface{}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+// limitEnvName maps "lorem.titleWords" to DL_LIMITS_LOREM_TITLE_WORDS.
+func limitEnvName(key string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix + "LIMITS_")
+	for _, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r):
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// Validate reports every invalid setting.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q: %v", c.Addr, err))
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		errs = append(errs, fmt.Errorf("addr %q: invalid port", c.Addr))
+	}
+	for _, o := range c.CORSOrigins {
+		if o != "*" && !strings.HasPrefix(o, "http://") && !strings.HasPrefix(o, "https://") {
+			errs = append(errs, fmt.Errorf("corsOrigins: %q must be * or start with http:// or https://", o))
+		}
+	}
+	if _, err := parseLevel(c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("logFormat %q: must be text or json", c.LogFormat))
+	}
+	if c.MaxBodyBytes <= 0 {
+		errs = append(errs, fmt.Errorf("maxBodyBytes must be positive"))
+	}
+	routes := make(map[string]bool)
+	for _, t := range handlers.Tools() {
+		routes[t.Path] = true
+	}
+	for _, e := range handlers.Endpoints() {
+		routes[e.Path] = true
+	}
+	for path, n := range c.BodyLimits {
+		if !routes[path] {
+			errs = append(errs, fmt.Errorf("bodyLimits: unknown route %q", path))
+		} else if n <= 0 {
+			errs = append(errs, fmt.Errorf("bodyLimits %s must be positive", path))
+		}
+	}
+	for _, t := range timeoutSettings {
+		if *t.field(&c.Timeouts) <= 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must be positive", t.key()))
+		}
+	}
+	if c.Timeouts.Request >= c.Timeouts.Write {
+		errs = append(errs, fmt.Errorf("timeouts.request (%s) must be shorter than timeouts.write (%s)",
+			time.Duration(c.Timeouts.Request), time.Duration(c.Timeouts.Write)))
+	}
+	m, err := limitsMap(c.Limits)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	keys, _ := c.limitKeys()
+	for _, key := range keys {
+		section, name, _ := strings.Cut(key, ".")
+		if n, _ := m[section][name].(float64); n <= 0 {
+			errs = append(errs, fmt.Errorf("limits.%s must be positive", key))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("logLevel %q: must be debug, info, warn or error", s)
+}
+
+// Logger returns a slog.Logger writing to w with the configured level and format.
+func (c *Config) Logger(w io.Writer) *slog.Logger {
+	level, _ := parseLevel(c.LogLevel)
+	opts := &slog.HandlerOptions{Level: level}
+	if c.LogFormat == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Print writes c as indented JSON, which is also valid YAML and can be used as a config file.
+func (c *Config) Print(w io.Writer) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
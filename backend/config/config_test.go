@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, printConfig, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if printConfig {
+		t.Errorf("printConfig should default to false")
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Load() = %+v, want defaults %+v", cfg, Default())
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "dl.yaml", `
+addr: ":9000"
+logLevel: debug
+corsOrigins: ["https://tools.internal"]
+limits:
+  lorem:
+    words: 50
+    sentences: 7
+`)
+	vars := map[string]string{
+		"DL_CONFIG":             path,
+		"DL_LOG_LEVEL":          "warn",
+		"DL_LIMITS_LOREM_WORDS": "60",
+	}
+	cfg, _, err := Load([]string{"--log-level", "error", "--limit", "lorem.titleWords=3"}, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Addr != ":9000" {
+		t.Errorf("addr = %q, want file value :9000", cfg.Addr)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("logLevel = %q, want flag value error", cfg.LogLevel)
+	}
+	if cfg.Limits.Lorem.Words != 60 {
+		t.Errorf("lorem.words = %d, want env value 60", cfg.Limits.Lorem.Words)
+	}
+	if cfg.Limits.Lorem.Sentences != 7 {
+		t.Errorf("lorem.sentences = %d, want file value 7", cfg.Limits.Lorem.Sentences)
+	}
+	if cfg.Limits.Lorem.TitleWords != 3 {
+		t.Errorf("lorem.titleWords = %d, want flag value 3", cfg.Limits.Lorem.TitleWords)
+	}
+	if cfg.Limits.Lorem.Paragraphs != Default().Limits.Lorem.Paragraphs {
+		t.Errorf("lorem.paragraphs should keep its default, got %d", cfg.Limits.Lorem.Paragraphs)
+	}
+	if !reflect.DeepEqual(cfg.CORSOrigins, []string{"https://tools.internal"}) {
+		t.Errorf("corsOrigins = %v", cfg.CORSOrigins)
+	}
+}
+
+func TestLoadBodyLimitsAndTimeouts(t *testing.T) {
+	path := writeFile(t, "dl.yaml", `
+bodyLimits:
+  /api/json/diff: 10485760
+timeouts:
+  request: 5s
+`)
+	vars := map[string]string{
+		"DL_BODY_LIMITS":   "/api/json/format=2048, /api/pipeline=4096",
+		"DL_WRITE_TIMEOUT": "90s",
+		"DL_CONFIG":        path,
+	}
+	cfg, _, err := Load([]string{"--body-limit", "/api/pipeline=8192", "--read-header-timeout", "2s"}, env(vars))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	limits := map[string]int64{
+		"/api/json/diff":            10485760,
+		"/api/json/format":          2048,
+		"/api/pipeline":             8192,
+		"/api/lorem-ipsum/generate": 64 << 10,
+		"/api/string/trim":          cfg.MaxBodyBytes,
+	}
+	for path, want := range limits {
+		if got := cfg.BodyLimit(path); got != want {
+			t.Errorf("BodyLimit(%s) = %d, want %d", path, got, want)
+		}
+	}
+	want := Timeouts{
+		ReadHeader: Duration(2 * time.Second),
+		Read:       Default().Timeouts.Read,
+		Write:      Duration(90 * time.Second),
+		Idle:       Default().Timeouts.Idle,
+		Request:    Duration(5 * time.Second),
+		Shutdown:   Default().Timeouts.Shutdown,
+	}
+	if cfg.Timeouts != want {
+		t.Errorf("timeouts = %+v, want %+v", cfg.Timeouts, want)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "dl.toml", `
+addr = "127.0.0.1:8200"
+maxBodyBytes = 1024
+
+[limits.pipeline]
+steps = 5
+`)
+	cfg, _, err := Load([]string{"--config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Addr != "127.0.0.1:8200" || cfg.MaxBodyBytes != 1024 || cfg.Limits.Pipeline.Steps != 5 {
+		t.Errorf("got %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	unknown := writeFile(t, "bad.yaml", "adress: \":1\"\n")
+	badExt := writeFile(t, "dl.ini", "addr=:1\n")
+	cases := []struct {
+		name    string
+		args    []string
+		vars    map[string]string
+		wantErr string
+	}{
+		{"unknown file key", []string{"--config", unknown}, nil, "unknown field"},
+		{"bad extension", []string{"--config", badExt}, nil, "unsupported extension"},
+		{"missing file", []string{"--config", "/nonexistent/dl.yaml"}, nil, "no such file"},
+		{"bad addr", []string{"--addr", "nope"}, nil, "addr"},
+		{"bad log format", nil, map[string]string{"DL_LOG_FORMAT": "xml"}, "logFormat"},
+		{"bad log level", []string{"--log-level", "loud"}, nil, "logLevel"},
+		{"bad origin", []string{"--cors-origins", "example.com"}, nil, "corsOrigins"},
+		{"zero body size", []string{"--max-body-bytes", "0"}, nil, "maxBodyBytes"},
+		{"bad body size env", nil, map[string]string{"DL_MAX_BODY_BYTES": "lots"}, "DL_MAX_BODY_BYTES"},
+		{"unknown limit", []string{"--limit", "lorem.lines=3"}, nil, "unknown limit"},
+		{"limit not key=value", []string{"--limit", "lorem.words"}, nil, "key=value"},
+		{"negative limit", nil, map[string]string{"DL_LIMITS_PIPELINE_STEPS": "-1"}, "limits.pipeline.steps"},
+		{"unknown body limit route", []string{"--body-limit", "/api/nope=10"}, nil, "unknown route"},
+		{"body limit not path=bytes", []string{"--body-limit", "/api/pipeline"}, nil, "path=bytes"},
+		{"zero body limit", nil, map[string]string{"DL_BODY_LIMITS": "/api/pipeline=0"}, "must be positive"},
+		{"bad duration", []string{"--idle-timeout", "soon"}, nil, "--idle-timeout"},
+		{"zero timeout", nil, map[string]string{"DL_READ_TIMEOUT": "0s"}, "timeouts.read must be positive"},
+		{"request outlives write", []string{"--request-timeout", "2m"}, nil, "shorter than timeouts.write"},
+		{"positional argument", []string{"serve"}, nil, "unexpected argument"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := Load(tc.args, env(tc.vars))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	_, _, err := Load([]string{"-h"}, env(nil))
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("err = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestPrintRoundTrip(t *testing.T) {
+	cfg, printConfig, err := Load([]string{"--print-config", "--addr", ":9999"}, env(nil))
+	if err != nil || !printConfig {
+		t.Fatalf("Load: %v, printConfig=%v", err, printConfig)
+	}
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// The printed JSON is valid YAML and loads back to the same configuration.
+	path := writeFile(t, "printed.yaml", buf.String())
+	again, _, err := Load([]string{"--config", path}, env(nil))
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reflect.DeepEqual(again, cfg) {
+		t.Errorf("reloaded %+v, want %+v", again, cfg)
+	}
+}
+
+func TestLimitEnvName(t *testing.T) {
+	cases := map[string]string{
+		"lorem.words":      "DL_LIMITS_LOREM_WORDS",
+		"lorem.titleWords": "DL_LIMITS_LOREM_TITLE_WORDS",
+		"pipeline.steps":   "DL_LIMITS_PIPELINE_STEPS",
+	}
+	for key, want := range cases {
+		if got := limitEnvName(key); got != want {
+			t.Errorf("limitEnvName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -2,4 +2,8 @@ module digi-leatherman/backend
 
 go 1.24.0
 
-require golang.org/x/text v0.33.0 // indirect
+require (
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/text v0.33.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// This file implements the RFC 8785 JSON Canonicalization Scheme (JCS): the input is
+// minified with object members sorted by the UTF-16 code units of their keys, numbers
+// written the way ECMAScript prints an IEEE 754 double and strings escaped only where
+// JSON requires it. Two documents with the same data canonicalize to the same bytes.
+
+// CanonicalRequest is the JSON body for the canonicalize endpoint. Hash, when set, also
+// returns the digest of the canonical UTF-8 bytes, written in Encoding ("hex" when
+// empty).
+type CanonicalRequest struct {
+	Value    string `json:"value"`
+	Hash     string `json:"hash,omitempty" enum:"sha256,sha512"`
+	Encoding string `json:"encoding,omitempty" enum:"hex,base64"`
+}
+
+// CanonicalResponse is the JSON response for the canonicalize endpoint. Result is the
+// canonical JSON; Digest is only set when a hash was requested.
+type CanonicalResponse struct {
+	Result string `json:"result"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// resultText is the digest when there is one, so that a pipeline or the command line
+// asking for a hash gets the hash.
+func (r CanonicalResponse) resultText() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Result
+}
+
+// CanonicalizeJSON writes JSON in RFC 8785 canonical form and optionally hashes it.
+func CanonicalizeJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(canonicalizeJSON))
+}
+
+func canonicalizeJSON(req CanonicalRequest) (CanonicalResponse, error) {
+	var h hash.Hash
+	switch req.Hash {
+	case "":
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return CanonicalResponse{}, invalidOption("hash", fmt.Sprintf("unknown hash %q", req.Hash), enumTag(req, "Hash")...)
+	}
+	switch req.Encoding {
+	case "", "hex", "base64":
+	default:
+		return CanonicalResponse{}, invalidOption("encoding", fmt.Sprintf("unknown encoding %q", req.Encoding), enumTag(req, "Encoding")...)
+	}
+	n, err := scanJSONValue("value", req.Value)
+	if err != nil {
+		return CanonicalResponse{}, err
+	}
+	var out strings.Builder
+	if err := writeCanonical(&out, n, ""); err != nil {
+		return CanonicalResponse{}, err
+	}
+	resp := CanonicalResponse{Result: out.String()}
+	if h != nil {
+		h.Write([]byte(resp.Result))
+		if req.Encoding == "base64" {
+			resp.Digest = base64.StdEncoding.EncodeToString(h.Sum(nil))
+		} else {
+			resp.Digest = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return resp, nil
+}
+
+// writeCanonical writes n, found at pointer, in canonical form. Duplicate keys, lone
+// surrogates and numbers too large for a double have no canonical form and are
+// invalid_value errors with details.path.
+func writeCanonical(out *strings.Builder, n *fmtNode, pointer string) error {
+	if n.scalar() {
+		switch c := n.raw[0]; {
+		case c == '"':
+			s, err := canonicalString(n.raw, pointer)
+			if err != nil {
+				return err
+			}
+			writeCanonicalString(out, s)
+		case c == '-' || c >= '0' && c <= '9':
+			f, err := strconv.ParseFloat(n.raw, 64)
+			if err != nil || math.IsInf(f, 0) {
+				e := newError(CodeInvalidValue, "value", fmt.Sprintf("number %s is too large for canonical JSON", n.raw))
+				e.Details = map[string]interface{}{"path": pointer}
+				return e
+			}
+			out.WriteString(formatES(f))
+		default:
+			out.WriteString(n.raw)
+		}
+		return nil
+	}
+	if !n.object {
+		out.WriteByte('[')
+		for i, el := range n.elems {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := writeCanonical(out, el, pointerJoin(pointer, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+		return nil
+	}
+	keys := make([]string, len(n.keys))
+	units := make([][]uint16, len(n.keys))
+	idx := make([]int, len(n.keys))
+	for i, k := range n.keys {
+		var err error
+		if keys[i], err = canonicalString(k, pointer); err != nil {
+			return err
+		}
+		units[i] = utf16.Encode([]rune(keys[i]))
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return compareUTF16(units[idx[a]], units[idx[b]]) < 0 })
+	out.WriteByte('{')
+	for j, i := range idx {
+		if j > 0 {
+			if keys[idx[j-1]] == keys[i] {
+				e := newError(CodeInvalidValue, "value", fmt.Sprintf("duplicate key %q has no canonical form", keys[i]))
+				e.Details = map[string]interface{}{"path": pointerJoin(pointer, keys[i])}
+				return e
+			}
+			out.WriteByte(',')
+		}
+		writeCanonicalString(out, keys[i])
+		out.WriteByte(':')
+		if err := writeCanonical(out, n.elems[i], pointerJoin(pointer, keys[i])); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+// canonicalString decodes the string literal raw, found at pointer. A \u escape of half
+// a UTF-16 surrogate pair has no UTF-8 form, so rather than decoding it to U+FFFD as
+// encoding/json does, which would give a different document the same canonical bytes,
+// it is an invalid_value error.
+func canonicalString(raw, pointer string) (string, error) {
+	for i := 1; i < len(raw)-1; i++ {
+		if raw[i] != '\\' {
+			continue
+		}
+		i++
+		if raw[i] != 'u' {
+			continue
+		}
+		// The scanner only hands out valid string literals, so four hex digits follow.
+		r, _ := strconv.ParseUint(raw[i+1:i+5], 16, 32)
+		i += 4
+		if !utf16.IsSurrogate(rune(r)) {
+			continue
+		}
+		if r < 0xdc00 && strings.HasPrefix(raw[i+1:], `\u`) {
+			if r2, _ := strconv.ParseUint(raw[i+3:i+7], 16, 32); r2 >= 0xdc00 && r2 < 0xe000 {
+				i += 6
+				continue
+			}
+		}
+		e := newError(CodeInvalidValue, "value", fmt.Sprintf("the escape \\u%04x is half of a UTF-16 surrogate pair and has no canonical form", r))
+		e.Details = map[string]interface{}{"path": pointer}
+		return "", e
+	}
+	var s string
+	_ = json.Unmarshal([]byte(raw), &s)
+	return s, nil
+}
+
+// compareUTF16 orders strings by their UTF-16 code units, as RFC 8785 section 3.2.3
+// requires; this differs from byte order for characters above U+FFFF.
+func compareUTF16(a, b []uint16) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return int(a[i]) - int(b[i])
+		}
+	}
+	return len(a) - len(b)
+}
+
+// writeCanonicalString writes s as a JSON string, escaping only the quote, the backslash
+// and control characters (RFC 8785 section 3.2.2.2).
+func writeCanonicalString(out *strings.Builder, s string) {
+	const hexDigits = "0123456789abcdef"
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\b':
+			out.WriteString(`\b`)
+		case '\f':
+			out.WriteString(`\f`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				out.WriteString(`\u00`)
+				out.WriteByte(hexDigits[r>>4])
+				out.WriteByte(hexDigits[r&0xf])
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+}
+
+// formatES formats f like ECMAScript's Number.prototype.toString: the shortest digits
+// that round-trip, in plain notation for exponents from -7 to 20 and in exponential
+// notation (1e+21, 1.5e-7) otherwise. Negative zero is "0".
+func formatES(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	// strconv's shortest 'e' form is d.ddde±xx; split it into digits and the position n
+	// of the decimal point, so that f = 0.digits × 10^n.
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mant, exp, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mant, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	n, k := x+1, len(digits)
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+	s := sign + digits[:1]
+	if k > 1 {
+		s += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return s + "e+" + strconv.Itoa(n-1)
+	}
+	return s + "e" + strconv.Itoa(n-1)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCanonicalizeJSON(t *testing.T) {
+	cases := []struct {
+		name, value, want string
+	}{
+		// RFC 8785, section 3.2.4.
+		{"rfc example", `{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		// RFC 8785, section 3.2.3: UTF-16 order puts U+1F600 before U+FB33.
+		{"utf-16 key order", `{"\u20ac": 1, "\r": 2, "\ufb33": 3, "1": 4, "\ud83d\ude00": 5, "\u0080": 6, "\u00f6": 7}`,
+			"{\"\\r\":2,\"1\":4,\"\u0080\":6,\"ö\":7,\"€\":1,\"😀\":5,\"דּ\":3}"},
+		{"nested", ` [ {"b": [], "a": {"d": -0, "c": 1e-7}} ] `, `[{"a":{"c":1e-7,"d":0},"b":[]}]`},
+		{"big integer rounds to a double", `9007199254740993`, `9007199254740992`},
+		{"html is not escaped", `"<a&b>\u2028"`, "\"<a&b>\u2028\""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := canonicalizeJSON(CanonicalRequest{Value: tc.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result = %s, want %s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+// The number serialization samples from RFC 8785, appendix B.
+func TestFormatES(t *testing.T) {
+	cases := []struct {
+		bits uint64
+		want string
+	}{
+		{0x0000000000000000, "0"},
+		{0x8000000000000000, "0"},
+		{0x0000000000000001, "5e-324"},
+		{0x8000000000000001, "-5e-324"},
+		{0x7fefffffffffffff, "1.7976931348623157e+308"},
+		{0xffefffffffffffff, "-1.7976931348623157e+308"},
+		{0x4340000000000000, "9007199254740992"},
+		{0xc340000000000000, "-9007199254740992"},
+		{0x4430000000000000, "295147905179352830000"},
+		{0x44b52d02c7e14af5, "9.999999999999997e+22"},
+		{0x44b52d02c7e14af6, "1e+23"},
+		{0x44b52d02c7e14af7, "1.0000000000000001e+23"},
+		{0x444b1ae4d6e2ef4e, "999999999999999700000"},
+		{0x444b1ae4d6e2ef4f, "999999999999999900000"},
+		{0x444b1ae4d6e2ef50, "1e+21"},
+		{0x3eb0c6f7a0b5ed8c, "9.999999999999997e-7"},
+		{0x3eb0c6f7a0b5ed8d, "0.000001"},
+		{0x41b3de4355555553, "333333333.3333332"},
+		{0x41b3de4355555554, "333333333.33333325"},
+		{0x41b3de4355555555, "333333333.3333333"},
+		{0x41b3de4355555556, "333333333.3333334"},
+		{0x41b3de4355555557, "333333333.33333343"},
+		{0xbecbf647612f3696, "-0.0000033333333333333333"},
+		{0x43143ff3c1cb0959, "1424953923781206.2"},
+	}
+	for _, tc := range cases {
+		if got := formatES(math.Float64frombits(tc.bits)); got != tc.want {
+			t.Errorf("formatES(%#016x) = %s, want %s", tc.bits, got, tc.want)
+		}
+	}
+}
+
+func TestCanonicalizeJSONDigest(t *testing.T) {
+	sum := sha256.Sum256([]byte(`{"a":1,"b":2}`))
+	resp, err := canonicalizeJSON(CanonicalRequest{Value: `{"b": 2, "a": 1.0}`, Hash: "sha256", Encoding: "base64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := base64.StdEncoding.EncodeToString(sum[:]); resp.Digest != want {
+		t.Errorf("digest = %s, want %s", resp.Digest, want)
+	}
+	if text, _ := ResultText(resp); text != resp.Digest {
+		t.Errorf("result text = %s, want the digest", text)
+	}
+	resp, err = canonicalizeJSON(CanonicalRequest{Value: `{}`, Hash: "sha512"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Digest) != 128 {
+		t.Errorf("sha512 hex digest = %s, want 128 hex digits", resp.Digest)
+	}
+}
+
+func TestCanonicalizeJSONErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		req   CanonicalRequest
+		code  string
+		field string
+		path  string
+	}{
+		{"duplicate key", CanonicalRequest{Value: `{"a": {"b": 1, "\u0062": 2}}`}, CodeInvalidValue, "value", "/a/b"},
+		{"lone surrogate", CanonicalRequest{Value: `{"a": ["x", "\ud800"]}`}, CodeInvalidValue, "value", "/a/1"},
+		{"lone low surrogate in a key", CanonicalRequest{Value: `{"a": {"\udc00\ud800": 1}}`}, CodeInvalidValue, "value", "/a"},
+		{"number out of range", CanonicalRequest{Value: `[1, 1e400]`}, CodeInvalidValue, "value", "/1"},
+		{"syntax", CanonicalRequest{Value: `{`}, CodeInvalidJSON, "value", ""},
+		{"unknown hash", CanonicalRequest{Value: `1`, Hash: "md5"}, CodeInvalidOption, "hash", ""},
+		{"unknown encoding", CanonicalRequest{Value: `1`, Hash: "sha256", Encoding: "base32"}, CodeInvalidOption, "encoding", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := canonicalizeJSON(tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.code || ae.Field != tc.field {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.code, tc.field)
+			}
+			if tc.path != "" && ae.Details["path"] != tc.path {
+				t.Errorf("details.path = %v, want %s", ae.Details["path"], tc.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// Error codes sent in APIError.Code. Clients should match on these rather than on messages.
+const (
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeBodyTooLarge     = "body_too_large"
+	CodeInvalidRequest   = "invalid_request"    // body is not a JSON object of the expected shape
+	CodeInvalidJSON      = "invalid_json"       // a JSON-text field (e.g. value) does not parse
+	CodeInvalidYAML      = "invalid_yaml"       // a YAML-text field does not parse or has no JSON equivalent
+	CodeInvalidValue     = "invalid_value"      // a field has a value the tool cannot process
+	CodeInvalidOption    = "invalid_option"     // an enumerated option has an unknown value
+	CodeOutOfRange       = "count_out_of_range" // a numeric field is outside [min, max]
+	CodeTooMany          = "too_many_items"     // a list field exceeds its maximum length
+	CodeRequired         = "required"           // a field is empty but must be set
+	CodePathNotFound     = "path_not_found"
+	CodeInvalidQuery     = "invalid_query" // a path or filter expression does not compile
+	CodeTimeout          = "timeout"       // the request ran past its deadline or was canceled
+	CodeTestFailed       = "test_failed"   // a JSON Patch test operation did not match (409)
+	CodeInternal         = "internal_error"
+)
+
+// APIError is a client-facing error with a machine-readable code. It is written as
+// {"error": {...}} with HTTP status Status (400 when zero).
+type APIError struct {
+	Status  int                    `json:"-"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Field   string                 `json:"field,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+// ErrorResponse is the JSON body of every non-2xx API response.
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// newError returns a 400 APIError for field with the given code and message.
+func newError(code, field, message string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: code, Field: field, Message: message}
+}
+
+// invalidOption reports an enumerated field set to an unsupported value.
+func invalidOption(field, message string, allowed ...string) *APIError {
+	e := newError(CodeInvalidOption, field, message)
+	e.Details = map[string]interface{}{"allowed": allowed}
+	return e
+}
+
+// outOfRange reports a count outside [min, max].
+func outOfRange(field string, min, max int) *APIError {
+	e := newError(CodeOutOfRange, field, fmt.Sprintf("%s out of range", field))
+	e.Details = map[string]interface{}{"min": min, "max": max}
+	return e
+}
+
+// invalidJSON reports that field does not contain valid JSON, with the line, column and
+// byte offset of the problem when the decoder provides one.
+func invalidJSON(field string, data []byte, err error) *APIError {
+	msg := fmt.Sprintf("invalid JSON: %v", err)
+	if field != "value" && field != "" {
+		msg = fmt.Sprintf("invalid JSON in %s: %v", field, err)
+	}
+	e := newError(CodeInvalidJSON, field, msg)
+	// offset is the 0-based byte index of the problem: the byte the decoder rejected, or
+	// the end of input when it ran out of data.
+	var offset int64 = -1
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		// Offset counts the rejected byte, except at end of input.
+		offset = syn.Offset
+		if offset > 0 && syn.Error() != "unexpected end of JSON input" {
+			offset--
+		}
+	case errors.As(err, &typ):
+		offset = typ.Offset
+	}
+	if offset >= 0 {
+		line, col := lineColumn(data, int(offset))
+		e.Details = map[string]interface{}{"offset": offset, "line": line, "column": col}
+	}
+	return e
+}
+
+// invalidYAML reports a problem with the YAML text in field at a 1-based line and column
+// (column 0 when it is not known).
+func invalidYAML(field string, line, column int, message string) *APIError {
+	e := newError(CodeInvalidYAML, field, fmt.Sprintf("invalid YAML: line %d: %s", line, message))
+	e.Details = map[string]interface{}{"line": line}
+	if column > 0 {
+		e.Details["column"] = column
+	}
+	return e
+}
+
+// invalidRequest reports a request body that is not JSON or does not match the request type.
+func invalidRequest(body []byte, err error) *APIError {
+	e := invalidJSON("", body, err)
+	e.Code = CodeInvalidRequest
+	e.Message = "invalid JSON"
+	var typ *json.UnmarshalTypeError
+	if errors.As(err, &typ) {
+		e.Field = typ.Field
+		what := typ.Field
+		if what == "" {
+			what = "the request body"
+		}
+		e.Message = fmt.Sprintf("invalid JSON: %s must be %s", what, jsonTypeName(typ.Type))
+	}
+	return e
+}
+
+// jsonTypeName names the JSON type that decodes into t, with its article ("an object"),
+// so that errors speak of the request's JSON rather than of the Go types behind it.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "a string" // []byte decodes from base64
+		}
+		return "an array"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a JSON value"
+}
+
+// lineColumn converts a byte offset in data to a 1-based line and column (in runes).
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line, col := 1, 1
+	for _, r := range string(data[:offset]) {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// querySyntaxError reports a query (e.g. a JSONPath) in field that does not compile, with
+// the line, column and 0-based byte offset of the problem in src.
+func querySyntaxError(field, src string, err error) *APIError {
+	e := newError(CodeInvalidQuery, field, fmt.Sprintf("invalid %s: %v", field, err))
+	if syn, ok := err.(*syntaxError); ok {
+		line, col := lineColumn([]byte(src), syn.offset)
+		e.Details = map[string]interface{}{"offset": syn.offset, "line": line, "column": col}
+	}
+	return e
+}
+
+// IsInputError reports whether err was caused by invalid tool input rather than a server fault.
+func IsInputError(err error) bool {
+	var ae *APIError
+	return errors.As(err, &ae) && (ae.Status == 0 || ae.Status < http.StatusInternalServerError)
+}
+
+// asAPIError returns err as an APIError; errors that are not APIErrors become 500s.
+func asAPIError(err error) *APIError {
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		ae = &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: err.Error()}
+	}
+	return ae
+}
+
+// writeError writes err as an ErrorResponse. Errors that are not APIErrors become 500s.
+func writeError(w http.ResponseWriter, err error) {
+	ae := asAPIError(err)
+	status := ae.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Error: ae})
+}
+
+// bodyTooLarge reports a request body over the configured size limit.
+func bodyTooLarge(limit int64) *APIError {
+	return &APIError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    CodeBodyTooLarge,
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+		Details: map[string]interface{}{"max": limit},
+	}
+}
+
+// BodyTooLarge writes the 413 error for a request body over limit bytes.
+func BodyTooLarge(w http.ResponseWriter, limit int64) {
+	writeError(w, bodyTooLarge(limit))
+}
+
+// checkContext returns a 503 timeout error once ctx is done. Transforms whose cost grows
+// with the input (e.g. the JSON diff) call it as they go so a deadline stops the work.
+func checkContext(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	msg := "request took longer than the server allows"
+	if errors.Is(err, context.Canceled) {
+		msg = "request canceled"
+	}
+	return &APIError{Status: http.StatusServiceUnavailable, Code: CodeTimeout, Message: msg}
+}
+
+// methodNotAllowed writes a 405 error listing the allowed method.
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, &APIError{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    CodeMethodNotAllowed,
+		Message: "method not allowed",
+		Details: map[string]interface{}{"allowed": []string{allowed}},
+	})
+}
+
+// NotFound writes a 404 error for API paths that match no route, so clients of the
+// embedded UI server get the JSON envelope instead of the single-page app.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &APIError{
+		Status:  http.StatusNotFound,
+		Code:    CodeNotFound,
+		Message: fmt.Sprintf("no API route for %s", r.URL.Path),
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseErrorResponse(t *testing.T, body string) *APIError {
+	t.Helper()
+	var res ErrorResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatalf("parseErrorResponse: %v (body %s)", err, body)
+	}
+	if res.Error == nil {
+		t.Fatalf("parseErrorResponse: no error in %s", body)
+	}
+	return res.Error
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	cases := []struct {
+		name        string
+		handler     http.HandlerFunc
+		method      string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantField   string
+		wantDetails map[string]interface{}
+	}{
+		{"not found", NotFound, "GET", "", http.StatusNotFound, CodeNotFound, "", nil},
+		{"method not allowed", Trim, "GET", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed, "", map[string]interface{}{"allowed": []interface{}{"POST"}}},
+		{"body not JSON", Trim, "POST", "{", http.StatusBadRequest, CodeInvalidRequest, "", nil},
+		{"body wrong type", LoremIpsum, "POST", `{"tool":"title","count":"3"}`, http.StatusBadRequest, CodeInvalidRequest, "count", nil},
+		{"base64", Base64Decode, "POST", `{"value":"!!"}`, http.StatusBadRequest, CodeInvalidValue, "value", nil},
+		{"base URL required", CreateURLWithParams, "POST", `{"value":""}`, http.StatusBadRequest, CodeRequired, "value", nil},
+		{"unknown alphabet", SpellOut, "POST", `{"value":"a","alphabet":"lapd"}`, http.StatusBadRequest, CodeInvalidOption, "alphabet", map[string]interface{}{"allowed": []interface{}{"nato"}}},
+		{"count out of range", LoremIpsum, "POST", `{"type":"words","count":1001}`, http.StatusBadRequest, CodeOutOfRange, "count", map[string]interface{}{"min": 1.0, "max": 1000.0}},
+		{"title out of range", LoremIpsum, "POST", `{"tool":"title","count":0}`, http.StatusBadRequest, CodeOutOfRange, "count", map[string]interface{}{"min": 1.0, "max": 20.0}},
+		{"too many keys", LoremIpsum, "POST", `{"tool":"json","count":1,"options":{"keys":["a","b","c","d","e","f","g","h","i","j","k"]}}`, http.StatusBadRequest, CodeTooMany, "options.keys", map[string]interface{}{"max": 10.0}},
+		{"invalid lorem type", LoremIpsum, "POST", `{"type":"lines","count":5}`, http.StatusBadRequest, CodeInvalidOption, "type", nil},
+		{"path not found", PathQueryJSON, "POST", `{"value":"{}","path":"a"}`, http.StatusBadRequest, CodePathNotFound, "path", nil},
+		{"json syntax", FormatJSON, "POST", `{"value":"{\n  \"a\": x\n}"}`, http.StatusBadRequest, CodeInvalidJSON, "value", map[string]interface{}{"line": 2.0, "column": 8.0, "offset": 9.0}},
+		{"json truncated", MinifyJSON, "POST", `{"value":"[1,"}`, http.StatusBadRequest, CodeInvalidJSON, "value", map[string]interface{}{"line": 1.0, "column": 4.0, "offset": 3.0}},
+		{"diff valueB", DiffJSON, "POST", `{"valueA":"1","valueB":"nope"}`, http.StatusBadRequest, CodeInvalidJSON, "valueB", map[string]interface{}{"line": 1.0, "column": 2.0, "offset": 1.0}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := runHandler(t, tc.handler, tc.method, tc.body)
+			if status != tc.wantStatus {
+				t.Errorf("status = %d, want %d; body: %s", status, tc.wantStatus, body)
+			}
+			e := parseErrorResponse(t, body)
+			if e.Code != tc.wantCode {
+				t.Errorf("code = %q, want %q", e.Code, tc.wantCode)
+			}
+			if e.Field != tc.wantField {
+				t.Errorf("field = %q, want %q", e.Field, tc.wantField)
+			}
+			if e.Message == "" {
+				t.Errorf("message should not be empty")
+			}
+			if tc.wantDetails != nil && !reflect.DeepEqual(e.Details, tc.wantDetails) {
+				t.Errorf("details = %v, want %v", e.Details, tc.wantDetails)
+			}
+		})
+	}
+}
+
+func TestInvalidRequestMessage(t *testing.T) {
+	cases := []struct {
+		body  string
+		field string
+		want  string
+	}{
+		{`{"tool":"title","count":"3"}`, "count", "invalid JSON: count must be an integer"},
+		{`{"count":1.5}`, "count", "invalid JSON: count must be an integer"},
+		{`{"count":1,"options":[]}`, "options", "invalid JSON: options must be an object"},
+		{`{"count":1,"options":{"keys":"a"}}`, "options.keys", "invalid JSON: options.keys must be an array"},
+		{`{"count":1,"options":{"startWithClassic":"yes"}}`, "options.startWithClassic", "invalid JSON: options.startWithClassic must be a boolean"},
+		{`{"tool":1}`, "tool", "invalid JSON: tool must be a string"},
+		{`[1]`, "", "invalid JSON: the request body must be an object"},
+	}
+	for _, tc := range cases {
+		var req LoremRequest
+		err := json.Unmarshal([]byte(tc.body), &req)
+		if err == nil {
+			t.Fatalf("%s: expected a decode error", tc.body)
+		}
+		e := invalidRequest([]byte(tc.body), err)
+		if e.Field != tc.field || e.Message != tc.want {
+			t.Errorf("%s: field %q, message %q; want %q, %q", tc.body, e.Field, e.Message, tc.field, tc.want)
+		}
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://test", strings.NewReader(`{"value":"  too long  "}`))
+	rec := httptest.NewRecorder()
+	req.Body = http.MaxBytesReader(rec, req.Body, 8)
+	Trim(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	e := parseErrorResponse(t, rec.Body.String())
+	if e.Code != CodeBodyTooLarge || !reflect.DeepEqual(e.Details, map[string]interface{}{"max": 8.0}) {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestLineColumn(t *testing.T) {
+	data := []byte("ab\ncdé\nf")
+	cases := []struct {
+		offset, line, col int
+	}{
+		{0, 1, 1},
+		{2, 1, 3},
+		{3, 2, 1},
+		{7, 2, 4},
+		{9, 3, 2},
+		{100, 3, 2},
+	}
+	for _, tc := range cases {
+		line, col := lineColumn(data, tc.offset)
+		if line != tc.line || col != tc.col {
+			t.Errorf("lineColumn(%d) = %d:%d, want %d:%d", tc.offset, line, col, tc.line, tc.col)
+		}
+	}
+}
+
+func TestIsInputError(t *testing.T) {
+	if !IsInputError(newError(CodeInvalidValue, "value", "bad")) {
+		t.Errorf("400 APIError should be an input error")
+	}
+	if IsInputError(&APIError{Status: http.StatusInternalServerError, Code: CodeInternal}) {
+		t.Errorf("500 APIError should not be an input error")
+	}
+	if IsInputError(errors.New("boom")) {
+		t.Errorf("plain error should not be an input error")
+	}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"go/token"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// This file generates Go type declarations from sample JSON. The samples are folded into
+// the same inferNode tree as schema inference (jsoninfer.go); every object location then
+// becomes a named struct.
+
+// GoStructRequest is the JSON body for the Go struct endpoint. Value holds the sample
+// documents, read as for InferSchemaRequest. Name is the root type (default "Root") and
+// Package the package clause of the generated file (default "main").
+type GoStructRequest struct {
+	Value    string `json:"value"`
+	Name     string `json:"name,omitempty"`
+	Package  string `json:"package,omitempty"`
+	EachItem bool   `json:"eachItem,omitempty"`
+}
+
+// GoStructJSON generates Go struct declarations from one or more sample documents.
+func GoStructJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(goStructJSON))
+}
+
+func goStructJSON(ctx context.Context, req GoStructRequest) (StringResponse, error) {
+	name := "Root"
+	if strings.TrimSpace(req.Name) != "" {
+		if name = goName(req.Name); name == "" || !token.IsIdentifier(name) {
+			return StringResponse{}, newError(CodeInvalidValue, "name", fmt.Sprintf("cannot make a Go type name from %q", req.Name))
+		}
+	}
+	pkg := "main"
+	if req.Package != "" {
+		if pkg = req.Package; !token.IsIdentifier(pkg) || pkg == "_" {
+			return StringResponse{}, newError(CodeInvalidValue, "package", fmt.Sprintf("%q is not a valid Go package name", req.Package))
+		}
+	}
+	inf := &inferrer{ctx: ctx, enumMax: -1}
+	root, err := inf.samples(req.Value, req.EachItem)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	g := &goGen{names: make(typeNames)}
+	var body bytes.Buffer
+	if goKind(root) != "object" {
+		g.names[name] = true
+		fmt.Fprintf(&body, "type %s %s\n\n", name, g.typeOf(root, name, ""))
+	} else {
+		g.declare(root, name, "")
+	}
+	for i := 0; i < len(g.decls); i++ {
+		g.writeStruct(&body, g.decls[i])
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n\n", pkg)
+	if g.usesTime {
+		src.WriteString("import \"time\"\n\n")
+	}
+	src.Write(body.Bytes())
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return StringResponse{}, fmt.Errorf("generated Go does not parse: %w", err)
+	}
+	return StringResponse{Result: string(out)}, nil
+}
+
+// goInitialisms are the words Go spells in all caps in identifiers.
+var goInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"LHS": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true, "SLA": true, "SMTP": true,
+	"SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"UID": true, "UUID": true, "URI": true, "URL": true, "UTF8": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// goName converts a JSON key to an exported Go identifier: the words of the key (split
+// at punctuation and camelCase humps) in PascalCase, with initialisms such as ID and URL
+// in capitals. It returns "" when the key has no letters or digits.
+func goName(key string) string {
+	words := titleWords(wordsFrom(splitCamel(key)))
+	for i, w := range words {
+		if goInitialisms[strings.ToUpper(w)] {
+			words[i] = strings.ToUpper(w)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// singularName guesses the element type name for an array type name: "Items" gives "Item"
+// and "Categories" "Category"; names that do not look plural get an "Item" suffix.
+func singularName(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses") || strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") &&
+		!strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+// goKind returns the one JSON type a location holds, ignoring null and treating integers
+// mixed with other numbers as "number"; it returns "" for no type and "mixed" for several.
+func goKind(n *inferNode) string {
+	kind := ""
+	for _, t := range inferTypes {
+		if !n.types[t] || t == "null" || (t == "integer" && n.types["number"]) {
+			continue
+		}
+		if kind != "" {
+			return "mixed"
+		}
+		kind = t
+	}
+	return kind
+}
+
+// goDecl is a struct type to be written.
+type goDecl struct {
+	name string
+	node *inferNode
+}
+
+// typeNames hands out the type names of one generated file.
+type typeNames map[string]bool
+
+// claim returns a free type name for hint: hint itself, else parent+hint, else hint
+// with a number.
+func (t typeNames) claim(hint, parent string) string {
+	if hint == "" {
+		hint = "Object"
+	}
+	name := hint
+	if t[name] && parent != "" {
+		name = parent + hint
+	}
+	for i := 2; t[name]; i++ {
+		name = hint + strconv.Itoa(i)
+	}
+	t[name] = true
+	return name
+}
+
+// goGen collects the struct declarations of one generated file.
+type goGen struct {
+	decls    []goDecl
+	names    typeNames
+	usesTime bool
+}
+
+// declare queues a struct for the object location n and returns its type name.
+func (g *goGen) declare(n *inferNode, hint, parent string) string {
+	name := g.names.claim(hint, parent)
+	g.decls = append(g.decls, goDecl{name: name, node: n})
+	return name
+}
+
+// typeOf returns the Go type for the values at n, declaring structs for objects. hint
+// names a struct and parent is the enclosing struct.
+func (g *goGen) typeOf(n *inferNode, hint, parent string) string {
+	switch goKind(n) {
+	case "object":
+		return g.declare(n, hint, parent)
+	case "array":
+		if n.items == nil || n.items.count == 0 {
+			return "[]any"
+		}
+		elem := g.typeOf(n.items, singularName(hint), parent)
+		if n.items.types["null"] && goPointable(elem) {
+			elem = "*" + elem
+		}
+		return "[]" + elem
+	case "string":
+		if len(n.formats) > 0 && n.formats[0] == "date-time" {
+			g.usesTime = true
+			return "time.Time"
+		}
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	}
+	return "any"
+}
+
+// jsonTagName reports whether encoding/json accepts key as the name in a json tag: it
+// must be non-empty and hold only letters, digits, spaces and some punctuation (no
+// quotes, backslashes or commas).
+func jsonTagName(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// goPointable reports whether a field of type t needs a pointer to tell a missing or
+// null value from the zero value; slices and any already have nil.
+func goPointable(t string) bool {
+	return t != "any" && !strings.HasPrefix(t, "[]")
+}
+
+func (g *goGen) writeStruct(b *bytes.Buffer, d goDecl) {
+	fmt.Fprintf(b, "type %s struct {\n", d.name)
+	fields := make(map[string]bool)
+	for _, key := range d.node.propOrder {
+		p := d.node.props[key]
+		field := goName(key)
+		switch {
+		case field == "":
+			field = "Field"
+		case field[0] >= '0' && field[0] <= '9':
+			field = "Field" + field
+		}
+		base := field
+		for i := 2; fields[field]; i++ {
+			field = base + strconv.Itoa(i)
+		}
+		fields[field] = true
+
+		optional := p.count < d.node.objects
+		typ := g.typeOf(p, field, d.name)
+		if (optional || p.types["null"]) && goPointable(typ) {
+			typ = "*" + typ
+		}
+		tag := key
+		if !jsonTagName(key) {
+			// encoding/json would fall back to the field name, so the field would read
+			// and write a different key; skip it instead and say so.
+			fmt.Fprintf(b, "\t// The key %s cannot be named in a json tag; decode it by hand.\n", strconv.Quote(key))
+			tag = "-"
+		} else if optional {
+			tag += ",omitempty"
+		}
+		tag = "json:" + strconv.Quote(tag)
+		if strings.Contains(tag, "`") {
+			tag = strconv.Quote(tag)
+		} else {
+			tag = "`" + tag + "`"
+		}
+		fmt.Fprintf(b, "\t%s %s %s\n", field, typ, tag)
+	}
+	b.WriteString("}\n\n")
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+)
+
+func TestGoName(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"id", "ID"},
+		{"user_id", "UserID"},
+		{"userId", "UserID"},
+		{"avatarURL", "AvatarURL"},
+		{"HTTPServer", "HTTPServer"},
+		{"http-status-code", "HTTPStatusCode"},
+		{"created_at", "CreatedAt"},
+		{"uuid", "UUID"},
+		{"apiKeys", "APIKeys"},
+		{"name", "Name"},
+		{"$", ""},
+	}
+	for _, tc := range cases {
+		if got := goName(tc.in); got != tc.want {
+			t.Errorf("goName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestGoStructJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		req  GoStructRequest
+		want string
+	}{
+		{"merged samples", GoStructRequest{Value: `
+{"id": 1, "userName": "ann", "avatarURL": "https://example.com/a.png", "createdAt": "2024-01-02T03:04:05Z", "score": 2, "tags": ["a"], "address": {"zip": "1000"}}
+{"id": 2, "userName": "bob", "createdAt": "2024-01-03T03:04:05Z", "score": 2.5, "tags": [], "address": null}`}, `package main
+
+import "time"
+
+type Root struct {
+	ID        int64     ` + "`json:\"id\"`" + `
+	UserName  string    ` + "`json:\"userName\"`" + `
+	AvatarURL *string   ` + "`json:\"avatarURL,omitempty\"`" + `
+	CreatedAt time.Time ` + "`json:\"createdAt\"`" + `
+	Score     float64   ` + "`json:\"score\"`" + `
+	Tags      []string  ` + "`json:\"tags\"`" + `
+	Address   *Address  ` + "`json:\"address\"`" + `
+}
+
+type Address struct {
+	Zip string ` + "`json:\"zip\"`" + `
+}
+`},
+		{"array elements", GoStructRequest{Value: `{"items": [{"sku": "a", "qty": 1}, {"sku": "b", "note": "gift"}], "mixed": [1, "x"], "empty": []}`, Name: "order", Package: "shop"}, `package shop
+
+type Order struct {
+	Items []Item ` + "`json:\"items\"`" + `
+	Mixed []any  ` + "`json:\"mixed\"`" + `
+	Empty []any  ` + "`json:\"empty\"`" + `
+}
+
+type Item struct {
+	Sku  string  ` + "`json:\"sku\"`" + `
+	Qty  *int64  ` + "`json:\"qty,omitempty\"`" + `
+	Note *string ` + "`json:\"note,omitempty\"`" + `
+}
+`},
+		{"top-level array", GoStructRequest{Value: `[{"a": true}]`, Name: "categories"}, `package main
+
+type Categories []Category
+
+type Category struct {
+	A bool ` + "`json:\"a\"`" + `
+}
+`},
+		{"name clashes", GoStructRequest{Value: `{"data": {"data": {"x": 1}}, "user-id": 1, "user_id": 2, "1st": "a"}`}, `package main
+
+type Root struct {
+	Data     Data   ` + "`json:\"data\"`" + `
+	UserID   int64  ` + "`json:\"user-id\"`" + `
+	UserID2  int64  ` + "`json:\"user_id\"`" + `
+	Field1St string ` + "`json:\"1st\"`" + `
+}
+
+type Data struct {
+	Data DataData ` + "`json:\"data\"`" + `
+}
+
+type DataData struct {
+	X int64 ` + "`json:\"x\"`" + `
+}
+`},
+		{"keys a json tag cannot name", GoStructRequest{Value: `{"": 1, "a,b": "x", "it's": true, "ok": 2}`}, `package main
+
+type Root struct {
+	// The key "" cannot be named in a json tag; decode it by hand.
+	Field int64 ` + "`json:\"-\"`" + `
+	// The key "a,b" cannot be named in a json tag; decode it by hand.
+	AB string ` + "`json:\"-\"`" + `
+	// The key "it's" cannot be named in a json tag; decode it by hand.
+	ItS bool  ` + "`json:\"-\"`" + `
+	Ok  int64 ` + "`json:\"ok\"`" + `
+}
+`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := goStructJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+			typeCheckGo(t, resp.Result)
+		})
+	}
+}
+
+// typeCheckGo fails the test when src is not a valid Go file.
+func typeCheckGo(t *testing.T, src string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("gen", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("type check: %v", err)
+	}
+}
+
+func TestGoStructJSONErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		req   GoStructRequest
+		code  string
+		field string
+	}{
+		{"no samples", GoStructRequest{Value: ""}, CodeRequired, "value"},
+		{"bad json", GoStructRequest{Value: `{`}, CodeInvalidJSON, "value"},
+		{"bad name", GoStructRequest{Value: `{}`, Name: "9lives"}, CodeInvalidValue, "name"},
+		{"bad package", GoStructRequest{Value: `{}`, Package: "my-pkg"}, CodeInvalidValue, "package"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := goStructJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.code || ae.Field != tc.field {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.code, tc.field)
+			}
+		})
+	}
+}
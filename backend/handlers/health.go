@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"digi-leatherman/backend/version"
+)
+
+// ready is false until the server starts listening and again once shutdown begins, so
+// load balancers stop routing new requests while in-flight ones drain.
+var ready atomic.Bool
+
+// SetReady marks the server as accepting (true) or refusing (false) new work.
+func SetReady(v bool) {
+	ready.Store(v)
+}
+
+// HealthResponse is the JSON response for the health and readiness probes.
+type HealthResponse struct {
+	Status string `json:"status" enum:"ok,ready,not_ready"`
+}
+
+// VersionResponse is the JSON response for the version endpoint.
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Healthz handles GET /healthz. Liveness: 200 as long as the process can serve HTTP.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, HealthResponse{Status: "ok"})
+}
+
+// Readyz handles GET /readyz. Readiness: 200 while serving, 503 before startup completes
+// and during a graceful shutdown.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if !ready.Load() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeJSON(w, HealthResponse{Status: "not_ready"})
+		return
+	}
+	writeJSON(w, HealthResponse{Status: "ready"})
+}
+
+// Version handles GET /api/version. Returns the build version and commit (set with
+// -ldflags at build time) and the Go version the binary was built with.
+func Version(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	info := version.Get()
+	writeJSON(w, VersionResponse{Version: info.Version, Commit: info.Commit, GoVersion: info.GoVersion})
+}
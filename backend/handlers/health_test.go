@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"digi-leatherman/backend/version"
+)
+
+func TestHealthz(t *testing.T) {
+	status, body := runHandler(t, Healthz, "GET", "")
+	if status != http.StatusOK || body != `{"status":"ok"}` {
+		t.Errorf("got %d %s", status, body)
+	}
+	if status, _ := runHandler(t, Healthz, "POST", "{}"); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	defer SetReady(false)
+	cases := []struct {
+		ready      bool
+		wantStatus int
+		wantBody   string
+	}{
+		{false, http.StatusServiceUnavailable, `{"status":"not_ready"}`},
+		{true, http.StatusOK, `{"status":"ready"}`},
+		{false, http.StatusServiceUnavailable, `{"status":"not_ready"}`},
+	}
+	for _, tc := range cases {
+		SetReady(tc.ready)
+		status, body := runHandler(t, Readyz, "GET", "")
+		if status != tc.wantStatus || body != tc.wantBody {
+			t.Errorf("ready=%v: got %d %s, want %d %s", tc.ready, status, body, tc.wantStatus, tc.wantBody)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	defer func(v, c string) { version.Version, version.Commit = v, c }(version.Version, version.Commit)
+	version.Version, version.Commit = "1.4.0", "0123abc"
+	status, body := runHandler(t, Version, "GET", "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	var got VersionResponse
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := VersionResponse{Version: "1.4.0", Commit: "0123abc", GoVersion: runtime.Version()}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
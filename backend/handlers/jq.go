@@ -0,0 +1,559 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements the jq endpoint: request handling, the JSON values jq works on
+// (objects keep their key order), decoding inputs and encoding outputs. The filter
+// language is parsed in jqparse.go, evaluated in jqeval.go and its builtins are in
+// jqbuiltins.go.
+
+// JQRequest is the JSON body for the jq endpoint. Value holds zero or more JSON texts,
+// each run through Filter in turn. The options match the jq command-line flags: Slurp
+// (-s) reads every input into one array, NullInput (-n) runs the filter once with null
+// as input (input and inputs still read Value), Raw (-r) writes string results without
+// quotes and Compact (-c) writes each result on one line.
+type JQRequest struct {
+	Value     string `json:"value"`
+	Filter    string `json:"filter"`
+	Slurp     bool   `json:"slurp,omitempty"`
+	NullInput bool   `json:"nullInput,omitempty"`
+	Raw       bool   `json:"raw,omitempty"`
+	Compact   bool   `json:"compact,omitempty"`
+}
+
+// JQResponse is the JSON response for the jq endpoint. Result is the filter's output as
+// jq prints it, one result after another; Outputs holds each result as JSON.
+type JQResponse struct {
+	Result  string            `json:"result"`
+	Outputs []json.RawMessage `json:"outputs"`
+}
+
+func (r JQResponse) resultText() string { return r.Result }
+
+// JQOutput is one line of the streamed (application/x-ndjson) jq response. Each result
+// is sent as {"output": ...} as soon as it is produced; a failure after the first line
+// is sent as a final {"error": {...}} line.
+type JQOutput struct {
+	Output json.RawMessage `json:"output"`
+}
+
+// JQJSON runs a jq filter over one or more JSON values. Clients that accept
+// application/x-ndjson get each result as it is produced.
+func JQJSON(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), ndjsonType) {
+		serveStream(w, r, streamJQ)
+		return
+	}
+	serve(w, r, typedContextTransform(jqJSON))
+}
+
+func jqJSON(ctx context.Context, req JQRequest) (JQResponse, error) {
+	resp := JQResponse{Outputs: []json.RawMessage{}}
+	var text []string
+	err := runJQ(ctx, req, func(out json.RawMessage, printed string) error {
+		resp.Outputs = append(resp.Outputs, out)
+		text = append(text, printed)
+		return nil
+	})
+	if err != nil {
+		return JQResponse{}, err
+	}
+	resp.Result = strings.Join(text, "\n")
+	return resp, nil
+}
+
+// streamJQ is the StreamTransform of the jq tool.
+func streamJQ(ctx context.Context, body json.RawMessage, emit func(interface{}) error) error {
+	var req JQRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return invalidRequest(body, err)
+	}
+	return runJQ(ctx, req, func(out json.RawMessage, _ string) error {
+		return emit(JQOutput{Output: out})
+	})
+}
+
+// runJQ compiles req.Filter and runs it over each input, passing every result to emit
+// as compact JSON and as jq would print it with req's output options.
+func runJQ(ctx context.Context, req JQRequest, emit func(out json.RawMessage, printed string) error) error {
+	filter := req.Filter
+	if strings.TrimSpace(filter) == "" {
+		filter = "."
+	}
+	prog, err := parseJQ(filter)
+	if err != nil {
+		return querySyntaxError("filter", req.Filter, err)
+	}
+	inputs, err := decodeJQInputs("value", req.Value)
+	if err != nil {
+		return err
+	}
+	if req.Slurp {
+		inputs = []interface{}{inputs}
+	}
+	ev := newJQEval(ctx, limits.JSON.JQSteps, limits.JSON.JQValueSize)
+	ev.inputs = inputs
+	indent := "  "
+	if req.Compact {
+		indent = ""
+	}
+	output := func(v jqVal) error {
+		out, err := ev.encode(v.v, "")
+		if err != nil {
+			return err
+		}
+		printed := string(out)
+		if s, ok := v.v.(string); ok && req.Raw {
+			printed = s
+		} else if indent != "" {
+			if printed, err = ev.encodeString(v.v, indent); err != nil {
+				return err
+			}
+		}
+		return emit(out, printed)
+	}
+	run := func(in interface{}) error {
+		err := ev.eval(prog, jqVal{v: in}, nil, output)
+		return ev.apiError(err)
+	}
+	if req.NullInput {
+		return run(nil)
+	}
+	for len(ev.inputs) > 0 {
+		in := ev.inputs[0]
+		ev.inputs = ev.inputs[1:]
+		if err := run(in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apiError converts an error from eval to the error the endpoint returns.
+func (ev *jqEval) apiError(err error) error {
+	var je *jqError
+	if errors.As(err, &je) {
+		e := newError(CodeInvalidValue, "filter", je.message())
+		if _, ok := je.value.(string); !ok && je.value != nil {
+			if out, err := ev.encode(je.value, ""); err == nil {
+				e.Details = map[string]interface{}{"error": json.RawMessage(out)}
+			}
+		}
+		return e
+	}
+	return err
+}
+
+// decodeJQInputs parses a sequence of JSON texts separated by optional whitespace.
+func decodeJQInputs(field, s string) ([]interface{}, error) {
+	inputs := []interface{}{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	for {
+		start := dec.InputOffset()
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return inputs, nil
+		}
+		if err != nil {
+			var syn *json.SyntaxError
+			if !errors.As(err, &syn) {
+				// A truncated value: let Unmarshal report where it ends.
+				var v interface{}
+				err = json.Unmarshal([]byte(s[start:]), &v)
+				if errors.As(err, &syn) {
+					syn.Offset += start
+				}
+			}
+			return nil, invalidJSON(field, []byte(s), err)
+		}
+		v, err := jqDecode(json.NewDecoder(bytes.NewReader(raw)))
+		if err != nil {
+			return nil, invalidJSON(field, []byte(s), err)
+		}
+		inputs = append(inputs, v)
+	}
+}
+
+// jqDecode reads one (already validated) JSON value from dec, keeping the order of
+// object keys.
+func jqDecode(dec *json.Decoder) (interface{}, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			arr := []interface{}{}
+			for dec.More() {
+				v, err := jqDecode(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		obj := newJQObject(0)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := jqDecode(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.put(key.(string), v)
+		}
+		_, err := dec.Token()
+		return obj, err
+	}
+	return tok, nil
+}
+
+// jqObject is a JSON object that keeps its keys in insertion order, as jq does. Values
+// are treated as immutable: with and without return modified copies.
+type jqObject struct {
+	keys []string
+	vals map[string]interface{}
+}
+
+func newJQObject(n int) *jqObject {
+	return &jqObject{keys: make([]string, 0, n), vals: make(map[string]interface{}, n)}
+}
+
+func (o *jqObject) len() int { return len(o.keys) }
+
+func (o *jqObject) get(key string) (interface{}, bool) {
+	v, ok := o.vals[key]
+	return v, ok
+}
+
+// put sets key in place; only use it on an object that has not been shared yet.
+func (o *jqObject) put(key string, v interface{}) {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+func (o *jqObject) copy() *jqObject {
+	c := newJQObject(len(o.keys) + 1)
+	c.keys = append(c.keys, o.keys...)
+	for k, v := range o.vals {
+		c.vals[k] = v
+	}
+	return c
+}
+
+// with returns a copy of o with key set to v.
+func (o *jqObject) with(key string, v interface{}) *jqObject {
+	c := o.copy()
+	c.put(key, v)
+	return c
+}
+
+// without returns a copy of o without key.
+func (o *jqObject) without(key string) *jqObject {
+	if _, ok := o.vals[key]; !ok {
+		return o
+	}
+	c := newJQObject(len(o.keys))
+	for _, k := range o.keys {
+		if k != key {
+			c.put(k, o.vals[k])
+		}
+	}
+	return c
+}
+
+func (o *jqObject) sortedKeys() []string {
+	keys := append([]string(nil), o.keys...)
+	sort.Strings(keys)
+	return keys
+}
+
+// jqTypeName returns the jq type of v, as the type builtin reports it.
+func jqTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case *jqObject:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jqNumber returns v as a float64 when it is a number.
+func jqNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(n), 64)
+		if err != nil {
+			// Out of range: ParseFloat returns ±Inf, as jq does.
+			return f, true
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+func jqTruthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+// jqTypeOrder ranks types for sorting: null < false < true < numbers < strings < arrays
+// < objects.
+func jqTypeOrder(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64, json.Number:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+// compare orders a and b the way jq sorts values. Objects compare by their sorted key
+// sets first, then by the values of those keys.
+func (ev *jqEval) compare(a, b interface{}) (int, error) {
+	if err := ev.step(1); err != nil {
+		return 0, err
+	}
+	ta, tb := jqTypeOrder(a), jqTypeOrder(b)
+	if ta != tb {
+		return cmpInt(ta, tb), nil
+	}
+	switch a := a.(type) {
+	case float64, json.Number:
+		x, _ := jqNumber(a)
+		y, _ := jqNumber(b)
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strings.Compare(a, b.(string)), nil
+	case []interface{}:
+		bs := b.([]interface{})
+		for i := 0; i < len(a) && i < len(bs); i++ {
+			if c, err := ev.compare(a[i], bs[i]); c != 0 || err != nil {
+				return c, err
+			}
+		}
+		return cmpInt(len(a), len(bs)), nil
+	case *jqObject:
+		bo := b.(*jqObject)
+		ka, kb := a.sortedKeys(), bo.sortedKeys()
+		for i := 0; i < len(ka) && i < len(kb); i++ {
+			if c := strings.Compare(ka[i], kb[i]); c != 0 {
+				return c, nil
+			}
+		}
+		if len(ka) != len(kb) {
+			return cmpInt(len(ka), len(kb)), nil
+		}
+		for _, k := range ka {
+			if c, err := ev.compare(a.vals[k], bo.vals[k]); c != 0 || err != nil {
+				return c, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// encode writes v as JSON; indent "" is compact output.
+func (ev *jqEval) encode(v interface{}, indent string) ([]byte, error) {
+	var b bytes.Buffer
+	if err := ev.encodeTo(&b, v, indent, 0); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (ev *jqEval) encodeString(v interface{}, indent string) (string, error) {
+	out, err := ev.encode(v, indent)
+	return string(out), err
+}
+
+func (ev *jqEval) encodeTo(b *bytes.Buffer, v interface{}, indent string, depth int) error {
+	if err := ev.step(1); err != nil {
+		return err
+	}
+	newline := func(depth int) {
+		if indent != "" {
+			b.WriteByte('\n')
+			for i := 0; i < depth; i++ {
+				b.WriteString(indent)
+			}
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case float64:
+		b.WriteString(jqFormatNumber(v))
+	case json.Number:
+		b.WriteString(string(v))
+	case string:
+		jqQuote(b, v)
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return nil
+		}
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			if err := ev.encodeTo(b, e, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		b.WriteByte(']')
+	case *jqObject:
+		if v.len() == 0 {
+			b.WriteString("{}")
+			return nil
+		}
+		b.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			newline(depth + 1)
+			jqQuote(b, k)
+			b.WriteByte(':')
+			if indent != "" {
+				b.WriteByte(' ')
+			}
+			if err := ev.encodeTo(b, v.vals[k], indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("jq: cannot encode %T", v)
+	}
+	return nil
+}
+
+// jqFormatNumber writes f the way encoding/json does. NaN has no JSON form and is
+// written as null; infinities become the largest finite doubles, as in jq.
+func jqFormatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "null"
+	case math.IsInf(f, 1):
+		f = math.MaxFloat64
+	case math.IsInf(f, -1):
+		f = -math.MaxFloat64
+	}
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+	if format == 'e' {
+		// 1e-07 becomes 1e-7.
+		if n := len(s); n >= 4 && s[n-4] == 'e' && s[n-3] == '-' && s[n-2] == '0' {
+			s = s[:n-2] + s[n-1:]
+		}
+	}
+	return s
+}
+
+// jqQuote writes s as a JSON string. Unlike encoding/json it leaves <, > and & alone;
+// invalid UTF-8 becomes U+FFFD.
+func jqQuote(b *bytes.Buffer, s string) {
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c < utf8.RuneSelf && c != 0x7f {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		if c < utf8.RuneSelf {
+			switch c {
+			case '"', '\\':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\b':
+				b.WriteString(`\b`)
+			case '\f':
+				b.WriteString(`\f`)
+			default:
+				fmt.Fprintf(b, `\u%04x`, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		i += size
+	}
+	b.WriteByte('"')
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJQJSON(t *testing.T) {
+	people := `[{"name":"ann","age":31,"team":"a"},{"name":"bob","age":25,"team":"b"},{"name":"cy","age":40,"team":"a"}]`
+	cases := []struct {
+		name   string
+		value  string
+		filter string
+		want   string
+	}{
+		{"identity", `{"b":1,"a":[1,2]}`, ".", `{"b":1,"a":[1,2]}`},
+		{"empty filter", `[1]`, "", `[1]`},
+		{"map select", people, `map(select(.age > 30) | .name)`, `["ann","cy"]`},
+		{"sort_by", people, `sort_by(.age) | map(.name)`, `["bob","ann","cy"]`},
+		{"group_by", people, `group_by(.team) | map({team: .[0].team, names: map(.name)})`, `[{"team":"a","names":["ann","cy"]},{"team":"b","names":["bob"]}]`},
+		{"object construction", `{"user":{"id":7,"tags":["x","y"]}}`, `{id: .user.id, tag: .user.tags[]}`, "{\"id\":7,\"tag\":\"x\"}\n{\"id\":7,\"tag\":\"y\"}"},
+		{"keys kept in order", `{"z":1,"a":2}`, `. + {m: 3}`, `{"z":1,"a":2,"m":3}`},
+		{"big numbers kept", `[12345678901234567890, 1.10]`, `.[]`, "12345678901234567890\n1.10"},
+		{"arithmetic", `{"a":7,"b":2}`, `.a / .b, .a % .b, "x" * 3, [1,2,3] - [2]`, "3.5\n1\n\"xxx\"\n[1,3]"},
+		{"reduce", `[1,2,3,4]`, `reduce .[] as $x (0; . + $x)`, "10"},
+		{"update", `{"a":{"b":[1,2]}}`, `.a.b[] |= . * 10`, `{"a":{"b":[10,20]}}`},
+		{"assign creates paths", `null`, `.a.b[1] = true`, `{"a":{"b":[null,true]}}`},
+		{"del", `{"a":1,"b":2,"c":3}`, `del(.a, .c)`, `{"b":2}`},
+		{"paths", `{"a":{"b":[1]}}`, `[paths]`, `[["a"],["a","b"],["a","b",0]]`},
+		{"to_entries", `{"a":1,"b":2}`, `with_entries(.value += 1)`, `{"a":2,"b":3}`},
+		{"try catch", `[1,"x"]`, `.[] | try (. + 1) catch "bad"`, "2\n\"bad\""},
+		{"alternative", `{"a":null}`, `.a // "default", (.b.c // 1)`, "\"default\"\n1"},
+		{"optional", `[1,{"a":2}]`, `[.[] | .a?]`, `[2]`},
+		{"string interpolation", `{"n":"ann","x":[1]}`, `"\(.n) has \(.x)"`, `"ann has [1]"`},
+		{"formats", `["a,b","c\"d"]`, `@csv, @base64 "\(.[0])"`, "\"\\\"a,b\\\",\\\"c\\\"\\\"d\\\"\"\n\"YSxi\""},
+		{"regex", `"2024-01-15"`, `capture("(?<y>\\d+)-(?<m>\\d+)"), sub("-"; "/"; "g")`, "{\"y\":\"2024\",\"m\":\"01\"}\n\"2024/01/15\""},
+		{"functions", `null`, `def fac: if . <= 1 then 1 else . * (. - 1 | fac) end; [range(1; 6) | fac]`, `[1,2,6,24,120]`},
+		{"destructuring", `[[1,{"a":2}]]`, `.[] as [$x, {a: $y}] | $x + $y`, "3"},
+		{"label break", `null`, `[label $out | range(10) | ., (select(. == 2) | break $out)]`, `[0,1,2]`},
+		{"limit", `null`, `[limit(3; repeat(1))]`, `[null,1,1]`},
+		{"unicode", `"héllo"`, `length, utf8bytelength, explode[1]`, "5\n6\n233"},
+		{"multiple inputs", `1 2 3`, `. * 2`, "2\n4\n6"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := jqJSON(context.Background(), JQRequest{Value: tc.value, Filter: tc.filter, Compact: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result = %s, want %s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestJQJSONOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		req  JQRequest
+		want string
+	}{
+		{"pretty", JQRequest{Value: `{"a":[1,2]}`, Filter: "."}, "{\n  \"a\": [\n    1,\n    2\n  ]\n}"},
+		{"raw", JQRequest{Value: `["x","y",1]`, Filter: ".[]", Raw: true, Compact: true}, "x\ny\n1"},
+		{"slurp", JQRequest{Value: `1 2 3`, Filter: "add", Slurp: true}, "6"},
+		{"null input", JQRequest{Value: `1 2 3`, Filter: "[inputs]", NullInput: true, Compact: true}, "[1,2,3]"},
+		{"input", JQRequest{Value: `1 2 3 4`, Filter: "[., input]", Compact: true}, "[1,2]\n[3,4]"},
+		{"no results", JQRequest{Value: `[]`, Filter: ".[]"}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := jqJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result = %q, want %q", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestJQJSONErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		req       JQRequest
+		wantCode  string
+		wantField string
+		details   map[string]interface{}
+	}{
+		{"syntax error", JQRequest{Value: "1", Filter: ".a |"}, CodeInvalidQuery, "filter", map[string]interface{}{"offset": 4, "line": 1, "column": 5}},
+		{"unknown function", JQRequest{Value: "1", Filter: "1 +\n  nope"}, CodeInvalidQuery, "filter", map[string]interface{}{"offset": 6, "line": 2, "column": 3}},
+		{"undefined variable", JQRequest{Value: "1", Filter: "$x"}, CodeInvalidQuery, "filter", map[string]interface{}{"offset": 0, "line": 1, "column": 1}},
+		{"invalid input", JQRequest{Value: `{"a":}`, Filter: "."}, CodeInvalidJSON, "value", nil},
+		{"runtime error", JQRequest{Value: `"x"`, Filter: ".[0]"}, CodeInvalidValue, "filter", nil},
+		{"error value", JQRequest{Value: "null", Filter: `error({"why": 1})`}, CodeInvalidValue, "filter", map[string]interface{}{"error": json.RawMessage(`{"why":1}`)}},
+		{"no more inputs", JQRequest{Value: "1", Filter: "input"}, CodeInvalidValue, "filter", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := jqJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.wantCode || ae.Field != tc.wantField {
+				t.Errorf("code = %s on %q, want %s on %q (%s)", ae.Code, ae.Field, tc.wantCode, tc.wantField, ae.Message)
+			}
+			if tc.details != nil && !reflect.DeepEqual(ae.Details, tc.details) {
+				t.Errorf("details = %v, want %v", ae.Details, tc.details)
+			}
+		})
+	}
+}
+
+func TestJQLimits(t *testing.T) {
+	defer SetLimits(limits)
+	l := DefaultLimits()
+	l.JSON.JQSteps = 1000
+	SetLimits(l)
+	for _, filter := range []string{"[range(1e9)]", "def f: f; f", "[recurse(. + 1)]", `"x" * 1e9`} {
+		_, err := jqJSON(context.Background(), JQRequest{Value: "0", Filter: filter})
+		var ae *APIError
+		if !errors.As(err, &ae) || ae.Code != CodeTooMany {
+			t.Errorf("%s: err = %v, want %s", filter, err, CodeTooMany)
+		}
+	}
+
+	// Large values fail on their size before they are built, within the step budget.
+	SetLimits(DefaultLimits())
+	if _, err := jqJSON(context.Background(), JQRequest{Value: "0", Filter: `"x" * 1e8 | length`}); err == nil {
+		t.Errorf(`"x" * 1e8: err = nil, want %s`, CodeTooMany)
+	}
+	l = DefaultLimits()
+	l.JSON.JQValueSize = 100
+	SetLimits(l)
+	for _, filter := range []string{`"x" * 101`, `("x" * 60) + ("x" * 60)`, "[range(60)] + [range(60)]"} {
+		_, err := jqJSON(context.Background(), JQRequest{Value: "0", Filter: filter})
+		var ae *APIError
+		if !errors.As(err, &ae) || ae.Code != CodeTooMany || !reflect.DeepEqual(ae.Details, map[string]interface{}{"max": 100}) {
+			t.Errorf("%s: err = %v, want %s with max 100", filter, err, CodeTooMany)
+		}
+	}
+	if _, err := jqJSON(context.Background(), JQRequest{Value: "0", Filter: `"x" * 100`}); err != nil {
+		t.Errorf(`"x" * 100: %v`, err)
+	}
+
+	SetLimits(DefaultLimits())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := jqJSON(ctx, JQRequest{Value: "0", Filter: "[range(100000)]"})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeTimeout {
+		t.Errorf("err = %v, want %s", err, CodeTimeout)
+	}
+}
+
+func TestJQStreaming(t *testing.T) {
+	tool, ok := LookupTool("json", "jq")
+	if !ok {
+		t.Fatal("jq tool not registered")
+	}
+	run := func(body string) (*httptest.ResponseRecorder, []map[string]json.RawMessage) {
+		req := httptest.NewRequest("POST", "http://test", strings.NewReader(body))
+		req.Header.Set("Accept", ndjsonType)
+		rec := httptest.NewRecorder()
+		tool.ServeHTTP(rec, req)
+		var lines []map[string]json.RawMessage
+		sc := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+		for sc.Scan() {
+			var line map[string]json.RawMessage
+			if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+				t.Fatalf("line %q: %v", sc.Text(), err)
+			}
+			lines = append(lines, line)
+		}
+		return rec, lines
+	}
+
+	rec, lines := run(`{"value":"[1,{\"a\":2}]","filter":".[]"}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ndjsonType {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if len(lines) != 2 || string(lines[0]["output"]) != "1" || string(lines[1]["output"]) != `{"a":2}` {
+		t.Errorf("lines = %s", rec.Body.String())
+	}
+
+	// An error after the first result ends the stream with an error line.
+	rec, lines = run(`{"value":"[1,\"x\"]","filter":".[] | . + 1"}`)
+	if rec.Code != http.StatusOK || len(lines) != 2 || lines[1]["error"] == nil {
+		t.Errorf("status = %d, body %s; want one output and an error line", rec.Code, rec.Body.String())
+	}
+
+	// An error before any output is an ordinary error response.
+	rec, _ = run(`{"value":"1","filter":".["}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if e := parseErrorResponse(t, rec.Body.String()); e.Code != CodeInvalidQuery {
+		t.Errorf("code = %s, want %s", e.Code, CodeInvalidQuery)
+	}
+}
@@ -0,0 +1,1673 @@
+package handlers
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This file holds the jq builtins: natives written in Go (jqNatives) and the ones jq
+// itself defines in jq (jqPrelude), plus the @formats. Builtins that loop (recurse,
+// repeat, while, until) are natives with an explicit stack so long iterations do not
+// nest Go calls.
+
+// jqNative is a builtin implemented in Go. It receives its arguments unevaluated, as
+// filters to run in env.
+type jqNative struct {
+	fn func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error
+}
+
+// jqFn adapts a function of the input and the values of its arguments. Arguments are
+// evaluated against the input, and fn runs once for every combination of their values.
+func jqFn(fn func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error)) *jqNative {
+	return &jqNative{fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, make([]interface{}, 0, len(args)), func(vals []interface{}) error {
+			v, err := fn(ev, in.v, vals)
+			if err != nil {
+				return err
+			}
+			return emit(jqVal{v: v})
+		})
+	}}
+}
+
+// eachArg evaluates args against in and calls fn with every combination of their
+// values, the first argument varying slowest (as def f($a; $b) binds them).
+func (ev *jqEval) eachArg(args []jqExpr, in jqVal, env *jqEnv, vals []interface{}, fn func([]interface{}) error) error {
+	if len(args) == 0 {
+		return fn(vals)
+	}
+	return ev.eval(args[0], in, env, func(v jqVal) error {
+		return ev.eachArg(args[1:], in, env, append(vals[:len(vals):len(vals)], v.v), fn)
+	})
+}
+
+// jqNatives maps name/arity to the builtins implemented in Go.
+var jqNatives = map[string]*jqNative{
+	"empty/0": {fn: func(*jqEval, jqVal, []jqExpr, *jqEnv, func(jqVal) error) error { return nil }},
+	"not/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return !jqTruthy(in), nil
+	}),
+	"error/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return nil, &jqError{value: in}
+	}),
+	"error/1": jqFn(func(_ *jqEval, _ interface{}, args []interface{}) (interface{}, error) {
+		return nil, &jqError{value: args[0]}
+	}),
+	"halt_error/1": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return nil, &jqError{value: in}
+	}),
+	"type/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqTypeName(in), nil
+	}),
+	"length/0":         jqFn(jqLength),
+	"utf8bytelength/0": jqFn(jqUTF8ByteLength),
+	"keys/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqKeys(in, true)
+	}),
+	"keys_unsorted/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqKeys(in, false)
+	}),
+	"has/1":      jqFn(jqHas),
+	"contains/1": jqFn(jqContains),
+	"add/0":      jqFn(jqAdd),
+	"range/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			return ev.rangeOf(0.0, vals[0], 1.0, emit)
+		})
+	}},
+	"range/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			return ev.rangeOf(vals[0], vals[1], 1.0, emit)
+		})
+	}},
+	"range/3": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			return ev.rangeOf(vals[0], vals[1], vals[2], emit)
+		})
+	}},
+	"floor/0": jqMath(math.Floor),
+	"ceil/0":  jqMath(math.Ceil),
+	"round/0": jqMath(math.Round),
+	"trunc/0": jqMath(math.Trunc),
+	"fabs/0":  jqMath(math.Abs),
+	"sqrt/0":  jqMath(math.Sqrt),
+	"log/0":   jqMath(math.Log),
+	"log2/0":  jqMath(math.Log2),
+	"log10/0": jqMath(math.Log10),
+	"exp/0":   jqMath(math.Exp),
+	"exp2/0":  jqMath(math.Exp2),
+	"exp10/0": jqMath(func(x float64) float64 { return math.Pow(10, x) }),
+	"sin/0":   jqMath(math.Sin),
+	"cos/0":   jqMath(math.Cos),
+	"tan/0":   jqMath(math.Tan),
+	"asin/0":  jqMath(math.Asin),
+	"acos/0":  jqMath(math.Acos),
+	"atan/0":  jqMath(math.Atan),
+	"pow/2": jqFn(func(_ *jqEval, _ interface{}, args []interface{}) (interface{}, error) {
+		x, ok1 := jqNumber(args[0])
+		y, ok2 := jqNumber(args[1])
+		if !ok1 || !ok2 {
+			return nil, jqErrorf("pow/2 requires number arguments")
+		}
+		return math.Pow(x, y), nil
+	}),
+	"abs/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		f, ok := jqNumber(in)
+		if !ok {
+			return nil, jqErrorf("%s has no absolute value", jqDescribe(in))
+		}
+		if f < 0 {
+			return -f, nil
+		}
+		return in, nil
+	}),
+	"infinite/0": jqFn(func(*jqEval, interface{}, []interface{}) (interface{}, error) { return math.Inf(1), nil }),
+	"nan/0":      jqFn(func(*jqEval, interface{}, []interface{}) (interface{}, error) { return math.NaN(), nil }),
+	"isinfinite/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		f, err := jqNumberInput(in, "number required")
+		return math.IsInf(f, 0), err
+	}),
+	"isnan/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		f, err := jqNumberInput(in, "number required")
+		return math.IsNaN(f), err
+	}),
+	"isnormal/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		f, err := jqNumberInput(in, "number required")
+		return !math.IsNaN(f) && !math.IsInf(f, 0) && f != 0 && math.Abs(f) >= 0x1p-1022, err
+	}),
+	"tostring/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqToString(ev, in)
+	}),
+	"tojson/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		out, err := ev.encode(in, "")
+		return string(out), err
+	}),
+	"fromjson/0": jqFn(jqFromJSON),
+	"tonumber/0": jqFn(jqToNumber),
+	"ascii_downcase/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqMapASCII(ev, in, "ascii_downcase", 'A', 'Z', 'a'-'A')
+	}),
+	"ascii_upcase/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqMapASCII(ev, in, "ascii_upcase", 'a', 'z', 'A'-'a')
+	}),
+	"explode/0": jqFn(jqExplode),
+	"implode/0": jqFn(jqImplode),
+	"ltrimstr/1": jqFn(func(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		s, ok1 := in.(string)
+		prefix, ok2 := args[0].(string)
+		if ok1 && ok2 {
+			return strings.TrimPrefix(s, prefix), nil
+		}
+		return in, nil
+	}),
+	"rtrimstr/1": jqFn(func(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		s, ok1 := in.(string)
+		suffix, ok2 := args[0].(string)
+		if ok1 && ok2 {
+			return strings.TrimSuffix(s, suffix), nil
+		}
+		return in, nil
+	}),
+	"startswith/1": jqFn(func(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		s, ok1 := in.(string)
+		prefix, ok2 := args[0].(string)
+		if !ok1 || !ok2 {
+			return nil, jqErrorf("startswith() requires string inputs")
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}),
+	"endswith/1": jqFn(func(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		s, ok1 := in.(string)
+		suffix, ok2 := args[0].(string)
+		if !ok1 || !ok2 {
+			return nil, jqErrorf("endswith() requires string inputs")
+		}
+		return strings.HasSuffix(s, suffix), nil
+	}),
+	"trim/0":  jqTrim("trim", strings.TrimSpace),
+	"ltrim/0": jqTrim("ltrim", func(s string) string { return strings.TrimLeft(s, " \t\n\r\f\v") }),
+	"rtrim/0": jqTrim("rtrim", func(s string) string { return strings.TrimRight(s, " \t\n\r\f\v") }),
+	"split/1": jqFn(func(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		s, ok1 := in.(string)
+		sep, ok2 := args[0].(string)
+		if !ok1 || !ok2 {
+			return nil, jqErrorf("split input and separator must be strings")
+		}
+		return jqSplit(s, sep), nil
+	}),
+	"join/1":    jqFn(jqJoin),
+	"indices/1": jqFn(jqIndices),
+	"flatten/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		return jqFlatten(ev, in, 1e9)
+	}),
+	"flatten/1": jqFn(func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		depth, ok := jqNumber(args[0])
+		if !ok || depth < 0 {
+			return nil, jqErrorf("flatten depth must not be negative")
+		}
+		return jqFlatten(ev, in, depth)
+	}),
+	"reverse/0": jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		switch c := in.(type) {
+		case nil:
+			return []interface{}{}, nil
+		case string:
+			r := []rune(c)
+			for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+				r[i], r[j] = r[j], r[i]
+			}
+			return string(r), nil
+		case []interface{}:
+			out := make([]interface{}, len(c))
+			for i, v := range c {
+				out[len(c)-1-i] = v
+			}
+			return out, nil
+		}
+		return nil, jqErrorf("Cannot reverse %s", jqDescribe(in))
+	}),
+	"sort/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		arr, err := jqArrayInput(in, "sorted")
+		if err != nil {
+			return nil, err
+		}
+		out := append([]interface{}(nil), arr...)
+		return out, ev.sortValues(out)
+	}),
+	"sort_by/1":   jqByNative(jqSortBy),
+	"group_by/1":  jqByNative(jqGroupBy),
+	"unique_by/1": jqByNative(jqUniqueBy),
+	"min_by/1":    jqByNative(jqMinBy),
+	"max_by/1":    jqByNative(jqMaxBy),
+	"unique/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		arr, err := jqArrayInput(in, "sorted")
+		if err != nil {
+			return nil, err
+		}
+		return jqUniqueBy(ev, arr, arr)
+	}),
+	"min/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		arr, err := jqArrayInput(in, "iterated over")
+		if err != nil {
+			return nil, err
+		}
+		return jqMinBy(ev, arr, arr)
+	}),
+	"max/0": jqFn(func(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		arr, err := jqArrayInput(in, "iterated over")
+		if err != nil {
+			return nil, err
+		}
+		return jqMaxBy(ev, arr, arr)
+	}),
+	"path/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eval(args[0], jqVal{v: in.v, path: jqRootPath}, env, func(out jqVal) error {
+			if out.path == nil {
+				return jqErrorf("Invalid path expression with result %s", jqDump(out.v))
+			}
+			return emit(jqVal{v: out.path.keys()})
+		})
+	}},
+	"getpath/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eval(args[0], in, env, func(p jqVal) error {
+			path, err := jqPathInput(p.v)
+			if err != nil {
+				return err
+			}
+			v, err := ev.getpath(in.v, path)
+			if err != nil {
+				return err
+			}
+			out := jqVal{v: v, path: in.path}
+			for _, k := range path {
+				out.path = out.path.extend(k)
+			}
+			return emit(out)
+		})
+	}},
+	"setpath/2": jqFn(func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		path, err := jqPathInput(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return ev.setpath(in, path, args[1])
+	}),
+	"delpaths/1": jqFn(func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		paths, ok := args[0].([]interface{})
+		if !ok {
+			return nil, jqErrorf("Paths must be specified as an array")
+		}
+		converted := make([]interface{}, len(paths))
+		for i, p := range paths {
+			path, err := jqPathInput(p)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = path
+		}
+		return ev.delpaths(in, converted)
+	}),
+	"recurse/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.recurse(in, args[0], nil, env, emit)
+	}},
+	"recurse/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.recurse(in, args[0], args[1], env, emit)
+	}},
+	"repeat/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.recurse(in, args[0], nil, env, emit)
+	}},
+	"while/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.loop(in, args[0], args[1], true, env, emit)
+	}},
+	"until/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.loop(in, args[0], args[1], false, env, emit)
+	}},
+	"limit/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eval(args[0], in, env, func(n jqVal) error {
+			limit, ok := jqNumber(n.v)
+			if !ok {
+				return jqErrorf("Invalid limit: %s", jqDescribe(n.v))
+			}
+			if limit < 0 {
+				return ev.eval(args[1], in, env, emit)
+			}
+			return ev.take(args[1], in, env, limit, emit)
+		})
+	}},
+	"first/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.take(args[0], in, env, 1, emit)
+	}},
+	"isempty/1": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		_, found, err := ev.first(args[0], in, env)
+		if err != nil {
+			return err
+		}
+		return emit(jqVal{v: !found})
+	}},
+	"input/0": jqFn(func(ev *jqEval, _ interface{}, _ []interface{}) (interface{}, error) {
+		if len(ev.inputs) == 0 {
+			return nil, jqErrorf("No more inputs")
+		}
+		in := ev.inputs[0]
+		ev.inputs = ev.inputs[1:]
+		return in, nil
+	}),
+	"inputs/0": {fn: func(ev *jqEval, _ jqVal, _ []jqExpr, _ *jqEnv, emit func(jqVal) error) error {
+		for len(ev.inputs) > 0 {
+			in := ev.inputs[0]
+			ev.inputs = ev.inputs[1:]
+			if err := emit(jqVal{v: in}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	"debug/0":  {fn: jqPassthrough},
+	"debug/1":  {fn: jqPassthrough},
+	"stderr/0": {fn: jqPassthrough},
+	"input_filename/0": jqFn(func(*jqEval, interface{}, []interface{}) (interface{}, error) {
+		return nil, nil
+	}),
+	"test/2": jqFn(func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		re, _, err := ev.regexpArgs(in, args[0], args[1], "test")
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(in.(string)), nil
+	}),
+	"match/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			return ev.matches(in.v, vals[0], vals[1], "match", func(m *jqObject) error {
+				return emit(jqVal{v: m})
+			})
+		})
+	}},
+	"capture/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			return ev.matches(in.v, vals[0], vals[1], "capture", func(m *jqObject) error {
+				return emit(jqVal{v: jqCaptureObject(m)})
+			})
+		})
+	}},
+	"scan/2": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg(args, in, env, nil, func(vals []interface{}) error {
+			flags, _ := vals[1].(string)
+			return ev.matches(in.v, vals[0], flags+"g", "scan", func(m *jqObject) error {
+				caps, _ := m.get("captures")
+				if len(caps.([]interface{})) == 0 {
+					s, _ := m.get("string")
+					return emit(jqVal{v: s})
+				}
+				var out []interface{}
+				for _, c := range caps.([]interface{}) {
+					s, _ := c.(*jqObject).get("string")
+					out = append(out, s)
+				}
+				return emit(jqVal{v: out})
+			})
+		})
+	}},
+	"split/2": jqFn(func(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+		re, _, err := ev.regexpArgs(in, args[0], args[1], "split")
+		if err != nil {
+			return nil, err
+		}
+		out := []interface{}{}
+		for _, part := range re.Split(in.(string), -1) {
+			out = append(out, part)
+		}
+		return out, nil
+	}),
+	"sub/3": {fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		return ev.eachArg([]jqExpr{args[0], args[2]}, in, env, nil, func(vals []interface{}) error {
+			return ev.substitute(in.v, vals[0], vals[1], args[1], env, emit)
+		})
+	}},
+	"now/0": jqFn(func(*jqEval, interface{}, []interface{}) (interface{}, error) {
+		return float64(time.Now().UnixNano()) / 1e9, nil
+	}),
+	"gmtime/0":   jqFn(jqGmtime),
+	"mktime/0":   jqFn(jqMktime),
+	"strftime/1": jqFn(jqStrftime),
+	"strptime/1": jqFn(jqStrptime),
+}
+
+func init() {
+	names := []string{}
+	for key := range jqNatives {
+		names = append(names, key)
+	}
+	jqNatives["builtins/0"] = jqFn(func(*jqEval, interface{}, []interface{}) (interface{}, error) {
+		out := []interface{}{}
+		for _, key := range names {
+			out = append(out, key)
+		}
+		for key := range jqPreludeDefs {
+			if !strings.HasPrefix(key, "_") {
+				out = append(out, key)
+			}
+		}
+		return out, nil
+	})
+	names = append(names, "builtins/0")
+
+	p := &jqParser{src: jqPrelude, prelude: jqPreludeDefs, definePrelude: true}
+	if _, err := p.pipe(false); err != nil {
+		panic(fmt.Sprintf("jq prelude: %v at %d", err, p.pos))
+	}
+}
+
+// jqPreludeDefs holds the definitions of jqPrelude by name/arity.
+var jqPreludeDefs = map[string]*jqFuncDef{}
+
+// jqPrelude defines the builtins that jq itself writes in jq. A definition may only
+// call natives and the definitions above it.
+const jqPrelude = `
+def recurse: recurse(.[]?);
+def select(f): if f then . else empty end;
+def map(f): [.[] | f];
+def map_values(f): .[] |= f;
+def values: select(. != null);
+def nulls: select(. == null);
+def booleans: select(type == "boolean");
+def numbers: select(type == "number");
+def strings: select(type == "string");
+def arrays: select(type == "array");
+def objects: select(type == "object");
+def iterables: select(type | . == "array" or . == "object");
+def scalars: select(type | . != "array" and . != "object");
+def finites: select(isinfinite or isnan | not);
+def normals: select(isnormal);
+def toarray: if type == "array" then . else [.] end;
+def first: .[0];
+def last: .[-1];
+def nth($n): .[$n];
+def last(f): reduce f as $x (null; $x);
+def nth($n; f): if $n < 0 then error("Out of bounds negative array index") else last(limit($n + 1; f)) end;
+def add(f): reduce f as $x (null; . + $x);
+def any: reduce .[] as $x (false; . or $x);
+def all: reduce .[] as $x (true; . and $x);
+def any(f): reduce (.[] | f) as $x (false; . or $x);
+def all(f): reduce (.[] | f) as $x (true; . and $x);
+def any(g; cond): isempty(first(g | cond or empty)) | not;
+def all(g; cond): isempty(first(g | cond and empty));
+def in(xs): . as $x | xs | has($x);
+def inside(xs): . as $x | xs | contains($x);
+def IN(s): any(s == .; .);
+def IN(src; s): any(src == s; .);
+def index($i): indices($i) | .[0];
+def rindex($i): indices($i) | .[-1:][0];
+def del(f): delpaths([path(f)]);
+def paths: path(..) | select(length > 0);
+def paths(node_filter): . as $dot | paths | select(. as $p | $dot | getpath($p) | node_filter);
+def leaf_paths: paths(scalars);
+def pick(pathexps): . as $top | reduce path(pathexps) as $p (null; setpath($p; $top | getpath($p)));
+def to_entries: [keys_unsorted[] as $k | {key: $k, value: .[$k]}];
+def from_entries: reduce .[] as $x ({};
+  . + {($x | if .key == null then .k // .name // .Name // .K // .Key else .key end
+           | if type == "string" then . else tojson end):
+       ($x | if has("value") then .value else .v end)});
+def with_entries(f): to_entries | map(f) | from_entries;
+def walk(f): def w: if type == "object" then map_values(w) elif type == "array" then map(w) else . end | f; w;
+def env: $ENV;
+def halt_error: halt_error(5);
+def transpose: [range(0; map(length) | max // 0) as $i | [.[][$i]]];
+def combinations: if length == 0 then [] else .[0][] as $x | (.[1:] | combinations) as $w | [$x] + $w end;
+def combinations(n): . as $dot | [range(n)] | map($dot) | combinations;
+def INDEX(stream; idx_expr): reduce stream as $row ({}; .[$row | idx_expr | tostring] |= $row);
+def INDEX(idx_expr): INDEX(.[]; idx_expr);
+def test($re): test($re; null);
+def match($re): match($re; null);
+def capture($re): capture($re; null);
+def scan($re): scan($re; null);
+def splits($re; flags): split($re; flags) | .[];
+def splits($re): splits($re; null);
+def sub($re; str): sub($re; str; "");
+def gsub($re; str; flags): sub($re; str; flags + "g");
+def gsub($re; str): sub($re; str; "g");
+def todate: strftime("%Y-%m-%dT%H:%M:%SZ");
+def todateiso8601: todate;
+def date: todate;
+def fromdateiso8601: strptime("%Y-%m-%dT%H:%M:%SZ") | mktime;
+def fromdate: fromdateiso8601;
+def tostream: path(def r: (.[]? | r), .; r) as $p | getpath($p) | reduce path(.[]?) as $q ([$p, .]; [$p + $q]);
+def fromstream(f): {x: null, e: false} as $init
+  | foreach f as $i ($init;
+      if .e then $init else . end
+      | if $i | length == 2
+        then setpath(["e"]; $i[0] | length == 0) | setpath(["x"] + $i[0]; $i[1])
+        else setpath(["e"]; $i[0] | length == 1) end;
+      if .e then .x else empty end);
+def truncate_stream(stream): . as $n | null | stream | . as $input
+  | if (.[0] | length) > $n then setpath([0]; .[0][$n:]) else empty end;
+.
+`
+
+func jqPassthrough(_ *jqEval, in jqVal, _ []jqExpr, _ *jqEnv, emit func(jqVal) error) error {
+	return emit(in)
+}
+
+// jqMath adapts a float64 function to a builtin on numbers.
+func jqMath(fn func(float64) float64) *jqNative {
+	return jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		f, err := jqNumberInput(in, "number required")
+		if err != nil {
+			return nil, err
+		}
+		return fn(f), nil
+	})
+}
+
+func jqNumberInput(in interface{}, msg string) (float64, error) {
+	f, ok := jqNumber(in)
+	if !ok {
+		return 0, jqErrorf("%s %s", jqDescribe(in), msg)
+	}
+	return f, nil
+}
+
+func jqArrayInput(in interface{}, verb string) ([]interface{}, error) {
+	arr, ok := in.([]interface{})
+	if !ok {
+		return nil, jqErrorf("%s cannot be %s, as it is not an array", jqDescribe(in), verb)
+	}
+	return arr, nil
+}
+
+// jqPathInput checks a path given to getpath, setpath or delpaths.
+func jqPathInput(p interface{}) ([]interface{}, error) {
+	arr, ok := p.([]interface{})
+	if !ok {
+		return nil, jqErrorf("Path must be specified as an array")
+	}
+	path := make([]interface{}, len(arr))
+	for i, k := range arr {
+		path[i] = jqPathKey(k)
+	}
+	return path, nil
+}
+
+func jqLength(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	switch c := in.(type) {
+	case nil:
+		return 0.0, nil
+	case bool:
+		return nil, jqErrorf("%s has no length", jqDescribe(in))
+	case string:
+		return float64(utf8RuneCount(c)), nil
+	case []interface{}:
+		return float64(len(c)), nil
+	case *jqObject:
+		return float64(c.len()), nil
+	}
+	f, _ := jqNumber(in)
+	return math.Abs(f), nil
+}
+
+func jqUTF8ByteLength(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, jqErrorf("%s only strings have UTF-8 byte length", jqDescribe(in))
+	}
+	return float64(len(s)), nil
+}
+
+func jqKeys(in interface{}, sorted bool) (interface{}, error) {
+	switch c := in.(type) {
+	case *jqObject:
+		keys := c.keys
+		if sorted {
+			keys = c.sortedKeys()
+		}
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = k
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(c))
+		for i := range c {
+			out[i] = float64(i)
+		}
+		return out, nil
+	}
+	return nil, jqErrorf("%s has no keys", jqDescribe(in))
+}
+
+func jqHas(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	switch c := in.(type) {
+	case *jqObject:
+		if k, ok := args[0].(string); ok {
+			_, found := c.get(k)
+			return found, nil
+		}
+	case []interface{}:
+		if f, ok := jqNumber(args[0]); ok {
+			return f >= 0 && f < float64(len(c)), nil
+		}
+	}
+	return nil, jqErrorf("Cannot check whether %s has a %s key", jqTypeName(in), jqTypeName(args[0]))
+}
+
+func jqContains(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	return ev.contains(in, args[0])
+}
+
+// contains reports whether b is contained in a: substrings for strings, every element
+// of b contained in some element of a for arrays, and recursively by key for objects.
+func (ev *jqEval) contains(a, b interface{}) (bool, error) {
+	if err := ev.step(1); err != nil {
+		return false, err
+	}
+	switch x := a.(type) {
+	case *jqObject:
+		y, ok := b.(*jqObject)
+		if !ok {
+			break
+		}
+		for _, k := range y.keys {
+			av, found := x.get(k)
+			if !found {
+				return false, nil
+			}
+			if ok, err := ev.contains(av, y.vals[k]); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		for _, bv := range y {
+			found := false
+			for _, av := range x {
+				ok, err := ev.contains(av, bv)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			break
+		}
+		return strings.Contains(x, y), nil
+	default:
+		if jqTypeName(a) == jqTypeName(b) {
+			c, err := ev.compare(a, b)
+			return c == 0, err
+		}
+	}
+	return false, jqErrorf("%s and %s cannot have their containment checked", jqDescribe(a), jqDescribe(b))
+}
+
+// jqAdd adds up the elements of an array (or the values of an object); strings and
+// arrays are joined in one pass instead of pairwise.
+func jqAdd(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	var items []interface{}
+	switch c := in.(type) {
+	case []interface{}:
+		items = c
+	case *jqObject:
+		for _, k := range c.keys {
+			items = append(items, c.vals[k])
+		}
+	case nil:
+		return nil, nil
+	default:
+		return nil, jqErrorf("Cannot iterate over %s", jqDescribe(in))
+	}
+	allStrings, allArrays := len(items) > 0, len(items) > 0
+	for _, v := range items {
+		_, isString := v.(string)
+		_, isArray := v.([]interface{})
+		allStrings = allStrings && isString
+		allArrays = allArrays && isArray
+	}
+	switch {
+	case allStrings:
+		var b strings.Builder
+		for _, v := range items {
+			b.WriteString(v.(string))
+		}
+		return b.String(), ev.step(b.Len() / 64)
+	case allArrays:
+		out := []interface{}{}
+		for _, v := range items {
+			out = append(out, v.([]interface{})...)
+		}
+		return out, ev.step(len(out))
+	}
+	var acc interface{}
+	for _, v := range items {
+		var err error
+		if acc, err = ev.binop("+", acc, v); err != nil {
+			return nil, err
+		}
+	}
+	return acc, nil
+}
+
+// rangeOf emits from, from+by, ... up to (excluding) upto.
+func (ev *jqEval) rangeOf(from, upto, by interface{}, emit func(jqVal) error) error {
+	x, ok1 := jqNumber(from)
+	end, ok2 := jqNumber(upto)
+	step, ok3 := jqNumber(by)
+	if !ok1 || !ok2 || !ok3 {
+		return jqErrorf("Range bounds must be numeric")
+	}
+	for ; (step > 0 && x < end) || (step < 0 && x > end); x += step {
+		if err := ev.step(1); err != nil {
+			return err
+		}
+		if err := emit(jqVal{v: x}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recurse emits in and then, depth first, everything reached by applying f
+// repeatedly. With cond, only values for which cond holds are followed.
+func (ev *jqEval) recurse(in jqVal, f, cond jqExpr, env *jqEnv, emit func(jqVal) error) error {
+	stack := []jqVal{in}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := emit(v); err != nil {
+			return err
+		}
+		var next []jqVal
+		err := ev.eval(f, v, env, func(child jqVal) error {
+			if cond == nil {
+				next = append(next, child)
+				return nil
+			}
+			return ev.eval(cond, child, env, func(c jqVal) error {
+				if jqTruthy(c.v) {
+					next = append(next, child)
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		for i := len(next) - 1; i >= 0; i-- {
+			stack = append(stack, next[i])
+		}
+	}
+	return nil
+}
+
+// loop runs while(cond; update) (emitting each value while cond holds) and
+// until(cond; update) (emitting the first value for which cond holds).
+func (ev *jqEval) loop(in jqVal, cond, update jqExpr, while bool, env *jqEnv, emit func(jqVal) error) error {
+	stack := []jqVal{in}
+	for len(stack) > 0 {
+		v := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		c, ok, err := ev.first(cond, v, env)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if jqTruthy(c) != while {
+			if err := emit(v); err != nil {
+				return err
+			}
+			continue
+		}
+		if while {
+			if err := emit(v); err != nil {
+				return err
+			}
+		}
+		var next []jqVal
+		if err := ev.eval(update, v, env, func(u jqVal) error {
+			next = append(next, u)
+			return nil
+		}); err != nil {
+			return err
+		}
+		for i := len(next) - 1; i >= 0; i-- {
+			stack = append(stack, next[i])
+		}
+	}
+	return nil
+}
+
+// take emits the first n outputs of f.
+func (ev *jqEval) take(f jqExpr, in jqVal, env *jqEnv, n float64, emit func(jqVal) error) error {
+	if n <= 0 {
+		return nil
+	}
+	stop := &jqBreakSignal{label: new(int)}
+	count := 0.0
+	err := ev.eval(f, in, env, func(v jqVal) error {
+		if err := emit(v); err != nil {
+			return err
+		}
+		if count++; count >= n {
+			return stop
+		}
+		return nil
+	})
+	if err == stop {
+		return nil
+	}
+	return err
+}
+
+// jqToString is tostring: strings are returned as they are, other values as JSON.
+func jqToString(ev *jqEval, v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	out, err := ev.encode(v, "")
+	return string(out), err
+}
+
+func jqFromJSON(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, jqErrorf("%s cannot be parsed as JSON", jqDescribe(in))
+	}
+	vals, err := decodeJQInputs("", s)
+	if err != nil {
+		return nil, jqErrorf("%s (while parsing '%s')", strings.TrimPrefix(err.Error(), "invalid JSON: "), s)
+	}
+	if len(vals) != 1 {
+		return nil, jqErrorf("Expected a single JSON value (while parsing '%s')", s)
+	}
+	return vals[0], nil
+}
+
+func jqToNumber(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case float64, json.Number:
+		return v, nil
+	case string:
+		t := strings.TrimSpace(v)
+		if f, err := strconv.ParseFloat(t, 64); err == nil && json.Valid([]byte(t)) {
+			if _, isNum := jqNumber(json.Number(t)); isNum && !math.IsInf(f, 0) {
+				return json.Number(t), nil
+			}
+			return f, nil
+		}
+		return nil, jqErrorf("Cannot parse '%s' as JSON", v)
+	}
+	return nil, jqErrorf("%s cannot be parsed as a number", jqDescribe(in))
+}
+
+func jqMapASCII(ev *jqEval, in interface{}, name string, lo, hi byte, shift int) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, jqErrorf("%s input must be a string", name)
+	}
+	if err := ev.step(len(s) / 64); err != nil {
+		return nil, err
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c >= lo && c <= hi {
+			b[i] = byte(int(c) + shift)
+		}
+	}
+	return string(b), nil
+}
+
+func jqTrim(name string, fn func(string) string) *jqNative {
+	return jqFn(func(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+		s, ok := in.(string)
+		if !ok {
+			return nil, jqErrorf("%s input must be a string", name)
+		}
+		return fn(s), nil
+	})
+}
+
+func jqExplode(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	s, ok := in.(string)
+	if !ok {
+		return nil, jqErrorf("%s cannot be exploded, as it is not a string", jqDescribe(in))
+	}
+	out := []interface{}{}
+	for _, r := range s {
+		out = append(out, float64(r))
+	}
+	return out, ev.step(len(out))
+}
+
+func jqImplode(ev *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	arr, ok := in.([]interface{})
+	if !ok {
+		return nil, jqErrorf("Implode input must be an array")
+	}
+	var b strings.Builder
+	for _, v := range arr {
+		f, ok := jqNumber(v)
+		if !ok {
+			return nil, jqErrorf("Unicode codepoint must be numeric")
+		}
+		r := rune(f)
+		if f < 0 || f > utf8.MaxRune || !utf8.ValidRune(r) {
+			r = utf8.RuneError
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), ev.step(len(arr))
+}
+
+func jqJoin(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	sep, ok := args[0].(string)
+	if !ok {
+		return nil, jqErrorf("%s cannot be used as a separator", jqDescribe(args[0]))
+	}
+	var items []interface{}
+	switch c := in.(type) {
+	case []interface{}:
+		items = c
+	case *jqObject:
+		for _, k := range c.keys {
+			items = append(items, c.vals[k])
+		}
+	default:
+		return nil, jqErrorf("Cannot iterate over %s", jqDescribe(in))
+	}
+	var b strings.Builder
+	for i, v := range items {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		switch x := v.(type) {
+		case nil:
+		case string:
+			b.WriteString(x)
+		case bool, float64, json.Number:
+			s, _ := jqToString(ev, x)
+			b.WriteString(s)
+		default:
+			return nil, jqErrorf("Cannot join with %s", jqTypeName(v))
+		}
+	}
+	return b.String(), ev.step(b.Len() / 64)
+}
+
+// jqIndices returns the positions of i in the input: code point offsets of a
+// substring, or the offsets at which an element or subarray occurs.
+func jqIndices(ev *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	switch c := in.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		sub, ok := args[0].(string)
+		if !ok {
+			return nil, jqErrorf("Cannot determine indices of %s in string", jqTypeName(args[0]))
+		}
+		if sub == "" {
+			return nil, nil
+		}
+		out := []interface{}{}
+		runes := 0
+		for i := 0; i < len(c); {
+			if strings.HasPrefix(c[i:], sub) {
+				out = append(out, float64(runes))
+			}
+			_, size := utf8.DecodeRuneInString(c[i:])
+			i += size
+			runes++
+		}
+		return out, ev.step(len(c) / 64)
+	case []interface{}:
+		sub, ok := args[0].([]interface{})
+		if !ok {
+			sub = []interface{}{args[0]}
+		}
+		if err := ev.step(len(c) * len(sub)); err != nil {
+			return nil, err
+		}
+		return jqArrayIndices(c, sub), nil
+	}
+	return jqIndexValue(in, args[0])
+}
+
+func jqFlatten(ev *jqEval, in interface{}, depth float64) (interface{}, error) {
+	arr, ok := in.([]interface{})
+	if !ok {
+		return nil, jqErrorf("Cannot flatten %s", jqDescribe(in))
+	}
+	out := []interface{}{}
+	var walk func(arr []interface{}, depth float64) error
+	walk = func(arr []interface{}, depth float64) error {
+		for _, v := range arr {
+			if err := ev.step(1); err != nil {
+				return err
+			}
+			if sub, ok := v.([]interface{}); ok && depth > 0 {
+				if err := walk(sub, depth-1); err != nil {
+					return err
+				}
+				continue
+			}
+			out = append(out, v)
+		}
+		return nil
+	}
+	return out, walk(arr, depth)
+}
+
+// sortValues sorts vals in place in jq order (stable).
+func (ev *jqEval) sortValues(vals []interface{}) error {
+	var err error
+	sort.SliceStable(vals, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		c, e := ev.compare(vals[i], vals[j])
+		if e != nil {
+			err = e
+		}
+		return c < 0
+	})
+	return err
+}
+
+// jqByNative adapts a *_by(f) builtin. Each element's key is the array of f's outputs
+// for it; fn receives the elements and their keys.
+func jqByNative(fn func(ev *jqEval, items, keys []interface{}) (interface{}, error)) *jqNative {
+	return &jqNative{fn: func(ev *jqEval, in jqVal, args []jqExpr, env *jqEnv, emit func(jqVal) error) error {
+		items, err := jqArrayInput(in.v, "sorted")
+		if err != nil {
+			return err
+		}
+		keys := make([]interface{}, len(items))
+		for i, item := range items {
+			key, err := ev.collect(args[0], jqVal{v: item}, env)
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				key = []interface{}{}
+			}
+			keys[i] = key
+		}
+		out, err := fn(ev, items, keys)
+		if err != nil {
+			return err
+		}
+		return emit(jqVal{v: out})
+	}}
+}
+
+// sortedByKey returns the indices of items in order of keys (stable).
+func (ev *jqEval) sortedByKey(keys []interface{}) ([]int, error) {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	var err error
+	sort.SliceStable(order, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		c, e := ev.compare(keys[order[i]], keys[order[j]])
+		if e != nil {
+			err = e
+		}
+		return c < 0
+	})
+	return order, err
+}
+
+func jqSortBy(ev *jqEval, items, keys []interface{}) (interface{}, error) {
+	order, err := ev.sortedByKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(items))
+	for i, idx := range order {
+		out[i] = items[idx]
+	}
+	return out, nil
+}
+
+// groups returns items grouped by equal keys, in key order.
+func (ev *jqEval) groups(items, keys []interface{}) ([][]interface{}, error) {
+	order, err := ev.sortedByKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	var groups [][]interface{}
+	for i, idx := range order {
+		if i > 0 {
+			c, err := ev.compare(keys[order[i-1]], keys[idx])
+			if err != nil {
+				return nil, err
+			}
+			if c == 0 {
+				groups[len(groups)-1] = append(groups[len(groups)-1], items[idx])
+				continue
+			}
+		}
+		groups = append(groups, []interface{}{items[idx]})
+	}
+	return groups, nil
+}
+
+func jqGroupBy(ev *jqEval, items, keys []interface{}) (interface{}, error) {
+	groups, err := ev.groups(items, keys)
+	out := []interface{}{}
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	return out, err
+}
+
+func jqUniqueBy(ev *jqEval, items, keys []interface{}) (interface{}, error) {
+	groups, err := ev.groups(items, keys)
+	out := []interface{}{}
+	for _, g := range groups {
+		out = append(out, g[0])
+	}
+	return out, err
+}
+
+// jqMinBy returns the first item with the smallest key; null for no items.
+func jqMinBy(ev *jqEval, items, keys []interface{}) (interface{}, error) {
+	best := -1
+	for i := range items {
+		if best >= 0 {
+			c, err := ev.compare(keys[i], keys[best])
+			if err != nil {
+				return nil, err
+			}
+			if c >= 0 {
+				continue
+			}
+		}
+		best = i
+	}
+	if best < 0 {
+		return nil, nil
+	}
+	return items[best], nil
+}
+
+// jqMaxBy returns the last item with the largest key; null for no items.
+func jqMaxBy(ev *jqEval, items, keys []interface{}) (interface{}, error) {
+	best := -1
+	for i := range items {
+		if best >= 0 {
+			c, err := ev.compare(keys[i], keys[best])
+			if err != nil {
+				return nil, err
+			}
+			if c < 0 {
+				continue
+			}
+		}
+		best = i
+	}
+	if best < 0 {
+		return nil, nil
+	}
+	return items[best], nil
+}
+
+// regexpArgs compiles a jq regex with its flags (g, i, n, p, s, l and x are
+// recognized; x is not supported) and checks that in is a string. The regex may also
+// be given as [regex, flags].
+func (ev *jqEval) regexpArgs(in, re, flags interface{}, name string) (*regexp.Regexp, string, error) {
+	if _, ok := in.(string); !ok {
+		return nil, "", jqErrorf("%s cannot be matched, as it is not a string", jqDescribe(in))
+	}
+	if arr, ok := re.([]interface{}); ok && len(arr) > 0 {
+		re = arr[0]
+		if len(arr) > 1 {
+			flags = arr[1]
+		}
+	}
+	src, ok := re.(string)
+	if !ok {
+		return nil, "", jqErrorf("%s cannot be matched, as it is not a string", jqDescribe(re))
+	}
+	f, ok := flags.(string)
+	if !ok && flags != nil {
+		return nil, "", jqErrorf("%s is not a string", jqDescribe(flags))
+	}
+	prefix := ""
+	longest := false
+	for _, c := range f {
+		switch c {
+		case 'g', 'n':
+		case 'i':
+			prefix += "i"
+		case 'p':
+			prefix += "s"
+		case 's':
+		case 'l':
+			longest = true
+		case 'x':
+			return nil, "", jqErrorf("%s: the x flag is not supported", name)
+		default:
+			return nil, "", jqErrorf("%s is not a valid modifier string", f)
+		}
+	}
+	if prefix != "" {
+		src = "(?" + prefix + ")" + src
+	}
+	key := src
+	if longest {
+		key = "l:" + key
+	}
+	if ev.regexps == nil {
+		ev.regexps = make(map[string]*regexp.Regexp)
+	}
+	compiled, ok := ev.regexps[key]
+	if !ok {
+		var err error
+		if compiled, err = regexp.Compile(src); err != nil {
+			msg := err.Error()
+			var se *syntax.Error
+			if errors.As(err, &se) {
+				msg = string(se.Code)
+			}
+			return nil, "", jqErrorf("%s is not a valid regex: %s", src, msg)
+		}
+		if longest {
+			compiled.Longest()
+		}
+		ev.regexps[key] = compiled
+	}
+	return compiled, f, nil
+}
+
+// matches calls fn with a match object ({offset, length, string, captures}) for the
+// first match of re in in, or for every match with the g flag. Offsets and lengths
+// count code points.
+func (ev *jqEval) matches(in, re, flags interface{}, name string, fn func(*jqObject) error) error {
+	compiled, f, err := ev.regexpArgs(in, re, flags, name)
+	if err != nil {
+		return err
+	}
+	s := in.(string)
+	n := 1
+	if strings.Contains(f, "g") {
+		n = -1
+	}
+	names := compiled.SubexpNames()
+	// Byte offsets grow monotonically between matches, so code points are counted
+	// incrementally.
+	lastByte, lastRune := 0, 0
+	for _, loc := range compiled.FindAllStringSubmatchIndex(s, n) {
+		if strings.Contains(f, "n") && loc[0] == loc[1] {
+			continue
+		}
+		if err := ev.step(1); err != nil {
+			return err
+		}
+		lastRune += utf8.RuneCountInString(s[lastByte:loc[0]])
+		lastByte = loc[0]
+		m := jqMatchObject(s, loc[0], loc[1], lastRune)
+		caps := []interface{}{}
+		for g := 1; g < len(loc)/2; g++ {
+			start, end := loc[2*g], loc[2*g+1]
+			var c *jqObject
+			if start < 0 {
+				c = newJQObject(4)
+				c.put("offset", -1.0)
+				c.put("length", 0.0)
+				c.put("string", nil)
+			} else {
+				c = jqMatchObject(s, start, end, lastRune+utf8.RuneCountInString(s[loc[0]:start]))
+			}
+			if names[g] != "" {
+				c.put("name", names[g])
+			} else {
+				c.put("name", nil)
+			}
+			caps = append(caps, c)
+		}
+		m.put("captures", caps)
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jqMatchObject(s string, start, end, runeOffset int) *jqObject {
+	m := newJQObject(4)
+	m.put("offset", float64(runeOffset))
+	m.put("length", float64(utf8.RuneCountInString(s[start:end])))
+	m.put("string", s[start:end])
+	return m
+}
+
+// jqCaptureObject returns the named captures of a match object as {name: string}.
+func jqCaptureObject(m *jqObject) *jqObject {
+	out := newJQObject(0)
+	caps, _ := m.get("captures")
+	for _, c := range caps.([]interface{}) {
+		co := c.(*jqObject)
+		if name, ok := co.vals["name"].(string); ok {
+			out.put(name, co.vals["string"])
+		}
+	}
+	return out
+}
+
+// substitute implements sub and gsub: each match is replaced by an output of repl,
+// evaluated with the named captures as input. Several outputs give several results.
+func (ev *jqEval) substitute(in, re, flags interface{}, repl jqExpr, env *jqEnv, emit func(jqVal) error) error {
+	var found []*jqObject
+	var ends []int
+	compiledFlags := flags
+	if compiledFlags == nil {
+		compiledFlags = ""
+	}
+	err := ev.matches(in, re, compiledFlags, "sub", func(m *jqObject) error {
+		found = append(found, m)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s := in.(string)
+	runes := []rune(s)
+	for _, m := range found {
+		off, _ := jqNumber(m.vals["offset"])
+		length, _ := jqNumber(m.vals["length"])
+		ends = append(ends, int(off+length))
+	}
+	var rec func(i, prev int, acc string) error
+	rec = func(i, prev int, acc string) error {
+		if i == len(found) {
+			return emit(jqVal{v: acc + string(runes[prev:])})
+		}
+		off, _ := jqNumber(found[i].vals["offset"])
+		return ev.eval(repl, jqVal{v: jqCaptureObject(found[i])}, env, func(r jqVal) error {
+			text, ok := r.v.(string)
+			if !ok {
+				return jqErrorf("%s cannot be added to a string", jqDescribe(r.v))
+			}
+			if err := ev.step(len(text) / 64); err != nil {
+				return err
+			}
+			return rec(i+1, ends[i], acc+string(runes[prev:int(off)])+text)
+		})
+	}
+	return rec(0, 0, "")
+}
+
+// jqFormats implements the @name string formats.
+var jqFormats = map[string]func(ev *jqEval, v interface{}) (string, error){
+	"text": jqToString,
+	"json": func(ev *jqEval, v interface{}) (string, error) {
+		out, err := ev.encode(v, "")
+		return string(out), err
+	},
+	"html": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		return jqHTMLEscaper.Replace(s), err
+	},
+	"uri": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		return b.String(), err
+	},
+	"csv": func(ev *jqEval, v interface{}) (string, error) {
+		return jqRow(ev, v, "csv", ",", func(s string) string {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		})
+	},
+	"tsv": func(ev *jqEval, v interface{}) (string, error) {
+		return jqRow(ev, v, "tsv", "\t", jqTSVEscaper.Replace)
+	},
+	"sh": func(ev *jqEval, v interface{}) (string, error) {
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			switch x := item.(type) {
+			case string:
+				parts[i] = "'" + strings.ReplaceAll(x, "'", `'\''`) + "'"
+			case []interface{}, *jqObject:
+				return "", jqErrorf("%s can not be escaped for shell", jqDescribe(item))
+			default:
+				parts[i], _ = jqToString(ev, x)
+			}
+		}
+		return strings.Join(parts, " "), nil
+	},
+	"base64": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		return base64.StdEncoding.EncodeToString([]byte(s)), err
+	},
+	"base64d": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		if err != nil {
+			return "", err
+		}
+		out, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return "", jqErrorf("%s is not valid base64 data", jqDescribe(v))
+		}
+		return strings.ToValidUTF8(string(out), "�"), nil
+	},
+	"base32": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		return base32.StdEncoding.EncodeToString([]byte(s)), err
+	},
+	"base32d": func(ev *jqEval, v interface{}) (string, error) {
+		s, err := jqToString(ev, v)
+		if err != nil {
+			return "", err
+		}
+		out, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return "", jqErrorf("%s is not valid base32 data", jqDescribe(v))
+		}
+		return strings.ToValidUTF8(string(out), "�"), nil
+	},
+}
+
+var (
+	jqHTMLEscaper = strings.NewReplacer("<", "&lt;", ">", "&gt;", "&", "&amp;", "'", "&#39;", `"`, "&quot;")
+	jqTSVEscaper  = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+func jqApplyFormat(ev *jqEval, name string, v interface{}) (string, error) {
+	return jqFormats[name](ev, v)
+}
+
+// jqRow formats an array as a CSV or TSV row; quote escapes strings.
+func jqRow(ev *jqEval, v interface{}, name, sep string, quote func(string) string) (string, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return "", jqErrorf("%s cannot be %s-formatted, only an array can be", jqDescribe(v), name)
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		switch x := item.(type) {
+		case nil:
+		case string:
+			parts[i] = quote(x)
+		case bool, float64, json.Number:
+			parts[i], _ = jqToString(ev, x)
+		default:
+			return "", jqErrorf("%s is not valid in a csv row", jqDescribe(item))
+		}
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// Dates are "broken down time" arrays as in jq: [year, month (0-11), day, hours,
+// minutes, seconds, weekday, day of the year (0-365)], always in UTC.
+
+func jqGmtime(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	f, ok := jqNumber(in)
+	if !ok {
+		return nil, jqErrorf("gmtime() requires a number")
+	}
+	sec, frac := math.Modf(f)
+	t := time.Unix(int64(sec), 0).UTC()
+	return jqBrokenDown(t, float64(t.Second())+frac), nil
+}
+
+func jqBrokenDown(t time.Time, seconds float64) []interface{} {
+	return []interface{}{
+		float64(t.Year()), float64(t.Month() - 1), float64(t.Day()),
+		float64(t.Hour()), float64(t.Minute()), seconds,
+		float64(t.Weekday()), float64(t.YearDay() - 1),
+	}
+}
+
+// jqTime converts a broken down time array to a time.
+func jqTime(in interface{}, name string) (time.Time, error) {
+	arr, ok := in.([]interface{})
+	if !ok || len(arr) < 6 {
+		return time.Time{}, jqErrorf("%s requires array of 6 numbers", name)
+	}
+	var n [6]float64
+	for i := range n {
+		if n[i], ok = jqNumber(arr[i]); !ok {
+			return time.Time{}, jqErrorf("%s requires parsed datetime inputs", name)
+		}
+	}
+	sec, frac := math.Modf(n[5])
+	return time.Date(int(n[0]), time.Month(n[1]+1), int(n[2]), int(n[3]), int(n[4]), int(sec), int(frac*1e9), time.UTC), nil
+}
+
+func jqMktime(_ *jqEval, in interface{}, _ []interface{}) (interface{}, error) {
+	t, err := jqTime(in, "mktime")
+	if err != nil {
+		return nil, err
+	}
+	return float64(t.Unix()), nil
+}
+
+// jqStrftimeDirectives maps strftime conversions to Go layouts.
+var jqStrftimeDirectives = map[byte]string{
+	'Y': "2006", 'm': "01", 'd': "02", 'e': "_2", 'H': "15", 'I': "03", 'M': "04",
+	'S': "05", 'y': "06", 'j': "002", 'a': "Mon", 'A': "Monday", 'b': "Jan", 'h': "Jan",
+	'B': "January", 'p': "PM", 'Z': "MST", 'z': "-0700", 'T': "15:04:05",
+	'F': "2006-01-02", 'D': "01/02/06", 'R': "15:04", 'c': "Mon Jan _2 15:04:05 2006",
+}
+
+func jqStrftime(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	format, ok := args[0].(string)
+	if !ok {
+		return nil, jqErrorf("strftime/1 requires a string format")
+	}
+	if _, isNum := jqNumber(in); isNum {
+		in, _ = jqGmtime(nil, in, nil)
+	}
+	t, err := jqTime(in, "strftime/1")
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case '%':
+			b.WriteByte('%')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'u':
+			wd := int(t.Weekday())
+			if wd == 0 {
+				wd = 7
+			}
+			b.WriteString(strconv.Itoa(wd))
+		case 'w':
+			b.WriteString(strconv.Itoa(int(t.Weekday())))
+		case 'C':
+			fmt.Fprintf(&b, "%02d", t.Year()/100)
+		case 'k':
+			fmt.Fprintf(&b, "%2d", t.Hour())
+		default:
+			layout, ok := jqStrftimeDirectives[c]
+			if !ok {
+				return nil, jqErrorf("strftime/1: unsupported conversion %%%c", c)
+			}
+			b.WriteString(t.Format(layout))
+		}
+	}
+	return b.String(), nil
+}
+
+func jqStrptime(_ *jqEval, in interface{}, args []interface{}) (interface{}, error) {
+	s, ok1 := in.(string)
+	format, ok2 := args[0].(string)
+	if !ok1 || !ok2 {
+		return nil, jqErrorf("strptime/1 requires string inputs and arguments")
+	}
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			layout.WriteByte(format[i])
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			layout.WriteByte('%')
+			continue
+		}
+		l, ok := jqStrftimeDirectives[format[i]]
+		if !ok || format[i] == 'c' {
+			return nil, jqErrorf("strptime/1: unsupported conversion %%%c", format[i])
+		}
+		layout.WriteString(l)
+	}
+	t, err := time.Parse(layout.String(), s)
+	if err != nil {
+		return nil, jqErrorf("date \"%s\" does not match format \"%s\"", s, format)
+	}
+	t = t.UTC()
+	return jqBrokenDown(t, float64(t.Second())), nil
+}
@@ -0,0 +1,1235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// This file evaluates parsed jq filters. Evaluation is in continuation-passing style:
+// eval calls emit once per output, so generators such as .[] or range stream their
+// results instead of building lists. Every eval call is one step of the budget in
+// limits.JSON.JQSteps, and the request context is checked as steps accumulate. Strings
+// and arrays built by + and * are capped separately by limits.JSON.JQValueSize, since
+// one step can allocate far more than the budget suggests.
+
+// jqMaxCallDepth bounds the nesting of function calls, so runaway recursion fails with
+// an error instead of exhausting the stack.
+const jqMaxCallDepth = 10000
+
+// jqVal is a value flowing through a filter. path records where the value was found
+// while a path expression is evaluated (path(f), assignments, del); it is nil otherwise.
+type jqVal struct {
+	v    interface{}
+	path *jqPath
+}
+
+// jqPath is a path as a list from the last key back to the root, jqRootPath.
+type jqPath struct {
+	parent *jqPath
+	key    interface{}
+}
+
+var jqRootPath = &jqPath{}
+
+func (p *jqPath) extend(key interface{}) *jqPath {
+	if p == nil {
+		return nil
+	}
+	return &jqPath{parent: p, key: key}
+}
+
+// keys returns the path as an array of keys, root first.
+func (p *jqPath) keys() []interface{} {
+	n := 0
+	for q := p; q != jqRootPath; q = q.parent {
+		n++
+	}
+	keys := make([]interface{}, n)
+	for q := p; q != jqRootPath; q = q.parent {
+		n--
+		keys[n] = q.key
+	}
+	return keys
+}
+
+// jqError is an error raised by a filter, either by error/1 or by an operation on the
+// wrong type. try and ? catch it; value is what the catch handler receives.
+type jqError struct {
+	value interface{}
+}
+
+func (e *jqError) Error() string { return e.message() }
+
+func (e *jqError) message() string {
+	if s, ok := e.value.(string); ok {
+		return s
+	}
+	return jqDump(e.value) + " (not a string)"
+}
+
+func jqErrorf(format string, args ...interface{}) error {
+	return &jqError{value: fmt.Sprintf(format, args...)}
+}
+
+// jqDump formats v as compact JSON for error messages, shortened when long.
+func jqDump(v interface{}) string {
+	out, err := (&jqEval{}).encode(v, "")
+	if err != nil {
+		return jqTypeName(v)
+	}
+	if len(out) > 30 {
+		return string(out[:27]) + "..."
+	}
+	return string(out)
+}
+
+// jqDescribe formats v as jq does in type errors: its type and a short dump.
+func jqDescribe(v interface{}) string {
+	return fmt.Sprintf("%s (%s)", jqTypeName(v), jqDump(v))
+}
+
+// jqBreakSignal unwinds evaluation to the label it names. It also stops generators
+// early (first, limit, ...) with a label that only the caller knows.
+type jqBreakSignal struct {
+	label *int
+}
+
+func (b *jqBreakSignal) Error() string { return "break" }
+
+// jqDownstream wraps an error returned by emit inside try or //, so errors raised by
+// later stages of the pipeline pass through instead of being caught.
+type jqDownstream struct {
+	err   error
+	owner *int
+}
+
+func (d *jqDownstream) Error() string { return d.err.Error() }
+
+// jqEnv is the runtime scope: a chain of variables, functions and labels.
+type jqEnv struct {
+	parent *jqEnv
+	kind   byte // 'v', 'f' or 'l', as in jqScope
+	name   string
+	arity  int
+	value  jqVal      // a variable's value
+	fn     *jqClosure // a function
+	label  *int       // a label's identity
+}
+
+// jqClosure is a function together with the scope it was defined in. A closure with
+// no def is a filter argument: body is evaluated in env, the caller's scope.
+type jqClosure struct {
+	def  *jqFuncDef
+	body jqExpr
+	env  *jqEnv
+}
+
+func (env *jqEnv) lookup(kind byte, name string, arity int) *jqEnv {
+	for e := env; e != nil; e = e.parent {
+		if e.kind == kind && e.name == name && e.arity == arity {
+			return e
+		}
+	}
+	return nil
+}
+
+// jqEval holds the state of one run: the budget, the call depth and the inputs not yet
+// read by input or inputs.
+type jqEval struct {
+	ctx       context.Context
+	maxSteps  int
+	maxSize   int
+	steps     int
+	nextCheck int
+	depth     int
+	inputs    []interface{}
+	regexps   map[string]*regexp.Regexp
+}
+
+func newJQEval(ctx context.Context, maxSteps, maxSize int) *jqEval {
+	return &jqEval{ctx: ctx, maxSteps: maxSteps, maxSize: maxSize}
+}
+
+// step charges n steps to the budget. A zero maxSteps or nil ctx is unlimited, which
+// is how helpers like jqDump use an evaluator.
+func (ev *jqEval) step(n int) error {
+	ev.steps += n
+	if ev.maxSteps > 0 && ev.steps > ev.maxSteps {
+		e := newError(CodeTooMany, "filter", fmt.Sprintf("filter takes more than %d steps", ev.maxSteps))
+		e.Details = map[string]interface{}{"max": ev.maxSteps}
+		return e
+	}
+	if ev.ctx != nil && ev.steps >= ev.nextCheck {
+		ev.nextCheck = ev.steps + 1024
+		return checkContext(ev.ctx)
+	}
+	return nil
+}
+
+// checkSize rejects a string of n bytes or an array of n items before it is built. A
+// zero maxSize is unlimited.
+func (ev *jqEval) checkSize(n float64, what string) error {
+	if ev.maxSize > 0 && n > float64(ev.maxSize) {
+		e := newError(CodeTooMany, "filter", fmt.Sprintf("filter builds %s longer than %d", what, ev.maxSize))
+		e.Details = map[string]interface{}{"max": ev.maxSize}
+		return e
+	}
+	return nil
+}
+
+func (ev *jqEval) eval(e jqExpr, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	if err := ev.step(1); err != nil {
+		return err
+	}
+	switch e := e.(type) {
+	case *jqIdentity:
+		return emit(in)
+	case *jqLiteral:
+		return emit(jqVal{v: e.value})
+	case *jqVar:
+		if b := env.lookup('v', e.name, 0); b != nil {
+			return emit(b.value)
+		}
+		// $ENV: the server's environment is not exposed.
+		return emit(jqVal{v: newJQObject(0)})
+	case *jqIndex:
+		return ev.eval(e.target, in, env, func(t jqVal) error {
+			return ev.eval(e.key, in, env, func(k jqVal) error {
+				v, err := jqIndexValue(t.v, k.v)
+				if err != nil {
+					return err
+				}
+				return emit(jqVal{v: v, path: t.path.extend(k.v)})
+			})
+		})
+	case *jqSlice:
+		return ev.eval(e.target, in, env, func(t jqVal) error {
+			return ev.evalOptional(e.to, in, env, func(to jqVal) error {
+				return ev.evalOptional(e.from, in, env, func(from jqVal) error {
+					key := newJQObject(2)
+					key.put("start", from.v)
+					key.put("end", to.v)
+					v, err := jqIndexValue(t.v, key)
+					if err != nil {
+						return err
+					}
+					return emit(jqVal{v: v, path: t.path.extend(key)})
+				})
+			})
+		})
+	case *jqIterate:
+		return ev.eval(e.target, in, env, func(t jqVal) error {
+			return ev.iterate(t, emit)
+		})
+	case *jqTry:
+		return ev.try(e, in, env, emit)
+	case *jqArray:
+		arr := []interface{}{}
+		if e.body != nil {
+			err := ev.eval(e.body, in, env, func(v jqVal) error {
+				arr = append(arr, v.v)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return emit(jqVal{v: arr})
+	case *jqObjectDef:
+		return ev.object(e.entries, nil, in, env, emit)
+	case *jqNeg:
+		return ev.eval(e.expr, in, env, func(v jqVal) error {
+			f, ok := jqNumber(v.v)
+			if !ok {
+				return jqErrorf("%s cannot be negated", jqDescribe(v.v))
+			}
+			return emit(jqVal{v: -f})
+		})
+	case *jqPipe:
+		return ev.eval(e.left, in, env, func(v jqVal) error {
+			return ev.eval(e.right, v, env, emit)
+		})
+	case *jqComma:
+		if err := ev.eval(e.left, in, env, emit); err != nil {
+			return err
+		}
+		return ev.eval(e.right, in, env, emit)
+	case *jqBinary:
+		return ev.eval(e.right, in, env, func(r jqVal) error {
+			return ev.eval(e.left, in, env, func(l jqVal) error {
+				v, err := ev.binop(e.op, l.v, r.v)
+				if err != nil {
+					return err
+				}
+				return emit(jqVal{v: v})
+			})
+		})
+	case *jqAnd:
+		return ev.eval(e.left, in, env, func(l jqVal) error {
+			if !jqTruthy(l.v) {
+				return emit(jqVal{v: false})
+			}
+			return ev.eval(e.right, in, env, func(r jqVal) error {
+				return emit(jqVal{v: jqTruthy(r.v)})
+			})
+		})
+	case *jqOr:
+		return ev.eval(e.left, in, env, func(l jqVal) error {
+			if jqTruthy(l.v) {
+				return emit(jqVal{v: true})
+			}
+			return ev.eval(e.right, in, env, func(r jqVal) error {
+				return emit(jqVal{v: jqTruthy(r.v)})
+			})
+		})
+	case *jqAlt:
+		return ev.alternative(e, in, env, emit)
+	case *jqAssign:
+		return ev.assign(e, in, env, emit)
+	case *jqIf:
+		return ev.eval(e.cond, in, env, func(c jqVal) error {
+			if jqTruthy(c.v) {
+				return ev.eval(e.then, in, env, emit)
+			}
+			if e.els == nil {
+				return emit(in)
+			}
+			return ev.eval(e.els, in, env, emit)
+		})
+	case *jqReduce:
+		return ev.eval(e.init, in, env, func(state jqVal) error {
+			err := ev.eval(e.source, in, env, func(item jqVal) error {
+				return ev.bind(e.pattern, item, in, env, func(env *jqEnv) error {
+					next := jqVal{}
+					err := ev.eval(e.update, state, env, func(v jqVal) error {
+						next = v
+						return nil
+					})
+					state = next
+					return err
+				})
+			})
+			if err != nil {
+				return err
+			}
+			return emit(state)
+		})
+	case *jqForeach:
+		return ev.eval(e.init, in, env, func(state jqVal) error {
+			return ev.eval(e.source, in, env, func(item jqVal) error {
+				return ev.bind(e.pattern, item, in, env, func(env *jqEnv) error {
+					return ev.eval(e.update, state, env, func(v jqVal) error {
+						state = v
+						if e.extract == nil {
+							return emit(v)
+						}
+						return ev.eval(e.extract, v, env, emit)
+					})
+				})
+			})
+		})
+	case *jqBind:
+		return ev.eval(e.source, in, env, func(v jqVal) error {
+			return ev.bind(e.pattern, v, in, env, func(env *jqEnv) error {
+				return ev.eval(e.body, in, env, emit)
+			})
+		})
+	case *jqLabel:
+		label := new(int)
+		err := ev.eval(e.body, in, &jqEnv{parent: env, kind: 'l', name: e.name, label: label}, emit)
+		if b, ok := err.(*jqBreakSignal); ok && b.label == label {
+			return nil
+		}
+		return err
+	case *jqBreak:
+		return &jqBreakSignal{label: env.lookup('l', e.name, 0).label}
+	case *jqDefs:
+		for _, def := range e.defs {
+			env = &jqEnv{parent: env, kind: 'f', name: def.name, arity: len(def.params), fn: &jqClosure{def: def}}
+			env.fn.env = env // in scope in its own body, for recursion
+		}
+		return ev.eval(e.body, in, env, emit)
+	case *jqCall:
+		switch {
+		case e.native != nil:
+			return e.native.fn(ev, in, e.args, env, emit)
+		case e.prelude != nil:
+			return ev.call(e.prelude, nil, e.args, in, env, emit)
+		}
+		f := env.lookup('f', e.name, len(e.args)).fn
+		if f.def == nil {
+			return ev.eval(f.body, in, f.env, emit)
+		}
+		return ev.call(f.def, f.env, e.args, in, env, emit)
+	case *jqString:
+		return ev.interpolate(e, len(e.parts)-1, "", in, env, emit)
+	case *jqFormat:
+		s, err := jqApplyFormat(ev, e.name, in.v)
+		if err != nil {
+			return err
+		}
+		return emit(jqVal{v: s})
+	}
+	return fmt.Errorf("jq: cannot evaluate %T", e)
+}
+
+// evalOptional evaluates e, or emits null when e is nil (an omitted slice bound).
+func (ev *jqEval) evalOptional(e jqExpr, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	if e == nil {
+		return emit(jqVal{})
+	}
+	return ev.eval(e, in, env, emit)
+}
+
+// call runs a function defined in defEnv with filter arguments from the caller's scope.
+func (ev *jqEval) call(def *jqFuncDef, defEnv *jqEnv, args []jqExpr, in jqVal, callerEnv *jqEnv, emit func(jqVal) error) error {
+	if ev.depth >= jqMaxCallDepth {
+		return jqErrorf("function calls nest more than %d levels", jqMaxCallDepth)
+	}
+	ev.depth++
+	defer func() { ev.depth-- }()
+	env := defEnv
+	for i, param := range def.params {
+		env = &jqEnv{parent: env, kind: 'f', name: param, fn: &jqClosure{body: args[i], env: callerEnv}}
+	}
+	return ev.eval(def.body, in, env, emit)
+}
+
+func (ev *jqEval) iterate(t jqVal, emit func(jqVal) error) error {
+	switch c := t.v.(type) {
+	case []interface{}:
+		for i, v := range c {
+			if err := emit(jqVal{v: v, path: t.path.extend(float64(i))}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *jqObject:
+		for _, k := range c.keys {
+			if err := emit(jqVal{v: c.vals[k], path: t.path.extend(k)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return jqErrorf("Cannot iterate over null")
+	}
+	return jqErrorf("Cannot iterate over %s", jqDescribe(t.v))
+}
+
+// try runs "try body catch handler" and "body?": errors raised by body are caught, but
+// errors raised downstream of it (by emit) are not.
+func (ev *jqEval) try(e *jqTry, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	owner := new(int)
+	err := ev.eval(e.body, in, env, func(v jqVal) error {
+		if err := emit(v); err != nil {
+			return &jqDownstream{err: err, owner: owner}
+		}
+		return nil
+	})
+	if d, ok := err.(*jqDownstream); ok && d.owner == owner {
+		return d.err
+	}
+	je, ok := err.(*jqError)
+	if !ok {
+		return err
+	}
+	if e.catch == nil {
+		return nil
+	}
+	return ev.eval(e.catch, jqVal{v: je.value}, env, emit)
+}
+
+// alternative runs "a // b": the truthy outputs of a, or the outputs of b when there are
+// none. Errors raised by a are ignored.
+func (ev *jqEval) alternative(e *jqAlt, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	owner := new(int)
+	found := false
+	err := ev.eval(e.left, in, env, func(v jqVal) error {
+		if !jqTruthy(v.v) {
+			return nil
+		}
+		found = true
+		if err := emit(v); err != nil {
+			return &jqDownstream{err: err, owner: owner}
+		}
+		return nil
+	})
+	if d, ok := err.(*jqDownstream); ok && d.owner == owner {
+		return d.err
+	}
+	if _, ok := err.(*jqError); err != nil && !ok {
+		return err
+	}
+	if found {
+		return nil
+	}
+	return ev.eval(e.right, in, env, emit)
+}
+
+// object builds every combination of the entries' keys and values; pairs holds the
+// keys and values chosen so far.
+func (ev *jqEval) object(entries []jqEntry, pairs []interface{}, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	if len(entries) == 0 {
+		obj := newJQObject(len(pairs) / 2)
+		for i := 0; i < len(pairs); i += 2 {
+			obj.put(pairs[i].(string), pairs[i+1])
+		}
+		return emit(jqVal{v: obj})
+	}
+	return ev.eval(entries[0].key, in, env, func(k jqVal) error {
+		key, ok := k.v.(string)
+		if !ok {
+			return jqErrorf("Object keys must be strings")
+		}
+		return ev.eval(entries[0].value, in, env, func(v jqVal) error {
+			next := append(pairs[:len(pairs):len(pairs)], key, v.v)
+			return ev.object(entries[1:], next, in, env, emit)
+		})
+	})
+}
+
+// interpolate builds the outputs of a string with \(...) parts, from the last part to
+// the first as jq does; tail is the text after part i.
+func (ev *jqEval) interpolate(s *jqString, i int, tail string, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	if i < 0 {
+		return emit(jqVal{v: tail})
+	}
+	if text, ok := s.parts[i].(string); ok {
+		return ev.interpolate(s, i-1, text+tail, in, env, emit)
+	}
+	return ev.eval(s.parts[i].(jqExpr), in, env, func(v jqVal) error {
+		format := s.format
+		if format == "" {
+			format = "text"
+		}
+		text, err := jqApplyFormat(ev, format, v.v)
+		if err != nil {
+			return err
+		}
+		if err := ev.step(len(text) / 64); err != nil {
+			return err
+		}
+		return ev.interpolate(s, i-1, text+tail, in, env, emit)
+	})
+}
+
+// bind matches v against pattern and calls fn with the variables bound. A pattern
+// whose object keys are generators binds once per key.
+func (ev *jqEval) bind(pat *jqPattern, v jqVal, in jqVal, env *jqEnv, fn func(*jqEnv) error) error {
+	if pat.name != "" {
+		env = &jqEnv{parent: env, kind: 'v', name: pat.name, value: v}
+	}
+	switch {
+	case pat.array != nil:
+		if _, ok := v.v.([]interface{}); !ok && v.v != nil {
+			return jqErrorf("Cannot index %s with number", jqTypeName(v.v))
+		}
+		return ev.bindElements(pat.array, 0, v, in, env, fn)
+	case pat.object != nil:
+		return ev.bindEntries(pat.object, v, in, env, fn)
+	}
+	return fn(env)
+}
+
+func (ev *jqEval) bindElements(pats []*jqPattern, i int, v jqVal, in jqVal, env *jqEnv, fn func(*jqEnv) error) error {
+	if i == len(pats) {
+		return fn(env)
+	}
+	elem, err := jqIndexValue(v.v, float64(i))
+	if err != nil {
+		return err
+	}
+	return ev.bind(pats[i], jqVal{v: elem, path: v.path.extend(float64(i))}, in, env, func(inner *jqEnv) error {
+		return ev.bindElements(pats, i+1, v, in, inner, fn)
+	})
+}
+
+func (ev *jqEval) bindEntries(entries []jqPatternEntry, v jqVal, in jqVal, env *jqEnv, fn func(*jqEnv) error) error {
+	if len(entries) == 0 {
+		return fn(env)
+	}
+	return ev.eval(entries[0].key, in, env, func(k jqVal) error {
+		key, ok := k.v.(string)
+		if !ok {
+			return jqErrorf("Cannot index %s with %s", jqTypeName(v.v), jqTypeName(k.v))
+		}
+		elem, err := jqIndexValue(v.v, key)
+		if err != nil {
+			return err
+		}
+		return ev.bind(entries[0].pattern, jqVal{v: elem, path: v.path.extend(key)}, in, env, func(inner *jqEnv) error {
+			return ev.bindEntries(entries[1:], v, in, inner, fn)
+		})
+	})
+}
+
+// assign runs the assignment operators. "lhs = rhs" sets every path lhs selects to a
+// value of rhs (computed against .), once per value; "lhs |= f" replaces each selected
+// value with the first output of f, deleting it when f is empty; "lhs op= rhs" is
+// lhs |= . op $v for each value $v of rhs.
+func (ev *jqEval) assign(e *jqAssign, in jqVal, env *jqEnv, emit func(jqVal) error) error {
+	if e.op == "|=" {
+		out, err := ev.update(e.lhs, in, env, func(old interface{}) (interface{}, bool, error) {
+			return ev.first(e.rhs, jqVal{v: old}, env)
+		})
+		if err != nil {
+			return err
+		}
+		return emit(jqVal{v: out})
+	}
+	return ev.eval(e.rhs, in, env, func(rhs jqVal) error {
+		out, err := ev.update(e.lhs, in, env, func(old interface{}) (interface{}, bool, error) {
+			switch e.op {
+			case "=":
+				return rhs.v, true, nil
+			case "//=":
+				if jqTruthy(old) {
+					return old, true, nil
+				}
+				return rhs.v, true, nil
+			}
+			v, err := ev.binop(strings.TrimSuffix(e.op, "="), old, rhs.v)
+			return v, true, err
+		})
+		if err != nil {
+			return err
+		}
+		return emit(jqVal{v: out})
+	})
+}
+
+// update replaces the value at each path lhs selects in in. When fn reports no value the
+// path is deleted after all updates are done.
+func (ev *jqEval) update(lhs jqExpr, in jqVal, env *jqEnv, fn func(old interface{}) (interface{}, bool, error)) (interface{}, error) {
+	paths, err := ev.paths(lhs, in.v, env)
+	if err != nil {
+		return nil, err
+	}
+	root := in.v
+	var deletes []interface{}
+	for _, p := range paths {
+		old, err := ev.getpath(root, p)
+		if err != nil {
+			return nil, err
+		}
+		v, ok, err := fn(old)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			deletes = append(deletes, p)
+			continue
+		}
+		if root, err = ev.setpath(root, p, v); err != nil {
+			return nil, err
+		}
+	}
+	if len(deletes) > 0 {
+		return ev.delpaths(root, deletes)
+	}
+	return root, nil
+}
+
+// paths evaluates a path expression against v and returns the path of each output.
+func (ev *jqEval) paths(e jqExpr, v interface{}, env *jqEnv) ([][]interface{}, error) {
+	var paths [][]interface{}
+	err := ev.eval(e, jqVal{v: v, path: jqRootPath}, env, func(out jqVal) error {
+		if out.path == nil {
+			return jqErrorf("Invalid path expression with result %s", jqDump(out.v))
+		}
+		paths = append(paths, out.path.keys())
+		return nil
+	})
+	return paths, err
+}
+
+// first returns the first output of e; ok is false when e has none.
+func (ev *jqEval) first(e jqExpr, in jqVal, env *jqEnv) (interface{}, bool, error) {
+	stop := &jqBreakSignal{label: new(int)}
+	var out interface{}
+	found := false
+	err := ev.eval(e, in, env, func(v jqVal) error {
+		out, found = v.v, true
+		return stop
+	})
+	if err != nil && err != stop {
+		return nil, false, err
+	}
+	return out, found, nil
+}
+
+// collect returns every output of e.
+func (ev *jqEval) collect(e jqExpr, in jqVal, env *jqEnv) ([]interface{}, error) {
+	var out []interface{}
+	err := ev.eval(e, in, env, func(v jqVal) error {
+		out = append(out, v.v)
+		return nil
+	})
+	return out, err
+}
+
+// jqIndexValue returns t[k]: an object member, an array element (negative indices count
+// from the end), a slice (k is {"start", "end"}) or, for an array k, the indices at
+// which k occurs in t. Indexing null gives null.
+func jqIndexValue(t, k interface{}) (interface{}, error) {
+	if obj, ok := k.(*jqObject); ok {
+		if _, isSlice := obj.get("start"); isSlice {
+			return jqSliceValue(t, obj)
+		}
+	}
+	switch c := t.(type) {
+	case nil:
+		switch k.(type) {
+		case nil, string, float64, json.Number:
+			return nil, nil
+		}
+	case *jqObject:
+		if key, ok := k.(string); ok {
+			v, _ := c.get(key)
+			return v, nil
+		}
+	case []interface{}:
+		if f, ok := jqNumber(k); ok {
+			if math.IsNaN(f) {
+				return nil, nil
+			}
+			i := math.Floor(f)
+			if i < 0 {
+				i += float64(len(c))
+			}
+			if i < 0 || i >= float64(len(c)) {
+				return nil, nil
+			}
+			return c[int(i)], nil
+		}
+		if sub, ok := k.([]interface{}); ok {
+			return jqArrayIndices(c, sub), nil
+		}
+	}
+	if key, ok := k.(string); ok {
+		return nil, jqErrorf("Cannot index %s with %q", jqTypeName(t), key)
+	}
+	return nil, jqErrorf("Cannot index %s with %s", jqTypeName(t), jqTypeName(k))
+}
+
+// jqSliceValue returns t[start:end] for an array, a string (by code point) or null.
+func jqSliceValue(t interface{}, bounds *jqObject) (interface{}, error) {
+	var n int
+	switch c := t.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		n = len(c)
+	case string:
+		n = utf8RuneCount(c)
+	default:
+		return nil, jqErrorf("Cannot index %s with object", jqTypeName(t))
+	}
+	start, end, err := jqSliceBounds(bounds, n)
+	if err != nil {
+		return nil, err
+	}
+	if arr, ok := t.([]interface{}); ok {
+		return arr[start:end:end], nil
+	}
+	return string([]rune(t.(string))[start:end]), nil
+}
+
+// jqSliceBounds resolves slice bounds against a length n: null means the end, negative
+// counts from the end, fractional start rounds down and end rounds up.
+func jqSliceBounds(bounds *jqObject, n int) (int, int, error) {
+	resolve := func(v interface{}, def float64, round func(float64) float64) (int, error) {
+		if v == nil {
+			return int(def), nil
+		}
+		f, ok := jqNumber(v)
+		if !ok {
+			return 0, jqErrorf("Start and end indices of an array slice must be numbers")
+		}
+		f = round(f)
+		if f < 0 {
+			f += float64(n)
+		}
+		return int(math.Max(0, math.Min(float64(n), f))), nil
+	}
+	s, _ := bounds.get("start")
+	e, _ := bounds.get("end")
+	start, err := resolve(s, 0, math.Floor)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := resolve(e, float64(n), math.Ceil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, nil
+}
+
+// jqArrayIndices returns the positions at which sub occurs in arr.
+func jqArrayIndices(arr, sub []interface{}) interface{} {
+	if len(sub) == 0 {
+		return nil
+	}
+	out := []interface{}{}
+	ev := &jqEval{}
+	for i := 0; i+len(sub) <= len(arr); i++ {
+		match := true
+		for j := range sub {
+			if c, _ := ev.compare(arr[i+j], sub[j]); c != 0 {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, float64(i))
+		}
+	}
+	return out
+}
+
+func utf8RuneCount(s string) int {
+	n := 0
+	for range s {
+		n++
+	}
+	return n
+}
+
+// binop applies an arithmetic or comparison operator.
+func (ev *jqEval) binop(op string, l, r interface{}) (interface{}, error) {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		c, err := ev.compare(l, r)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return c == 0, nil
+		case "!=":
+			return c != 0, nil
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	}
+	x, lnum := jqNumber(l)
+	y, rnum := jqNumber(r)
+	if lnum && rnum {
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return nil, jqErrorf("%s and %s cannot be divided because the divisor is zero", jqDescribe(l), jqDescribe(r))
+			}
+			return x / y, nil
+		case "%":
+			a, b := jqToInt(x), jqToInt(y)
+			if b == 0 {
+				return nil, jqErrorf("%s and %s cannot be divided because the divisor is zero", jqDescribe(l), jqDescribe(r))
+			}
+			if b < 0 {
+				b = -b
+			}
+			return float64(a % b), nil
+		}
+	}
+	switch op {
+	case "+":
+		return ev.add(l, r)
+	case "-":
+		if a, ok := l.([]interface{}); ok {
+			if b, ok := r.([]interface{}); ok {
+				out := []interface{}{}
+				for _, x := range a {
+					keep := true
+					for _, y := range b {
+						c, err := ev.compare(x, y)
+						if err != nil {
+							return nil, err
+						}
+						if c == 0 {
+							keep = false
+							break
+						}
+					}
+					if keep {
+						out = append(out, x)
+					}
+				}
+				return out, nil
+			}
+		}
+		return nil, jqErrorf("%s and %s cannot be subtracted", jqDescribe(l), jqDescribe(r))
+	case "*":
+		if s, ok := l.(string); ok && rnum {
+			return ev.repeat(s, y)
+		}
+		if s, ok := r.(string); ok && lnum {
+			return ev.repeat(s, x)
+		}
+		a, aok := l.(*jqObject)
+		b, bok := r.(*jqObject)
+		if aok && bok {
+			return ev.deepMerge(a, b)
+		}
+		return nil, jqErrorf("%s and %s cannot be multiplied", jqDescribe(l), jqDescribe(r))
+	case "/":
+		a, aok := l.(string)
+		b, bok := r.(string)
+		if aok && bok {
+			return jqSplit(a, b), nil
+		}
+		return nil, jqErrorf("%s and %s cannot be divided", jqDescribe(l), jqDescribe(r))
+	}
+	return nil, jqErrorf("%s and %s cannot be divided", jqDescribe(l), jqDescribe(r))
+}
+
+// jqToInt truncates f to an integer, saturating instead of overflowing.
+func jqToInt(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// add implements + for everything but two numbers: null is the identity, strings and
+// arrays concatenate and objects merge (right wins).
+func (ev *jqEval) add(l, r interface{}) (interface{}, error) {
+	if l == nil {
+		return r, nil
+	}
+	if r == nil {
+		return l, nil
+	}
+	switch a := l.(type) {
+	case string:
+		if b, ok := r.(string); ok {
+			if err := ev.checkSize(float64(len(a)+len(b)), "a string"); err != nil {
+				return nil, err
+			}
+			if err := ev.step((len(a) + len(b)) / 64); err != nil {
+				return nil, err
+			}
+			return a + b, nil
+		}
+	case []interface{}:
+		if b, ok := r.([]interface{}); ok {
+			if err := ev.checkSize(float64(len(a)+len(b)), "an array"); err != nil {
+				return nil, err
+			}
+			if err := ev.step(len(a) + len(b)); err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, 0, len(a)+len(b))
+			return append(append(out, a...), b...), nil
+		}
+	case *jqObject:
+		if b, ok := r.(*jqObject); ok {
+			if err := ev.step(a.len() + b.len()); err != nil {
+				return nil, err
+			}
+			out := a.copy()
+			for _, k := range b.keys {
+				out.put(k, b.vals[k])
+			}
+			return out, nil
+		}
+	}
+	return nil, jqErrorf("%s and %s cannot be added", jqDescribe(l), jqDescribe(r))
+}
+
+// repeat implements string * number: n copies of s (rounded up), or null when n <= 0.
+func (ev *jqEval) repeat(s string, n float64) (interface{}, error) {
+	if n <= 0 || math.IsNaN(n) {
+		return nil, nil
+	}
+	count := math.Ceil(n)
+	size := count * float64(len(s))
+	if err := ev.checkSize(size, "a string"); err != nil {
+		return nil, err
+	}
+	if ev.maxSteps > 0 && size/64 > float64(ev.maxSteps) {
+		return nil, ev.step(ev.maxSteps + 1)
+	}
+	if err := ev.step(int(size) / 64); err != nil {
+		return nil, err
+	}
+	return strings.Repeat(s, int(count)), nil
+}
+
+// deepMerge implements object * object: like + but merging nested objects.
+func (ev *jqEval) deepMerge(a, b *jqObject) (*jqObject, error) {
+	if err := ev.step(a.len() + b.len()); err != nil {
+		return nil, err
+	}
+	out := a.copy()
+	for _, k := range b.keys {
+		bv := b.vals[k]
+		if ao, ok := out.vals[k].(*jqObject); ok {
+			if bo, ok := bv.(*jqObject); ok {
+				merged, err := ev.deepMerge(ao, bo)
+				if err != nil {
+					return nil, err
+				}
+				out.put(k, merged)
+				continue
+			}
+		}
+		out.put(k, bv)
+	}
+	return out, nil
+}
+
+// jqSplit splits s on sep; an empty s gives an empty array, as in jq.
+func jqSplit(s, sep string) []interface{} {
+	out := []interface{}{}
+	if s == "" {
+		return out
+	}
+	for _, part := range strings.Split(s, sep) {
+		out = append(out, part)
+	}
+	return out
+}
+
+// getpath returns the value at path in v; missing members and elements are null.
+func (ev *jqEval) getpath(v interface{}, path []interface{}) (interface{}, error) {
+	for _, k := range path {
+		if v == nil {
+			return nil, nil
+		}
+		var err error
+		if v, err = jqIndexValue(v, k); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// setpath returns a copy of root with the value at path replaced by v, creating
+// objects and arrays (padded with null) as needed.
+func (ev *jqEval) setpath(root interface{}, path []interface{}, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	if err := ev.step(1); err != nil {
+		return nil, err
+	}
+	k := path[0]
+	switch key := k.(type) {
+	case string:
+		var obj *jqObject
+		switch c := root.(type) {
+		case nil:
+			obj = newJQObject(1)
+		case *jqObject:
+			obj = c
+		default:
+			return nil, jqErrorf("Cannot index %s with %q", jqTypeName(root), key)
+		}
+		if err := ev.step(obj.len()); err != nil {
+			return nil, err
+		}
+		child, _ := obj.get(key)
+		nv, err := ev.setpath(child, path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		return obj.with(key, nv), nil
+	case float64, json.Number:
+		var arr []interface{}
+		switch c := root.(type) {
+		case nil:
+		case []interface{}:
+			arr = c
+		default:
+			return nil, jqErrorf("Cannot index %s with number", jqTypeName(root))
+		}
+		f, _ := jqNumber(key)
+		if f >= math.MaxInt32 {
+			return nil, jqErrorf("Array index too large")
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += len(arr)
+			if i < 0 {
+				return nil, jqErrorf("Out of bounds negative array index")
+			}
+		}
+		size := len(arr)
+		if i >= size {
+			size = i + 1
+		}
+		if err := ev.step(size); err != nil {
+			return nil, err
+		}
+		out := make([]interface{}, size)
+		copy(out, arr)
+		var child interface{}
+		if i < len(arr) {
+			child = arr[i]
+		}
+		nv, err := ev.setpath(child, path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = nv
+		return out, nil
+	case *jqObject:
+		arr, ok := root.([]interface{})
+		if !ok && root != nil {
+			return nil, jqErrorf("Cannot update field at object index of %s", jqTypeName(root))
+		}
+		start, end, err := jqSliceBounds(key, len(arr))
+		if err != nil {
+			return nil, err
+		}
+		nv, err := ev.setpath(arr[start:end:end], path[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		repl, ok := nv.([]interface{})
+		if !ok {
+			return nil, jqErrorf("A slice of an array can only be assigned another array")
+		}
+		if err := ev.step(len(arr) + len(repl)); err != nil {
+			return nil, err
+		}
+		out := make([]interface{}, 0, len(arr)-(end-start)+len(repl))
+		out = append(append(append(out, arr[:start]...), repl...), arr[end:]...)
+		return out, nil
+	}
+	return nil, jqErrorf("Invalid path component %s", jqDump(k))
+}
+
+// delpaths returns a copy of root without the values at paths. Paths are deleted
+// longest and last first, so earlier deletions do not shift later array indices.
+func (ev *jqEval) delpaths(root interface{}, paths []interface{}) (interface{}, error) {
+	sorted := make([]interface{}, len(paths))
+	copy(sorted, paths)
+	if err := ev.sortValues(sorted); err != nil {
+		return nil, err
+	}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		p, ok := sorted[i].([]interface{})
+		if !ok {
+			return nil, jqErrorf("Path must be specified as an array")
+		}
+		var err error
+		if root, err = ev.delpath(root, p); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+func (ev *jqEval) delpath(root interface{}, path []interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	if root == nil {
+		return nil, nil
+	}
+	if err := ev.step(1); err != nil {
+		return nil, err
+	}
+	k := path[0]
+	last := len(path) == 1
+	switch c := root.(type) {
+	case *jqObject:
+		key, ok := k.(string)
+		if !ok {
+			return nil, jqErrorf("Cannot delete field at index of object")
+		}
+		child, present := c.get(key)
+		if !present {
+			return root, nil
+		}
+		if err := ev.step(c.len()); err != nil {
+			return nil, err
+		}
+		if last {
+			return c.without(key), nil
+		}
+		nv, err := ev.delpath(child, path[1:])
+		if err != nil {
+			return nil, err
+		}
+		return c.with(key, nv), nil
+	case []interface{}:
+		if err := ev.step(len(c)); err != nil {
+			return nil, err
+		}
+		if bounds, ok := k.(*jqObject); ok {
+			start, end, err := jqSliceBounds(bounds, len(c))
+			if err != nil {
+				return nil, err
+			}
+			if last {
+				out := make([]interface{}, 0, len(c)-(end-start))
+				return append(append(out, c[:start]...), c[end:]...), nil
+			}
+			nv, err := ev.delpath(c[start:end:end], path[1:])
+			if err != nil {
+				return nil, err
+			}
+			return ev.setpath(root, []interface{}{k}, nv)
+		}
+		f, ok := jqNumber(k)
+		if !ok {
+			return nil, jqErrorf("Cannot delete field at object index of array")
+		}
+		i := int(math.Floor(f))
+		if i < 0 {
+			i += len(c)
+		}
+		if i < 0 || i >= len(c) {
+			return root, nil
+		}
+		if last {
+			out := make([]interface{}, 0, len(c)-1)
+			return append(append(out, c[:i]...), c[i+1:]...), nil
+		}
+		nv, err := ev.delpath(c[i], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		out := append([]interface{}(nil), c...)
+		out[i] = nv
+		return out, nil
+	}
+	return nil, jqErrorf("Cannot delete field at %s of %s", jqDump(k), jqTypeName(root))
+}
+
+// jqPathKey converts a path component from user input (getpath, setpath, delpaths).
+func jqPathKey(k interface{}) interface{} {
+	if n, ok := k.(json.Number); ok {
+		f, _ := strconv.ParseFloat(string(n), 64)
+		return f
+	}
+	return k
+}
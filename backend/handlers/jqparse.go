@@ -0,0 +1,1297 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// This file parses jq filters into an expression tree (evaluated in jqeval.go). The
+// grammar and operator precedence follow jq 1.7, from loosest to tightest binding:
+// "|", ",", "//", the assignments (=, |=, +=, ...), "or", "and", the comparisons,
+// "+" and "-", "*", "/" and "%", unary minus, then postfix terms (.a, [i], [], ?).
+// Function calls are resolved while parsing, so unknown functions and variables are
+// compile errors with a position.
+
+type jqExpr interface{}
+
+type (
+	jqIdentity struct{}
+	jqLiteral  struct{ value interface{} }
+	// jqString is a string literal with \(...) interpolations; parts holds strings and
+	// expressions. format is the @name the interpolated values are rendered with.
+	jqString struct {
+		parts  []interface{}
+		format string
+	}
+	jqFormat struct{ name string } // @base64 etc. applied to .
+	jqIndex  struct {
+		target, key jqExpr
+	}
+	jqSlice struct {
+		target, from, to jqExpr // from and to are nil when omitted
+	}
+	jqIterate struct{ target jqExpr }
+	jqTry     struct {
+		body, catch jqExpr // catch is nil for "try body" and "body?"
+	}
+	jqArray     struct{ body jqExpr } // nil for []
+	jqObjectDef struct{ entries []jqEntry }
+	jqNeg       struct{ expr jqExpr }
+	jqPipe      struct{ left, right jqExpr }
+	jqComma     struct{ left, right jqExpr }
+	jqBinary    struct {
+		op          string // + - * / % == != < <= > >=
+		left, right jqExpr
+	}
+	jqAnd    struct{ left, right jqExpr }
+	jqOr     struct{ left, right jqExpr }
+	jqAlt    struct{ left, right jqExpr } // a // b
+	jqAssign struct {
+		op       string // = |= += -= *= /= %= //=
+		lhs, rhs jqExpr
+	}
+	jqIf struct {
+		cond, then, els jqExpr // els is nil when omitted
+	}
+	jqReduce struct {
+		source       jqExpr
+		pattern      *jqPattern
+		init, update jqExpr
+	}
+	jqForeach struct {
+		source                jqExpr
+		pattern               *jqPattern
+		init, update, extract jqExpr // extract is nil when omitted
+	}
+	jqBind struct {
+		source  jqExpr
+		pattern *jqPattern
+		body    jqExpr
+	}
+	jqVar   struct{ name string }
+	jqLabel struct {
+		name string
+		body jqExpr
+	}
+	jqBreak struct{ name string }
+	jqDefs  struct {
+		defs []*jqFuncDef
+		body jqExpr
+	}
+	jqCall struct {
+		name    string
+		args    []jqExpr
+		native  *jqNative  // a builtin implemented in Go
+		prelude *jqFuncDef // a builtin defined in jq (jqPrelude)
+		// neither: a function or closure parameter defined in the filter, looked up in
+		// the environment when called
+	}
+)
+
+// jqEntry is one key: value pair of an object construction.
+type jqEntry struct {
+	key, value jqExpr
+}
+
+// jqFuncDef is a function definition: def name(params): body;
+type jqFuncDef struct {
+	name   string
+	params []string
+	body   jqExpr
+}
+
+// jqPattern is the target of "as": a variable, or an array or object to destructure.
+type jqPattern struct {
+	name   string // $name
+	array  []*jqPattern
+	object []jqPatternEntry
+}
+
+type jqPatternEntry struct {
+	key     jqExpr
+	pattern *jqPattern
+}
+
+var jqKeywords = map[string]bool{
+	"def": true, "if": true, "then": true, "elif": true, "else": true, "end": true,
+	"as": true, "reduce": true, "foreach": true, "try": true, "catch": true,
+	"label": true, "import": true, "include": true, "and": true, "or": true,
+	"__loc__": true,
+}
+
+// jqScope is one name visible while parsing: a variable, a function or a label.
+type jqScope struct {
+	kind  byte // 'v', 'f' or 'l'
+	name  string
+	arity int
+}
+
+type jqParser struct {
+	src   string
+	pos   int
+	depth int
+	scope []jqScope
+	// lastTerm is the most recent postfix term, so "Term as $x | ..." can be told apart
+	// from an operator expression followed by "as".
+	lastTerm jqExpr
+	// prelude holds the builtins defined in jq. While jqPrelude itself is parsed
+	// (definePrelude), its top-level definitions are added to it instead of to scope.
+	prelude       map[string]*jqFuncDef
+	definePrelude bool
+	defDepth      int
+}
+
+// parseJQ compiles a jq filter.
+func parseJQ(src string) (jqExpr, error) {
+	p := &jqParser{src: src, prelude: jqPreludeDefs}
+	e, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	p.blank()
+	if p.pos < len(p.src) {
+		return nil, p.unexpected()
+	}
+	return e, nil
+}
+
+func jqFuncKey(name string, arity int) string {
+	return name + "/" + strconv.Itoa(arity)
+}
+
+func (p *jqParser) errorf(format string, args ...interface{}) error {
+	return &syntaxError{offset: p.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *jqParser) errorAt(pos int, format string, args ...interface{}) error {
+	return &syntaxError{offset: pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *jqParser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return p.errorf("unexpected end of filter")
+	}
+	if name := p.peekIdent(); name != "" {
+		return p.errorf("unexpected %s", name)
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.errorf("unexpected %q", r)
+}
+
+// blank skips whitespace and # comments.
+func (p *jqParser) blank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		case '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *jqParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// op consumes the operator s (after blank space) unless it is the start of a longer
+// operator listed in not.
+func (p *jqParser) op(s string, not ...string) bool {
+	p.blank()
+	rest := p.src[p.pos:]
+	if !strings.HasPrefix(rest, s) {
+		return false
+	}
+	for _, n := range not {
+		if strings.HasPrefix(rest, n) {
+			return false
+		}
+	}
+	p.pos += len(s)
+	return true
+}
+
+func (p *jqParser) expect(s string) error {
+	if !p.op(s) {
+		if p.pos >= len(p.src) {
+			return p.errorf("expected %q, found end of filter", s)
+		}
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// peekIdent returns the identifier at the current position without consuming it.
+func (p *jqParser) peekIdent() string {
+	if p.pos >= len(p.src) || !isIdentStart(p.src[p.pos]) {
+		return ""
+	}
+	end := p.pos + 1
+	for end < len(p.src) && isIdentChar(p.src[end]) {
+		end++
+	}
+	return p.src[p.pos:end]
+}
+
+func (p *jqParser) ident() string {
+	name := p.peekIdent()
+	p.pos += len(name)
+	return name
+}
+
+// keyword consumes the keyword kw if it comes next.
+func (p *jqParser) keyword(kw string) bool {
+	p.blank()
+	if p.peekIdent() == kw {
+		p.pos += len(kw)
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.errorf("expected %s", kw)
+	}
+	return nil
+}
+
+func (p *jqParser) nest() error {
+	if p.depth++; p.depth > maxQueryDepth {
+		return p.errorf("filter nests more than %d levels", maxQueryDepth)
+	}
+	return nil
+}
+
+func (p *jqParser) push(kind byte, name string, arity int) {
+	p.scope = append(p.scope, jqScope{kind, name, arity})
+}
+
+func (p *jqParser) lookup(kind byte, name string, arity int) bool {
+	for i := len(p.scope) - 1; i >= 0; i-- {
+		s := p.scope[i]
+		if s.kind == kind && s.name == name && (kind != 'f' || s.arity == arity) {
+			return true
+		}
+	}
+	return false
+}
+
+// pipe parses a full expression: definitions, "label", "Term as $x | ...", and
+// expressions joined by "|". With noComma the expression stops at a comma, as object
+// values do.
+func (p *jqParser) pipe(noComma bool) (jqExpr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	p.blank()
+	switch p.peekIdent() {
+	case "def":
+		mark := len(p.scope)
+		var defs []*jqFuncDef
+		for p.keyword("def") {
+			def, err := p.funcDef()
+			if err != nil {
+				return nil, err
+			}
+			defs = append(defs, def)
+			p.blank()
+		}
+		body, err := p.pipe(noComma)
+		p.scope = p.scope[:mark]
+		if err != nil {
+			return nil, err
+		}
+		return &jqDefs{defs, body}, nil
+	case "label":
+		p.keyword("label")
+		p.blank()
+		if !p.op("$") {
+			return nil, p.errorf("expected $name after label")
+		}
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a label name")
+		}
+		if err := p.expect("|"); err != nil {
+			return nil, err
+		}
+		p.push('l', name, 0)
+		body, err := p.pipe(noComma)
+		p.scope = p.scope[:len(p.scope)-1]
+		if err != nil {
+			return nil, err
+		}
+		return &jqLabel{name, body}, nil
+	case "import", "include":
+		return nil, p.errorf("modules are not supported")
+	}
+
+	var left jqExpr
+	var err error
+	if noComma {
+		left, err = p.alternative()
+	} else {
+		left, err = p.comma()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.keyword("as") {
+		if left != p.lastTerm {
+			return nil, p.errorAt(p.pos-2, "unexpected as")
+		}
+		mark := len(p.scope)
+		pat, err := p.pattern()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("|"); err != nil {
+			return nil, err
+		}
+		body, err := p.pipe(noComma)
+		p.scope = p.scope[:mark]
+		if err != nil {
+			return nil, err
+		}
+		return &jqBind{left, pat, body}, nil
+	}
+	if p.op("|", "|=") {
+		right, err := p.pipe(noComma)
+		if err != nil {
+			return nil, err
+		}
+		return &jqPipe{left, right}, nil
+	}
+	return left, nil
+}
+
+// funcDef parses the rest of "def name(params): body;" and leaves name in scope.
+func (p *jqParser) funcDef() (*jqFuncDef, error) {
+	p.blank()
+	start := p.pos
+	name := p.ident()
+	if name == "" || jqKeywords[name] {
+		return nil, p.errorAt(start, "expected a function name after def")
+	}
+	def := &jqFuncDef{name: name}
+	var varParams []string
+	if p.op("(") {
+		for {
+			p.blank()
+			isVar := p.op("$")
+			param := p.ident()
+			if param == "" {
+				return nil, p.errorf("expected a parameter name")
+			}
+			def.params = append(def.params, param)
+			if isVar {
+				varParams = append(varParams, param)
+			}
+			if p.op(";") {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if p.definePrelude && p.defDepth == 0 {
+		p.prelude[jqFuncKey(name, len(def.params))] = def
+	} else {
+		p.push('f', name, len(def.params))
+	}
+	p.defDepth++
+	defer func() { p.defDepth-- }()
+	mark := len(p.scope)
+	for _, param := range def.params {
+		p.push('f', param, 0)
+	}
+	for _, param := range varParams {
+		p.push('v', param, 0)
+	}
+	body, err := p.pipe(false)
+	p.scope = p.scope[:mark]
+	if err != nil {
+		return nil, err
+	}
+	// def f($a): body is def f(a): a as $a | body.
+	for i := len(varParams) - 1; i >= 0; i-- {
+		body = &jqBind{&jqCall{name: varParams[i]}, &jqPattern{name: varParams[i]}, body}
+	}
+	def.body = body
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// pattern parses $name, [$a, $b] or {key: $v, $name} and puts its variables in scope.
+func (p *jqParser) pattern() (*jqPattern, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	p.blank()
+	switch {
+	case p.op("$"):
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a variable name")
+		}
+		p.push('v', name, 0)
+		return &jqPattern{name: name}, nil
+	case p.op("["):
+		pat := &jqPattern{array: []*jqPattern{}}
+		for {
+			elem, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			pat.array = append(pat.array, elem)
+			if p.op(",") {
+				continue
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			return pat, nil
+		}
+	case p.op("{"):
+		pat := &jqPattern{object: []jqPatternEntry{}}
+		for {
+			entry, err := p.patternEntry()
+			if err != nil {
+				return nil, err
+			}
+			pat.object = append(pat.object, entry)
+			if p.op(",") {
+				continue
+			}
+			if err := p.expect("}"); err != nil {
+				return nil, err
+			}
+			return pat, nil
+		}
+	}
+	return nil, p.errorf("expected $name, [...] or {...} after as")
+}
+
+func (p *jqParser) patternEntry() (jqPatternEntry, error) {
+	p.blank()
+	if p.op("$") {
+		name := p.ident()
+		if name == "" {
+			return jqPatternEntry{}, p.errorf("expected a variable name")
+		}
+		p.push('v', name, 0)
+		entry := jqPatternEntry{key: &jqLiteral{name}, pattern: &jqPattern{name: name}}
+		if p.op(":") {
+			// {$name: [...]} binds $name to .name and destructures it as well.
+			start := p.pos
+			sub, err := p.pattern()
+			if err != nil {
+				return jqPatternEntry{}, err
+			}
+			if sub.name != "" {
+				return jqPatternEntry{}, p.errorAt(start, "expected [...] or {...} after $%s:", name)
+			}
+			entry.pattern = &jqPattern{name: name, array: sub.array, object: sub.object}
+		}
+		return entry, nil
+	}
+	var key jqExpr
+	switch c := p.peek(); {
+	case isIdentStart(c):
+		key = &jqLiteral{p.ident()}
+	case c == '"':
+		s, err := p.stringLiteral("")
+		if err != nil {
+			return jqPatternEntry{}, err
+		}
+		key = s
+	case c == '(':
+		p.pos++
+		k, err := p.pipe(false)
+		if err != nil {
+			return jqPatternEntry{}, err
+		}
+		if err := p.expect(")"); err != nil {
+			return jqPatternEntry{}, err
+		}
+		key = k
+	default:
+		return jqPatternEntry{}, p.errorf("expected an object key in pattern")
+	}
+	if err := p.expect(":"); err != nil {
+		return jqPatternEntry{}, err
+	}
+	sub, err := p.pattern()
+	if err != nil {
+		return jqPatternEntry{}, err
+	}
+	return jqPatternEntry{key, sub}, nil
+}
+
+func (p *jqParser) comma() (jqExpr, error) {
+	left, err := p.alternative()
+	if err != nil {
+		return nil, err
+	}
+	for p.op(",") {
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqComma{left, right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) alternative() (jqExpr, error) {
+	left, err := p.assignment()
+	if err != nil {
+		return nil, err
+	}
+	if p.op("//", "//=") {
+		right, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		return &jqAlt{left, right}, nil
+	}
+	return left, nil
+}
+
+var jqAssignOps = []string{"|=", "+=", "-=", "*=", "/=", "%=", "//="}
+
+func (p *jqParser) assignment() (jqExpr, error) {
+	left, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range jqAssignOps {
+		if p.op(op) {
+			right, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return &jqAssign{op, left, right}, nil
+		}
+	}
+	if p.op("=", "==") {
+		right, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return &jqAssign{"=", left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *jqParser) or() (jqExpr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) and() (jqExpr, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) comparison() (jqExpr, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.op(op) {
+			right, err := p.additive()
+			if err != nil {
+				return nil, err
+			}
+			e := &jqBinary{op, left, right}
+			for _, again := range []string{"==", "!=", "<", ">"} {
+				save := p.pos
+				if p.op(again) {
+					p.pos = save
+					return nil, p.errorf("comparisons cannot be chained; use parentheses")
+				}
+			}
+			return e, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) additive() (jqExpr, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.op("+", "+="):
+			op = "+"
+		case p.op("-", "-="):
+			op = "-"
+		default:
+			return left, nil
+		}
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op, left, right}
+	}
+}
+
+func (p *jqParser) multiplicative() (jqExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.op("*", "*="):
+			op = "*"
+		case p.op("/", "/=", "//"):
+			op = "/"
+		case p.op("%", "%="):
+			op = "%"
+		default:
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &jqBinary{op, left, right}
+	}
+}
+
+func (p *jqParser) unary() (jqExpr, error) {
+	if p.op("-", "-=") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := e.(*jqLiteral); ok {
+			if f, ok := lit.value.(float64); ok {
+				return &jqLiteral{-f}, nil
+			}
+		}
+		return &jqNeg{e}, nil
+	}
+	return p.postfix()
+}
+
+// postfix parses a term followed by any number of .name, ."name", [...] and ? suffixes.
+func (p *jqParser) postfix() (jqExpr, error) {
+	t, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		save := p.pos
+		p.blank()
+		switch {
+		case p.peek() == '.' && p.pos+1 < len(p.src) && (isIdentStart(p.src[p.pos+1]) || p.src[p.pos+1] == '"'):
+			p.pos++
+			key, err := p.fieldName()
+			if err != nil {
+				return nil, err
+			}
+			t = &jqIndex{t, key}
+		case p.peek() == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '[':
+			p.pos++
+			if t, err = p.bracketSuffix(t); err != nil {
+				return nil, err
+			}
+		case p.peek() == '[':
+			if t, err = p.bracketSuffix(t); err != nil {
+				return nil, err
+			}
+		case p.peek() == '?':
+			p.pos++
+			t = &jqTry{body: t}
+		default:
+			p.pos = save
+			p.lastTerm = t
+			return t, nil
+		}
+	}
+}
+
+// fieldName parses the name after a dot: an identifier or a string literal.
+func (p *jqParser) fieldName() (jqExpr, error) {
+	if p.peek() == '"' {
+		return p.stringLiteral("")
+	}
+	return &jqLiteral{p.ident()}, nil
+}
+
+// bracketSuffix parses [], [e], [e:], [:e] or [e:e] applied to target.
+func (p *jqParser) bracketSuffix(target jqExpr) (jqExpr, error) {
+	p.pos++ // [
+	if p.op("]") {
+		return &jqIterate{target}, nil
+	}
+	if p.op(":") {
+		to, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &jqSlice{target, nil, to}, nil
+	}
+	from, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.op(":") {
+		if p.op("]") {
+			return &jqSlice{target, from, nil}, nil
+		}
+		to, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &jqSlice{target, from, to}, nil
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return &jqIndex{target, from}, nil
+}
+
+func (p *jqParser) term() (jqExpr, error) {
+	p.blank()
+	if p.pos >= len(p.src) {
+		return nil, p.unexpected()
+	}
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9', c == '.' && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9':
+		return p.number()
+	case c == '.':
+		p.pos++
+		switch {
+		case p.peek() == '.':
+			p.pos++
+			return &jqCall{name: "recurse", prelude: p.prelude[jqFuncKey("recurse", 0)]}, nil
+		case isIdentStart(p.peek()), p.peek() == '"':
+			key, err := p.fieldName()
+			if err != nil {
+				return nil, err
+			}
+			return &jqIndex{&jqIdentity{}, key}, nil
+		}
+		return &jqIdentity{}, nil
+	case c == '"':
+		return p.stringLiteral("")
+	case c == '@':
+		p.pos++
+		name := p.ident()
+		if jqFormats[name] == nil {
+			return nil, p.errorAt(p.pos-len(name)-1, "unknown format @%s", name)
+		}
+		save := p.pos
+		p.blank()
+		if p.peek() == '"' {
+			return p.stringLiteral(name)
+		}
+		p.pos = save
+		return &jqFormat{name}, nil
+	case c == '$':
+		start := p.pos
+		p.pos++
+		name := p.ident()
+		if name == "" {
+			return nil, p.errorf("expected a variable name after $")
+		}
+		if !p.lookup('v', name, 0) && name != "ENV" {
+			return nil, p.errorAt(start, "$%s is not defined", name)
+		}
+		return &jqVar{name}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case c == '[':
+		p.pos++
+		if p.op("]") {
+			return &jqArray{}, nil
+		}
+		body, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &jqArray{body}, nil
+	case c == '{':
+		return p.object()
+	case isIdentStart(c):
+		return p.identTerm()
+	}
+	return nil, p.unexpected()
+}
+
+func (p *jqParser) number() (jqExpr, error) {
+	start := p.pos
+	digits := func() {
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	digits()
+	if p.peek() == '.' {
+		p.pos++
+		digits()
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		save := p.pos
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		if c := p.peek(); c < '0' || c > '9' {
+			p.pos = save
+		} else {
+			digits()
+		}
+	}
+	f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil && f == 0 {
+		return nil, p.errorAt(start, "invalid number %s", p.src[start:p.pos])
+	}
+	return &jqLiteral{f}, nil
+}
+
+// stringLiteral parses a double-quoted string with JSON escapes and \(...)
+// interpolation; format is the @name prefix, if any.
+func (p *jqParser) stringLiteral(format string) (jqExpr, error) {
+	p.pos++ // opening quote
+	var parts []interface{}
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return nil, p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			if b.Len() > 0 || len(parts) == 0 {
+				parts = append(parts, b.String())
+			}
+			if len(parts) == 1 && format == "" {
+				if s, ok := parts[0].(string); ok {
+					return &jqLiteral{s}, nil
+				}
+			}
+			return &jqString{parts, format}, nil
+		case c == '\\':
+			if p.pos+1 < len(p.src) && p.src[p.pos+1] == '(' {
+				p.pos += 2
+				if b.Len() > 0 {
+					parts = append(parts, b.String())
+					b.Reset()
+				}
+				e, err := p.pipe(false)
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				parts = append(parts, e)
+				continue
+			}
+			r, err := p.escape()
+			if err != nil {
+				return nil, err
+			}
+			b.WriteRune(r)
+		case c < 0x20:
+			return nil, p.errorf("control character in string; use an escape")
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// escape parses a JSON escape sequence, joining UTF-16 surrogate pairs.
+func (p *jqParser) escape() (rune, error) {
+	start := p.pos
+	p.pos++ // backslash
+	if p.pos >= len(p.src) {
+		return 0, p.errorf("unterminated string")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case '"', '\\', '/':
+		return rune(c), nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		r, err := p.hex4()
+		if err != nil {
+			return 0, err
+		}
+		if utf16.IsSurrogate(r) {
+			if strings.HasPrefix(p.src[p.pos:], `\u`) {
+				p.pos += 2
+				lo, err := p.hex4()
+				if err != nil {
+					return 0, err
+				}
+				if dec := utf16.DecodeRune(r, lo); dec != utf8.RuneError {
+					return dec, nil
+				}
+			}
+			return utf8.RuneError, nil
+		}
+		return r, nil
+	}
+	return 0, p.errorAt(start, "invalid escape \\%c", c)
+}
+
+func (p *jqParser) hex4() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("expected 4 hex digits")
+	}
+	v, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("expected 4 hex digits")
+	}
+	p.pos += 4
+	return rune(v), nil
+}
+
+// object parses an object construction: {a, "b": 1, (.k): .v, $x, @base64: ...}.
+func (p *jqParser) object() (jqExpr, error) {
+	p.pos++ // {
+	obj := &jqObjectDef{}
+	if p.op("}") {
+		return obj, nil
+	}
+	for {
+		entry, err := p.objectEntry()
+		if err != nil {
+			return nil, err
+		}
+		obj.entries = append(obj.entries, entry)
+		if p.op(",") {
+			continue
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+}
+
+func (p *jqParser) objectEntry() (jqEntry, error) {
+	p.blank()
+	var key jqExpr
+	c := p.peek()
+	switch {
+	case c == '$':
+		start := p.pos
+		p.pos++
+		name := p.ident()
+		if name == "" {
+			return jqEntry{}, p.errorf("expected a variable name after $")
+		}
+		if !p.lookup('v', name, 0) {
+			return jqEntry{}, p.errorAt(start, "$%s is not defined", name)
+		}
+		if p.op(":") {
+			return jqEntry{}, p.errorAt(start, "variable keys must be parenthesized: ($%s)", name)
+		}
+		return jqEntry{&jqLiteral{name}, &jqVar{name}}, nil
+	case isIdentStart(c):
+		key = &jqLiteral{p.ident()}
+	case c == '"':
+		k, err := p.stringLiteral("")
+		if err != nil {
+			return jqEntry{}, err
+		}
+		key = k
+	case c == '@':
+		k, err := p.term()
+		if err != nil {
+			return jqEntry{}, err
+		}
+		key = k
+	case c == '(':
+		p.pos++
+		k, err := p.pipe(false)
+		if err != nil {
+			return jqEntry{}, err
+		}
+		if err := p.expect(")"); err != nil {
+			return jqEntry{}, err
+		}
+		if !p.op(":") {
+			return jqEntry{}, p.errorf("expected \":\" after a computed key")
+		}
+		v, err := p.pipe(true)
+		if err != nil {
+			return jqEntry{}, err
+		}
+		return jqEntry{k, v}, nil
+	default:
+		return jqEntry{}, p.errorf("expected an object key")
+	}
+	if !p.op(":") {
+		// {a} is {a: .a}.
+		return jqEntry{key, &jqIndex{&jqIdentity{}, key}}, nil
+	}
+	v, err := p.pipe(true)
+	if err != nil {
+		return jqEntry{}, err
+	}
+	return jqEntry{key, v}, nil
+}
+
+// identTerm parses a term starting with an identifier: literals, if, try, reduce,
+// foreach, break and function calls.
+func (p *jqParser) identTerm() (jqExpr, error) {
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "null":
+		return &jqLiteral{nil}, nil
+	case "true":
+		return &jqLiteral{true}, nil
+	case "false":
+		return &jqLiteral{false}, nil
+	case "if":
+		return p.ifTerm()
+	case "try":
+		body, err := p.postfixNoTry()
+		if err != nil {
+			return nil, err
+		}
+		t := &jqTry{body: body}
+		if p.keyword("catch") {
+			if t.catch, err = p.postfixNoTry(); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	case "reduce", "foreach":
+		return p.fold(name)
+	case "break":
+		p.blank()
+		if !p.op("$") {
+			return nil, p.errorf("expected $name after break")
+		}
+		label := p.ident()
+		if !p.lookup('l', label, 0) {
+			return nil, p.errorAt(start, "$*label-%s is not defined", label)
+		}
+		return &jqBreak{label}, nil
+	}
+	if jqKeywords[name] {
+		return nil, p.errorAt(start, "unexpected %s", name)
+	}
+	var args []jqExpr
+	if p.peek() == '(' {
+		p.pos++
+		for {
+			arg, err := p.pipe(false)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.op(";") {
+				continue
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	call := &jqCall{name: name, args: args}
+	if !p.lookup('f', name, len(args)) {
+		key := jqFuncKey(name, len(args))
+		if def := p.prelude[key]; def != nil {
+			call.prelude = def
+		} else if native := jqNatives[key]; native != nil {
+			call.native = native
+		} else {
+			return nil, p.errorAt(start, "%s is not defined", key)
+		}
+	}
+	return call, nil
+}
+
+// postfixNoTry parses the body of try or catch: a postfix term.
+func (p *jqParser) postfixNoTry() (jqExpr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	return p.postfix()
+}
+
+func (p *jqParser) ifTerm() (jqExpr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	cond, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	e := &jqIf{cond: cond, then: then}
+	switch {
+	case p.keyword("elif"):
+		if e.els, err = p.ifTerm(); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case p.keyword("else"):
+		if e.els, err = p.pipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expectKeyword("end"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// fold parses reduce SOURCE as $x (INIT; UPDATE) and
+// foreach SOURCE as $x (INIT; UPDATE; EXTRACT).
+func (p *jqParser) fold(kind string) (jqExpr, error) {
+	if err := p.nest(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	source, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("as"); err != nil {
+		return nil, err
+	}
+	mark := len(p.scope)
+	pat, err := p.pattern()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { p.scope = p.scope[:mark] }()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	// $x is not visible in INIT, but jq resolves it there anyway; keeping it in scope
+	// matches that.
+	init, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, err
+	}
+	update, err := p.pipe(false)
+	if err != nil {
+		return nil, err
+	}
+	if kind == "reduce" {
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &jqReduce{source, pat, init, update}, nil
+	}
+	var extract jqExpr
+	if p.op(";") {
+		if extract, err = p.pipe(false); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &jqForeach{source, pat, init, update, extract}, nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,93 +9,37 @@ import (
 	"strings"
 )
 
-// ValidateResponse is the JSON response for the validate endpoint.
-type ValidateResponse struct {
-	Valid bool   `json:"valid"`
-	Error string `json:"error,omitempty"`
-}
-
-// PathRequest is the JSON body for the path query endpoint.
+// PathRequest is the JSON body for the path query endpoint. Syntax "dot" is the legacy
+// dot-separated form (a.b.0.c), "jsonpath" is RFC 9535 ($.a['b'][0]) and "pointer" is an
+// RFC 6901 JSON Pointer (/a/b/0). When empty, paths starting with $ are JSONPath, paths
+// starting with / or # are pointers and anything else is dot syntax. PathFormat chooses
+// how match paths are written: "normalized" (the default) or "pointer". Lenient accepts
+// JSON5 and JSONC input.
 type PathRequest struct {
-	Value string `json:"value"`
-	Path  string `json:"path"`
+	Value      string `json:"value"`
+	Path       string `json:"path"`
+	Syntax     string `json:"syntax,omitempty" enum:"dot,jsonpath,pointer"`
+	PathFormat string `json:"pathFormat,omitempty" enum:"normalized,pointer"`
+	Lenient    bool   `json:"lenient,omitempty"`
 }
 
-// DiffRequest is the JSON body for the diff endpoint.
-type DiffRequest struct {
-	ValueA string `json:"valueA"`
-	ValueB string `json:"valueB"`
+// PathMatch is one value selected by a path query, with its RFC 9535 normalized path
+// (e.g. $['items'][0]['name']) or, with pathFormat "pointer", its JSON Pointer
+// (/items/0/name).
+type PathMatch struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
 }
 
-// FormatJSON pretty-prints JSON with 2-space indentation.
-func FormatJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	var v interface{}
-	if err := json.Unmarshal([]byte(req.Value), &v); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
-		return
-	}
-	out, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		http.Error(w, fmt.Sprintf("marshal: %v", err), http.StatusInternalServerError)
-		return
-	}
-	writeJSON(w, StringResponse{Result: string(out)})
+// PathResponse is the JSON response for the path query endpoint. In dot and pointer
+// syntax Result is the selected value; in JSONPath syntax it is an array of every
+// matched value.
+type PathResponse struct {
+	Result  string      `json:"result"`
+	Matches []PathMatch `json:"matches"`
 }
 
-// MinifyJSON removes unnecessary whitespace from JSON.
-func MinifyJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	var v interface{}
-	if err := json.Unmarshal([]byte(req.Value), &v); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
-		return
-	}
-	out, err := json.Marshal(v)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("marshal: %v", err), http.StatusInternalServerError)
-		return
-	}
-	writeJSON(w, StringResponse{Result: string(out)})
-}
-
-// ValidateJSON checks whether the input is valid JSON.
-func ValidateJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	valid := json.Valid([]byte(req.Value))
-	resp := ValidateResponse{Valid: valid}
-	if !valid {
-		var v interface{}
-		if err := json.Unmarshal([]byte(req.Value), &v); err != nil {
-			resp.Error = err.Error()
-		}
-	}
-	writeJSON(w, resp)
-}
+func (r PathResponse) resultText() string { return r.Result }
 
 // pathGet walks a dot-separated path with optional numeric indices (e.g. "a.b.0.c").
 // Returns the value at the path and true, or nil and false if not found.
@@ -149,124 +94,129 @@ func splitPath(path string) []interface{} {
 	return parts
 }
 
-// PathQueryJSON extracts a value at the given path from JSON.
+// PathQueryJSON extracts the values selected by a dot path or an RFC 9535 JSONPath query.
 func PathQueryJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req PathRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	var v interface{}
-	if err := json.Unmarshal([]byte(req.Value), &v); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON: %v", err), http.StatusBadRequest)
-		return
-	}
-	got, ok := pathGet(v, req.Path)
-	if !ok {
-		http.Error(w, "path not found", http.StatusBadRequest)
-		return
-	}
-	out, err := json.Marshal(got)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("marshal: %v", err), http.StatusInternalServerError)
-		return
-	}
-	writeJSON(w, StringResponse{Result: string(out)})
+	serve(w, r, typedContextTransform(pathQueryJSON))
 }
 
-// pathJoin returns path + "." + segment, or just segment if path is empty.
-func pathJoin(path, segment string) string {
-	if path == "" {
-		return segment
+func pathQueryJSON(ctx context.Context, req PathRequest) (PathResponse, error) {
+	value, err := strictJSON("value", req.Value, req.Lenient)
+	if err != nil {
+		return PathResponse{}, err
 	}
-	return path + "." + segment
-}
-
-// diffRecurse builds a structural diff between two values; returns a slice of "path: left -> right" lines.
-func diffRecurse(a, b interface{}, path string) []string {
-	var lines []string
-	pathLabel := path
-	if pathLabel == "" {
-		pathLabel = "(root)"
+	v, err := decodeJSONValue("value", value)
+	if err != nil {
+		return PathResponse{}, err
+	}
+	syntax := req.Syntax
+	if syntax == "" {
+		trimmed := strings.TrimSpace(req.Path)
+		switch {
+		case strings.HasPrefix(trimmed, "$"):
+			syntax = "jsonpath"
+		case strings.HasPrefix(req.Path, "/"), strings.HasPrefix(req.Path, "#"):
+			syntax = "pointer"
+		default:
+			syntax = "dot"
+		}
+	}
+	switch req.PathFormat {
+	case "", "normalized", "pointer":
+	default:
+		return PathResponse{}, invalidOption("pathFormat", fmt.Sprintf("unknown path format %q", req.PathFormat), enumTag(req, "PathFormat")...)
 	}
-	switch av := a.(type) {
-	case map[string]interface{}:
-		bv, ok := b.(map[string]interface{})
+	var nodes []jpNode
+	switch syntax {
+	case "dot":
+		got, ok := pathGet(v, req.Path)
 		if !ok {
-			lines = append(lines, fmt.Sprintf("%s: (object) -> (%T)", pathLabel, b))
-			return lines
+			return PathResponse{}, newError(CodePathNotFound, "path", "path not found")
 		}
-		seen := make(map[string]bool)
-		for k, v := range av {
-			seen[k] = true
-			p := pathJoin(path, k)
-			if bval, has := bv[k]; has {
-				lines = append(lines, diffRecurse(v, bval, p)...)
-			} else {
-				lines = append(lines, fmt.Sprintf("%s: %v -> (missing)", p, v))
-			}
-		}
-		for k, v := range bv {
-			if !seen[k] {
-				lines = append(lines, fmt.Sprintf("%s: (missing) -> %v", pathJoin(path, k), v))
-			}
+		nodes = []jpNode{dotNode(req.Path, got)}
+	case "pointer":
+		if nodes, err = pointerQuery(v, req.Path); err != nil {
+			return PathResponse{}, err
 		}
-	case []interface{}:
-		bv, ok := b.([]interface{})
-		if !ok {
-			lines = append(lines, fmt.Sprintf("%s: (array) -> (%T)", pathLabel, b))
-			return lines
+	case "jsonpath":
+		q, err := parseJSONPath(strings.TrimSpace(req.Path))
+		if err != nil {
+			return PathResponse{}, querySyntaxError("path", strings.TrimSpace(req.Path), err)
 		}
-		max := len(av)
-		if len(bv) > max {
-			max = len(bv)
-		}
-		for i := 0; i < max; i++ {
-			p := pathJoin(path, strconv.Itoa(i))
-			if i >= len(av) {
-				lines = append(lines, fmt.Sprintf("%s: (missing) -> %v", p, bv[i]))
-			} else if i >= len(bv) {
-				lines = append(lines, fmt.Sprintf("%s: %v -> (missing)", p, av[i]))
-			} else {
-				lines = append(lines, diffRecurse(av[i], bv[i], p)...)
-			}
+		ev := &jpEvaluator{ctx: ctx, root: v, maxNodes: limits.JSON.PathMatches}
+		if nodes, err = ev.query(q, jpNode{}); err != nil {
+			return PathResponse{}, err
 		}
 	default:
-		if a != b {
-			lines = append(lines, fmt.Sprintf("%s: %v -> %v", pathLabel, a, b))
+		return PathResponse{}, invalidOption("syntax", fmt.Sprintf("unknown syntax %q", req.Syntax), enumTag(req, "Syntax")...)
+	}
+
+	resp := PathResponse{Matches: make([]PathMatch, len(nodes))}
+	values := make([]json.RawMessage, len(nodes))
+	for i, n := range nodes {
+		out, err := json.Marshal(n.value)
+		if err != nil {
+			return PathResponse{}, fmt.Errorf("marshal: %w", err)
 		}
+		path := n.path
+		if req.PathFormat == "pointer" {
+			path = n.pointer
+		}
+		resp.Matches[i] = PathMatch{Path: path, Value: out}
+		values[i] = out
+	}
+	if syntax != "jsonpath" {
+		resp.Result = string(values[0])
+		return resp, nil
 	}
-	return lines
+	out, err := json.Marshal(values)
+	if err != nil {
+		return PathResponse{}, fmt.Errorf("marshal: %w", err)
+	}
+	resp.Result = string(out)
+	return resp, nil
 }
 
-// DiffJSON compares two JSON values and returns a structural diff.
-func DiffJSON(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
+// decodeJSONValue parses the JSON text in field, keeping numbers exact (json.Number).
+func decodeJSONValue(field, s string) (interface{}, error) {
+	var v interface{}
+	if !json.Valid([]byte(s)) {
+		// Unmarshal reports the syntax error with the offset invalidJSON expects.
+		err := json.Unmarshal([]byte(s), &v)
+		return nil, invalidJSON(field, []byte(s), err)
 	}
-	var req DiffRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, invalidJSON(field, []byte(s), err)
 	}
-	var a, b interface{}
-	if err := json.Unmarshal([]byte(req.ValueA), &a); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON in valueA: %v", err), http.StatusBadRequest)
-		return
+	return v, nil
+}
+
+// decodeOrderedValue parses the JSON text in field like decodeJSONValue, but keeps the
+// key order of objects (*jqObject).
+func decodeOrderedValue(field, s string) (interface{}, error) {
+	if !json.Valid([]byte(s)) {
+		var v interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return nil, invalidJSON(field, []byte(s), err)
 	}
-	if err := json.Unmarshal([]byte(req.ValueB), &b); err != nil {
-		http.Error(w, fmt.Sprintf("invalid JSON in valueB: %v", err), http.StatusBadRequest)
-		return
+	v, err := jqDecode(json.NewDecoder(strings.NewReader(s)))
+	if err != nil {
+		return nil, invalidJSON(field, []byte(s), err)
 	}
-	lines := diffRecurse(a, b, "")
-	result := strings.Join(lines, "\n")
-	if result == "" {
-		result = "(no differences)"
+	return v, nil
+}
+
+// dotNode returns the node for a dot path, holding v (the value pathGet found there).
+func dotNode(path string, v interface{}) jpNode {
+	n := jpNode{path: "$"}
+	for _, part := range splitPath(strings.TrimSpace(path)) {
+		if i, ok := part.(int); ok {
+			n = n.element(i, nil)
+		} else {
+			n = n.member(part.(string), nil)
+		}
 	}
-	writeJSON(w, StringResponse{Result: result})
+	n.value = v
+	return n
 }
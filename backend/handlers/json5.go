@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// This file implements lenient input: a parser for JSON5 (https://spec.json5.org), which
+// also covers JSONC, that rewrites its input as strict JSON. Tools that accept
+// "lenient": true run their input through it first; the repair tool returns the result
+// with the list of fixes it made.
+
+// RepairRequest is the JSON body for the repair endpoint.
+type RepairRequest struct {
+	Value string `json:"value"`
+}
+
+// RepairFix is one change made to turn lenient input into strict JSON, at the position
+// in the input where it was made.
+type RepairFix struct {
+	Kind    string `json:"kind" enum:"bom,comment,trailing_comma,single_quotes,unquoted_key,number,non_finite,escape,control_character"`
+	Message string `json:"message"`
+	Offset  int    `json:"offset"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// RepairResponse is the JSON response for the repair endpoint. Result is the strict
+// JSON, pretty-printed; Fixes is empty when the input was strict JSON already.
+type RepairResponse struct {
+	Result string      `json:"result"`
+	Fixes  []RepairFix `json:"fixes"`
+}
+
+func (r RepairResponse) resultText() string { return r.Result }
+
+// RepairJSON converts JSON5 or JSONC to strict JSON and lists the fixes made.
+func RepairJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(repairJSON))
+}
+
+func repairJSON(req RepairRequest) (RepairResponse, error) {
+	strict, fixes, err := lenientJSON("value", req.Value)
+	if err != nil {
+		return RepairResponse{}, err
+	}
+	out, err := formatJSON(FormatRequest{Value: strict})
+	if err != nil {
+		return RepairResponse{}, err
+	}
+	return RepairResponse{Result: out.Result, Fixes: fixes}, nil
+}
+
+// strictJSON returns the JSON text s from field unchanged or, when lenient is set, its
+// conversion from JSON5 to strict JSON.
+func strictJSON(field, s string, lenient bool) (string, error) {
+	if !lenient {
+		return s, nil
+	}
+	strict, _, err := lenientJSON(field, s)
+	return strict, err
+}
+
+// lenientJSON converts the JSON5 text in field to compact strict JSON. Strings and
+// numbers that are valid JSON already are copied as written. Infinity and NaN have no
+// JSON form and become null.
+func lenientJSON(field, s string) (string, []RepairFix, error) {
+	p := &jsonRepairer{data: s, fixes: []RepairFix{}}
+	if err := p.document(); err != nil {
+		msg := err.message
+		if err.expected != "" {
+			msg += "; expected " + err.expected
+		}
+		if field != "value" && field != "" {
+			msg = fmt.Sprintf("invalid JSON5 in %s: %s", field, msg)
+		} else {
+			msg = "invalid JSON5: " + msg
+		}
+		e := newError(CodeInvalidJSON, field, msg)
+		line, col := lineColumn([]byte(s), err.offset)
+		e.Details = map[string]interface{}{"offset": err.offset, "line": line, "column": col}
+		return "", nil, e
+	}
+	// A trailing comma is only known to be one after the comments that follow it.
+	sort.SliceStable(p.fixes, func(i, j int) bool { return p.fixes[i].Offset < p.fixes[j].Offset })
+	return p.out.String(), p.fixes, nil
+}
+
+// jsonRepairer parses JSON5 and writes the strict JSON equivalent to out as it goes.
+type jsonRepairer struct {
+	data  string
+	pos   int
+	depth int
+	out   strings.Builder
+	fixes []RepairFix
+}
+
+func (p *jsonRepairer) document() *lintSyntaxError {
+	if strings.HasPrefix(p.data, "\ufeff") {
+		p.fix(0, "bom", "removed the byte order mark")
+		p.pos = len("\ufeff")
+	}
+	if err := p.value(); err != nil {
+		return err
+	}
+	if err := p.skip(); err != nil {
+		return err
+	}
+	if p.pos < len(p.data) {
+		return p.unexpected("the end of the input")
+	}
+	return nil
+}
+
+func (p *jsonRepairer) fix(offset int, kind, msg string) {
+	line, col := lineColumn([]byte(p.data), offset)
+	p.fixes = append(p.fixes, RepairFix{Kind: kind, Message: msg, Offset: offset, Line: line, Column: col})
+}
+
+func (p *jsonRepairer) fail(msg, expected string) *lintSyntaxError {
+	return &lintSyntaxError{offset: p.pos, message: msg, expected: expected}
+}
+
+func (p *jsonRepairer) unexpected(expected string) *lintSyntaxError {
+	if p.pos >= len(p.data) {
+		return p.fail("unexpected end of input", expected)
+	}
+	r, _ := utf8.DecodeRuneInString(p.data[p.pos:])
+	return p.fail(fmt.Sprintf("unexpected character %q", r), expected)
+}
+
+func (p *jsonRepairer) peek() byte {
+	if p.pos < len(p.data) {
+		return p.data[p.pos]
+	}
+	return 0
+}
+
+// skip passes over whitespace, which in JSON5 includes every Unicode space separator,
+// and comments.
+func (p *jsonRepairer) skip() *lintSyntaxError {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case strings.IndexByte(" \t\r\n\v\f", c) >= 0:
+			p.pos++
+		case strings.HasPrefix(p.data[p.pos:], "//"):
+			p.fix(p.pos, "comment", "removed a // comment")
+			end := strings.IndexAny(p.data[p.pos:], "\r\n")
+			if end < 0 {
+				end = len(p.data) - p.pos
+			}
+			p.pos += end
+		case strings.HasPrefix(p.data[p.pos:], "/*"):
+			end := strings.Index(p.data[p.pos+2:], "*/")
+			if end < 0 {
+				return p.fail("unterminated /* comment", "*/")
+			}
+			p.fix(p.pos, "comment", "removed a /* */ comment")
+			p.pos += 2 + end + 2
+		case c >= utf8.RuneSelf:
+			r, size := utf8.DecodeRuneInString(p.data[p.pos:])
+			if r != '\ufeff' && r != '\u2028' && r != '\u2029' && !unicode.Is(unicode.Zs, r) {
+				return nil
+			}
+			p.pos += size
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *jsonRepairer) value() *lintSyntaxError {
+	if err := p.skip(); err != nil {
+		return err
+	}
+	switch c := p.peek(); {
+	case c == '{' || c == '[':
+		if p.depth++; p.depth > maxLintDepth {
+			return p.fail(fmt.Sprintf("nesting is deeper than %d levels", maxLintDepth), "")
+		}
+		defer func() { p.depth-- }()
+		if c == '{' {
+			return p.object()
+		}
+		return p.array()
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	}
+	// Keywords cannot be written with escapes, so match the name as written.
+	start := p.pos
+	_, _ = p.ident()
+	switch word := p.data[start:p.pos]; word {
+	case "true", "false", "null":
+		p.out.WriteString(word)
+		return nil
+	case "Infinity", "NaN":
+		p.fix(start, "non_finite", fmt.Sprintf("replaced %s, which JSON cannot represent, with null", word))
+		p.out.WriteString("null")
+		return nil
+	}
+	p.pos = start
+	return p.unexpected(expectValue)
+}
+
+func (p *jsonRepairer) object() *lintSyntaxError {
+	p.pos++
+	p.out.WriteByte('{')
+	if err := p.skip(); err != nil {
+		return err
+	}
+	if p.peek() == '}' {
+		p.pos++
+		p.out.WriteByte('}')
+		return nil
+	}
+	for {
+		if err := p.skip(); err != nil {
+			return err
+		}
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			if err := p.str(); err != nil {
+				return err
+			}
+		default:
+			start := p.pos
+			key, err := p.ident()
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				return p.unexpected("a key")
+			}
+			msg := fmt.Sprintf("quoted the key %s", p.data[start:p.pos])
+			if raw := p.data[start:p.pos]; raw != key {
+				msg += fmt.Sprintf(" as %q", key)
+			}
+			p.fix(start, "unquoted_key", msg)
+			p.out.WriteString(`"` + key + `"`)
+		}
+		if err := p.skip(); err != nil {
+			return err
+		}
+		if p.peek() != ':' {
+			return p.unexpected("a colon after the key")
+		}
+		p.pos++
+		p.out.WriteByte(':')
+		if err := p.value(); err != nil {
+			return err
+		}
+		done, err := p.next('}')
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+func (p *jsonRepairer) array() *lintSyntaxError {
+	p.pos++
+	p.out.WriteByte('[')
+	if err := p.skip(); err != nil {
+		return err
+	}
+	if p.peek() == ']' {
+		p.pos++
+		p.out.WriteByte(']')
+		return nil
+	}
+	for {
+		if err := p.value(); err != nil {
+			return err
+		}
+		done, err := p.next(']')
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// next consumes what follows a member or element: a comma, a comma and the closing
+// bracket (dropping the trailing comma) or the closing bracket. It reports whether the
+// container is closed.
+func (p *jsonRepairer) next(closer byte) (bool, *lintSyntaxError) {
+	if err := p.skip(); err != nil {
+		return false, err
+	}
+	switch p.peek() {
+	case ',':
+		comma := p.pos
+		p.pos++
+		if err := p.skip(); err != nil {
+			return false, err
+		}
+		if p.peek() == closer {
+			p.fix(comma, "trailing_comma", "removed a trailing comma")
+			p.pos++
+			p.out.WriteByte(closer)
+			return true, nil
+		}
+		p.out.WriteByte(',')
+		return false, nil
+	case closer:
+		p.pos++
+		p.out.WriteByte(closer)
+		return true, nil
+	}
+	return false, p.unexpected(fmt.Sprintf("a comma or %c", closer))
+}
+
+// ident consumes an ECMAScript identifier name, as used for unquoted keys, and returns
+// it with its \uHHHH escapes decoded ("" when there is none). An escape must stand for a
+// character the name could hold as written.
+func (p *jsonRepairer) ident() (string, *lintSyntaxError) {
+	start := p.pos
+	var name strings.Builder
+	for p.pos < len(p.data) {
+		r, size := utf8.DecodeRuneInString(p.data[p.pos:])
+		first := p.pos == start
+		if r == '\\' {
+			if p.pos+1 >= len(p.data) || p.data[p.pos+1] != 'u' {
+				p.pos++
+				return "", p.unexpected(`u after the backslash in a key`)
+			}
+			for i := 2; i <= 5; i++ {
+				if p.pos+i >= len(p.data) || !isHexDigit(p.data[p.pos+i]) {
+					p.pos += i
+					return "", p.unexpected(`4 hex digits after \u`)
+				}
+			}
+			v, _ := strconv.ParseUint(p.data[p.pos+2:p.pos+6], 16, 32)
+			if r, size = rune(v), 6; !identRune(r, first) {
+				return "", p.fail(fmt.Sprintf("the escape %s is not allowed in a key", p.data[p.pos:p.pos+6]), "a letter, digit, _ or $")
+			}
+		} else if !identRune(r, first) {
+			break
+		}
+		name.WriteRune(r)
+		p.pos += size
+	}
+	return name.String(), nil
+}
+
+// identRune reports whether r can appear in an identifier name, first or later.
+func identRune(r rune, first bool) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r) ||
+		!first && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc) || r == '\u200c' || r == '\u200d')
+}
+
+// str converts a single- or double-quoted JSON5 string. JSON escapes are copied as
+// written; the escapes only JSON5 has (\', \v, \0, \xHH, line continuations and
+// unnecessary backslashes) and raw control characters are rewritten.
+func (p *jsonRepairer) str() *lintSyntaxError {
+	quote := p.data[p.pos]
+	if quote == '\'' {
+		p.fix(p.pos, "single_quotes", "changed a single-quoted string to double quotes")
+	}
+	p.pos++
+	p.out.WriteByte('"')
+	for {
+		if p.pos >= len(p.data) {
+			return p.fail("unexpected end of input in a string", "a closing quote")
+		}
+		c := p.data[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			p.out.WriteByte('"')
+			return nil
+		case c == '"':
+			p.pos++
+			p.out.WriteString(`\"`)
+		case c < 0x20:
+			p.fix(p.pos, "control_character", fmt.Sprintf("escaped the control character %U in a string", c))
+			p.pos++
+			p.out.WriteString(jsonControlEscape(c))
+		case c == '\\':
+			if err := p.escape(); err != nil {
+				return err
+			}
+		default:
+			p.pos++
+			p.out.WriteByte(c)
+		}
+	}
+}
+
+func (p *jsonRepairer) escape() *lintSyntaxError {
+	start := p.pos
+	p.pos++
+	if p.pos >= len(p.data) {
+		return p.fail("unexpected end of input in a string", "an escape character")
+	}
+	rewrite := func(n int, out string) {
+		p.fix(start, "escape", fmt.Sprintf("rewrote the escape %s as %s", p.data[start:p.pos+n], out))
+		p.pos += n
+		p.out.WriteString(out)
+	}
+	switch e := p.data[p.pos]; e {
+	case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+		p.pos++
+		p.out.WriteString(p.data[start:p.pos])
+	case 'u':
+		for i := 1; i <= 4; i++ {
+			if p.pos+i >= len(p.data) || !isHexDigit(p.data[p.pos+i]) {
+				p.pos += i
+				return p.unexpected(`4 hex digits after \u`)
+			}
+		}
+		p.pos += 5
+		p.out.WriteString(p.data[start:p.pos])
+	case '\'':
+		rewrite(1, "'")
+	case 'v':
+		rewrite(1, `\u000b`)
+	case '0':
+		if c := p.data[p.pos+1:]; c != "" && c[0] >= '0' && c[0] <= '9' {
+			return p.fail("octal escapes are not allowed", "a character other than a digit after \\0")
+		}
+		rewrite(1, `\u0000`)
+	case 'x':
+		for i := 1; i <= 2; i++ {
+			if p.pos+i >= len(p.data) || !isHexDigit(p.data[p.pos+i]) {
+				p.pos += i
+				return p.unexpected(`2 hex digits after \x`)
+			}
+		}
+		rewrite(3, `\u00`+strings.ToLower(p.data[p.pos+1:p.pos+3]))
+	case '\r', '\n':
+		n := 1
+		if strings.HasPrefix(p.data[p.pos:], "\r\n") {
+			n = 2
+		}
+		p.fix(start, "escape", "removed a line continuation")
+		p.pos += n
+	default:
+		if e >= '1' && e <= '9' {
+			return p.fail("octal escapes are not allowed", `one of " \ / b f n r t u after the backslash`)
+		}
+		r, size := utf8.DecodeRuneInString(p.data[p.pos:])
+		if r == '\u2028' || r == '\u2029' {
+			p.fix(start, "escape", "removed a line continuation")
+			p.pos += size
+			return nil
+		}
+		p.fix(start, "escape", fmt.Sprintf("removed the unnecessary backslash before %q", r))
+		p.pos += size
+		p.out.WriteString(string(r))
+	}
+	return nil
+}
+
+// number converts a JSON5 number: hexadecimal integers become decimal, and a leading
+// plus sign, leading zeros and a missing digit before or after the decimal point are
+// fixed. Literals that are valid JSON are copied as written.
+func (p *jsonRepairer) number() *lintSyntaxError {
+	start := p.pos
+	sign := ""
+	if c := p.peek(); c == '+' || c == '-' {
+		if c == '-' {
+			sign = "-"
+		}
+		p.pos++
+	}
+	for _, word := range []string{"Infinity", "NaN"} {
+		if strings.HasPrefix(p.data[p.pos:], word) {
+			p.pos += len(word)
+			p.fix(start, "non_finite", fmt.Sprintf("replaced %s, which JSON cannot represent, with null", p.data[start:p.pos]))
+			p.out.WriteString("null")
+			return nil
+		}
+	}
+	if rest := p.data[p.pos:]; strings.HasPrefix(rest, "0x") || strings.HasPrefix(rest, "0X") {
+		p.pos += 2
+		digits := p.pos
+		for p.pos < len(p.data) && isHexDigit(p.data[p.pos]) {
+			p.pos++
+		}
+		if p.pos == digits {
+			return p.unexpected("a hex digit")
+		}
+		n, _ := new(big.Int).SetString(p.data[digits:p.pos], 16)
+		if n.Sign() == 0 {
+			sign = ""
+		}
+		out := sign + n.String()
+		p.fix(start, "number", fmt.Sprintf("rewrote the number %s as %s", p.data[start:p.pos], out))
+		p.out.WriteString(out)
+		return nil
+	}
+	intPart := p.digits()
+	frac, point := "", p.peek() == '.'
+	if point {
+		p.pos++
+		frac = p.digits()
+	}
+	if intPart == "" && frac == "" {
+		return p.unexpected("a digit")
+	}
+	out := strings.TrimLeft(intPart, "0")
+	if out == "" {
+		out = "0"
+	}
+	out = sign + out
+	if frac != "" {
+		out += "." + frac
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		exp := p.pos
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		if p.digits() == "" {
+			return p.unexpected("a digit in the exponent")
+		}
+		out += p.data[exp:p.pos]
+	}
+	if lit := p.data[start:p.pos]; out != lit {
+		p.fix(start, "number", fmt.Sprintf("rewrote the number %s as %s", lit, out))
+	}
+	p.out.WriteString(out)
+	return nil
+}
+
+func (p *jsonRepairer) digits() string {
+	start := p.pos
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	return p.data[start:p.pos]
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// jsonControlEscape returns the JSON escape for the control character c.
+func jsonControlEscape(c byte) string {
+	switch c {
+	case '\b':
+		return `\b`
+	case '\f':
+		return `\f`
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	}
+	return fmt.Sprintf(`\u%04x`, c)
+}
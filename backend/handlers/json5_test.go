@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLenientJSON(t *testing.T) {
+	cases := []struct {
+		name, value, want string
+		kinds             []string
+	}{
+		{"strict input is copied", `{"a": [1.50, "é\/"], "b": null}`, `{"a":[1.50,"é\/"],"b":null}`, nil},
+		{"comments", "// config\n{\n  \"a\": 1, /* inline */ \"b\": 2 // end\n}", `{"a":1,"b":2}`,
+			[]string{"comment", "comment", "comment"}},
+		{"trailing commas", `{"a": [1, 2,], }`, `{"a":[1,2]}`, []string{"trailing_comma", "trailing_comma"}},
+		{"unquoted keys", `{a: 1, $b_2: 2, ünï: 3}`, `{"a":1,"$b_2":2,"ünï":3}`,
+			[]string{"unquoted_key", "unquoted_key", "unquoted_key"}},
+		{"escaped unquoted keys", `{\u0061b: 1, a\u00e9: 2}`, `{"ab":1,"aé":2}`, []string{"unquoted_key", "unquoted_key"}},
+		{"trailing comma before a comment", "[1, // one\n]", `[1]`, []string{"trailing_comma", "comment"}},
+		{"single-quoted strings", `['say "hi"', 'it\'s']`, `["say \"hi\"","it's"]`,
+			[]string{"single_quotes", "single_quotes", "escape"}},
+		{"json5 escapes", `"\x41\v\0\q\` + "\n" + `end"`, `"\u0041\u000b\u0000qend"`,
+			[]string{"escape", "escape", "escape", "escape", "escape"}},
+		{"control characters", "\"a\tb\x01\"", `"a\tb\u0001"`, []string{"control_character", "control_character"}},
+		{"numbers", `[0x1F, -0XfF, +1, .5, 5., 007, 1e3, -0x0]`, `[31,-255,1,0.5,5,7,1e3,0]`,
+			[]string{"number", "number", "number", "number", "number", "number", "number"}},
+		{"non-finite numbers", `[Infinity, -Infinity, NaN]`, `[null,null,null]`,
+			[]string{"non_finite", "non_finite", "non_finite"}},
+		{"json5 whitespace", "\ufeff{ \"a\":\v1\u00a0}", `{"a":1}`, []string{"bom"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, fixes, err := lenientJSON("value", tc.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("result = %s, want %s", got, tc.want)
+			}
+			kinds := []string(nil)
+			for _, f := range fixes {
+				kinds = append(kinds, f.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tc.kinds) {
+				t.Errorf("fixes = %v, want %v", kinds, tc.kinds)
+			}
+		})
+	}
+}
+
+func TestLenientJSONErrors(t *testing.T) {
+	cases := []struct {
+		name, value string
+		offset      int
+	}{
+		{"unterminated comment", `[1 /* x`, 3},
+		{"missing comma", `{a: 1 b: 2}`, 6},
+		{"octal escape", `'\01'`, 2},
+		{"bad hex escape", `'\xZ1'`, 3},
+		{"trailing data", `{} {}`, 3},
+		{"bare word", `[undefined]`, 1},
+		{"escaped keyword", `tru\u0065`, 0},
+		{"key escape not an identifier character", `{a\u0020b: 1}`, 2},
+		{"key escape without hex digits", `{\u00g1: 1}`, 5},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := lenientJSON("valueB", tc.value)
+			var ae *APIError
+			if !errors.As(err, &ae) || ae.Code != CodeInvalidJSON || ae.Field != "valueB" {
+				t.Fatalf("err = %v, want invalid_json on valueB", err)
+			}
+			if ae.Details["offset"] != tc.offset {
+				t.Errorf("offset = %v, want %d (%s)", ae.Details["offset"], tc.offset, ae.Message)
+			}
+		})
+	}
+}
+
+func TestRepairJSON(t *testing.T) {
+	resp, err := repairJSON(RepairRequest{Value: "{\n  // port\n  port: 8080,\n}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"port\": 8080\n}"; resp.Result != want {
+		t.Errorf("result = %s, want %s", resp.Result, want)
+	}
+	want := []RepairFix{
+		{Kind: "comment", Message: "removed a // comment", Offset: 4, Line: 2, Column: 3},
+		{Kind: "unquoted_key", Message: "quoted the key port", Offset: 14, Line: 3, Column: 3},
+		{Kind: "trailing_comma", Message: "removed a trailing comma", Offset: 24, Line: 3, Column: 13},
+	}
+	if !reflect.DeepEqual(resp.Fixes, want) {
+		t.Errorf("fixes = %+v, want %+v", resp.Fixes, want)
+	}
+}
+
+// Lenient mode reaches every tool that offers it.
+func TestLenientTools(t *testing.T) {
+	const a, b = `{a: 1, /* c */ b: [1,2,],}`, `{'a': 1, b: [1, 3]}`
+	if resp, err := formatJSON(FormatRequest{Value: a, Lenient: true, CompactArrays: true}); err != nil || resp.Result != "{\n  \"a\": 1,\n  \"b\": [1, 2]\n}" {
+		t.Errorf("format = %q, %v", resp.Result, err)
+	}
+	if resp, err := minifyJSON(MinifyRequest{Value: a, Lenient: true}); err != nil || resp.Result != `{"a":1,"b":[1,2]}` {
+		t.Errorf("minify = %q, %v", resp.Result, err)
+	}
+	if resp, err := pathQueryJSON(context.Background(), PathRequest{Value: a, Path: "b.1", Lenient: true}); err != nil || resp.Result != "2" {
+		t.Errorf("path = %q, %v", resp.Result, err)
+	}
+	if resp, err := diffJSON(context.Background(), DiffRequest{ValueA: a, ValueB: b, Lenient: true}); err != nil || resp.Result != "b.1: 2 -> 3" {
+		t.Errorf("diff = %q, %v", resp.Result, err)
+	}
+	if _, err := minifyJSON(MinifyRequest{Value: a}); err == nil {
+		t.Error("minify accepted JSON5 without lenient")
+	}
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func runJSONHandler(t *testing.T, handler http.HandlerFunc, method, body string) (int, string) {
@@ -216,6 +219,33 @@ func TestDiffJSON(t *testing.T) {
 	}
 }
 
+func TestDiffJSONDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "http://test", strings.NewReader(`{"valueA":"[1,2]","valueB":"[1,3]"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	DiffJSON(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusServiceUnavailable, rec.Body.String())
+	}
+	if e := parseErrorResponse(t, rec.Body.String()); e.Code != CodeTimeout {
+		t.Errorf("code = %q, want %q", e.Code, CodeTimeout)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	v, err := decodeOrderedValue("value", `{"a":[{"b":1}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &differ{ctx: expired, mode: "lcs", format: formatDotPath}
+	err = d.diff(v, v, nil)
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeTimeout || ae.Status != http.StatusServiceUnavailable {
+		t.Errorf("diff past the deadline: err = %v, want a 503 timeout", err)
+	}
+}
+
 func TestSplitPath(t *testing.T) {
 	cases := []struct {
 		path string
@@ -0,0 +1,749 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements the diff tool: the list of changes between two JSON values, with
+// options for how array elements are paired up, paths to leave out and a tolerance for
+// numbers. The "patch" output (an RFC 6902 JSON Patch) is generated in jsonpatch.go.
+
+// DiffRequest is the JSON body for the diff endpoint. PathFormat chooses how changed
+// locations are written: "dot" (a.b.0, the default) or "pointer" (/a/b/0), which stays
+// unambiguous when keys contain dots. Output "patch" returns an RFC 6902 JSON Patch
+// that turns ValueA into ValueB instead of the "path: left -> right" lines of "text"
+// (the default); its paths are always JSON Pointers and it cannot be combined with
+// ArrayMode other than "index", Ignore or Tolerance.
+//
+// ArrayMode chooses how array elements are paired: "index" (the default) compares
+// elements at the same position, "lcs" aligns the arrays on their longest common
+// subsequence, "key" matches objects by the value of their ArrayKey member and "set"
+// ignores element order. Ignore lists locations to leave out, as JSON Pointers or dot
+// paths in which * matches any key or index. Numbers that differ by at most Tolerance
+// are equal. Lenient accepts JSON5 and JSONC input.
+type DiffRequest struct {
+	ValueA     string   `json:"valueA"`
+	ValueB     string   `json:"valueB"`
+	PathFormat string   `json:"pathFormat,omitempty" enum:"dot,pointer"`
+	Output     string   `json:"output,omitempty" enum:"text,patch"`
+	ArrayMode  string   `json:"arrayMode,omitempty" enum:"index,lcs,key,set"`
+	ArrayKey   string   `json:"arrayKey,omitempty"`
+	Ignore     []string `json:"ignore,omitempty"`
+	Tolerance  float64  `json:"tolerance,omitempty"`
+	Lenient    bool     `json:"lenient,omitempty"`
+}
+
+// DiffChange is one difference between the two values. Path locates it in ValueB,
+// except that a removed value is located in ValueA; for a moved array element, From is
+// its location in ValueA. Left and Right hold the values in ValueA and ValueB.
+type DiffChange struct {
+	Kind  string          `json:"kind" enum:"added,removed,moved,changed"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Left  json.RawMessage `json:"left,omitempty"`
+	Right json.RawMessage `json:"right,omitempty"`
+}
+
+// DiffResponse is the JSON response for the diff endpoint. Result is the changes as
+// text, one per line, or the JSON Patch for output "patch". With a patch, Changes
+// follows it: one change per operation, at the operation's pointers, which apply to the
+// document as the earlier operations left it rather than to ValueA or ValueB.
+type DiffResponse struct {
+	Result  string       `json:"result"`
+	Changes []DiffChange `json:"changes"`
+}
+
+func (r DiffResponse) resultText() string { return r.Result }
+
+// maxDiffIgnore caps the number of ignored paths.
+const maxDiffIgnore = 100
+
+// maxDiffCells caps the element pairs compared when aligning two arrays by LCS or as
+// sets; beyond it the remaining elements are paired by position.
+const maxDiffCells = 1 << 20
+
+// DiffJSON compares two JSON values and returns a structural diff.
+func DiffJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(diffJSON))
+}
+
+func diffJSON(ctx context.Context, req DiffRequest) (DiffResponse, error) {
+	d := &differ{ctx: ctx, mode: req.ArrayMode, key: req.ArrayKey, tolerance: req.Tolerance, changes: []DiffChange{}}
+	switch req.PathFormat {
+	case "", "dot":
+		d.format = formatDotPath
+	case "pointer":
+		d.format = formatPointer
+	default:
+		return DiffResponse{}, invalidOption("pathFormat", fmt.Sprintf("unknown path format %q", req.PathFormat), enumTag(req, "PathFormat")...)
+	}
+	switch req.Output {
+	case "", "text", "patch":
+	default:
+		return DiffResponse{}, invalidOption("output", fmt.Sprintf("unknown output %q", req.Output), enumTag(req, "Output")...)
+	}
+	switch d.mode {
+	case "":
+		d.mode = "index"
+	case "index", "lcs", "set":
+	case "key":
+		if req.ArrayKey == "" {
+			return DiffResponse{}, newError(CodeRequired, "arrayKey", `arrayKey is required with arrayMode "key"`)
+		}
+	default:
+		return DiffResponse{}, invalidOption("arrayMode", fmt.Sprintf("unknown array mode %q", req.ArrayMode), enumTag(req, "ArrayMode")...)
+	}
+	if req.Tolerance < 0 || math.IsNaN(req.Tolerance) || math.IsInf(req.Tolerance, 0) {
+		return DiffResponse{}, newError(CodeInvalidValue, "tolerance", "tolerance must be a non-negative number")
+	}
+	var err error
+	if d.ignore, err = parseDiffIgnore(req.Ignore); err != nil {
+		return DiffResponse{}, err
+	}
+	if req.Output == "patch" {
+		// A patch must turn ValueA into ValueB exactly, so it cannot leave out ignored
+		// paths or numbers within the tolerance, and it pairs array elements itself.
+		switch {
+		case len(req.Ignore) > 0:
+			return DiffResponse{}, newError(CodeInvalidOption, "ignore", `ignore cannot be used with output "patch"`)
+		case req.Tolerance > 0:
+			return DiffResponse{}, newError(CodeInvalidOption, "tolerance", `tolerance cannot be used with output "patch"`)
+		case d.mode != "index":
+			return DiffResponse{}, invalidOption("arrayMode", fmt.Sprintf(`arrayMode %q cannot be used with output "patch"`, d.mode), "index")
+		}
+	}
+	valueA, err := strictJSON("valueA", req.ValueA, req.Lenient)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+	valueB, err := strictJSON("valueB", req.ValueB, req.Lenient)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+	a, err := decodeOrderedValue("valueA", valueA)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+	b, err := decodeOrderedValue("valueB", valueB)
+	if err != nil {
+		return DiffResponse{}, err
+	}
+	if req.Output == "patch" {
+		ops, err := diffPatch(ctx, a, b)
+		if err != nil {
+			return DiffResponse{}, err
+		}
+		resp := DiffResponse{Changes: patchChanges(ops)}
+		if resp.Result, err = encodePatch(ops); err != nil {
+			return DiffResponse{}, err
+		}
+		return resp, nil
+	}
+	if err := d.diff(a, b, []string{}); err != nil {
+		return DiffResponse{}, err
+	}
+	return DiffResponse{Result: diffText(d.changes), Changes: d.changes}, nil
+}
+
+// formatDotPath joins tokens into a dot path (a.b.0).
+func formatDotPath(tokens []string) string {
+	return strings.Join(tokens, ".")
+}
+
+// parseDiffIgnore splits each ignored path into tokens: JSON Pointers start with "/",
+// anything else is a dot path.
+func parseDiffIgnore(paths []string) ([][]string, error) {
+	if len(paths) > maxDiffIgnore {
+		e := newError(CodeTooMany, "ignore", fmt.Sprintf("too many ignored paths (max %d)", maxDiffIgnore))
+		e.Details = map[string]interface{}{"max": maxDiffIgnore}
+		return nil, e
+	}
+	var patterns [][]string
+	for _, p := range paths {
+		switch {
+		case p == "":
+			return nil, newError(CodeInvalidValue, "ignore", "ignored paths must not be empty")
+		case strings.HasPrefix(p, "/"):
+			tokens, err := parsePointer(p)
+			if err != nil {
+				return nil, querySyntaxError("ignore", p, err)
+			}
+			patterns = append(patterns, tokens)
+		default:
+			patterns = append(patterns, strings.Split(p, "."))
+		}
+	}
+	return patterns, nil
+}
+
+// diffText writes changes as lines: "path: left -> right", with (missing) for the side
+// an added or removed value is not on, and "path: moved from from" for moved elements.
+func diffText(changes []DiffChange) string {
+	if len(changes) == 0 {
+		return "(no differences)"
+	}
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		label := c.Path
+		if label == "" {
+			label = "(root)"
+		}
+		switch c.Kind {
+		case "added":
+			lines[i] = fmt.Sprintf("%s: (missing) -> %s", label, c.Right)
+		case "removed":
+			lines[i] = fmt.Sprintf("%s: %s -> (missing)", label, c.Left)
+		case "moved":
+			lines[i] = fmt.Sprintf("%s: moved from %s", label, c.From)
+		default:
+			lines[i] = fmt.Sprintf("%s: %s -> %s", label, c.Left, c.Right)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errDiffFound stops a quiet differ at the first change.
+var errDiffFound = errors.New("difference found")
+
+// differ collects the changes between two decoded values.
+type differ struct {
+	ctx       context.Context
+	mode      string // arrayMode
+	key       string // arrayKey
+	ignore    [][]string
+	tolerance float64
+	format    func(tokens []string) string
+	quiet     bool // stop with errDiffFound at the first change (see same)
+	changes   []DiffChange
+}
+
+// diffStep pairs element a of one array with element b of the other; -1 marks an
+// element that was removed (b) or added (a).
+type diffStep struct {
+	a, b  int
+	moved bool
+}
+
+func (d *differ) emit(kind string, tokens, from []string, left, right interface{}) error {
+	if d.quiet {
+		return errDiffFound
+	}
+	c := DiffChange{Kind: kind, Path: d.format(tokens)}
+	if from != nil {
+		c.From = d.format(from)
+	}
+	if kind == "removed" || kind == "changed" {
+		c.Left = diffRaw(left)
+	}
+	if kind == "added" || kind == "changed" {
+		c.Right = diffRaw(right)
+	}
+	d.changes = append(d.changes, c)
+	return nil
+}
+
+// diffRaw encodes v as compact JSON.
+func diffRaw(v interface{}) json.RawMessage {
+	out, _ := (&jqEval{}).encode(v, "")
+	return out
+}
+
+// ignored reports whether tokens match one of the ignored paths.
+func (d *differ) ignored(tokens []string) bool {
+patterns:
+	for _, p := range d.ignore {
+		if len(p) != len(tokens) {
+			continue
+		}
+		for i, tok := range p {
+			if tok != "*" && tok != tokens[i] {
+				continue patterns
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// diff compares a and b at tokens. It stops with a timeout error when ctx is done.
+func (d *differ) diff(a, b interface{}, tokens []string) error {
+	if err := checkContext(d.ctx); err != nil {
+		return err
+	}
+	if d.ignored(tokens) {
+		return nil
+	}
+	switch x := a.(type) {
+	case *jqObject:
+		if y, ok := b.(*jqObject); ok {
+			return d.objects(x, y, tokens)
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			return d.arrays(x, y, tokens)
+		}
+	}
+	if d.equalScalars(a, b) {
+		return nil
+	}
+	return d.emit("changed", tokens, nil, a, b)
+}
+
+// equalScalars compares two values that are not both objects or both arrays.
+func (d *differ) equalScalars(a, b interface{}) bool {
+	for _, v := range []interface{}{a, b} {
+		switch v.(type) {
+		case *jqObject, []interface{}:
+			return false
+		}
+	}
+	x, xok := a.(json.Number)
+	y, yok := b.(json.Number)
+	if !xok || !yok {
+		return a == b
+	}
+	if schemaCompare(x, y) == 0 {
+		return true
+	}
+	fx, _ := x.Float64()
+	fy, _ := y.Float64()
+	return d.tolerance > 0 && math.Abs(fx-fy) <= d.tolerance
+}
+
+func (d *differ) objects(x, y *jqObject, tokens []string) error {
+	for _, k := range x.keys {
+		t := childTokens(tokens, k)
+		if yv, ok := y.get(k); ok {
+			if err := d.diff(x.vals[k], yv, t); err != nil {
+				return err
+			}
+		} else if !d.ignored(t) {
+			if err := d.emit("removed", t, nil, x.vals[k], nil); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range y.keys {
+		if _, ok := x.get(k); ok {
+			continue
+		}
+		if t := childTokens(tokens, k); !d.ignored(t) {
+			if err := d.emit("added", t, nil, nil, y.vals[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *differ) arrays(x, y []interface{}, tokens []string) error {
+	var steps []diffStep
+	var err error
+	switch d.mode {
+	case "lcs":
+		steps, err = d.alignLCS(x, y, diffIndexes(len(x)), diffIndexes(len(y)), tokens)
+	case "set":
+		steps, err = d.alignSet(x, y, tokens)
+	case "key":
+		steps, err = d.alignKey(x, y, tokens)
+	default:
+		steps = diffByIndex(diffIndexes(len(x)), diffIndexes(len(y)))
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range steps {
+		switch {
+		case s.a >= 0 && s.b >= 0:
+			t := childTokens(tokens, strconv.Itoa(s.b))
+			if s.moved && !d.ignored(t) {
+				if err := d.emit("moved", t, childTokens(tokens, strconv.Itoa(s.a)), nil, nil); err != nil {
+					return err
+				}
+			}
+			err = d.diff(x[s.a], y[s.b], t)
+		case s.a >= 0:
+			if t := childTokens(tokens, strconv.Itoa(s.a)); !d.ignored(t) {
+				err = d.emit("removed", t, nil, x[s.a], nil)
+			}
+		default:
+			if t := childTokens(tokens, strconv.Itoa(s.b)); !d.ignored(t) {
+				err = d.emit("added", t, nil, nil, y[s.b])
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// same reports whether a and b have no differences under d's options.
+func (d *differ) same(a, b interface{}, tokens []string) (bool, error) {
+	q := *d
+	q.quiet = true
+	err := q.diff(a, b, tokens)
+	if err == errDiffFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// diffIndexes returns 0..n-1.
+func diffIndexes(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// diffByIndex pairs the elements ai and bi by position; the longer list's extra
+// elements are removed or added.
+func diffByIndex(ai, bi []int) []diffStep {
+	var steps []diffStep
+	for i := 0; i < len(ai) || i < len(bi); i++ {
+		switch {
+		case i >= len(ai):
+			steps = append(steps, diffStep{a: -1, b: bi[i]})
+		case i >= len(bi):
+			steps = append(steps, diffStep{a: ai[i], b: -1})
+		default:
+			steps = append(steps, diffStep{a: ai[i], b: bi[i]})
+		}
+	}
+	return steps
+}
+
+// alignLCS aligns the elements ai of x with the elements bi of y on their longest
+// common subsequence of equal elements. Between two equal pairs, removed and added
+// elements are paired by position (and compared in turn); of the rest, an element
+// removed in one place and added in another is a move.
+func (d *differ) alignLCS(x, y []interface{}, ai, bi []int, tokens []string) ([]diffStep, error) {
+	eq := func(i, j int) (bool, error) {
+		return d.same(x[ai[i]], y[bi[j]], childTokens(tokens, strconv.Itoa(bi[j])))
+	}
+	// Common prefix and suffix.
+	pre := 0
+	for pre < len(ai) && pre < len(bi) {
+		if ok, err := eq(pre, pre); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		pre++
+	}
+	suf := 0
+	for suf < len(ai)-pre && suf < len(bi)-pre {
+		if ok, err := eq(len(ai)-1-suf, len(bi)-1-suf); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+		suf++
+	}
+	n, m := len(ai)-pre-suf, len(bi)-pre-suf
+	var steps []diffStep
+	for i := 0; i < pre; i++ {
+		steps = append(steps, diffStep{a: ai[i], b: bi[i]})
+	}
+	if n*m > maxDiffCells {
+		steps = append(steps, diffByIndex(ai[pre:pre+n], bi[pre:pre+m])...)
+	} else {
+		// lcs[i][j] is the LCS length of the middle elements from i and j on.
+		same := make([][]bool, n)
+		lcs := make([][]int, n+1)
+		lcs[n] = make([]int, m+1)
+		for i := n - 1; i >= 0; i-- {
+			same[i] = make([]bool, m)
+			lcs[i] = make([]int, m+1)
+			for j := m - 1; j >= 0; j-- {
+				ok, err := eq(pre+i, pre+j)
+				if err != nil {
+					return nil, err
+				}
+				same[i][j] = ok
+				switch {
+				case ok:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] >= lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		var gapA, gapB []int
+		flush := func() {
+			steps = append(steps, diffByIndex(gapA, gapB)...)
+			gapA, gapB = nil, nil
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && same[i][j]:
+				flush()
+				steps = append(steps, diffStep{a: ai[pre+i], b: bi[pre+j]})
+				i++
+				j++
+			case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+				gapA = append(gapA, ai[pre+i])
+				i++
+			default:
+				gapB = append(gapB, bi[pre+j])
+				j++
+			}
+		}
+		flush()
+	}
+	for i := len(ai) - suf; i < len(ai); i++ {
+		steps = append(steps, diffStep{a: ai[i], b: bi[i-len(ai)+len(bi)]})
+	}
+	return d.findMoves(x, y, steps, tokens)
+}
+
+// findMoves turns a removed element and an equal added element into one moved pair,
+// placed where the element was added. An element added back at the index it was
+// removed from is paired without a move.
+func (d *differ) findMoves(x, y []interface{}, steps []diffStep, tokens []string) ([]diffStep, error) {
+	var removed []int // indexes into steps
+	for k, s := range steps {
+		if s.b < 0 {
+			removed = append(removed, k)
+		}
+	}
+	if len(removed) == 0 {
+		return steps, nil
+	}
+	gone := make(map[int]bool)
+	for k, s := range steps {
+		if s.a >= 0 {
+			continue
+		}
+		for r, rk := range removed {
+			if rk < 0 {
+				continue
+			}
+			ok, err := d.same(x[steps[rk].a], y[s.b], childTokens(tokens, strconv.Itoa(s.b)))
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				steps[k] = diffStep{a: steps[rk].a, b: s.b, moved: steps[rk].a != s.b}
+				gone[rk] = true
+				removed[r] = -1
+				break
+			}
+		}
+	}
+	out := steps[:0]
+	for k, s := range steps {
+		if !gone[k] {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// alignSet pairs every element of y with the first unpaired equal element of x, in
+// any order.
+func (d *differ) alignSet(x, y []interface{}, tokens []string) ([]diffStep, error) {
+	paired := make([]bool, len(x))
+	var steps []diffStep
+	var unpaired []int
+	// Identical elements first, by their canonical form.
+	exact := make(map[string][]int)
+	for i, v := range x {
+		k := schemaKey(v)
+		exact[k] = append(exact[k], i)
+	}
+	for j, v := range y {
+		k := schemaKey(v)
+		if is := exact[k]; len(is) > 0 {
+			exact[k] = is[1:]
+			paired[is[0]] = true
+			steps = append(steps, diffStep{a: is[0], b: j})
+		} else {
+			unpaired = append(unpaired, j)
+		}
+	}
+	// Then elements that are equal under the options (tolerance, ignored paths).
+	var left []int
+	for i := range x {
+		if !paired[i] {
+			left = append(left, i)
+		}
+	}
+	for _, j := range unpaired {
+		found := -1
+		if len(left)*len(unpaired) <= maxDiffCells {
+			for _, i := range left {
+				if paired[i] {
+					continue
+				}
+				ok, err := d.same(x[i], y[j], childTokens(tokens, strconv.Itoa(j)))
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					found = i
+					break
+				}
+			}
+		}
+		if found >= 0 {
+			paired[found] = true
+		}
+		steps = append(steps, diffStep{a: found, b: j})
+	}
+	for i := range x {
+		if !paired[i] {
+			steps = append(steps, diffStep{a: i, b: -1})
+		}
+	}
+	sortDiffSteps(steps)
+	return steps, nil
+}
+
+// alignKey pairs objects by the value of their d.key member, in order for repeated
+// values. Paired elements whose order changed are moves; elements without the key are
+// aligned by LCS.
+func (d *differ) alignKey(x, y []interface{}, tokens []string) ([]diffStep, error) {
+	keyOf := func(v interface{}) (string, bool) {
+		obj, ok := v.(*jqObject)
+		if !ok {
+			return "", false
+		}
+		kv, ok := obj.get(d.key)
+		if !ok {
+			return "", false
+		}
+		return schemaKey(kv), true
+	}
+	byKey := make(map[string][]int)
+	var ua, ub []int
+	for i, v := range x {
+		if k, ok := keyOf(v); ok {
+			byKey[k] = append(byKey[k], i)
+		} else {
+			ua = append(ua, i)
+		}
+	}
+	var steps []diffStep
+	var pairs []int // indexes into steps, in y order
+	for j, v := range y {
+		k, ok := keyOf(v)
+		if !ok {
+			ub = append(ub, j)
+			continue
+		}
+		if is := byKey[k]; len(is) > 0 {
+			byKey[k] = is[1:]
+			pairs = append(pairs, len(steps))
+			steps = append(steps, diffStep{a: is[0], b: j})
+		} else {
+			steps = append(steps, diffStep{a: -1, b: j})
+		}
+	}
+	for _, is := range byKey {
+		for _, i := range is {
+			steps = append(steps, diffStep{a: i, b: -1})
+		}
+	}
+	// The pairs on a longest run of increasing x indexes kept their order. Runs that
+	// keep more elements at their old index are preferred, and an element that is still
+	// at its old index is never reported as moved.
+	seq := make([]int, len(pairs))
+	stayed := make([]bool, len(pairs))
+	for n, k := range pairs {
+		seq[n] = steps[k].a
+		stayed[n] = steps[k].a == steps[k].b
+	}
+	kept := longestIncreasing(seq, stayed)
+	for n, k := range pairs {
+		steps[k].moved = !kept[n] && !stayed[n]
+	}
+	rest, err := d.alignLCS(x, y, ua, ub, tokens)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, rest...)
+	sortDiffSteps(steps)
+	return steps, nil
+}
+
+// sortDiffSteps orders steps by their position in y, followed by the removed
+// elements in x order.
+func sortDiffSteps(steps []diffStep) {
+	sort.SliceStable(steps, func(i, j int) bool {
+		si, sj := steps[i], steps[j]
+		if (si.b < 0) != (sj.b < 0) {
+			return sj.b < 0
+		}
+		if si.b < 0 {
+			return si.a < sj.a
+		}
+		return si.b < sj.b
+	})
+}
+
+// longestIncreasing marks the members of a longest strictly increasing subsequence
+// of seq, which holds distinct non-negative ints. Among the longest runs it picks one
+// with the most elements marked in prefer (nil prefers none), and then the one that
+// ends last.
+func longestIncreasing(seq []int, prefer []bool) []bool {
+	// run is the best increasing run found ending at seq[last] (last is -1 for none).
+	type run struct{ length, preferred, last int }
+	better := func(p, q run) bool {
+		return p.length > q.length || p.length == q.length && p.preferred > q.preferred
+	}
+	size := 0
+	for _, v := range seq {
+		if v+1 > size {
+			size = v + 1
+		}
+	}
+	// tree is a Fenwick tree over values: a prefix query gives the best run ending
+	// at a value below v. prev links each element to the one before it in its run.
+	tree := make([]run, size+1)
+	for k := range tree {
+		tree[k].last = -1
+	}
+	prev := make([]int, len(seq))
+	best := run{last: -1}
+	for i, v := range seq {
+		r := run{last: -1}
+		for k := v; k > 0; k -= k & -k {
+			if better(tree[k], r) {
+				r = tree[k]
+			}
+		}
+		prev[i] = r.last
+		r.length++
+		if prefer != nil && prefer[i] {
+			r.preferred++
+		}
+		r.last = i
+		for k := v + 1; k <= size; k += k & -k {
+			if better(r, tree[k]) {
+				tree[k] = r
+			}
+		}
+		if !better(best, r) {
+			best = r
+		}
+	}
+	kept := make([]bool, len(seq))
+	for i := best.last; i >= 0; i = prev[i] {
+		kept[i] = true
+	}
+	return kept
+}
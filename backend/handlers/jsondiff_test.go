@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSONOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		req  DiffRequest
+		want string
+	}{
+		{"index", DiffRequest{ValueA: `{"a": 1, "b": {"c": [1, 2]}, "d": "x"}`, ValueB: `{"a": 2, "b": {"c": [1]}, "e": null}`},
+			"a: 1 -> 2\nb.c.1: 2 -> (missing)\nd: \"x\" -> (missing)\ne: (missing) -> null"},
+		{"index shifts", DiffRequest{ValueA: `[1, 2]`, ValueB: `[0, 1, 2]`, PathFormat: "pointer"},
+			"/0: 1 -> 0\n/1: 2 -> 1\n/2: (missing) -> 2"},
+		{"lcs insert", DiffRequest{ValueA: `[1, 2]`, ValueB: `[0, 1, 2]`, PathFormat: "pointer", ArrayMode: "lcs"},
+			"/0: (missing) -> 0"},
+		{"lcs change between equal elements", DiffRequest{ValueA: `["a", {"n": 1}, "c", "d"]`, ValueB: `["a", {"n": 2}, "c"]`, ArrayMode: "lcs"},
+			"1.n: 1 -> 2\n3: \"d\" -> (missing)"},
+		{"lcs move", DiffRequest{ValueA: `["a", "b", "c"]`, ValueB: `["b", "c", "a"]`, PathFormat: "pointer", ArrayMode: "lcs"},
+			"/2: moved from /0"},
+		{"key", DiffRequest{ValueA: `[{"id": 1, "n": "a"}, {"id": 2, "n": "b"}, {"id": 3}]`, ValueB: `[{"id": 3}, {"id": 1, "n": "A"}, {"id": 4}]`,
+			PathFormat: "pointer", ArrayMode: "key", ArrayKey: "id"},
+			"/0: moved from /2\n/1/n: \"a\" -> \"A\"\n/2: (missing) -> {\"id\":4}\n/1: {\"id\":2,\"n\":\"b\"} -> (missing)"},
+		{"key move keeps elements at their index", DiffRequest{ValueA: `[{"id": 1}, {"id": 2}]`, ValueB: `[{"id": 0}, {"id": 2, "v": 3}, {"id": 1}]`,
+			ArrayMode: "key", ArrayKey: "id"},
+			"0: (missing) -> {\"id\":0}\n1.v: (missing) -> 3\n2: moved from 0"},
+		{"lcs element back at its index", DiffRequest{ValueA: `[1, 2, 3]`, ValueB: `[3, 2, 1]`, ArrayMode: "lcs"},
+			"2: moved from 0"},
+		{"key with unkeyed elements", DiffRequest{ValueA: `[{"id": 1}, "x", "y"]`, ValueB: `["y", {"id": 1, "v": true}]`, ArrayMode: "key", ArrayKey: "id"},
+			"1.v: (missing) -> true\n1: \"x\" -> (missing)"},
+		{"set", DiffRequest{ValueA: `[1, 2, 3, 3]`, ValueB: `[3, 1, 4, 3]`, ArrayMode: "set"},
+			"2: (missing) -> 4\n1: 2 -> (missing)"},
+		{"ignore", DiffRequest{ValueA: `{"a": {"ts": 1, "v": 1}, "items": [{"ts": 5, "x": 1}], "gone": 1}`, ValueB: `{"a": {"ts": 2, "v": 1}, "items": [{"ts": 6, "x": 2}]}`,
+			Ignore: []string{"a.ts", "/items/*/ts", "gone"}},
+			"items.0.x: 1 -> 2"},
+		{"tolerance", DiffRequest{ValueA: `{"x": 1.0, "y": 2}`, ValueB: `{"x": 1.005, "y": 2.5}`, Tolerance: 0.01},
+			"y: 2 -> 2.5"},
+		{"exact numbers", DiffRequest{ValueA: `[1, 1.0, 1e2]`, ValueB: `[1.0, 1, 100]`}, "(no differences)"},
+		{"set with tolerance", DiffRequest{ValueA: `[1.0, 2.0]`, ValueB: `[2.001, 0.999]`, ArrayMode: "set", Tolerance: 0.01}, "(no differences)"},
+		{"root", DiffRequest{ValueA: `{"a": 1}`, ValueB: `[1]`}, "(root): {\"a\":1} -> [1]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := diffJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+			if len(resp.Changes) == 0 && resp.Result != "(no differences)" {
+				t.Errorf("changes is empty")
+			}
+		})
+	}
+}
+
+func TestDiffJSONChanges(t *testing.T) {
+	resp, err := diffJSON(context.Background(), DiffRequest{
+		ValueA: `{"tags": ["a", "b"], "n": 1, "old": {"x": 1}}`, ValueB: `{"tags": ["b", "a"], "n": 2, "new": null}`,
+		PathFormat: "pointer", ArrayMode: "lcs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiffChange{
+		{Kind: "moved", Path: "/tags/1", From: "/tags/0"},
+		{Kind: "changed", Path: "/n", Left: []byte(`1`), Right: []byte(`2`)},
+		{Kind: "removed", Path: "/old", Left: []byte(`{"x":1}`)},
+		{Kind: "added", Path: "/new", Right: []byte(`null`)},
+	}
+	if !reflect.DeepEqual(resp.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", resp.Changes, want)
+	}
+}
+
+func TestDiffJSONOptionErrors(t *testing.T) {
+	many := make([]string, maxDiffIgnore+1)
+	for i := range many {
+		many[i] = "a"
+	}
+	cases := []struct {
+		name  string
+		req   DiffRequest
+		code  string
+		field string
+	}{
+		{"unknown array mode", DiffRequest{ValueA: `1`, ValueB: `1`, ArrayMode: "hash"}, CodeInvalidOption, "arrayMode"},
+		{"key without arrayKey", DiffRequest{ValueA: `1`, ValueB: `1`, ArrayMode: "key"}, CodeRequired, "arrayKey"},
+		{"negative tolerance", DiffRequest{ValueA: `1`, ValueB: `1`, Tolerance: -1}, CodeInvalidValue, "tolerance"},
+		{"bad ignore pointer", DiffRequest{ValueA: `1`, ValueB: `1`, Ignore: []string{"/a~2"}}, CodeInvalidQuery, "ignore"},
+		{"empty ignore path", DiffRequest{ValueA: `1`, ValueB: `1`, Ignore: []string{""}}, CodeInvalidValue, "ignore"},
+		{"too many ignore paths", DiffRequest{ValueA: `1`, ValueB: `1`, Ignore: many}, CodeTooMany, "ignore"},
+		// A patch has to reproduce ValueB exactly, so options that hide changes are rejected.
+		{"patch with ignore", DiffRequest{ValueA: `{"t":1}`, ValueB: `{"t":2}`, Output: "patch", Ignore: []string{"t"}}, CodeInvalidOption, "ignore"},
+		{"patch with tolerance", DiffRequest{ValueA: `{"n":1}`, ValueB: `{"n":1.05}`, Output: "patch", Tolerance: 0.1}, CodeInvalidOption, "tolerance"},
+		{"patch with array mode", DiffRequest{ValueA: `[1]`, ValueB: `[2]`, Output: "patch", ArrayMode: "set"}, CodeInvalidOption, "arrayMode"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := diffJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.code || ae.Field != tc.field {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.code, tc.field)
+			}
+		})
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	got := longestIncreasing([]int{3, 0, 1, 5, 2}, nil)
+	want := []bool{false, true, true, false, true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("longestIncreasing = %v, want %v", got, want)
+	}
+	got = longestIncreasing([]int{2, 0, 1, 3}, []bool{false, false, true, true})
+	want = []bool{false, true, true, true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("longestIncreasing with prefer = %v, want %v", got, want)
+	}
+	got = longestIncreasing([]int{1, 0}, []bool{true, false})
+	want = []bool{true, false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("longestIncreasing tie = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// The formatter works on the token stream of the input rather than on decoded values:
+// every string and number is copied exactly as written, object members keep their
+// order and duplicate keys are kept.
+
+// maxFormatIndent caps the indent option of the JSON and YAML tools.
+const maxFormatIndent = 8
+
+// FormatRequest is the JSON body for the format endpoint. Indent is the number of spaces
+// per level (2 when zero); Tabs indents with one tab per level instead. SortKeys orders
+// object members by key, keeping duplicates in input order, and CompactArrays writes
+// arrays that hold only strings, numbers, booleans and nulls on a single line. Lenient
+// accepts JSON5 and JSONC input (see json5.go).
+type FormatRequest struct {
+	Value         string `json:"value"`
+	Indent        int    `json:"indent,omitempty"`
+	Tabs          bool   `json:"tabs,omitempty"`
+	SortKeys      bool   `json:"sortKeys,omitempty"`
+	CompactArrays bool   `json:"compactArrays,omitempty"`
+	Lenient       bool   `json:"lenient,omitempty"`
+}
+
+// MinifyRequest is the JSON body for the minify endpoint. Lenient accepts JSON5 and
+// JSONC input.
+type MinifyRequest struct {
+	Value   string `json:"value"`
+	Lenient bool   `json:"lenient,omitempty"`
+}
+
+// FormatJSON pretty-prints JSON, keeping key order and number literals.
+func FormatJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(formatJSON))
+}
+
+// formatIndent checks an indent option shared by the JSON and YAML tools: 0 means the
+// default of 2 spaces, anything else must lie between min and maxFormatIndent.
+func formatIndent(indent, min int) (int, error) {
+	if indent == 0 {
+		return 2, nil
+	}
+	if indent < min || indent > maxFormatIndent {
+		e := outOfRange("indent", min, maxFormatIndent)
+		e.Message = fmt.Sprintf("indent must be between %d and %d, or 0 for the default of 2", min, maxFormatIndent)
+		e.Details["default"] = 2
+		return 0, e
+	}
+	return indent, nil
+}
+
+func formatJSON(req FormatRequest) (StringResponse, error) {
+	size, err := formatIndent(req.Indent, 1)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	value, err := strictJSON("value", req.Value, req.Lenient)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	n, err := scanJSONValue("value", value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	f := &jsonFormatter{indent: "\t", sortKeys: req.SortKeys, compactArrays: req.CompactArrays}
+	if !req.Tabs {
+		f.indent = strings.Repeat(" ", size)
+	}
+	f.write(n, 0)
+	return StringResponse{Result: f.out.String()}, nil
+}
+
+// MinifyJSON removes unnecessary whitespace from JSON.
+func MinifyJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(minifyJSON))
+}
+
+func minifyJSON(req MinifyRequest) (StringResponse, error) {
+	value, err := strictJSON("value", req.Value, req.Lenient)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	n, err := scanJSONValue("value", value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	f := &jsonFormatter{}
+	f.write(n, 0)
+	return StringResponse{Result: f.out.String()}, nil
+}
+
+// fmtNode is a JSON value as written in the input. Scalars keep their literal text in
+// raw; objects hold their keys (quoted, as written) alongside elems.
+type fmtNode struct {
+	raw    string
+	object bool
+	keys   []string
+	elems  []*fmtNode
+}
+
+func (n *fmtNode) scalar() bool {
+	return n.raw != ""
+}
+
+// scanJSONValue checks that s (the text in field) is valid JSON and splits it into
+// fmtNodes.
+func scanJSONValue(field, s string) (*fmtNode, error) {
+	if !json.Valid([]byte(s)) {
+		// Unmarshal reports the syntax error with the offset invalidJSON expects.
+		var v interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return nil, invalidJSON(field, []byte(s), err)
+	}
+	sc := &fmtScanner{data: s}
+	return sc.value(), nil
+}
+
+// fmtScanner walks JSON text that json.Valid has accepted, so it does not check syntax.
+type fmtScanner struct {
+	data string
+	pos  int
+}
+
+func (s *fmtScanner) skipSpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+// next skips whitespace and consumes the following byte.
+func (s *fmtScanner) next() byte {
+	s.skipSpace()
+	c := s.data[s.pos]
+	s.pos++
+	return c
+}
+
+func (s *fmtScanner) value() *fmtNode {
+	s.skipSpace()
+	switch s.data[s.pos] {
+	case '{', '[':
+		n := &fmtNode{object: s.next() == '{'}
+		s.skipSpace()
+		if c := s.data[s.pos]; c == '}' || c == ']' {
+			s.pos++
+			return n
+		}
+		for {
+			if n.object {
+				s.skipSpace()
+				n.keys = append(n.keys, s.str())
+				s.next() // ':'
+			}
+			n.elems = append(n.elems, s.value())
+			if c := s.next(); c != ',' {
+				return n
+			}
+		}
+	case '"':
+		return &fmtNode{raw: s.str()}
+	default:
+		start := s.pos
+		for s.pos < len(s.data) && strings.IndexByte(",]} \t\r\n", s.data[s.pos]) < 0 {
+			s.pos++
+		}
+		return &fmtNode{raw: s.data[start:s.pos]}
+	}
+}
+
+// str consumes a string literal and returns it with its quotes and escapes.
+func (s *fmtScanner) str() string {
+	start := s.pos
+	for s.pos++; s.data[s.pos] != '"'; s.pos++ {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+	}
+	s.pos++
+	return s.data[start:s.pos]
+}
+
+// jsonFormatter writes fmtNodes back out. An empty indent writes compact JSON.
+type jsonFormatter struct {
+	out           strings.Builder
+	indent        string
+	sortKeys      bool
+	compactArrays bool
+}
+
+func (f *jsonFormatter) write(n *fmtNode, depth int) {
+	if n.scalar() {
+		f.out.WriteString(n.raw)
+		return
+	}
+	open, closer := byte('['), byte(']')
+	if n.object {
+		open, closer = '{', '}'
+	}
+	f.out.WriteByte(open)
+	if len(n.elems) == 0 {
+		f.out.WriteByte(closer)
+		return
+	}
+	inline := f.indent == "" || !n.object && f.compactArrays && allScalars(n.elems)
+	for j, i := range f.order(n) {
+		if j > 0 {
+			f.out.WriteByte(',')
+			if inline && f.indent != "" {
+				f.out.WriteByte(' ')
+			}
+		}
+		if !inline {
+			f.newline(depth + 1)
+		}
+		if n.object {
+			f.out.WriteString(n.keys[i])
+			f.out.WriteByte(':')
+			if f.indent != "" {
+				f.out.WriteByte(' ')
+			}
+		}
+		f.write(n.elems[i], depth+1)
+	}
+	if !inline {
+		f.newline(depth)
+	}
+	f.out.WriteByte(closer)
+}
+
+func (f *jsonFormatter) newline(depth int) {
+	f.out.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		f.out.WriteString(f.indent)
+	}
+}
+
+// order returns the indexes of n's elements in output order: input order, or for
+// objects with sortKeys, sorted by the decoded key.
+func (f *jsonFormatter) order(n *fmtNode) []int {
+	idx := make([]int, len(n.elems))
+	for i := range idx {
+		idx[i] = i
+	}
+	if !n.object || !f.sortKeys {
+		return idx
+	}
+	keys := make([]string, len(n.keys))
+	for i, k := range n.keys {
+		// The scanner only hands out valid string literals.
+		_ = json.Unmarshal([]byte(k), &keys[i])
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return keys[idx[a]] < keys[idx[b]] })
+	return idx
+}
+
+func allScalars(nodes []*fmtNode) bool {
+	for _, n := range nodes {
+		if !n.scalar() {
+			return false
+		}
+	}
+	return true
+}
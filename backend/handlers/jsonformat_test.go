@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatJSONOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		req  FormatRequest
+		want string
+	}{
+		{"key order and numbers", FormatRequest{Value: `{"z": 9007199254740993, "a": 1.50, "m": 1e400}`},
+			"{\n  \"z\": 9007199254740993,\n  \"a\": 1.50,\n  \"m\": 1e400\n}"},
+		{"duplicate keys", FormatRequest{Value: `{"a":1,"a":2}`}, "{\n  \"a\": 1,\n  \"a\": 2\n}"},
+		{"string escapes", FormatRequest{Value: `["\u00e9\/<&>", "é"]`}, "[\n  \"\\u00e9\\/<&>\",\n  \"é\"\n]"},
+		{"empty containers", FormatRequest{Value: ` {"a": [ ], "b": { }} `}, "{\n  \"a\": [],\n  \"b\": {}\n}"},
+		{"scalar", FormatRequest{Value: " 1.0 "}, "1.0"},
+		{"indent", FormatRequest{Value: `{"a":[1]}`, Indent: 4}, "{\n    \"a\": [\n        1\n    ]\n}"},
+		{"tabs", FormatRequest{Value: `{"a":{"b":1}}`, Tabs: true}, "{\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}"},
+		{"sort keys", FormatRequest{Value: `{"b":1,"a":{"d":1,"c":2},"\u0061":3}`, SortKeys: true},
+			"{\n  \"a\": {\n    \"c\": 2,\n    \"d\": 1\n  },\n  \"\\u0061\": 3,\n  \"b\": 1\n}"},
+		{"compact arrays", FormatRequest{Value: `{"a":[1,"x",null,true],"b":[[1],{"c":[]}]}`, CompactArrays: true},
+			"{\n  \"a\": [1, \"x\", null, true],\n  \"b\": [\n    [1],\n    {\n      \"c\": []\n    }\n  ]\n}"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := formatJSON(tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatJSONIndentRange(t *testing.T) {
+	for _, indent := range []int{-1, maxFormatIndent + 1} {
+		_, err := formatJSON(FormatRequest{Value: `{}`, Indent: indent})
+		var ae *APIError
+		if !errors.As(err, &ae) || ae.Code != CodeOutOfRange || ae.Field != "indent" {
+			t.Fatalf("indent %d: err = %v, want count_out_of_range on indent", indent, err)
+		}
+		if ae.Details["min"] != 1 || ae.Details["max"] != maxFormatIndent || ae.Details["default"] != 2 {
+			t.Errorf("indent %d: details = %v, want min 1, max %d, default 2", indent, ae.Details, maxFormatIndent)
+		}
+	}
+	resp, err := formatJSON(FormatRequest{Value: `{"a":1}`, Indent: 0})
+	if err != nil || resp.Result != "{\n  \"a\": 1\n}" {
+		t.Errorf("indent 0: %q, %v; want the default of 2", resp.Result, err)
+	}
+}
+
+func TestMinifyJSONKeepsLiterals(t *testing.T) {
+	resp, err := minifyJSON(MinifyRequest{Value: "{\n  \"id\": 9007199254740993,\n  \"b\": [1.0, \"a b\"],\n  \"a\": {\"b\": 1, \"b\": 2}\n}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":9007199254740993,"b":[1.0,"a b"],"a":{"b":1,"b":2}}`
+	if resp.Result != want {
+		t.Errorf("minify = %s, want %s", resp.Result, want)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// This file infers a JSON Schema from sample documents. Every sample is folded into a
+// tree of inferNodes, one per location, which is then written out as a schema.
+
+// InferSchemaRequest is the JSON body for the schema inference endpoint. Value holds one
+// or more sample documents separated by whitespace (e.g. one per line); with EachItem, a
+// top-level array is taken as a list of samples instead. A string location becomes an
+// enum when it has at most EnumMax distinct values (default 5; -1 disables enums)
+// and each value is seen twice on average. Draft chooses the $schema URI.
+type InferSchemaRequest struct {
+	Value    string `json:"value"`
+	EachItem bool   `json:"eachItem,omitempty"`
+	EnumMax  int    `json:"enumMax,omitempty"`
+	Draft    string `json:"draft,omitempty" enum:"2020-12,draft-07"`
+}
+
+const defaultInferEnumMax = 5
+
+// maxInferEnumMax caps EnumMax, so the distinct values kept per location stay bounded.
+const maxInferEnumMax = 100
+
+// schemaDialects maps a draft name to its $schema URI.
+var schemaDialects = map[string]string{
+	"2020-12":  "https://json-schema.org/draft/2020-12/schema",
+	"draft-07": "http://json-schema.org/draft-07/schema#",
+}
+
+// InferSchemaJSON infers a JSON Schema from one or more sample documents.
+func InferSchemaJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(inferSchemaJSON))
+}
+
+func inferSchemaJSON(ctx context.Context, req InferSchemaRequest) (StringResponse, error) {
+	draft := req.Draft
+	if draft == "" {
+		draft = "2020-12"
+	}
+	dialect, ok := schemaDialects[draft]
+	if !ok {
+		return StringResponse{}, invalidOption("draft", fmt.Sprintf("unknown draft %q", req.Draft), enumTag(req, "Draft")...)
+	}
+	enumMax := req.EnumMax
+	switch {
+	case enumMax == 0:
+		enumMax = defaultInferEnumMax
+	case enumMax < -1 || enumMax > maxInferEnumMax:
+		return StringResponse{}, outOfRange("enumMax", -1, maxInferEnumMax)
+	}
+	inf := &inferrer{ctx: ctx, enumMax: enumMax}
+	root, err := inf.samples(req.Value, req.EachItem)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	schema := inf.schema(root)
+	schema.keys = append([]string{"$schema"}, schema.keys...)
+	schema.vals["$schema"] = dialect
+	out, err := (&jqEval{}).encode(schema, "  ")
+	if err != nil {
+		return StringResponse{}, err
+	}
+	return StringResponse{Result: string(out)}, nil
+}
+
+// inferTypes lists the JSON Schema types in the order the type keyword lists them.
+var inferTypes = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// inferFormats lists the format hints, most specific first. A string location gets the
+// first format every one of its values has.
+var inferFormats = []string{"date-time", "date", "uuid", "email", "uri"}
+
+// inferFormat reports whether s has format. URIs must name a host, so that strings
+// like "key:value" are not hinted as URIs.
+func inferFormat(format, s string) bool {
+	if !schemaFormats[format](s) {
+		return false
+	}
+	if format == "uri" {
+		u, _ := url.Parse(s)
+		return u.Host != ""
+	}
+	return true
+}
+
+// inferNode accumulates what the samples hold at one location.
+type inferNode struct {
+	count int             // values seen here
+	types map[string]bool // JSON Schema types seen
+
+	strings  int            // string values seen
+	distinct map[string]int // distinct strings, while there are at most enumMax+1
+	order    []string       // distinct strings in the order first seen
+	formats  []string       // formats every string so far has; nil before the first
+
+	objects   int // objects seen
+	props     map[string]*inferNode
+	propOrder []string
+
+	items *inferNode // elements of every array seen here
+}
+
+// inferrer folds samples into inferNodes.
+type inferrer struct {
+	ctx     context.Context
+	enumMax int
+	steps   int
+}
+
+// samples decodes the sample documents in value (see InferSchemaRequest for eachItem)
+// and folds them into one tree.
+func (inf *inferrer) samples(value string, eachItem bool) (*inferNode, error) {
+	samples, err := decodeJQInputs("value", value)
+	if err != nil {
+		return nil, err
+	}
+	if eachItem && len(samples) == 1 {
+		if arr, ok := samples[0].([]interface{}); ok {
+			samples = arr
+		}
+	}
+	if len(samples) == 0 {
+		return nil, newError(CodeRequired, "value", "value must hold at least one sample document")
+	}
+	root := &inferNode{}
+	for _, s := range samples {
+		if err := inf.add(root, s); err != nil {
+			return nil, err
+		}
+	}
+	return root, nil
+}
+
+func (inf *inferrer) add(n *inferNode, v interface{}) error {
+	if inf.steps++; inf.steps%1024 == 0 {
+		if err := checkContext(inf.ctx); err != nil {
+			return err
+		}
+	}
+	n.count++
+	if n.types == nil {
+		n.types = make(map[string]bool)
+	}
+	switch x := v.(type) {
+	case *jqObject:
+		n.types["object"] = true
+		n.objects++
+		if n.props == nil {
+			n.props = make(map[string]*inferNode)
+		}
+		for _, k := range x.keys {
+			p, ok := n.props[k]
+			if !ok {
+				p = &inferNode{}
+				n.props[k] = p
+				n.propOrder = append(n.propOrder, k)
+			}
+			if err := inf.add(p, x.vals[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = &inferNode{}
+		}
+		for _, e := range x {
+			if err := inf.add(n.items, e); err != nil {
+				return err
+			}
+		}
+	case string:
+		n.types["string"] = true
+		inf.addString(n, x)
+	default:
+		n.types[schemaType(v)] = true
+	}
+	return nil
+}
+
+func (inf *inferrer) addString(n *inferNode, s string) {
+	n.strings++
+	if n.distinct == nil {
+		n.distinct = make(map[string]int)
+	}
+	if _, seen := n.distinct[s]; seen || len(n.distinct) <= inf.enumMax {
+		if !seen {
+			n.order = append(n.order, s)
+		}
+		n.distinct[s]++
+	}
+	candidates := n.formats
+	if candidates == nil {
+		candidates = inferFormats
+	}
+	n.formats = []string{} // non-nil once a string has been seen
+	for _, f := range candidates {
+		if inferFormat(f, s) {
+			n.formats = append(n.formats, f)
+		}
+	}
+}
+
+// schema writes the schema for n, with keywords and properties in a stable order.
+func (inf *inferrer) schema(n *inferNode) *jqObject {
+	s := newJQObject(6)
+	var types []interface{}
+	for _, t := range inferTypes {
+		if n.types[t] && !(t == "integer" && n.types["number"]) {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 1 {
+		s.put("type", types[0])
+	} else {
+		s.put("type", types)
+	}
+	if n.types["string"] {
+		switch {
+		case len(n.formats) > 0:
+			s.put("format", n.formats[0])
+		case inf.enumMax > 0 && len(n.distinct) <= inf.enumMax && n.strings >= 2*len(n.distinct):
+			enum := make([]interface{}, len(n.order))
+			for i, v := range n.order {
+				enum[i] = v
+			}
+			s.put("enum", enum)
+		}
+	}
+	if n.types["object"] {
+		props := newJQObject(len(n.propOrder))
+		var required []interface{}
+		for _, k := range n.propOrder {
+			p := n.props[k]
+			props.put(k, inf.schema(p))
+			if p.count == n.objects {
+				required = append(required, k)
+			}
+		}
+		s.put("properties", props)
+		if len(required) > 0 {
+			s.put("required", required)
+		}
+	}
+	if n.types["array"] && n.items.count > 0 {
+		s.put("items", inf.schema(n.items))
+	}
+	return s
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestInferSchemaJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		req  InferSchemaRequest
+		want string
+	}{
+		{"scalar", InferSchemaRequest{Value: `1`}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "integer"
+}`},
+		{"merged types and required", InferSchemaRequest{Value: `
+{"id": 1, "name": "ann", "score": 2, "note": null}
+{"id": 2, "name": "bob", "score": 2.5, "note": "late"}
+{"id": 3, "score": 3}`}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "id": {
+      "type": "integer"
+    },
+    "name": {
+      "type": "string"
+    },
+    "score": {
+      "type": "number"
+    },
+    "note": {
+      "type": [
+        "string",
+        "null"
+      ]
+    }
+  },
+  "required": [
+    "id",
+    "score"
+  ]
+}`},
+		{"enum and formats", InferSchemaRequest{Value: `[
+  {"status": "open", "id": "123e4567-e89b-12d3-a456-426614174000", "at": "2024-01-02T03:04:05Z", "by": "a@example.com", "link": "https://example.com/1"},
+  {"status": "closed", "id": "123e4567-e89b-12d3-a456-426614174001", "at": "2024-02-02T03:04:05+01:00", "by": "b@example.com", "link": "https://example.com/2"},
+  {"status": "open", "id": "123e4567-e89b-12d3-a456-426614174002", "at": "2024-03-02T03:04:05Z", "by": "c@example.com", "link": "https://example.com/3"},
+  {"status": "closed", "id": "123e4567-e89b-12d3-a456-426614174003", "at": "2024-04-02T03:04:05Z", "by": "d@example.com", "link": "https://example.com/4"}
+]`, EachItem: true}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "status": {
+      "type": "string",
+      "enum": [
+        "open",
+        "closed"
+      ]
+    },
+    "id": {
+      "type": "string",
+      "format": "uuid"
+    },
+    "at": {
+      "type": "string",
+      "format": "date-time"
+    },
+    "by": {
+      "type": "string",
+      "format": "email"
+    },
+    "link": {
+      "type": "string",
+      "format": "uri"
+    }
+  },
+  "required": [
+    "status",
+    "id",
+    "at",
+    "by",
+    "link"
+  ]
+}`},
+		{"arrays", InferSchemaRequest{Value: `{"tags": ["a", "b"], "items": [{"n": 1}, {"n": 2, "x": true}], "empty": []}`, Draft: "draft-07"}, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "n": {
+            "type": "integer"
+          },
+          "x": {
+            "type": "boolean"
+          }
+        },
+        "required": [
+          "n"
+        ]
+      }
+    },
+    "empty": {
+      "type": "array"
+    }
+  },
+  "required": [
+    "tags",
+    "items",
+    "empty"
+  ]
+}`},
+		{"enums disabled", InferSchemaRequest{Value: `"a" "a" "b" "b"`, EnumMax: -1}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "string"
+}`},
+		{"too many distinct values", InferSchemaRequest{Value: `"a" "a" "b" "b" "c" "c"`, EnumMax: 2}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "string"
+}`},
+		{"not a uri without a host", InferSchemaRequest{Value: `"key:value"`}, `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "string"
+}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := inferSchemaJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestInferSchemaValidatesSamples(t *testing.T) {
+	samples := `{"a": 1, "b": [{"c": "x"}, {"c": "y", "d": null}], "e": "2024-01-02"}
+{"a": 2.5, "b": [], "f": {"g": false}}`
+	resp, err := inferSchemaJSON(context.Background(), InferSchemaRequest{Value: samples})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := decodeJQInputs("value", samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range docs {
+		out, _ := (&jqEval{}).encode(doc, "")
+		res, err := validateSchemaJSON(context.Background(), SchemaRequest{Value: string(out), Schema: resp.Result})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Valid {
+			t.Errorf("sample %s does not match the inferred schema: %+v", out, res.Violations)
+		}
+	}
+}
+
+func TestInferSchemaJSONErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      InferSchemaRequest
+		wantCode string
+		field    string
+	}{
+		{"no samples", InferSchemaRequest{Value: "  "}, CodeRequired, "value"},
+		{"empty sample list", InferSchemaRequest{Value: "[]", EachItem: true}, CodeRequired, "value"},
+		{"bad json", InferSchemaRequest{Value: `{"a": 1} {`}, CodeInvalidJSON, "value"},
+		{"unknown draft", InferSchemaRequest{Value: `1`, Draft: "draft-04"}, CodeInvalidOption, "draft"},
+		{"enumMax too large", InferSchemaRequest{Value: `1`, EnumMax: 1000}, CodeOutOfRange, "enumMax"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := inferSchemaJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.wantCode || ae.Field != tc.field {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.wantCode, tc.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// This file implements the validate tool: a JSON parser that reports where and why a
+// document fails to parse and warns about documents that parse but that other parsers
+// may read differently.
+
+// maxLintDepth is the nesting depth at which validation gives up, as encoding/json does.
+const maxLintDepth = 10000
+
+// snippetWidth is the number of characters of the offending line a SyntaxDetail shows.
+const snippetWidth = 80
+
+// ValidateResponse is the JSON response for the validate endpoint. Error repeats the
+// syntax error as one line; Warnings is left out when there is nothing to report.
+type ValidateResponse struct {
+	Valid    bool          `json:"valid"`
+	Error    string        `json:"error,omitempty"`
+	Syntax   *SyntaxDetail `json:"syntax,omitempty"`
+	Warnings []LintWarning `json:"warnings,omitempty"`
+}
+
+// SyntaxDetail locates the first syntax error in a document. Offset is the 0-based byte
+// index of the problem (the input length when the input ends too early); Line and Column
+// are 1-based, the column counted in characters. Snippet is the offending line with a
+// caret under the column and Expected says what would have been valid there.
+type SyntaxDetail struct {
+	Message  string `json:"message"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Snippet  string `json:"snippet"`
+	Expected string `json:"expected,omitempty"`
+}
+
+// LintWarning is something in a document that parses but that other JSON parsers may
+// read differently. Path is the JSON Pointer of the value concerned ("" for the root and
+// for whole-document warnings).
+type LintWarning struct {
+	Code    string `json:"code" enum:"duplicate_key,imprecise_number,lone_surrogate,bom,trailing_data"`
+	Message string `json:"message"`
+	Path    string `json:"path"`
+	Offset  int    `json:"offset"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// ValidateJSON checks whether the input is valid JSON, pointing out syntax errors and
+// suspicious constructs.
+func ValidateJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(validateJSON))
+}
+
+func validateJSON(req StringRequest) (ValidateResponse, error) {
+	data := []byte(req.Value)
+	l := &jsonLinter{data: data}
+	err := l.document()
+	resp := ValidateResponse{Valid: err == nil, Warnings: l.warnings}
+	if err != nil {
+		line, col := lineColumn(data, err.offset)
+		resp.Syntax = &SyntaxDetail{
+			Message: err.message, Offset: err.offset, Line: line, Column: col,
+			Snippet: syntaxSnippet(data, err.offset), Expected: err.expected,
+		}
+		resp.Error = fmt.Sprintf("line %d, column %d: %s", line, col, err.message)
+		if err.expected != "" {
+			resp.Error += "; expected " + err.expected
+		}
+	}
+	return resp, nil
+}
+
+// lintSyntaxError is a syntax error at a byte offset, with a hint of what was expected.
+type lintSyntaxError struct {
+	offset   int
+	message  string
+	expected string
+}
+
+func (e *lintSyntaxError) Error() string { return e.message }
+
+// jsonLinter is a recursive-descent JSON parser that keeps no values: it only records
+// the first syntax error and the warnings on the way there.
+type jsonLinter struct {
+	data     []byte
+	pos      int
+	depth    int
+	warnings []LintWarning
+}
+
+const expectValue = "a value (object, array, string, number, true, false or null)"
+
+// document parses a whole input. A byte order mark before the value and data after it
+// are warnings rather than errors, since the value itself can still be read.
+func (l *jsonLinter) document() *lintSyntaxError {
+	if bytes.HasPrefix(l.data, []byte("\xef\xbb\xbf")) {
+		l.warn(0, "bom", "the document starts with a UTF-8 byte order mark, which RFC 8259 forbids and many parsers reject", "")
+		l.pos = 3
+	}
+	if err := l.value(""); err != nil {
+		return err
+	}
+	l.skipSpace()
+	if l.pos < len(l.data) {
+		l.warn(l.pos, "trailing_data", "data after the top-level value is ignored by some parsers and rejected by others", "")
+	}
+	return nil
+}
+
+func (l *jsonLinter) warn(offset int, code, msg, pointer string) {
+	line, col := lineColumn(l.data, offset)
+	l.warnings = append(l.warnings, LintWarning{Code: code, Message: msg, Path: pointer, Offset: offset, Line: line, Column: col})
+}
+
+func (l *jsonLinter) fail(msg, expected string) *lintSyntaxError {
+	return &lintSyntaxError{offset: l.pos, message: msg, expected: expected}
+}
+
+// unexpected reports the character at the current position, or the end of input.
+func (l *jsonLinter) unexpected(expected string) *lintSyntaxError {
+	if l.pos >= len(l.data) {
+		return l.fail("unexpected end of input", expected)
+	}
+	r, _ := utf8.DecodeRune(l.data[l.pos:])
+	return l.fail(fmt.Sprintf("unexpected character %q", r), expected)
+}
+
+// peek returns the byte at the current position, or 0 at the end of input.
+func (l *jsonLinter) peek() byte {
+	if l.pos < len(l.data) {
+		return l.data[l.pos]
+	}
+	return 0
+}
+
+func (l *jsonLinter) skipSpace() {
+	for l.pos < len(l.data) && strings.IndexByte(" \t\r\n", l.data[l.pos]) >= 0 {
+		l.pos++
+	}
+}
+
+func (l *jsonLinter) value(pointer string) *lintSyntaxError {
+	l.skipSpace()
+	switch c := l.peek(); {
+	case c == '{' || c == '[':
+		if l.depth++; l.depth > maxLintDepth {
+			return l.fail(fmt.Sprintf("nesting is deeper than %d levels", maxLintDepth), "")
+		}
+		defer func() { l.depth-- }()
+		if c == '{' {
+			return l.object(pointer)
+		}
+		return l.array(pointer)
+	case c == '"':
+		_, err := l.str(pointer)
+		return err
+	case c == '-' || c >= '0' && c <= '9':
+		return l.number(pointer)
+	case c == 't':
+		return l.literal("true")
+	case c == 'f':
+		return l.literal("false")
+	case c == 'n':
+		return l.literal("null")
+	}
+	return l.unexpected(expectValue)
+}
+
+func (l *jsonLinter) object(pointer string) *lintSyntaxError {
+	l.pos++
+	l.skipSpace()
+	if l.peek() == '}' {
+		l.pos++
+		return nil
+	}
+	seen := map[string]bool{}
+	for first := true; ; first = false {
+		l.skipSpace()
+		if l.peek() != '"' {
+			switch {
+			case first:
+				return l.unexpected("a string key or }")
+			case l.peek() == '}':
+				return l.fail("trailing comma before }", "a string key")
+			}
+			return l.unexpected("a string key")
+		}
+		start := l.pos
+		key, err := l.str(pointer)
+		if err != nil {
+			return err
+		}
+		member := pointerJoin(pointer, key)
+		if seen[key] {
+			l.warn(start, "duplicate_key", fmt.Sprintf("duplicate key %q; parsers disagree on which value wins", key), member)
+		}
+		seen[key] = true
+		l.skipSpace()
+		if l.peek() != ':' {
+			return l.unexpected("a colon after the key")
+		}
+		l.pos++
+		if err := l.value(member); err != nil {
+			return err
+		}
+		l.skipSpace()
+		switch l.peek() {
+		case ',':
+			l.pos++
+		case '}':
+			l.pos++
+			return nil
+		default:
+			return l.unexpected("a comma or }")
+		}
+	}
+}
+
+func (l *jsonLinter) array(pointer string) *lintSyntaxError {
+	l.pos++
+	l.skipSpace()
+	if l.peek() == ']' {
+		l.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		l.skipSpace()
+		if i > 0 && l.peek() == ']' {
+			return l.fail("trailing comma before ]", expectValue)
+		}
+		if err := l.value(pointerJoin(pointer, strconv.Itoa(i))); err != nil {
+			return err
+		}
+		l.skipSpace()
+		switch l.peek() {
+		case ',':
+			l.pos++
+		case ']':
+			l.pos++
+			return nil
+		default:
+			return l.unexpected("a comma or ]")
+		}
+	}
+}
+
+// str parses a string literal and returns its value. Lone UTF-16 surrogates decode to
+// U+FFFD, as encoding/json does, and are warned about.
+func (l *jsonLinter) str(pointer string) (string, *lintSyntaxError) {
+	l.pos++
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.data) {
+			return "", l.fail("unexpected end of input in a string", "a closing quote")
+		}
+		switch c := l.data[l.pos]; {
+		case c == '"':
+			l.pos++
+			return sb.String(), nil
+		case c < 0x20:
+			return "", l.fail(fmt.Sprintf("unescaped control character %U in a string", c), `an escape such as \n or \u001f`)
+		case c == '\\':
+			start := l.pos
+			l.pos++
+			switch e := l.peek(); e {
+			case '"', '\\', '/':
+				sb.WriteByte(e)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				l.pos++
+				r, err := l.hex4()
+				if err != nil {
+					return "", err
+				}
+				if utf16.IsSurrogate(r) {
+					r2, ok := rune(0), false
+					if r < 0xdc00 {
+						r2, ok = l.lowSurrogate()
+					}
+					if ok {
+						r = utf16.DecodeRune(r, r2)
+					} else {
+						l.warn(start, "lone_surrogate", fmt.Sprintf("the escape \\u%04x is half of a UTF-16 surrogate pair; it decodes to U+FFFD", r), pointer)
+						r = utf8.RuneError
+					}
+				}
+				sb.WriteRune(r)
+				continue
+			default:
+				if l.pos >= len(l.data) {
+					return "", l.fail("unexpected end of input in a string", "an escape character")
+				}
+				return "", l.unexpected(`one of " \ / b f n r t u after the backslash`)
+			}
+			l.pos++
+		default:
+			sb.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+// hex4 parses the four hex digits of a \u escape.
+func (l *jsonLinter) hex4() (rune, *lintSyntaxError) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		c := l.peek()
+		var d byte
+		switch {
+		case c >= '0' && c <= '9':
+			d = c - '0'
+		case c >= 'a' && c <= 'f':
+			d = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			d = c - 'A' + 10
+		default:
+			return 0, l.unexpected(`4 hex digits after \u`)
+		}
+		r = r<<4 | rune(d)
+		l.pos++
+	}
+	return r, nil
+}
+
+// lowSurrogate consumes a following \uDC00-\uDFFF escape, if there is one.
+func (l *jsonLinter) lowSurrogate() (rune, bool) {
+	save := l.pos
+	if l.pos+6 <= len(l.data) && l.data[l.pos] == '\\' && l.data[l.pos+1] == 'u' {
+		l.pos += 2
+		if r, err := l.hex4(); err == nil && r >= 0xdc00 && r <= 0xdfff {
+			return r, true
+		}
+	}
+	l.pos = save
+	return 0, false
+}
+
+func (l *jsonLinter) number(pointer string) *lintSyntaxError {
+	start := l.pos
+	if l.peek() == '-' {
+		l.pos++
+	}
+	switch c := l.peek(); {
+	case c == '0':
+		l.pos++
+		if c := l.peek(); c >= '0' && c <= '9' {
+			return l.fail("leading zeros are not allowed in numbers", "a decimal point, an exponent or the end of the number")
+		}
+	case c >= '1' && c <= '9':
+		l.digits()
+	default:
+		return l.unexpected("a digit")
+	}
+	if l.peek() == '.' {
+		l.pos++
+		if c := l.peek(); c < '0' || c > '9' {
+			return l.unexpected("a digit after the decimal point")
+		}
+		l.digits()
+	}
+	if c := l.peek(); c == 'e' || c == 'E' {
+		l.pos++
+		if c := l.peek(); c == '+' || c == '-' {
+			l.pos++
+		}
+		if c := l.peek(); c < '0' || c > '9' {
+			return l.unexpected("a digit in the exponent")
+		}
+		l.digits()
+	}
+	lit := string(l.data[start:l.pos])
+	if msg := numberPrecision(lit); msg != "" {
+		l.warn(start, "imprecise_number", msg, pointer)
+	}
+	return nil
+}
+
+func (l *jsonLinter) digits() {
+	for c := l.peek(); c >= '0' && c <= '9'; c = l.peek() {
+		l.pos++
+	}
+}
+
+func (l *jsonLinter) literal(lit string) *lintSyntaxError {
+	for i := 0; i < len(lit); i++ {
+		if l.peek() != lit[i] {
+			return l.unexpected(lit)
+		}
+		l.pos++
+	}
+	return nil
+}
+
+// numberPrecision explains how the number literal lit changes when read as a 64-bit
+// float, or returns "" when a double keeps every significant digit.
+func numberPrecision(lit string) string {
+	f, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return fmt.Sprintf("%s is out of range for a 64-bit float", lit)
+	}
+	digits, exp := significand(lit)
+	fdigits, fexp := significand(strconv.FormatFloat(f, 'e', -1, 64))
+	if digits == fdigits && exp == fexp {
+		return ""
+	}
+	return fmt.Sprintf("%s has more precision than a 64-bit float keeps; parsers that use doubles read %s", lit, formatES(f))
+}
+
+// significand splits a JSON number literal into its significant digits, without leading
+// or trailing zeros, and the power of ten they are multiplied by. Zero is ("", 0).
+func significand(lit string) (string, int) {
+	lit = strings.TrimPrefix(lit, "-")
+	mant, expPart, _ := strings.Cut(strings.ToLower(lit), "e")
+	exp, _ := strconv.Atoi(expPart)
+	intPart, frac, _ := strings.Cut(mant, ".")
+	digits := strings.TrimLeft(intPart+frac, "0")
+	exp -= len(frac)
+	trimmed := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(trimmed)
+	if trimmed == "" {
+		return "", 0
+	}
+	return trimmed, exp
+}
+
+// syntaxSnippet returns the line of data holding offset, cut to snippetWidth characters
+// around it, and a second line with a caret under offset.
+func syntaxSnippet(data []byte, offset int) string {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	end := bytes.IndexByte(data[offset:], '\n')
+	if end < 0 {
+		end = len(data)
+	} else {
+		end += offset
+	}
+	line := []rune(strings.TrimSuffix(string(data[start:end]), "\r"))
+	col := utf8.RuneCount(data[start:offset])
+	from, to := 0, len(line)
+	if len(line) > snippetWidth {
+		from = max(0, min(col-snippetWidth/2, len(line)-snippetWidth))
+		to = from + snippetWidth
+	}
+	var caret strings.Builder
+	for _, r := range line[from:min(col, len(line))] {
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	for i := len(line); i < col; i++ {
+		caret.WriteByte(' ')
+	}
+	caret.WriteByte('^')
+	return string(line[from:to]) + "\n" + caret.String()
+}
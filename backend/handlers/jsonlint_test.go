@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONSyntax(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  SyntaxDetail
+	}{
+		{"bad value", "{\n  \"a\": x\n}", SyntaxDetail{Message: "unexpected character 'x'", Offset: 9, Line: 2, Column: 8,
+			Snippet: "  \"a\": x\n       ^", Expected: expectValue}},
+		{"missing comma", `{"a": 1 "b": 2}`, SyntaxDetail{Message: `unexpected character '"'`, Offset: 8, Line: 1, Column: 9,
+			Snippet: "{\"a\": 1 \"b\": 2}\n        ^", Expected: "a comma or }"}},
+		{"unquoted key", `{invalid}`, SyntaxDetail{Message: "unexpected character 'i'", Offset: 1, Line: 1, Column: 2,
+			Snippet: "{invalid}\n ^", Expected: "a string key or }"}},
+		{"trailing comma", "[1,\n\t2,\n]", SyntaxDetail{Message: "trailing comma before ]", Offset: 8, Line: 3, Column: 1,
+			Snippet: "]\n^", Expected: expectValue}},
+		{"truncated", `[1,`, SyntaxDetail{Message: "unexpected end of input", Offset: 3, Line: 1, Column: 4,
+			Snippet: "[1,\n   ^", Expected: expectValue}},
+		{"unterminated string", `"abc`, SyntaxDetail{Message: "unexpected end of input in a string", Offset: 4, Line: 1, Column: 5,
+			Snippet: "\"abc\n    ^", Expected: "a closing quote"}},
+		{"bad escape", `"a\qb"`, SyntaxDetail{Message: "unexpected character 'q'", Offset: 3, Line: 1, Column: 4,
+			Snippet: "\"a\\qb\"\n   ^", Expected: `one of " \ / b f n r t u after the backslash`}},
+		{"leading zero", `[01]`, SyntaxDetail{Message: "leading zeros are not allowed in numbers", Offset: 2, Line: 1, Column: 3,
+			Snippet: "[01]\n  ^", Expected: "a decimal point, an exponent or the end of the number"}},
+		{"bad literal", `[tru]`, SyntaxDetail{Message: "unexpected character ']'", Offset: 4, Line: 1, Column: 5,
+			Snippet: "[tru]\n    ^", Expected: "true"}},
+		{"tab aligned caret", "\t{,}", SyntaxDetail{Message: "unexpected character ','", Offset: 2, Line: 1, Column: 3,
+			Snippet: "\t{,}\n\t ^", Expected: "a string key or }"}},
+		{"empty", ``, SyntaxDetail{Message: "unexpected end of input", Offset: 0, Line: 1, Column: 1,
+			Snippet: "\n^", Expected: expectValue}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := validateJSON(StringRequest{Value: tc.value})
+			if resp.Valid || resp.Syntax == nil {
+				t.Fatalf("valid = %v, syntax = %v; want a syntax error", resp.Valid, resp.Syntax)
+			}
+			if *resp.Syntax != tc.want {
+				t.Errorf("syntax = %+v, want %+v", *resp.Syntax, tc.want)
+			}
+			// Everything the linter rejects, encoding/json rejects too.
+			if json.Valid([]byte(tc.value)) {
+				t.Errorf("json.Valid accepts %q", tc.value)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSnippetWindow(t *testing.T) {
+	value := `["` + strings.Repeat("a", 200) + `" x]`
+	resp, _ := validateJSON(StringRequest{Value: value})
+	lines := strings.Split(resp.Syntax.Snippet, "\n")
+	if len([]rune(lines[0])) != snippetWidth || !strings.Contains(lines[0], `" x]`) {
+		t.Errorf("snippet line = %q, want %d characters ending at the error", lines[0], snippetWidth)
+	}
+	if caret := strings.Index(lines[1], "^"); lines[0][caret] != 'x' {
+		t.Errorf("caret is under %q, want 'x'", lines[0][caret])
+	}
+}
+
+func TestValidateJSONWarnings(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  []LintWarning
+	}{
+		{"clean", `{"a": [1, 0.1, 1e2, -0, 1234567890123456e3, "\ud83d\ude00"]}`, nil},
+		{"duplicate key", "{\"a\": {\"b\": 1,\n \"\\u0062\": 2}}", []LintWarning{
+			{Code: "duplicate_key", Message: `duplicate key "b"; parsers disagree on which value wins`, Path: "/a/b", Offset: 16, Line: 2, Column: 2}}},
+		{"imprecise number", `{"id": 9007199254740993}`, []LintWarning{
+			{Code: "imprecise_number", Message: "9007199254740993 has more precision than a 64-bit float keeps; parsers that use doubles read 9007199254740992",
+				Path: "/id", Offset: 7, Line: 1, Column: 8}}},
+		{"number out of range", `[1e400]`, []LintWarning{
+			{Code: "imprecise_number", Message: "1e400 is out of range for a 64-bit float", Path: "/0", Offset: 1, Line: 1, Column: 2}}},
+		{"lone surrogate", `["ok", "a\udc00b\ud800"]`, []LintWarning{
+			{Code: "lone_surrogate", Message: `the escape \udc00 is half of a UTF-16 surrogate pair; it decodes to U+FFFD`, Path: "/1", Offset: 9, Line: 1, Column: 10},
+			{Code: "lone_surrogate", Message: `the escape \ud800 is half of a UTF-16 surrogate pair; it decodes to U+FFFD`, Path: "/1", Offset: 16, Line: 1, Column: 17}}},
+		{"bom", "\ufeff{}", []LintWarning{
+			{Code: "bom", Message: "the document starts with a UTF-8 byte order mark, which RFC 8259 forbids and many parsers reject", Offset: 0, Line: 1, Column: 1}}},
+		{"trailing data", "{}\n}", []LintWarning{
+			{Code: "trailing_data", Message: "data after the top-level value is ignored by some parsers and rejected by others", Offset: 3, Line: 2, Column: 1}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, _ := validateJSON(StringRequest{Value: tc.value})
+			if !resp.Valid {
+				t.Fatalf("valid = false: %s", resp.Error)
+			}
+			if !reflect.DeepEqual(resp.Warnings, tc.want) {
+				t.Errorf("warnings = %+v, want %+v", resp.Warnings, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateJSONDepth(t *testing.T) {
+	deep := strings.Repeat("[", maxLintDepth) + strings.Repeat("]", maxLintDepth)
+	if resp, _ := validateJSON(StringRequest{Value: deep}); !resp.Valid {
+		t.Errorf("depth %d: %s", maxLintDepth, resp.Error)
+	}
+	resp, _ := validateJSON(StringRequest{Value: "[" + deep + "]"})
+	if resp.Valid || resp.Syntax.Offset != maxLintDepth {
+		t.Errorf("depth %d: valid = %v, syntax = %+v", maxLintDepth+1, resp.Valid, resp.Syntax)
+	}
+}
@@ -0,0 +1,627 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// This file implements RFC 6902 JSON Patch: applying a patch to a document and
+// generating one from two documents (the diff tool's "patch" output). Documents are
+// decoded with ordered objects, so patched output and generated operations keep the
+// key order of the input.
+
+// PatchRequest is the JSON body for the JSON Patch endpoint. Patch is a JSON array of
+// operations applied to Value in order.
+type PatchRequest struct {
+	Value string `json:"value"`
+	Patch string `json:"patch"`
+}
+
+// PatchJSON applies an RFC 6902 JSON Patch to a document.
+func PatchJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(patchJSON))
+}
+
+func patchJSON(ctx context.Context, req PatchRequest) (StringResponse, error) {
+	doc, err := decodeOrderedValue("value", req.Value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	ops, err := parsePatch(req.Patch)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	// Operations never modify doc in place, so a failure leaves nothing half-applied.
+	out, err := applyPatch(ctx, doc, ops)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	text, err := (&jqEval{}).encodeString(out, "  ")
+	if err != nil {
+		return StringResponse{}, err
+	}
+	return StringResponse{Result: text}, nil
+}
+
+// patchOp is one JSON Patch operation. Pointers are kept both as written and as
+// reference tokens.
+type patchOp struct {
+	op         string
+	path       string
+	pathTokens []string
+	from       string
+	fromTokens []string
+	value      interface{}
+
+	old interface{} // the value a generated remove or replace takes out
+	key string      // schemaKey of value (add) or old (remove), filled in on demand
+}
+
+// patchValueOps are the operations that carry a value member.
+var patchValueOps = map[string]bool{"add": true, "replace": true, "test": true}
+
+// patchFromOps are the operations that carry a from member.
+var patchFromOps = map[string]bool{"move": true, "copy": true}
+
+// parsePatch reads the patch field: a JSON array of operation objects. Members other
+// than op, path, from and value are ignored, as the RFC requires.
+func parsePatch(s string) ([]patchOp, error) {
+	v, err := decodeOrderedValue("patch", s)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, newError(CodeInvalidValue, "patch", "patch must be a JSON array of operations")
+	}
+	ops := make([]patchOp, 0, len(list))
+	for i, e := range list {
+		obj, ok := e.(*jqObject)
+		if !ok {
+			return nil, badPatchOp(i, "must be an object")
+		}
+		var op patchOp
+		name, _ := obj.get("op")
+		if op.op, ok = name.(string); !ok {
+			return nil, badPatchOp(i, `"op" must be a string`)
+		}
+		if !patchValueOps[op.op] && !patchFromOps[op.op] && op.op != "remove" {
+			return nil, badPatchOp(i, fmt.Sprintf("unknown op %q (want add, remove, replace, move, copy or test)", op.op))
+		}
+		if op.path, op.pathTokens, err = patchPointer(obj, "path", i); err != nil {
+			return nil, err
+		}
+		if patchFromOps[op.op] {
+			if op.from, op.fromTokens, err = patchPointer(obj, "from", i); err != nil {
+				return nil, err
+			}
+		}
+		if patchValueOps[op.op] {
+			if op.value, ok = obj.get("value"); !ok {
+				return nil, badPatchOp(i, fmt.Sprintf("%s needs a \"value\"", op.op))
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// patchPointer reads the JSON Pointer member name of operation i.
+func patchPointer(obj *jqObject, name string, i int) (string, []string, error) {
+	v, _ := obj.get(name)
+	s, ok := v.(string)
+	if !ok {
+		return "", nil, badPatchOp(i, fmt.Sprintf("%q must be a JSON Pointer string", name))
+	}
+	if strings.HasPrefix(s, "#") {
+		return "", nil, badPatchOp(i, fmt.Sprintf("%q must be a JSON Pointer, not a URI fragment", name))
+	}
+	tokens, err := parsePointer(s)
+	if err != nil {
+		return "", nil, badPatchOp(i, fmt.Sprintf("invalid %s %q: %v", name, s, err))
+	}
+	return s, tokens, nil
+}
+
+// badPatchOp reports a malformed operation; details.operation is its 0-based index.
+func badPatchOp(i int, msg string) *APIError {
+	e := newError(CodeInvalidValue, "patch", fmt.Sprintf("operation %d: %s", i, msg))
+	e.Details = map[string]interface{}{"operation": i}
+	return e
+}
+
+// applyPatch applies ops to doc in order and returns the result; doc itself is not
+// modified. A failing operation stops the patch with an error whose details name it
+// (operation, op and path).
+func applyPatch(ctx context.Context, doc interface{}, ops []patchOp) (interface{}, error) {
+	for i, op := range ops {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			var ae *APIError
+			if errors.As(err, &ae) {
+				ae.Message = fmt.Sprintf("operation %d (%s): %s", i, op.op, ae.Message)
+				if ae.Details == nil {
+					ae.Details = make(map[string]interface{})
+				}
+				ae.Details["operation"] = i
+				ae.Details["op"] = op.op
+				ae.Details["path"] = op.path
+			}
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func (op patchOp) apply(doc interface{}) (interface{}, error) {
+	switch op.op {
+	case "add":
+		return patchAdd(doc, op.pathTokens, op.value)
+	case "remove":
+		if len(op.pathTokens) == 0 {
+			return nil, newError(CodeInvalidValue, "patch", "cannot remove the whole document")
+		}
+		if _, n, ok := pointerGet(doc, op.pathTokens); !ok {
+			return nil, pointerNotFound("patch", op.pathTokens, n)
+		}
+		return patchUpdate(doc, op.pathTokens, 0, patchRemoveChild)
+	case "replace":
+		if _, n, ok := pointerGet(doc, op.pathTokens); !ok {
+			return nil, pointerNotFound("patch", op.pathTokens, n)
+		}
+		if len(op.pathTokens) == 0 {
+			return op.value, nil
+		}
+		return patchUpdate(doc, op.pathTokens, 0, func(parent interface{}, tok string) (interface{}, error) {
+			if obj, ok := parent.(*jqObject); ok {
+				return obj.with(tok, op.value), nil
+			}
+			arr := append([]interface{}(nil), parent.([]interface{})...)
+			idx, _ := pointerIndex(tok)
+			arr[idx] = op.value
+			return arr, nil
+		})
+	case "move", "copy":
+		v, n, ok := pointerGet(doc, op.fromTokens)
+		if !ok {
+			return nil, pointerNotFound("patch", op.fromTokens, n)
+		}
+		if op.op == "copy" {
+			return patchAdd(doc, op.pathTokens, v)
+		}
+		if op.from == op.path {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.path, op.from+"/") {
+			return nil, newError(CodeInvalidValue, "patch", fmt.Sprintf("cannot move %s into itself", op.from))
+		}
+		doc, err := patchUpdate(doc, op.fromTokens, 0, patchRemoveChild)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.pathTokens, v)
+	case "test":
+		v, n, ok := pointerGet(doc, op.pathTokens)
+		if !ok {
+			return nil, pointerNotFound("patch", op.pathTokens, n)
+		}
+		if !jsonEqual(v, op.value) {
+			e := newError(CodeTestFailed, "patch", fmt.Sprintf("test failed: the value is %s, not %s", jqDump(v), jqDump(op.value)))
+			e.Status = http.StatusConflict
+			return nil, e
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown patch op %q", op.op)
+}
+
+// patchUpdate returns a copy of v in which the container holding tokens[len-1] has been
+// replaced by fn(container, last token). Only the containers along the path are copied.
+func patchUpdate(v interface{}, tokens []string, i int, fn func(parent interface{}, tok string) (interface{}, error)) (interface{}, error) {
+	if i == len(tokens)-1 {
+		switch v.(type) {
+		case *jqObject, []interface{}:
+			return fn(v, tokens[i])
+		}
+		return nil, pointerNotFound("patch", tokens, i)
+	}
+	child, _, ok := pointerGet(v, tokens[i:i+1])
+	if !ok {
+		return nil, pointerNotFound("patch", tokens, i)
+	}
+	nc, err := patchUpdate(child, tokens, i+1, fn)
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := v.(*jqObject); ok {
+		return obj.with(tokens[i], nc), nil
+	}
+	arr := append([]interface{}(nil), v.([]interface{})...)
+	idx, _ := pointerIndex(tokens[i])
+	arr[idx] = nc
+	return arr, nil
+}
+
+// patchAdd returns doc with value added at tokens: a member is set, an array element is
+// inserted before the index ("-" appends) and the empty pointer replaces the document.
+func patchAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return patchUpdate(doc, tokens, 0, func(parent interface{}, tok string) (interface{}, error) {
+		if obj, ok := parent.(*jqObject); ok {
+			return obj.with(tok, value), nil
+		}
+		arr := parent.([]interface{})
+		idx, ok := len(arr), tok == "-"
+		if !ok {
+			idx, ok = pointerIndex(tok)
+		}
+		if !ok || idx > len(arr) {
+			return nil, pointerNotFound("patch", tokens, len(tokens)-1)
+		}
+		out := make([]interface{}, 0, len(arr)+1)
+		out = append(out, arr[:idx]...)
+		out = append(out, value)
+		return append(out, arr[idx:]...), nil
+	})
+}
+
+// patchRemoveChild removes the member or element tok, which must exist, from parent.
+func patchRemoveChild(parent interface{}, tok string) (interface{}, error) {
+	if obj, ok := parent.(*jqObject); ok {
+		return obj.without(tok), nil
+	}
+	arr := parent.([]interface{})
+	idx, _ := pointerIndex(tok)
+	out := make([]interface{}, 0, len(arr)-1)
+	out = append(out, arr[:idx]...)
+	return append(out, arr[idx+1:]...), nil
+}
+
+// patchDiff generates a JSON Patch that turns one document into another.
+type patchDiff struct {
+	ctx   context.Context
+	steps int
+	ops   []patchOp
+}
+
+// diffPatch returns a JSON Patch from a to b. Members are compared by key and arrays by
+// position after their common prefix and suffix, so inserting or deleting elements
+// does not rewrite the rest of the array. A removed value that is added elsewhere
+// becomes a move, and an added object or array that already exists in a becomes a copy.
+func diffPatch(ctx context.Context, a, b interface{}) ([]patchOp, error) {
+	d := &patchDiff{ctx: ctx}
+	if err := d.diff(a, b, []string{}); err != nil {
+		return nil, err
+	}
+	// Moves and copies are only placed where no operation in between can tell the
+	// difference; the result is still checked once, and on a miss the plainer patch is
+	// kept.
+	plain := append([]patchOp(nil), d.ops...)
+	if d.findMoves() {
+		if err := d.verify(a, b, plain); err != nil {
+			return nil, err
+		}
+	}
+	plain = append(plain[:0:0], d.ops...)
+	if d.findCopies(a) {
+		if err := d.verify(a, b, plain); err != nil {
+			return nil, err
+		}
+	}
+	return d.ops, nil
+}
+
+// verify keeps d.ops when they turn a into b and goes back to fallback otherwise.
+func (d *patchDiff) verify(a, b interface{}, fallback []patchOp) error {
+	ok, err := d.check(a, b, d.ops)
+	if !ok {
+		d.ops = fallback
+	}
+	return err
+}
+
+func (d *patchDiff) emit(op string, tokens []string, value, old interface{}) {
+	d.ops = append(d.ops, patchOp{op: op, path: formatPointer(tokens), pathTokens: tokens, value: value, old: old})
+}
+
+// childTokens returns tokens extended by tok, without sharing the backing array.
+func childTokens(tokens []string, tok string) []string {
+	return append(tokens[:len(tokens):len(tokens)], tok)
+}
+
+func (d *patchDiff) diff(a, b interface{}, tokens []string) error {
+	if d.steps++; d.steps%1024 == 0 {
+		if err := checkContext(d.ctx); err != nil {
+			return err
+		}
+	}
+	switch x := a.(type) {
+	case *jqObject:
+		y, ok := b.(*jqObject)
+		if !ok {
+			break
+		}
+		for _, k := range x.keys {
+			if yv, ok := y.get(k); ok {
+				if err := d.diff(x.vals[k], yv, childTokens(tokens, k)); err != nil {
+					return err
+				}
+			} else {
+				d.emit("remove", childTokens(tokens, k), nil, x.vals[k])
+			}
+		}
+		for _, k := range y.keys {
+			if _, ok := x.get(k); !ok {
+				d.emit("add", childTokens(tokens, k), y.vals[k], nil)
+			}
+		}
+		return nil
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		pre := 0
+		for pre < len(x) && pre < len(y) && jsonEqual(x[pre], y[pre]) {
+			pre++
+		}
+		suf := 0
+		for suf < len(x)-pre && suf < len(y)-pre && jsonEqual(x[len(x)-1-suf], y[len(y)-1-suf]) {
+			suf++
+		}
+		nx, ny := len(x)-pre-suf, len(y)-pre-suf
+		common := min(nx, ny)
+		for i := 0; i < common; i++ {
+			if err := d.diff(x[pre+i], y[pre+i], childTokens(tokens, strconv.Itoa(pre+i))); err != nil {
+				return err
+			}
+		}
+		for i := nx - 1; i >= common; i-- {
+			d.emit("remove", childTokens(tokens, strconv.Itoa(pre+i)), nil, x[pre+i])
+		}
+		for i := common; i < ny; i++ {
+			d.emit("add", childTokens(tokens, strconv.Itoa(pre+i)), y[pre+i], nil)
+		}
+		return nil
+	}
+	if !jsonEqual(a, b) {
+		d.emit("replace", tokens, b, a)
+	}
+	return nil
+}
+
+// opKey returns the schemaKey of the value op adds or removes.
+func (d *patchDiff) opKey(i int) string {
+	op := &d.ops[i]
+	if op.key == "" {
+		if op.op == "remove" {
+			op.key = schemaKey(op.old)
+		} else {
+			op.key = schemaKey(op.value)
+		}
+	}
+	return op.key
+}
+
+// maxMoveCandidates caps the removes of an equal value that findMoves tries for one add.
+const maxMoveCandidates = 8
+
+// findMoves turns a remove and an add of the same value into one move, placed where the
+// add was or, failing that, where the remove was. The removes are indexed by value, and
+// a pair is only joined when the operations between them are unaffected (see
+// patchClear). It reports whether it made any.
+func (d *patchDiff) findMoves() bool {
+	removes := make(map[string][]int)
+	for i := range d.ops {
+		if d.ops[i].op == "remove" {
+			removes[d.opKey(i)] = append(removes[d.opKey(i)], i)
+		}
+	}
+	if len(removes) == 0 {
+		return false
+	}
+	moved := false
+	for j := range d.ops {
+		if d.ops[j].op != "add" {
+			continue
+		}
+		key := d.opKey(j)
+		cands := removes[key]
+		for n, i := range cands[:min(len(cands), maxMoveCandidates)] {
+			at, ok := d.movePlace(i, j)
+			if !ok {
+				continue
+			}
+			rm, add := d.ops[i], d.ops[j]
+			d.ops[i], d.ops[j] = patchOp{}, patchOp{}
+			d.ops[at] = patchOp{op: "move", from: rm.path, fromTokens: rm.pathTokens, path: add.path, pathTokens: add.pathTokens, value: add.value}
+			removes[key] = append(cands[:n:n], cands[n+1:]...)
+			moved = true
+			break
+		}
+	}
+	d.ops = slices.DeleteFunc(d.ops, func(op patchOp) bool { return op.op == "" })
+	return moved
+}
+
+// movePlace returns where the move joining remove i and add j can go without changing
+// what the patch does: at j, when the operations in between do not touch the removed
+// path, or at i, when they do not touch the added one.
+func (d *patchDiff) movePlace(i, j int) (int, bool) {
+	from, path := d.ops[i].pathTokens, d.ops[j].pathTokens
+	// A move removes before it adds, so an add that came first must not depend on it.
+	if j < i && pointersInteract(from, path) {
+		return 0, false
+	}
+	lo, hi := min(i, j), max(i, j)
+	if d.patchClear(lo, hi, from) {
+		return j, true
+	}
+	if d.patchClear(lo, hi, path) {
+		return i, true
+	}
+	return 0, false
+}
+
+// patchClear reports whether no operation strictly between lo and hi interacts with the
+// pointer tokens, so the change at tokens can happen anywhere in that span.
+func (d *patchDiff) patchClear(lo, hi int, tokens []string) bool {
+	for _, op := range d.ops[lo+1 : hi] {
+		if op.op == "" {
+			continue
+		}
+		if pointersInteract(op.pathTokens, tokens) || op.fromTokens != nil && pointersInteract(op.fromTokens, tokens) {
+			return false
+		}
+	}
+	return true
+}
+
+// pointersInteract reports whether an operation at p can change what q refers to or the
+// other way round: one is inside the other, or they are elements (or inside elements)
+// of the same array and one of them is the element itself, whose insertion or removal
+// shifts the other's index.
+func pointersInteract(p, q []string) bool {
+	n := 0
+	for n < len(p) && n < len(q) && p[n] == q[n] {
+		n++
+	}
+	if n == len(p) || n == len(q) {
+		return true
+	}
+	_, pi := pointerIndex(p[n])
+	_, qi := pointerIndex(q[n])
+	return pi && qi && (n == len(p)-1 || n == len(q)-1)
+}
+
+// findCopies turns the add of a non-empty object or array that also appears in a into
+// a copy from its first location there, when no earlier operation touches that location.
+// It reports whether it made any.
+func (d *patchDiff) findCopies(a interface{}) bool {
+	copied := false
+	var sources map[string][]string
+	for j := range d.ops {
+		op := d.ops[j]
+		if op.op != "add" || !patchCopyable(op.value) {
+			continue
+		}
+		if sources == nil {
+			sources = make(map[string][]string)
+			patchSources(a, []string{}, sources)
+		}
+		from, ok := sources[d.opKey(j)]
+		if !ok || !d.patchClear(-1, j, from) {
+			continue
+		}
+		d.ops[j] = patchOp{op: "copy", from: formatPointer(from), fromTokens: from, path: op.path, pathTokens: op.pathTokens, value: op.value}
+		copied = true
+	}
+	return copied
+}
+
+// patchCopyable reports whether v is worth a copy operation: a non-empty object or array.
+func patchCopyable(v interface{}) bool {
+	switch x := v.(type) {
+	case *jqObject:
+		return x.len() > 0
+	case []interface{}:
+		return len(x) > 0
+	}
+	return false
+}
+
+// patchSources maps the schemaKey of every copyable value in v to its first location.
+func patchSources(v interface{}, tokens []string, sources map[string][]string) {
+	if !patchCopyable(v) {
+		return
+	}
+	if key := schemaKey(v); sources[key] == nil {
+		sources[key] = tokens
+	}
+	switch x := v.(type) {
+	case *jqObject:
+		for _, k := range x.keys {
+			patchSources(x.vals[k], childTokens(tokens, k), sources)
+		}
+	case []interface{}:
+		for i, e := range x {
+			patchSources(e, childTokens(tokens, strconv.Itoa(i)), sources)
+		}
+	}
+}
+
+// check reports whether ops turn a into b. Only a timeout is returned as an error.
+func (d *patchDiff) check(a, b interface{}, ops []patchOp) (bool, error) {
+	out, err := applyPatch(d.ctx, a, ops)
+	if err != nil {
+		var ae *APIError
+		if errors.As(err, &ae) && ae.Code == CodeTimeout {
+			return false, err
+		}
+		return false, nil
+	}
+	return jsonEqual(out, b), nil
+}
+
+// patchChanges lists a generated patch as diff changes, one per operation and at the
+// operation's pointers: add and copy are "added" (a copy with From), remove is
+// "removed", replace "changed" and move "moved".
+func patchChanges(ops []patchOp) []DiffChange {
+	changes := make([]DiffChange, len(ops))
+	for i, op := range ops {
+		c := DiffChange{Path: op.path, From: op.from}
+		switch op.op {
+		case "add", "copy":
+			c.Kind, c.Right = "added", diffRaw(op.value)
+		case "remove":
+			c.Kind, c.Left = "removed", diffRaw(op.old)
+		case "replace":
+			c.Kind, c.Left, c.Right = "changed", diffRaw(op.old), diffRaw(op.value)
+		case "move":
+			c.Kind = "moved"
+		}
+		changes[i] = c
+	}
+	return changes
+}
+
+// encodePatch writes a patch as a JSON array with one compact operation per line.
+func encodePatch(ops []patchOp) (string, error) {
+	if len(ops) == 0 {
+		return "[]", nil
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	for i, op := range ops {
+		obj := newJQObject(4)
+		obj.put("op", op.op)
+		if patchFromOps[op.op] {
+			obj.put("from", op.from)
+		}
+		obj.put("path", op.path)
+		if patchValueOps[op.op] {
+			obj.put("value", op.value)
+		}
+		out, err := (&jqEval{}).encode(obj, "")
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  ")
+		b.Write(out)
+	}
+	b.WriteString("\n]")
+	return b.String(), nil
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatchJSON(t *testing.T) {
+	cases := []struct {
+		name, value, patch, want string
+	}{
+		// From RFC 6902, appendix A.
+		{"add member", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{"insert element", `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"remove member", `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move member", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"test", `{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"nested add", `{"foo": "bar"}`, `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"ignores unknown members", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`, `{"foo":"bar","baz":"qux"}`},
+		{"append", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"escaped keys", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}, {"op": "copy", "from": "/~1", "path": "/a~1b"}]`, `{"/":9,"~1":10,"a/b":9}`},
+		{"null value", `{"foo": null}`, `[{"op": "test", "path": "/foo", "value": null}, {"op": "replace", "path": "/foo", "value": false}]`, `{"foo":false}`},
+		{"replace document", `{"a": 1}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+		{"empty patch", `{"b": 1, "a": 2}`, `[]`, `{"b":1,"a":2}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := patchJSON(context.Background(), PatchRequest{Value: tc.value, Patch: tc.patch})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := compactJSON(t, resp.Result); got != tc.want {
+				t.Errorf("result = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPatchJSONErrors(t *testing.T) {
+	cases := []struct {
+		name, value, patch string
+		code               string
+		status             int
+		operation          int // -1: no details.operation
+	}{
+		{"not an array", `{}`, `{"op": "add"}`, CodeInvalidValue, http.StatusBadRequest, -1},
+		{"bad patch json", `{}`, `[`, CodeInvalidJSON, http.StatusBadRequest, -1},
+		{"unknown op", `{}`, `[{"op": "add", "path": "/a", "value": 1}, {"op": "delete", "path": "/a"}]`, CodeInvalidValue, http.StatusBadRequest, 1},
+		{"missing value", `{}`, `[{"op": "add", "path": "/a"}]`, CodeInvalidValue, http.StatusBadRequest, 0},
+		{"missing from", `{}`, `[{"op": "move", "path": "/a"}]`, CodeInvalidValue, http.StatusBadRequest, 0},
+		{"bad pointer", `{}`, `[{"op": "remove", "path": "a"}]`, CodeInvalidValue, http.StatusBadRequest, 0},
+		{"missing parent", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, CodePathNotFound, http.StatusBadRequest, 0},
+		{"remove missing", `{"a": 1}`, `[{"op": "remove", "path": "/a"}, {"op": "remove", "path": "/a"}]`, CodePathNotFound, http.StatusBadRequest, 1},
+		{"index past end", `[1]`, `[{"op": "add", "path": "/2", "value": 3}]`, CodePathNotFound, http.StatusBadRequest, 0},
+		{"leading zero", `[1, 2]`, `[{"op": "replace", "path": "/01", "value": 3}]`, CodePathNotFound, http.StatusBadRequest, 0},
+		{"move into itself", `{"a": {"b": 1}}`, `[{"op": "move", "from": "/a", "path": "/a/c"}]`, CodeInvalidValue, http.StatusBadRequest, 0},
+		{"test failed", `{"baz": "qux"}`, `[{"op": "add", "path": "/x", "value": 1}, {"op": "test", "path": "/baz", "value": "bar"}]`, CodeTestFailed, http.StatusConflict, 1},
+		{"test compares types", `{"a": "1"}`, `[{"op": "test", "path": "/a", "value": 1}]`, CodeTestFailed, http.StatusConflict, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := patchJSON(context.Background(), PatchRequest{Value: tc.value, Patch: tc.patch})
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			status := ae.Status
+			if status == 0 {
+				status = http.StatusBadRequest
+			}
+			if ae.Code != tc.code || ae.Field != "patch" || status != tc.status {
+				t.Errorf("error = %s on %q (%d), want %s on \"patch\" (%d): %s", ae.Code, ae.Field, status, tc.code, tc.status, ae.Message)
+			}
+			if op, ok := ae.Details["operation"]; tc.operation >= 0 && op != tc.operation || tc.operation < 0 && ok {
+				t.Errorf("details.operation = %v, want %d", op, tc.operation)
+			}
+		})
+	}
+}
+
+func TestDiffJSONPatch(t *testing.T) {
+	cases := []struct {
+		name, a, b, want string
+	}{
+		{"equal", `{"a": [1, {"b": 1.0}]}`, `{"a": [1, {"b": 1}]}`, `[]`},
+		{"members", `{"a": 1, "b": {"c": true}, "d": "x"}`, `{"a": 2, "b": {"c": true, "e": null}, "f": [1]}`, `[
+  {"op":"replace","path":"/a","value":2},
+  {"op":"add","path":"/b/e","value":null},
+  {"op":"remove","path":"/d"},
+  {"op":"add","path":"/f","value":[1]}
+]`},
+		{"array insert", `[1, 2, 3, 4]`, `[0, 1, 2, 3, 4]`, `[
+  {"op":"add","path":"/0","value":0}
+]`},
+		{"array middle", `[1, 2, 3, 4, 5]`, `[1, 9, 4, 5]`, `[
+  {"op":"replace","path":"/1","value":9},
+  {"op":"remove","path":"/2"}
+]`},
+		{"array tail", `{"xs": [1, 2, 3, 4]}`, `{"xs": [1]}`, `[
+  {"op":"remove","path":"/xs/3"},
+  {"op":"remove","path":"/xs/2"},
+  {"op":"remove","path":"/xs/1"}
+]`},
+		{"renamed key", `{"user": {"name": "ann", "tags": ["a", "b"]}}`, `{"owner": {"name": "ann", "tags": ["a", "b"]}}`, `[
+  {"op":"move","from":"/user","path":"/owner"}
+]`},
+		{"moved element", `{"a": [1, 2], "b": [3]}`, `{"a": [2], "b": [1, 3]}`, `[
+  {"op":"move","from":"/a/0","path":"/b/0"}
+]`},
+		{"copied value", `{"billing": {"street": "Main", "zip": "1000"}}`, `{"billing": {"street": "Main", "zip": "1000"}, "shipping": {"street": "Main", "zip": "1000"}}`, `[
+  {"op":"copy","from":"/billing","path":"/shipping"}
+]`},
+		{"type change", `{"a": [1]}`, `{"a": {"0": 1}}`, `[
+  {"op":"replace","path":"/a","value":{"0":1}}
+]`},
+		{"document", `1`, `"1"`, `[
+  {"op":"replace","path":"","value":"1"}
+]`},
+		{"escaped pointer", `{"a/b": 1}`, `{"a/b": 2, "~": 3}`, `[
+  {"op":"replace","path":"/a~1b","value":2},
+  {"op":"add","path":"/~0","value":3}
+]`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := diffJSON(context.Background(), DiffRequest{ValueA: tc.a, ValueB: tc.b, Output: "patch"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("patch =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+			// The patch must turn a into b.
+			out, err := patchJSON(context.Background(), PatchRequest{Value: tc.a, Patch: resp.Result})
+			if err != nil {
+				t.Fatalf("applying the patch: %v", err)
+			}
+			if !jsonEqual(decodeForTest(t, out.Result), decodeForTest(t, tc.b)) {
+				t.Errorf("patched = %s, want %s", compactJSON(t, out.Result), tc.b)
+			}
+		})
+	}
+}
+
+func TestDiffJSONPatchRoundTrip(t *testing.T) {
+	pairs := [][2]string{
+		{`[{"id": 1}, {"id": 2}, {"id": 3}]`, `[{"id": 3}, {"id": 1}, {"id": 2}]`},
+		{`{"a": [1, 2], "b": [3]}`, `{"a": [2], "b": [1, 3]}`},
+		{`{"a": {"x": [1, 2, 3]}, "b": 1}`, `{"b": {"x": [1, 2, 3]}, "a": [{"x": [1, 2, 3]}]}`},
+		{`[[1, 2], [3], [1, 2]]`, `[[3], [1, 2], [1, 2], [1, 2]]`},
+		{`{"k": [true, false, null, "s"]}`, `{"k": ["s", null, false, true], "j": [true, false, null, "s"]}`},
+		{`{"a": [{"x": 1}, 2, 3], "b": []}`, `{"a": [2, 3], "b": [{"x": 1}]}`},
+		{`{"a": [{"x": 1}, {"y": 2}, 3, 4]}`, `{"a": [3, 4, {"y": 2}, {"x": 1}]}`},
+		{`{"a": {"b": {"c": [1]}}, "d": [5, 6]}`, `{"d": [{"c": [1]}, 5, 6], "e": {"b": {"c": [1]}}}`},
+		{`[[1], [2], [3], [4]]`, `[[4], [3], [2], [1], [1]]`},
+	}
+	for _, p := range pairs {
+		resp, err := diffJSON(context.Background(), DiffRequest{ValueA: p[0], ValueB: p[1], Output: "patch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := patchJSON(context.Background(), PatchRequest{Value: p[0], Patch: resp.Result})
+		if err != nil {
+			t.Fatalf("%s -> %s: applying %s: %v", p[0], p[1], resp.Result, err)
+		}
+		if !jsonEqual(decodeForTest(t, out.Result), decodeForTest(t, p[1])) {
+			t.Errorf("%s -> %s: patch %s gives %s", p[0], p[1], resp.Result, out.Result)
+		}
+	}
+}
+
+func TestDiffJSONPatchChanges(t *testing.T) {
+	resp, err := diffJSON(context.Background(), DiffRequest{ValueA: `{"xs": [1, 2, 3], "a": {"b": 1}, "c": 1}`, ValueB: `{"xs": [0, 1, 2, 3], "z": {"b": 1}, "c": 2}`, Output: "patch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiffChange{
+		{Kind: "added", Path: "/xs/0", Right: json.RawMessage(`0`)},
+		{Kind: "changed", Path: "/c", Left: json.RawMessage(`1`), Right: json.RawMessage(`2`)},
+		{Kind: "moved", Path: "/z", From: "/a"},
+	}
+	if !reflect.DeepEqual(resp.Changes, want) {
+		t.Errorf("changes = %+v, want %+v\npatch %s", resp.Changes, want, resp.Result)
+	}
+}
+
+func TestDiffJSONPatchManyMoves(t *testing.T) {
+	// Renaming every key used to check each candidate move by applying the whole patch.
+	const n = 1000
+	var a, b []string
+	for i := 0; i < n; i++ {
+		a = append(a, fmt.Sprintf(`"k%d": {"v": %d}`, i, i))
+		b = append(b, fmt.Sprintf(`"r%d": {"v": %d}`, i, i))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := diffJSON(ctx, DiffRequest{ValueA: "{" + strings.Join(a, ",") + "}", ValueB: "{" + strings.Join(b, ",") + "}", Output: "patch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(resp.Result, `"op":"move"`); got != n {
+		t.Errorf("got %d moves, want %d", got, n)
+	}
+}
+
+func TestPointersInteract(t *testing.T) {
+	cases := []struct {
+		p, q []string
+		want bool
+	}{
+		{[]string{"a"}, []string{"b"}, false},
+		{[]string{"a"}, []string{"a", "b"}, true},
+		{[]string{}, []string{"a"}, true},
+		{[]string{"xs", "0"}, []string{"xs", "3"}, true},
+		{[]string{"xs", "0"}, []string{"xs", "3", "id"}, true},
+		{[]string{"xs", "0", "id"}, []string{"xs", "3", "id"}, false},
+		{[]string{"o", "0"}, []string{"o", "a"}, false},
+	}
+	for _, tc := range cases {
+		if got := pointersInteract(tc.p, tc.q); got != tc.want {
+			t.Errorf("pointersInteract(%q, %q) = %v, want %v", tc.p, tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestDiffJSONBadOutput(t *testing.T) {
+	_, err := diffJSON(context.Background(), DiffRequest{ValueA: `1`, ValueB: `2`, Output: "yaml"})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeInvalidOption || ae.Field != "output" {
+		t.Errorf("err = %v, want invalid_option on output", err)
+	}
+}
+
+// compactJSON re-encodes JSON text compactly, keeping key order.
+func compactJSON(t *testing.T, s string) string {
+	t.Helper()
+	out, err := (&jqEval{}).encode(decodeForTest(t, s), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func decodeForTest(t *testing.T, s string) interface{} {
+	t.Helper()
+	v, err := decodeOrderedValue("value", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
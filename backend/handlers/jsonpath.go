@@ -0,0 +1,1234 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements RFC 9535 JSONPath: parsing a query into segments and selectors
+// (names, wildcards, indices, slices and filters with the standard functions length,
+// count, match, search and value) and evaluating it to a nodelist with normalized paths.
+// Object members are visited in sorted key order so results are deterministic.
+
+// jpNode is one node of a nodelist: a value with its normalized path, e.g. $['a'][0],
+// and the equivalent JSON Pointer, e.g. /a/0.
+type jpNode struct {
+	path    string
+	pointer string
+	value   interface{}
+}
+
+// member returns the node for the member name of n, holding v.
+func (n jpNode) member(name string, v interface{}) jpNode {
+	return jpNode{n.path + jpNameSegment(name), pointerJoin(n.pointer, name), v}
+}
+
+// element returns the node for array index i of n, holding v.
+func (n jpNode) element(i int, v interface{}) jpNode {
+	idx := strconv.Itoa(i)
+	return jpNode{n.path + "[" + idx + "]", n.pointer + "/" + idx, v}
+}
+
+type jpQuery struct {
+	relative bool // @ (current node) instead of $ (root)
+	segments []jpSegment
+}
+
+type jpSegment struct {
+	descendant bool // ..[...]
+	selectors  []jpSelector
+}
+
+type jpSelectorKind int
+
+const (
+	jpName jpSelectorKind = iota
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+type jpSelector struct {
+	kind   jpSelectorKind
+	name   string
+	index  int64
+	slice  [3]*int64 // start, end, step; nil when omitted
+	filter jpExpr
+}
+
+// singular reports whether q selects at most one node: only name and index selectors in
+// child segments.
+func (q *jpQuery) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 {
+			return false
+		}
+		if k := seg.selectors[0].kind; k != jpName && k != jpIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter expressions. Logical expressions evaluate to bool; comparables evaluate to a
+// value or Nothing.
+type jpExpr interface{}
+
+type (
+	jpOr      struct{ terms []jpExpr }
+	jpAnd     struct{ terms []jpExpr }
+	jpNot     struct{ expr jpExpr }
+	jpExists  struct{ query *jpQuery } // test expression on a filter query
+	jpCompare struct {
+		op          string
+		left, right jpExpr // *jpLiteral, *jpQuery (singular) or *jpCall
+	}
+	jpLiteral struct{ value interface{} }
+)
+
+// Function extension types (RFC 9535 section 2.4.1).
+type jpType int
+
+const (
+	jpValueType jpType = iota
+	jpLogicalType
+	jpNodesType
+)
+
+type jpCall struct {
+	name string
+	args []jpExpr
+}
+
+type jpFunction struct {
+	params []jpType
+	result jpType
+}
+
+var jpFunctions = map[string]jpFunction{
+	"length": {[]jpType{jpValueType}, jpValueType},
+	"count":  {[]jpType{jpNodesType}, jpValueType},
+	"match":  {[]jpType{jpValueType, jpValueType}, jpLogicalType},
+	"search": {[]jpType{jpValueType, jpValueType}, jpLogicalType},
+	"value":  {[]jpType{jpNodesType}, jpValueType},
+}
+
+// jpMaxInt is the largest index allowed in a query (I-JSON exact integer range).
+const jpMaxInt = 1<<53 - 1
+
+// maxQueryDepth bounds how deeply a query or filter may nest (parentheses, filters within
+// filters), so parsing and evaluation cannot exhaust the stack.
+const maxQueryDepth = 128
+
+// syntaxError is a query or filter compile error at a byte offset of the source.
+type syntaxError struct {
+	offset int
+	msg    string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.msg, e.offset)
+}
+
+// jpParser is a recursive-descent parser for the RFC 9535 ABNF.
+type jpParser struct {
+	src   string
+	pos   int
+	depth int // nesting of logical expressions
+}
+
+// parseJSONPath compiles a JSONPath query such as $.items[?@.price > 10].name.
+func parseJSONPath(src string) (*jpQuery, error) {
+	p := &jpParser{src: src}
+	if !p.eat("$") {
+		return nil, p.errorf("query must start with $")
+	}
+	q, err := p.segments(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.rest())
+	}
+	return q, nil
+}
+
+func (p *jpParser) errorf(format string, args ...interface{}) error {
+	return &syntaxError{offset: p.pos, msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *jpParser) rest() string {
+	r := p.src[p.pos:]
+	if len(r) > 10 {
+		r = r[:10] + "..."
+	}
+	return r
+}
+
+func (p *jpParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *jpParser) eat(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) blank() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// segments parses the segments after $ or @. Blank space is allowed before each segment,
+// but not at the end of the whole query, so it is only consumed when a segment follows.
+func (p *jpParser) segments(relative bool) (*jpQuery, error) {
+	q := &jpQuery{relative: relative}
+	for {
+		save := p.pos
+		p.blank()
+		var seg jpSegment
+		switch {
+		case p.eat(".."):
+			seg.descendant = true
+			switch {
+			case p.peek() == '[':
+				sels, err := p.bracketed()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			case p.eat("*"):
+				seg.selectors = []jpSelector{{kind: jpWildcard}}
+			default:
+				name, ok := p.memberName()
+				if !ok {
+					return nil, p.errorf("expected name, * or [ after ..")
+				}
+				seg.selectors = []jpSelector{{kind: jpName, name: name}}
+			}
+		case p.eat("."):
+			if p.eat("*") {
+				seg.selectors = []jpSelector{{kind: jpWildcard}}
+			} else {
+				name, ok := p.memberName()
+				if !ok {
+					return nil, p.errorf("expected name or * after .")
+				}
+				seg.selectors = []jpSelector{{kind: jpName, name: name}}
+			}
+		case p.peek() == '[':
+			sels, err := p.bracketed()
+			if err != nil {
+				return nil, err
+			}
+			seg.selectors = sels
+		default:
+			p.pos = save
+			return q, nil
+		}
+		q.segments = append(q.segments, seg)
+	}
+}
+
+// memberName parses a member-name-shorthand: ALPHA / "_" / non-ASCII, then also digits.
+func (p *jpParser) memberName() (string, bool) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		first := p.pos == start
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r >= 0x80 || (!first && r >= '0' && r <= '9') {
+			p.pos += size
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos], p.pos > start
+}
+
+func (p *jpParser) bracketed() ([]jpSelector, error) {
+	p.pos++ // [
+	var sels []jpSelector
+	for {
+		p.blank()
+		sel, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.blank()
+		if p.eat("]") {
+			return sels, nil
+		}
+		if !p.eat(",") {
+			return nil, p.errorf("expected , or ] in selector list")
+		}
+	}
+}
+
+func (p *jpParser) selector() (jpSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		return jpSelector{kind: jpName, name: s}, err
+	case c == '*':
+		p.pos++
+		return jpSelector{kind: jpWildcard}, nil
+	case c == '?':
+		p.pos++
+		p.blank()
+		expr, err := p.logicalOr()
+		return jpSelector{kind: jpFilter, filter: expr}, err
+	case c == ':' || c == '-' || (c >= '0' && c <= '9'):
+		return p.indexOrSlice()
+	}
+	return jpSelector{}, p.errorf("invalid selector")
+}
+
+func (p *jpParser) indexOrSlice() (jpSelector, error) {
+	var parts [3]*int64
+	n := 0
+	for {
+		if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+			v, err := p.integer()
+			if err != nil {
+				return jpSelector{}, err
+			}
+			parts[n] = &v
+			p.blank()
+		}
+		if n == 2 || !p.eat(":") {
+			break
+		}
+		n++
+		p.blank()
+	}
+	if n == 0 {
+		if parts[0] == nil {
+			return jpSelector{}, p.errorf("invalid index")
+		}
+		return jpSelector{kind: jpIndex, index: *parts[0]}, nil
+	}
+	return jpSelector{kind: jpSlice, slice: parts}, nil
+}
+
+// integer parses an int (no leading zeros, no -0) within the I-JSON range.
+func (p *jpParser) integer() (int64, error) {
+	start := p.pos
+	p.eat("-")
+	digits := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	switch {
+	case p.pos == digits:
+		return 0, p.errorf("expected digits")
+	case p.src[digits] == '0' && p.pos-digits > 1, text == "-0":
+		p.pos = start
+		return 0, p.errorf("invalid integer %q", text)
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || v > jpMaxInt || v < -jpMaxInt {
+		p.pos = start
+		return 0, p.errorf("integer %s out of range", text)
+	}
+	return v, nil
+}
+
+// stringLiteral parses a single- or double-quoted string with JSON-style escapes.
+func (p *jpParser) stringLiteral() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c == '\\':
+			r, err := p.escape(quote)
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+func (p *jpParser) escape(quote byte) (rune, error) {
+	p.pos++ // backslash
+	if p.pos >= len(p.src) {
+		return 0, p.errorf("unterminated escape")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case '/', '\\':
+		return rune(c), nil
+	case 'u':
+		r, err := p.hex4()
+		if err != nil {
+			return 0, err
+		}
+		if r >= 0xD800 && r <= 0xDBFF {
+			if !p.eat(`\u`) {
+				return 0, p.errorf("unpaired surrogate")
+			}
+			lo, err := p.hex4()
+			if err != nil {
+				return 0, err
+			}
+			if lo < 0xDC00 || lo > 0xDFFF {
+				return 0, p.errorf("unpaired surrogate")
+			}
+			return 0x10000 + (r-0xD800)<<10 + (lo - 0xDC00), nil
+		}
+		if r >= 0xDC00 && r <= 0xDFFF {
+			return 0, p.errorf("unpaired surrogate")
+		}
+		return r, nil
+	}
+	if c == quote {
+		return rune(c), nil
+	}
+	p.pos--
+	return 0, p.errorf("invalid escape \\%c", c)
+}
+
+func (p *jpParser) hex4() (rune, error) {
+	if p.pos+4 > len(p.src) {
+		return 0, p.errorf("short \\u escape")
+	}
+	v, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid \\u escape")
+	}
+	p.pos += 4
+	return rune(v), nil
+}
+
+func (p *jpParser) logicalOr() (jpExpr, error) {
+	if p.depth++; p.depth > maxQueryDepth {
+		return nil, p.errorf("filter nests more than %d levels", maxQueryDepth)
+	}
+	defer func() { p.depth-- }()
+	left, err := p.logicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []jpExpr{left}
+	for {
+		save := p.pos
+		p.blank()
+		if !p.eat("||") {
+			p.pos = save
+			break
+		}
+		p.blank()
+		t, err := p.logicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return &jpOr{terms}, nil
+}
+
+func (p *jpParser) logicalAnd() (jpExpr, error) {
+	left, err := p.basic()
+	if err != nil {
+		return nil, err
+	}
+	terms := []jpExpr{left}
+	for {
+		save := p.pos
+		p.blank()
+		if !p.eat("&&") {
+			p.pos = save
+			break
+		}
+		p.blank()
+		t, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return &jpAnd{terms}, nil
+}
+
+// basic parses a parenthesized expression, a comparison or a test expression.
+func (p *jpParser) basic() (jpExpr, error) {
+	if p.eat("!") {
+		p.blank()
+		start := p.pos
+		e, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := e.(*jpCompare); ok {
+			p.pos = start
+			return nil, p.errorf("! cannot negate a comparison without parentheses")
+		}
+		return &jpNot{e}, nil
+	}
+	if p.eat("(") {
+		p.blank()
+		e, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.blank()
+		if !p.eat(")") {
+			return nil, p.errorf("expected )")
+		}
+		return e, nil
+	}
+	start := p.pos
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	save := p.pos
+	p.blank()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.eat(op) {
+			if err := p.checkComparable(left, start); err != nil {
+				return nil, err
+			}
+			p.blank()
+			rstart := p.pos
+			right, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.checkComparable(right, rstart); err != nil {
+				return nil, err
+			}
+			return &jpCompare{op: op, left: left, right: right}, nil
+		}
+	}
+	p.pos = save
+	switch e := left.(type) {
+	case *jpQuery:
+		return &jpExists{e}, nil
+	case *jpCall:
+		if jpFunctions[e.name].result == jpValueType {
+			p.pos = start
+			return nil, p.errorf("result of %s() must be compared", e.name)
+		}
+		return e, nil
+	}
+	p.pos = start
+	return nil, p.errorf("literal must be compared")
+}
+
+func (p *jpParser) checkComparable(e jpExpr, at int) error {
+	switch e := e.(type) {
+	case *jpQuery:
+		if !e.singular() {
+			p.pos = at
+			return p.errorf("only singular queries can be compared")
+		}
+	case *jpCall:
+		if jpFunctions[e.name].result != jpValueType {
+			p.pos = at
+			return p.errorf("result of %s() cannot be compared", e.name)
+		}
+	}
+	return nil
+}
+
+// primary parses a literal, a filter query (@... or $...) or a function call.
+func (p *jpParser) primary() (jpExpr, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		return p.segments(c == '@')
+	case c == '\'' || c == '"':
+		s, err := p.stringLiteral()
+		return &jpLiteral{s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' {
+				p.pos++
+				continue
+			}
+			break
+		}
+		name := p.src[start:p.pos]
+		switch name {
+		case "true":
+			return &jpLiteral{true}, nil
+		case "false":
+			return &jpLiteral{false}, nil
+		case "null":
+			return &jpLiteral{nil}, nil
+		}
+		if p.peek() != '(' {
+			p.pos = start
+			return nil, p.errorf("unexpected %q", name)
+		}
+		return p.call(name, start)
+	}
+	return nil, p.errorf("expected a literal, query or function")
+}
+
+func (p *jpParser) number() (jpExpr, error) {
+	start := p.pos
+	p.eat("-")
+	digits := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == digits || (p.src[digits] == '0' && p.pos-digits > 1) {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	if p.eat(".") {
+		frac := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == frac {
+			return nil, p.errorf("expected digits after .")
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		p.pos++
+		if c := p.peek(); c == '+' || c == '-' {
+			p.pos++
+		}
+		exp := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+		if p.pos == exp {
+			return nil, p.errorf("expected exponent digits")
+		}
+	}
+	f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number")
+	}
+	return &jpLiteral{f}, nil
+}
+
+// call parses a function call's arguments and checks them against the function's
+// declared parameter types.
+func (p *jpParser) call(name string, start int) (jpExpr, error) {
+	fn, ok := jpFunctions[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s()", name)
+	}
+	p.pos++ // (
+	c := &jpCall{name: name}
+	p.blank()
+	if !p.eat(")") {
+		for {
+			p.blank()
+			astart := p.pos
+			arg, err := p.primary()
+			if err != nil {
+				return nil, err
+			}
+			i := len(c.args)
+			if i < len(fn.params) {
+				if err := p.checkArg(name, fn.params[i], arg, astart); err != nil {
+					return nil, err
+				}
+			}
+			c.args = append(c.args, arg)
+			p.blank()
+			if p.eat(")") {
+				break
+			}
+			if !p.eat(",") {
+				return nil, p.errorf("expected , or ) in arguments of %s()", name)
+			}
+		}
+	}
+	if len(c.args) != len(fn.params) {
+		p.pos = start
+		return nil, p.errorf("%s() takes %d argument(s), got %d", name, len(fn.params), len(c.args))
+	}
+	return c, nil
+}
+
+func (p *jpParser) checkArg(name string, want jpType, arg jpExpr, at int) error {
+	ok := true
+	switch a := arg.(type) {
+	case *jpLiteral:
+		ok = want == jpValueType
+	case *jpQuery:
+		ok = want == jpNodesType || want == jpLogicalType || (want == jpValueType && a.singular())
+	case *jpCall:
+		got := jpFunctions[a.name].result
+		ok = got == want || (want == jpLogicalType && got == jpNodesType)
+	}
+	if !ok {
+		p.pos = at
+		return p.errorf("invalid argument for %s()", name)
+	}
+	return nil
+}
+
+// jpEvaluator runs a compiled query against a document, honoring ctx and a cap on the
+// number of nodes produced by any segment.
+type jpEvaluator struct {
+	ctx      context.Context
+	root     interface{}
+	maxNodes int
+	steps    int
+	regexps  map[string]*regexp.Regexp
+}
+
+func (ev *jpEvaluator) tick() error {
+	ev.steps++
+	if ev.steps%1024 == 0 {
+		return checkContext(ev.ctx)
+	}
+	return nil
+}
+
+func (ev *jpEvaluator) query(q *jpQuery, current jpNode) ([]jpNode, error) {
+	nodes := []jpNode{{path: "$", value: ev.root}}
+	if q.relative {
+		nodes = []jpNode{current}
+	}
+	for _, seg := range q.segments {
+		var out []jpNode
+		for _, n := range nodes {
+			var err error
+			if seg.descendant {
+				out, err = ev.descend(seg.selectors, n, out)
+			} else {
+				out, err = ev.selectAll(seg.selectors, n, out)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		nodes = out
+	}
+	return nodes, nil
+}
+
+// descend applies selectors to n and every descendant of n, in document order.
+func (ev *jpEvaluator) descend(sels []jpSelector, n jpNode, out []jpNode) ([]jpNode, error) {
+	out, err := ev.selectAll(sels, n, out)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range jpChildren(n) {
+		if out, err = ev.descend(sels, child, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (ev *jpEvaluator) selectAll(sels []jpSelector, n jpNode, out []jpNode) ([]jpNode, error) {
+	for _, sel := range sels {
+		var err error
+		if out, err = ev.selectOne(sel, n, out); err != nil {
+			return nil, err
+		}
+	}
+	if len(out) > ev.maxNodes {
+		e := newError(CodeTooMany, "path", fmt.Sprintf("query selects more than %d nodes", ev.maxNodes))
+		e.Details = map[string]interface{}{"max": ev.maxNodes}
+		return nil, e
+	}
+	return out, nil
+}
+
+func (ev *jpEvaluator) selectOne(sel jpSelector, n jpNode, out []jpNode) ([]jpNode, error) {
+	if err := ev.tick(); err != nil {
+		return nil, err
+	}
+	switch sel.kind {
+	case jpName:
+		if m, ok := n.value.(map[string]interface{}); ok {
+			if v, ok := m[sel.name]; ok {
+				out = append(out, n.member(sel.name, v))
+			}
+		}
+	case jpWildcard:
+		out = append(out, jpChildren(n)...)
+	case jpIndex:
+		if a, ok := n.value.([]interface{}); ok {
+			i := sel.index
+			if i < 0 {
+				i += int64(len(a))
+			}
+			if i >= 0 && i < int64(len(a)) {
+				out = append(out, n.element(int(i), a[i]))
+			}
+		}
+	case jpSlice:
+		if a, ok := n.value.([]interface{}); ok {
+			for _, i := range jpSliceIndices(sel.slice, int64(len(a))) {
+				out = append(out, n.element(int(i), a[i]))
+			}
+		}
+	case jpFilter:
+		for _, child := range jpChildren(n) {
+			ok, err := ev.logical(sel.filter, child)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				out = append(out, child)
+			}
+		}
+	}
+	return out, nil
+}
+
+// jpChildren returns the array elements or object members (sorted by key) of n.
+func jpChildren(n jpNode) []jpNode {
+	switch v := n.value.(type) {
+	case []interface{}:
+		out := make([]jpNode, len(v))
+		for i, e := range v {
+			out[i] = n.element(i, e)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]jpNode, len(keys))
+		for i, k := range keys {
+			out[i] = n.member(k, v[k])
+		}
+		return out
+	}
+	return nil
+}
+
+// jpSliceIndices returns the indices selected by start:end:step on an array of length n
+// (RFC 9535 section 2.3.4.2.2).
+func jpSliceIndices(s [3]*int64, n int64) []int64 {
+	step := int64(1)
+	if s[2] != nil {
+		step = *s[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	norm := func(i int64) int64 {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int64) int64 {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+	var out []int64
+	if step > 0 {
+		start, end := int64(0), n
+		if s[0] != nil {
+			start = norm(*s[0])
+		}
+		if s[1] != nil {
+			end = norm(*s[1])
+		}
+		for i := clamp(start, 0, n); i < clamp(end, 0, n); i += step {
+			out = append(out, i)
+		}
+		return out
+	}
+	start, end := n-1, -n-1
+	if s[0] != nil {
+		start = norm(*s[0])
+	}
+	if s[1] != nil {
+		end = norm(*s[1])
+	}
+	for i := clamp(start, -1, n-1); clamp(end, -1, n-1) < i; i += step {
+		out = append(out, i)
+	}
+	return out
+}
+
+// logical evaluates a filter expression with @ bound to current.
+func (ev *jpEvaluator) logical(e jpExpr, current jpNode) (bool, error) {
+	switch e := e.(type) {
+	case *jpOr:
+		for _, t := range e.terms {
+			ok, err := ev.logical(t, current)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case *jpAnd:
+		for _, t := range e.terms {
+			ok, err := ev.logical(t, current)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case *jpNot:
+		ok, err := ev.logical(e.expr, current)
+		return !ok, err
+	case *jpExists:
+		nodes, err := ev.query(e.query, current)
+		return len(nodes) > 0, err
+	case *jpCall:
+		v, err := ev.call(e, current)
+		if err != nil {
+			return false, err
+		}
+		if nodes, ok := v.([]jpNode); ok {
+			return len(nodes) > 0, nil
+		}
+		b, _ := v.(bool)
+		return b, nil
+	case *jpCompare:
+		l, lok, err := ev.comparable(e.left, current)
+		if err != nil {
+			return false, err
+		}
+		r, rok, err := ev.comparable(e.right, current)
+		if err != nil {
+			return false, err
+		}
+		return jpCompareValues(e.op, l, lok, r, rok), nil
+	}
+	return false, fmt.Errorf("jsonpath: unexpected expression %T", e)
+}
+
+// comparable evaluates a literal, singular query or value function; ok is false for
+// Nothing (an empty nodelist or a function with no result).
+func (ev *jpEvaluator) comparable(e jpExpr, current jpNode) (interface{}, bool, error) {
+	switch e := e.(type) {
+	case *jpLiteral:
+		return e.value, true, nil
+	case *jpQuery:
+		nodes, err := ev.query(e, current)
+		if err != nil || len(nodes) == 0 {
+			return nil, false, err
+		}
+		return nodes[0].value, true, nil
+	case *jpCall:
+		v, err := ev.call(e, current)
+		if err != nil {
+			return nil, false, err
+		}
+		if _, nothing := v.(jpNothing); nothing {
+			return nil, false, nil
+		}
+		return v, true, nil
+	}
+	return nil, false, fmt.Errorf("jsonpath: unexpected comparable %T", e)
+}
+
+// jpNothing is the result of a value function with no value.
+type jpNothing struct{}
+
+// call evaluates a function: a value (or jpNothing), a bool or a []jpNode.
+func (ev *jpEvaluator) call(c *jpCall, current jpNode) (interface{}, error) {
+	valueArg := func(i int) (interface{}, bool, error) {
+		return ev.comparable(c.args[i], current)
+	}
+	nodesArg := func(i int) ([]jpNode, error) {
+		switch a := c.args[i].(type) {
+		case *jpQuery:
+			return ev.query(a, current)
+		case *jpCall:
+			v, err := ev.call(a, current)
+			nodes, _ := v.([]jpNode)
+			return nodes, err
+		}
+		return nil, nil
+	}
+	switch c.name {
+	case "length":
+		v, ok, err := valueArg(0)
+		if err != nil || !ok {
+			return jpNothing{}, err
+		}
+		switch v := v.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return jpNothing{}, nil
+	case "count":
+		nodes, err := nodesArg(0)
+		return float64(len(nodes)), err
+	case "value":
+		nodes, err := nodesArg(0)
+		if err != nil || len(nodes) != 1 {
+			return jpNothing{}, err
+		}
+		return nodes[0].value, nil
+	case "match", "search":
+		s, sok, err := valueArg(0)
+		if err != nil {
+			return false, err
+		}
+		pat, pok, err := valueArg(1)
+		if err != nil {
+			return false, err
+		}
+		str, isStr := s.(string)
+		patStr, isPat := pat.(string)
+		if !sok || !pok || !isStr || !isPat {
+			return false, nil
+		}
+		re := ev.regexp(patStr, c.name == "match")
+		return re != nil && re.MatchString(str), nil
+	}
+	return nil, fmt.Errorf("jsonpath: unknown function %s", c.name)
+}
+
+// regexp compiles an I-Regexp (RFC 9485) pattern, anchored for match(); nil if invalid.
+func (ev *jpEvaluator) regexp(pattern string, anchored bool) *regexp.Regexp {
+	key := strconv.FormatBool(anchored) + pattern
+	if re, ok := ev.regexps[key]; ok {
+		return re
+	}
+	src := iRegexpToGo(pattern)
+	if anchored {
+		src = `\A(?:` + src + `)\z`
+	}
+	re, err := regexp.Compile(src)
+	if err != nil {
+		re = nil
+	}
+	if ev.regexps == nil {
+		ev.regexps = make(map[string]*regexp.Regexp)
+	}
+	ev.regexps[key] = re
+	return re
+}
+
+// iRegexpToGo rewrites the I-Regexp "." (any character except CR and LF) for RE2, whose
+// "." only excludes LF. The rest of I-Regexp is a subset of RE2 syntax.
+func iRegexpToGo(pattern string) string {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteByte(c)
+			i++
+			b.WriteByte(pattern[i])
+			continue
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// jpCompareValues implements the comparison rules of RFC 9535 section 2.3.5.2.2.
+func jpCompareValues(op string, l interface{}, lok bool, r interface{}, rok bool) bool {
+	switch op {
+	case "==":
+		return jpEqual(l, lok, r, rok)
+	case "!=":
+		return !jpEqual(l, lok, r, rok)
+	case "<":
+		return lok && rok && jpLess(l, r)
+	case "<=":
+		return lok && rok && (jpLess(l, r) || jpEqual(l, lok, r, rok))
+	case ">":
+		return lok && rok && jpLess(r, l)
+	case ">=":
+		return lok && rok && (jpLess(r, l) || jpEqual(l, lok, r, rok))
+	}
+	return false
+}
+
+func jpEqual(l interface{}, lok bool, r interface{}, rok bool) bool {
+	if !lok || !rok {
+		return lok == rok
+	}
+	return jsonEqual(l, r)
+}
+
+// jsonEqual reports deep equality of decoded JSON values, comparing numbers by value.
+// Objects may be maps or ordered *jqObjects; key order does not matter.
+func jsonEqual(a, b interface{}) bool {
+	if an, ok := jsonNumber(a); ok {
+		bn, ok := jsonNumber(b)
+		return ok && an == bn
+	}
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			w, ok := bv[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case *jqObject:
+		bv, ok := b.(*jqObject)
+		if !ok || av.len() != bv.len() {
+			return false
+		}
+		for _, k := range av.keys {
+			w, ok := bv.get(k)
+			if !ok || !jsonEqual(av.vals[k], w) {
+				return false
+			}
+		}
+		return true
+	}
+	switch b.(type) {
+	case []interface{}, map[string]interface{}, *jqObject:
+		return false
+	}
+	return a == b
+}
+
+func jpLess(l, r interface{}) bool {
+	if ln, ok := jsonNumber(l); ok {
+		rn, ok := jsonNumber(r)
+		return ok && ln < rn
+	}
+	ls, ok := l.(string)
+	rs, rok := r.(string)
+	return ok && rok && ls < rs // UTF-8 byte order is code point order
+}
+
+// jsonNumber returns v as a float64 if it is a decoded JSON number.
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		if err != nil && !math.IsInf(f, 0) {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// jpNameSegment renders a member name as a normalized path segment: ['name'] with the
+// escaping of RFC 9535 section 2.7.
+func jpNameSegment(name string) string {
+	var b strings.Builder
+	b.WriteString("['")
+	for _, r := range name {
+		switch r {
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteString("']")
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The example documents of RFC 9535 (figures 1 and 6 and the slice and name examples).
+const (
+	rfcBookstore = `{ "store": {
+    "book": [
+      { "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+      { "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+      { "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+      { "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+    ],
+    "bicycle": { "color": "red", "price": 399 }
+  } }`
+	rfcFilterDoc = `{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}],
+  "o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}}, "e": "f"}`
+	rfcSliceDoc = `["a", "b", "c", "d", "e", "f", "g"]`
+	rfcNameDoc  = `{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`
+)
+
+func runJSONPath(t *testing.T, doc, query string) ([]string, PathResponse) {
+	t.Helper()
+	resp, err := pathQueryJSON(context.Background(), PathRequest{Value: doc, Path: query})
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	paths := make([]string, len(resp.Matches))
+	for i, m := range resp.Matches {
+		paths[i] = m.Path
+	}
+	return paths, resp
+}
+
+func TestJSONPathMatches(t *testing.T) {
+	cases := []struct {
+		doc, query string
+		want       []string
+	}{
+		{rfcBookstore, "$.store.book[*].author", []string{"$['store']['book'][0]['author']", "$['store']['book'][1]['author']", "$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+		{rfcBookstore, "$..author", []string{"$['store']['book'][0]['author']", "$['store']['book'][1]['author']", "$['store']['book'][2]['author']", "$['store']['book'][3]['author']"}},
+		{rfcBookstore, "$.store.*", []string{"$['store']['bicycle']", "$['store']['book']"}},
+		{rfcBookstore, "$.store..price", []string{"$['store']['bicycle']['price']", "$['store']['book'][0]['price']", "$['store']['book'][1]['price']", "$['store']['book'][2]['price']", "$['store']['book'][3]['price']"}},
+		{rfcBookstore, "$..book[2]", []string{"$['store']['book'][2]"}},
+		{rfcBookstore, "$..book[-1]", []string{"$['store']['book'][3]"}},
+		{rfcBookstore, "$..book[0,1]", []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+		{rfcBookstore, "$..book[:2]", []string{"$['store']['book'][0]", "$['store']['book'][1]"}},
+		{rfcBookstore, "$..book[?@.isbn]", []string{"$['store']['book'][2]", "$['store']['book'][3]"}},
+		{rfcBookstore, "$..book[?@.price<10]", []string{"$['store']['book'][0]", "$['store']['book'][2]"}},
+		{rfcBookstore, "$.store.book[?(@.price > 10)].title", []string{"$['store']['book'][1]['title']", "$['store']['book'][3]['title']"}},
+		{rfcBookstore, "$.nope", []string{}},
+
+		{rfcFilterDoc, "$.a[?@.b == 'kilo']", []string{"$['a'][9]"}},
+		{rfcFilterDoc, "$.a[?(@.b == 'kilo')]", []string{"$['a'][9]"}},
+		{rfcFilterDoc, "$.a[?@>3.5]", []string{"$['a'][1]", "$['a'][4]", "$['a'][5]"}},
+		{rfcFilterDoc, "$.a[?@.b]", []string{"$['a'][6]", "$['a'][7]", "$['a'][8]", "$['a'][9]"}},
+		{rfcFilterDoc, "$[?@.*]", []string{"$['a']", "$['o']"}},
+		{rfcFilterDoc, "$[?@[?@.b]]", []string{"$['a']"}},
+		{rfcFilterDoc, "$.o[?@<3, ?@<3]", []string{"$['o']['p']", "$['o']['q']", "$['o']['p']", "$['o']['q']"}},
+		{rfcFilterDoc, `$.a[?@<2 || @.b == "k"]`, []string{"$['a'][2]", "$['a'][7]"}},
+		{rfcFilterDoc, `$.a[?match(@.b, "[jk]")]`, []string{"$['a'][6]", "$['a'][7]"}},
+		{rfcFilterDoc, `$.a[?search(@.b, "[jk]")]`, []string{"$['a'][6]", "$['a'][7]", "$['a'][9]"}},
+		{rfcFilterDoc, "$.o[?@>1 && @<4]", []string{"$['o']['q']", "$['o']['r']"}},
+		{rfcFilterDoc, "$.o[?@.u || @.x]", []string{"$['o']['t']"}},
+		{rfcFilterDoc, "$.a[?@.b == $.x]", []string{"$['a'][0]", "$['a'][1]", "$['a'][2]", "$['a'][3]", "$['a'][4]", "$['a'][5]"}},
+		{rfcFilterDoc, "$.a[?!@.b]", []string{"$['a'][0]", "$['a'][1]", "$['a'][2]", "$['a'][3]", "$['a'][4]", "$['a'][5]"}},
+		{rfcFilterDoc, "$[?length(@) < 3]", []string{"$['e']"}},
+		{rfcFilterDoc, "$[?count(@.*) == 1]", []string{"$['e']"}[:0]},
+		{rfcFilterDoc, "$.o[?count(@.*) == 1]", []string{"$['o']['t']"}},
+		{rfcFilterDoc, "$[?value(@..u) == 6]", []string{"$['o']"}},
+		{rfcFilterDoc, "$.a[?@.b == null]", nil},
+
+		{rfcSliceDoc, "$[1:3]", []string{"$[1]", "$[2]"}},
+		{rfcSliceDoc, "$[5:]", []string{"$[5]", "$[6]"}},
+		{rfcSliceDoc, "$[1:5:2]", []string{"$[1]", "$[3]"}},
+		{rfcSliceDoc, "$[5:1:-2]", []string{"$[5]", "$[3]"}},
+		{rfcSliceDoc, "$[::-1]", []string{"$[6]", "$[5]", "$[4]", "$[3]", "$[2]", "$[1]", "$[0]"}},
+		{rfcSliceDoc, "$[1:3:0]", []string{}},
+		{rfcSliceDoc, "$[-2:]", []string{"$[5]", "$[6]"}},
+
+		{rfcNameDoc, "$.o['j j']['k.k']", []string{"$['o']['j j']['k.k']"}},
+		{rfcNameDoc, `$.o["j j"]["k.k"]`, []string{"$['o']['j j']['k.k']"}},
+		{rfcNameDoc, `$["'"]["@"]`, []string{`$['\'']['@']`}},
+		{rfcNameDoc, "$ .o ['j j']", []string{"$['o']['j j']"}},
+		{`{"a\nb": 1, "c\\d": 2, "\u0001": 3}`, "$.*", []string{`$['\u0001']`, `$['a\nb']`, `$['c\\d']`}},
+		{`{"é": {"x_1": true}}`, "$.é.x_1", []string{"$['é']['x_1']"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, _ := runJSONPath(t, tc.doc, tc.query)
+			if tc.want == nil {
+				tc.want = []string{}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("paths = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONPathResult(t *testing.T) {
+	_, resp := runJSONPath(t, rfcBookstore, "$..book[?@.price < 9].price")
+	if resp.Result != "[8.95,8.99]" {
+		t.Errorf("result = %s, want [8.95,8.99]", resp.Result)
+	}
+	if string(resp.Matches[1].Value) != "8.99" {
+		t.Errorf("match value = %s", resp.Matches[1].Value)
+	}
+	// Numbers are passed through exactly.
+	_, resp = runJSONPath(t, `{"id": 12345678901234567890}`, "$.id")
+	if resp.Result != "[12345678901234567890]" {
+		t.Errorf("result = %s", resp.Result)
+	}
+}
+
+func TestJSONPathSyntaxErrors(t *testing.T) {
+	cases := []struct {
+		query      string
+		wantOffset int
+	}{
+		{"", 0},
+		{"$.", 2},
+		{"$[", 2},
+		{"$[?@.b == {}]", 10},
+		{"$[01]", 2},
+		{"$[-0]", 2},
+		{"$['a'", 5},
+		{"$[?@.a]x", 7},
+		{"$[?1]", 3},
+		{"$[?length(@.*) == 1]", 10},
+		{"$[?count(@.*)]", 3},
+		{"$[?match(@.a, 'x') == true]", 3},
+		{"$[?@.* == 1]", 3},
+		{"$[?!@.a == 1]", 4},
+		{"$[?foo(@)]", 3},
+		{"$[?length(@, 1) == 1]", 3},
+		{"$[9007199254740992]", 2},
+		{`$["\x"]`, 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			_, err := pathQueryJSON(context.Background(), PathRequest{Value: "{}", Path: tc.query, Syntax: "jsonpath"})
+			var ae *APIError
+			if !errors.As(err, &ae) || ae.Code != CodeInvalidQuery || ae.Field != "path" {
+				t.Fatalf("err = %v, want %s on path", err, CodeInvalidQuery)
+			}
+			if got := ae.Details["offset"]; got != tc.wantOffset {
+				t.Errorf("offset = %v, want %d (%s)", got, tc.wantOffset, ae.Message)
+			}
+		})
+	}
+}
+
+func TestPathQuerySyntaxOption(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantResult string
+		wantPaths  []string
+	}{
+		{"auto dot", `{"value":"{\"a\":{\"b\":[1,2]}}","path":"a.b.1"}`, http.StatusOK, "2", []string{"$['a']['b'][1]"}},
+		{"auto jsonpath", `{"value":"{\"a\":{\"b\":[1,2]}}","path":"$.a.b[*]"}`, http.StatusOK, "[1,2]", []string{"$['a']['b'][0]", "$['a']['b'][1]"}},
+		{"forced dot keeps $ keys", `{"value":"{\"$\":{\"x\":true}}","path":"$.x","syntax":"dot"}`, http.StatusOK, "true", []string{"$['$']['x']"}},
+		{"forced jsonpath", `{"value":"[1]","path":"0","syntax":"jsonpath"}`, http.StatusBadRequest, "", nil},
+		{"unknown syntax", `{"value":"[1]","path":"0","syntax":"xpath"}`, http.StatusBadRequest, "", nil},
+		{"empty jsonpath result", `{"value":"[1]","path":"$[5]"}`, http.StatusOK, "[]", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := runJSONHandler(t, PathQueryJSON, "POST", tc.body)
+			if status != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", status, tc.wantStatus, body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			var resp PathResponse
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.wantResult {
+				t.Errorf("result = %s, want %s", resp.Result, tc.wantResult)
+			}
+			var paths []string
+			for _, m := range resp.Matches {
+				paths = append(paths, m.Path)
+			}
+			if strings.Join(paths, " ") != strings.Join(tc.wantPaths, " ") {
+				t.Errorf("paths = %q, want %q", paths, tc.wantPaths)
+			}
+		})
+	}
+}
+
+func TestJSONPathLimits(t *testing.T) {
+	defer SetLimits(limits)
+	l := DefaultLimits()
+	l.JSON.PathMatches = 3
+	SetLimits(l)
+	_, err := pathQueryJSON(context.Background(), PathRequest{Value: "[1,2,3,4]", Path: "$[*]"})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeTooMany {
+		t.Errorf("err = %v, want %s", err, CodeTooMany)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	SetLimits(DefaultLimits())
+	big := "[" + strings.Repeat("[1,2,3],", 2000) + "0]"
+	_, err = pathQueryJSON(ctx, PathRequest{Value: big, Path: "$..*"})
+	if !errors.As(err, &ae) || ae.Code != CodeTimeout {
+		t.Errorf("err = %v, want %s", err, CodeTimeout)
+	}
+}
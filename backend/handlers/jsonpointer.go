@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// This file implements RFC 6901 JSON Pointers (/items/0/name): parsing, escaping and
+// resolution against decoded JSON values.
+
+// PointerRequest is the JSON body for the JSON Pointer endpoint.
+type PointerRequest struct {
+	Value   string `json:"value"`
+	Pointer string `json:"pointer"`
+}
+
+// PointerJSON returns the value a JSON Pointer refers to.
+func PointerJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(pointerJSON))
+}
+
+func pointerJSON(req PointerRequest) (StringResponse, error) {
+	v, err := decodeJSONValue("value", req.Value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	tokens, err := parsePointer(req.Pointer)
+	if err != nil {
+		return StringResponse{}, querySyntaxError("pointer", req.Pointer, err)
+	}
+	got, n, ok := pointerGet(v, tokens)
+	if !ok {
+		return StringResponse{}, pointerNotFound("pointer", tokens, n)
+	}
+	out, err := json.Marshal(got)
+	if err != nil {
+		return StringResponse{}, fmt.Errorf("marshal: %w", err)
+	}
+	return StringResponse{Result: string(out)}, nil
+}
+
+// pointerNotFound reports that tokens[n] could not be resolved; details.resolved is the
+// longest prefix of the pointer that exists.
+func pointerNotFound(field string, tokens []string, n int) *APIError {
+	e := newError(CodePathNotFound, field, fmt.Sprintf("no value at %s", formatPointer(tokens[:n+1])))
+	e.Details = map[string]interface{}{"resolved": formatPointer(tokens[:n])}
+	return e
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens; "" is the whole
+// document. The URI fragment form (#/items/0) is accepted too, with percent-encoding
+// decoded first.
+func parsePointer(s string) ([]string, error) {
+	base := 0
+	if strings.HasPrefix(s, "#") {
+		dec, err := url.PathUnescape(s[1:])
+		if err != nil {
+			return nil, &syntaxError{offset: 1, msg: "invalid percent-encoding in URI fragment"}
+		}
+		s, base = dec, 1
+	}
+	tokens := []string{}
+	if s == "" {
+		return tokens, nil
+	}
+	if s[0] != '/' {
+		return nil, &syntaxError{offset: base, msg: `pointer must be empty or start with "/"`}
+	}
+	start := 1
+	for i := 1; i <= len(s); i++ {
+		if i < len(s) && s[i] != '/' {
+			continue
+		}
+		tok, err := unescapePointerToken(s[start:i], base+start)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		start = i + 1
+	}
+	return tokens, nil
+}
+
+// unescapePointerToken decodes ~1 to / and ~0 to ~; offset locates tok in the pointer
+// for error reporting.
+func unescapePointerToken(tok string, offset int) (string, error) {
+	if !strings.Contains(tok, "~") {
+		return tok, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(tok); i++ {
+		if tok[i] != '~' {
+			b.WriteByte(tok[i])
+			continue
+		}
+		if i+1 == len(tok) || (tok[i+1] != '0' && tok[i+1] != '1') {
+			return "", &syntaxError{offset: offset + i, msg: `"~" must be followed by 0 or 1`}
+		}
+		if tok[i+1] == '0' {
+			b.WriteByte('~')
+		} else {
+			b.WriteByte('/')
+		}
+		i++
+	}
+	return b.String(), nil
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointerToken escapes one reference token: ~ becomes ~0 and / becomes ~1.
+func escapePointerToken(tok string) string {
+	return pointerEscaper.Replace(tok)
+}
+
+// formatPointer joins reference tokens into a JSON Pointer.
+func formatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, tok := range tokens {
+		b.WriteByte('/')
+		b.WriteString(escapePointerToken(tok))
+	}
+	return b.String()
+}
+
+// pointerJoin appends one unescaped token to a JSON Pointer.
+func pointerJoin(pointer, tok string) string {
+	return pointer + "/" + escapePointerToken(tok)
+}
+
+// pointerGet resolves tokens against v, which may hold ordered objects. When a token cannot be resolved it returns false
+// and the index of that token.
+func pointerGet(v interface{}, tokens []string) (interface{}, int, bool) {
+	for i, tok := range tokens {
+		switch c := v.(type) {
+		case map[string]interface{}:
+			val, ok := c[tok]
+			if !ok {
+				return nil, i, false
+			}
+			v = val
+		case *jqObject:
+			val, ok := c.get(tok)
+			if !ok {
+				return nil, i, false
+			}
+			v = val
+		case []interface{}:
+			idx, ok := pointerIndex(tok)
+			if !ok || idx >= len(c) {
+				return nil, i, false
+			}
+			v = c[idx]
+		default:
+			return nil, i, false
+		}
+	}
+	return v, len(tokens), true
+}
+
+// pointerIndex parses an array index token: decimal digits without leading zeros. The
+// token "-" (the position after the last element) is not an index.
+func pointerIndex(tok string) (int, bool) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, false
+	}
+	for i := 0; i < len(tok); i++ {
+		if tok[i] < '0' || tok[i] > '9' {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(tok)
+	return i, err == nil
+}
+
+// pointerQuery resolves a JSON Pointer for the path tool, as a one-node nodelist.
+func pointerQuery(v interface{}, pointer string) ([]jpNode, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, querySyntaxError("path", pointer, err)
+	}
+	n := jpNode{path: "$", value: v}
+	for i, tok := range tokens {
+		got, _, ok := pointerGet(n.value, tokens[i:i+1])
+		if !ok {
+			return nil, pointerNotFound("path", tokens, i)
+		}
+		if _, isArray := n.value.([]interface{}); isArray {
+			idx, _ := pointerIndex(tok)
+			n = n.element(idx, got)
+		} else {
+			n = n.member(tok, got)
+		}
+	}
+	return []jpNode{n}, nil
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// The example document of RFC 6901 section 5.
+const rfcPointerDoc = `{"foo": ["bar", "baz"], "": 0, "a/b": 1, "c%d": 2, "e^f": 3, "g|h": 4,
+  "i\\j": 5, "k\"l": 6, " ": 7, "m~n": 8}`
+
+func TestPointerJSON(t *testing.T) {
+	cases := []struct {
+		pointer string
+		want    string
+	}{
+		{"", `{"":0," ":7,"a/b":1,"c%d":2,"e^f":3,"foo":["bar","baz"],"g|h":4,"i\\j":5,"k\"l":6,"m~n":8}`},
+		{"/foo", `["bar","baz"]`},
+		{"/foo/0", `"bar"`},
+		{"/", "0"},
+		{"/a~1b", "1"},
+		{"/c%d", "2"},
+		{"/e^f", "3"},
+		{"/g|h", "4"},
+		{`/i\j`, "5"},
+		{`/k"l`, "6"},
+		{"/ ", "7"},
+		{"/m~0n", "8"},
+		{"#", `{"":0," ":7,"a/b":1,"c%d":2,"e^f":3,"foo":["bar","baz"],"g|h":4,"i\\j":5,"k\"l":6,"m~n":8}`},
+		{"#/foo/0", `"bar"`},
+		{"#/a~1b", "1"},
+		{"#/c%25d", "2"},
+		{"#/%20", "7"},
+		{"#/m~0n", "8"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.pointer, func(t *testing.T) {
+			resp, err := pointerJSON(PointerRequest{Value: rfcPointerDoc, Pointer: tc.pointer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result = %s, want %s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestPointerJSONErrors(t *testing.T) {
+	cases := []struct {
+		pointer  string
+		wantCode string
+		details  map[string]interface{}
+	}{
+		{"foo", CodeInvalidQuery, map[string]interface{}{"offset": 0, "line": 1, "column": 1}},
+		{"/m~2n", CodeInvalidQuery, map[string]interface{}{"offset": 2, "line": 1, "column": 3}},
+		{"/foo/a~", CodeInvalidQuery, map[string]interface{}{"offset": 6, "line": 1, "column": 7}},
+		{"#/c%zz", CodeInvalidQuery, map[string]interface{}{"offset": 1, "line": 1, "column": 2}},
+		{"/foo/2", CodePathNotFound, map[string]interface{}{"resolved": "/foo"}},
+		{"/foo/01", CodePathNotFound, map[string]interface{}{"resolved": "/foo"}},
+		{"/foo/-", CodePathNotFound, map[string]interface{}{"resolved": "/foo"}},
+		{"/foo/0/x", CodePathNotFound, map[string]interface{}{"resolved": "/foo/0"}},
+		{"/x~1y/z", CodePathNotFound, map[string]interface{}{"resolved": ""}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.pointer, func(t *testing.T) {
+			_, err := pointerJSON(PointerRequest{Value: rfcPointerDoc, Pointer: tc.pointer})
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.wantCode || ae.Field != "pointer" {
+				t.Errorf("code = %s on %q, want %s on pointer", ae.Code, ae.Field, tc.wantCode)
+			}
+			if !reflect.DeepEqual(ae.Details, tc.details) {
+				t.Errorf("details = %v, want %v", ae.Details, tc.details)
+			}
+		})
+	}
+}
+
+func TestFormatPointer(t *testing.T) {
+	tokens := []string{"a/b", "m~n", "~1", ""}
+	p := formatPointer(tokens)
+	if p != "/a~1b/m~0n/~01/" {
+		t.Errorf("formatPointer = %q", p)
+	}
+	back, err := parsePointer(p)
+	if err != nil || !reflect.DeepEqual(back, tokens) {
+		t.Errorf("parsePointer(%q) = %q, %v; want %q", p, back, err, tokens)
+	}
+}
+
+func TestPathQueryPointers(t *testing.T) {
+	doc := `{"a/b": {"m~n": [10, 20]}, "x.y": 1}`
+	cases := []struct {
+		req        PathRequest
+		wantResult string
+		wantPaths  []string
+	}{
+		{PathRequest{Path: "/a~1b/m~0n/1"}, "20", []string{"$['a/b']['m~n'][1]"}},
+		{PathRequest{Path: "/a~1b/m~0n/1", PathFormat: "pointer"}, "20", []string{"/a~1b/m~0n/1"}},
+		{PathRequest{Path: "$..*", PathFormat: "pointer"}, `[{"m~n":[10,20]},1,[10,20],10,20]`, []string{"/a~1b", "/x.y", "/a~1b/m~0n", "/a~1b/m~0n/0", "/a~1b/m~0n/1"}},
+		{PathRequest{Path: "$", PathFormat: "pointer"}, `[{"a/b":{"m~n":[10,20]},"x.y":1}]`, []string{""}},
+		{PathRequest{Path: "x", Syntax: "dot", PathFormat: "pointer"}, "", nil},
+	}
+	for _, tc := range cases {
+		tc.req.Value = doc
+		t.Run(tc.req.Path, func(t *testing.T) {
+			resp, err := pathQueryJSON(context.Background(), tc.req)
+			if tc.wantPaths == nil {
+				var ae *APIError
+				if !errors.As(err, &ae) || ae.Code != CodePathNotFound {
+					t.Fatalf("err = %v, want %s", err, CodePathNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.wantResult {
+				t.Errorf("result = %s, want %s", resp.Result, tc.wantResult)
+			}
+			var paths []string
+			for _, m := range resp.Matches {
+				paths = append(paths, m.Path)
+			}
+			if !reflect.DeepEqual(paths, tc.wantPaths) {
+				t.Errorf("paths = %q, want %q", paths, tc.wantPaths)
+			}
+		})
+	}
+}
+
+func TestDiffJSONPathFormat(t *testing.T) {
+	cases := []struct {
+		name       string
+		pathFormat string
+		wantStatus int
+		want       string
+	}{
+		{"dot", "", http.StatusOK, "a.b.c: 1 -> 2"},
+		{"pointer", "pointer", http.StatusOK, "/a.b/c: 1 -> 2"},
+		{"unknown", "xpath", http.StatusBadRequest, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(DiffRequest{ValueA: `{"a.b":{"c":1}}`, ValueB: `{"a.b":{"c":2}}`, PathFormat: tc.pathFormat})
+			status, out := runJSONHandler(t, DiffJSON, "POST", string(body))
+			if status != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", status, tc.wantStatus, out)
+			}
+			if status == http.StatusOK {
+				if got := parseJSONResult(t, out); got != tc.want {
+					t.Errorf("result = %q, want %q", got, tc.want)
+				}
+			}
+		})
+	}
+}
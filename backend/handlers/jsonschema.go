@@ -0,0 +1,1107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This file validates JSON values against a JSON Schema (draft 2020-12 or draft-07).
+// Schemas are interpreted directly from the decoded document; $ref is resolved within
+// that document, by JSON Pointer, $id or $anchor.
+
+// SchemaRequest is the JSON body for the schema validation endpoint. Draft chooses the
+// dialect: when empty it is taken from the schema's $schema keyword, defaulting to
+// 2020-12. In draft-07, keywords next to $ref are ignored.
+type SchemaRequest struct {
+	Value  string `json:"value"`
+	Schema string `json:"schema"`
+	Draft  string `json:"draft,omitempty" enum:"2020-12,draft-07"`
+}
+
+// SchemaViolation is one way the value fails the schema. InstancePath is the JSON Pointer
+// of the offending value and SchemaPath the JSON Pointer of the failing keyword in the
+// schema document (after following $ref). When no alternative of an anyOf or oneOf
+// matches, Causes holds the violations of each alternative.
+type SchemaViolation struct {
+	InstancePath string            `json:"instancePath"`
+	SchemaPath   string            `json:"schemaPath"`
+	Keyword      string            `json:"keyword"`
+	Message      string            `json:"message"`
+	Causes       []SchemaViolation `json:"causes,omitempty"`
+}
+
+// SchemaResponse is the JSON response for the schema validation endpoint. Violations is
+// empty when Valid is true.
+type SchemaResponse struct {
+	Valid      bool              `json:"valid"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+// ValidateSchemaJSON validates a JSON value against a JSON Schema.
+func ValidateSchemaJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(validateSchemaJSON))
+}
+
+func validateSchemaJSON(ctx context.Context, req SchemaRequest) (SchemaResponse, error) {
+	schema, err := decodeJSONValue("schema", req.Schema)
+	if err != nil {
+		return SchemaResponse{}, err
+	}
+	value, err := decodeJSONValue("value", req.Value)
+	if err != nil {
+		return SchemaResponse{}, err
+	}
+	draft := req.Draft
+	switch draft {
+	case "":
+		draft = "2020-12"
+		if m, ok := schema.(map[string]interface{}); ok {
+			if id, _ := m["$schema"].(string); strings.Contains(id, "draft-07") || strings.Contains(id, "draft-06") {
+				draft = "draft-07"
+			}
+		}
+	case "2020-12", "draft-07":
+	default:
+		return SchemaResponse{}, invalidOption("draft", fmt.Sprintf("unknown draft %q", req.Draft), enumTag(req, "Draft")...)
+	}
+	sv, err := newSchemaValidator(ctx, schema, draft)
+	if err != nil {
+		return SchemaResponse{}, err
+	}
+	res, err := sv.validate(schema, "", value, "", "")
+	if err != nil {
+		return SchemaResponse{}, err
+	}
+	resp := SchemaResponse{Valid: len(res.errs) == 0, Violations: res.errs}
+	if resp.Violations == nil {
+		resp.Violations = []SchemaViolation{}
+	}
+	return resp, nil
+}
+
+// schemaRootBase is the base URI of a schema without an $id, so that relative $refs
+// resolve against something.
+const schemaRootBase = "json-schema:///root"
+
+// schemaValidator validates instances against one schema document.
+type schemaValidator struct {
+	ctx       context.Context
+	draft     string
+	root      interface{}
+	resources map[string]string // base URI (from $id) → schema pointer
+	anchors   map[string]string // base URI#anchor → schema pointer
+	bases     map[string]string // schema pointer → base URI in effect there
+	regexps   map[string]*regexp.Regexp
+	active    map[string]bool // $ref targets being applied, by schema and instance pointer
+	steps     int
+}
+
+// schemaResult holds the violations found for one instance location and the
+// annotations unevaluatedProperties and unevaluatedItems need: which properties and
+// items were evaluated by a successful subschema.
+type schemaResult struct {
+	errs     []SchemaViolation
+	props    map[string]bool
+	items    map[int]bool
+	allItems bool
+}
+
+func (r *schemaResult) fail(iptr, sptr, keyword, msg string, causes ...SchemaViolation) {
+	r.errs = append(r.errs, SchemaViolation{InstancePath: iptr, SchemaPath: sptr, Keyword: keyword, Message: msg, Causes: causes})
+}
+
+// merge adds the annotations of a successful subschema result.
+func (r *schemaResult) merge(o *schemaResult) {
+	if len(o.errs) > 0 {
+		return
+	}
+	for k := range o.props {
+		r.evaluatedProp(k)
+	}
+	for i := range o.items {
+		r.evaluatedItem(i)
+	}
+	r.allItems = r.allItems || o.allItems
+}
+
+func (r *schemaResult) evaluatedProp(k string) {
+	if r.props == nil {
+		r.props = make(map[string]bool)
+	}
+	r.props[k] = true
+}
+
+func (r *schemaResult) evaluatedItem(i int) {
+	if r.items == nil {
+		r.items = make(map[int]bool)
+	}
+	r.items[i] = true
+}
+
+func newSchemaValidator(ctx context.Context, root interface{}, draft string) (*schemaValidator, error) {
+	sv := &schemaValidator{
+		ctx:       ctx,
+		draft:     draft,
+		root:      root,
+		resources: make(map[string]string),
+		anchors:   make(map[string]string),
+		bases:     make(map[string]string),
+		regexps:   make(map[string]*regexp.Regexp),
+		active:    make(map[string]bool),
+	}
+	base, _ := url.Parse(schemaRootBase)
+	sv.resources[schemaRootBase] = ""
+	if err := sv.index(root, "", base); err != nil {
+		return nil, err
+	}
+	return sv, nil
+}
+
+// invalidSchema reports a schema the validator cannot apply.
+func invalidSchema(sptr, msg string) *APIError {
+	loc := sptr
+	if loc == "" {
+		loc = "the root"
+	}
+	e := newError(CodeInvalidValue, "schema", fmt.Sprintf("invalid schema at %s: %s", loc, msg))
+	e.Details = map[string]interface{}{"schemaPath": sptr}
+	return e
+}
+
+// Keywords whose values are subschemas, by shape, for indexing $id and $anchor.
+var (
+	schemaSingleKeywords = []string{"additionalItems", "additionalProperties", "unevaluatedItems",
+		"unevaluatedProperties", "contains", "propertyNames", "not", "if", "then", "else", "items"}
+	schemaListKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems", "items"}
+	schemaMapKeywords  = []string{"$defs", "definitions", "properties", "patternProperties", "dependentSchemas", "dependencies"}
+)
+
+// index records the base URI of every subschema and the resources and anchors that
+// $ref can name.
+func (sv *schemaValidator) index(s interface{}, sptr string, base *url.URL) error {
+	m, ok := s.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if id, ok := m["$id"].(string); ok {
+		u, err := base.Parse(id)
+		if err != nil {
+			return invalidSchema(sptr+"/$id", err.Error())
+		}
+		if strings.HasPrefix(id, "#") {
+			// A draft-07 location-independent identifier: {"$id": "#name"}.
+			sv.anchors[schemaURI(base)+id] = sptr
+		} else {
+			u.Fragment = ""
+			base = u
+			sv.resources[schemaURI(base)] = sptr
+		}
+	}
+	for _, kw := range []string{"$anchor", "$dynamicAnchor"} {
+		if a, ok := m[kw].(string); ok {
+			sv.anchors[schemaURI(base)+"#"+a] = sptr
+		}
+	}
+	sv.bases[sptr] = schemaURI(base)
+	for _, kw := range schemaSingleKeywords {
+		if sub, ok := m[kw]; ok {
+			if err := sv.index(sub, pointerJoin(sptr, kw), base); err != nil {
+				return err
+			}
+		}
+	}
+	for _, kw := range schemaListKeywords {
+		if list, ok := m[kw].([]interface{}); ok {
+			for i, sub := range list {
+				if err := sv.index(sub, pointerJoin(sptr, kw)+"/"+strconv.Itoa(i), base); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, kw := range schemaMapKeywords {
+		if subs, ok := m[kw].(map[string]interface{}); ok {
+			for name, sub := range subs {
+				if err := sv.index(sub, pointerJoin(pointerJoin(sptr, kw), name), base); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func schemaURI(u *url.URL) string {
+	c := *u
+	c.Fragment = ""
+	return c.String()
+}
+
+// resolveRef returns the schema a $ref names and its pointer in the document.
+func (sv *schemaValidator) resolveRef(ref, sptr string) (interface{}, string, error) {
+	base, err := url.Parse(sv.baseAt(sptr))
+	if err != nil {
+		return nil, "", invalidSchema(sptr, err.Error())
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return nil, "", invalidSchema(sptr, fmt.Sprintf("invalid $ref %q: %v", ref, err))
+	}
+	doc := schemaURI(u)
+	var target string
+	switch {
+	case u.Fragment == "" || strings.HasPrefix(u.Fragment, "/"):
+		resource, ok := sv.resources[doc]
+		if !ok {
+			return nil, "", invalidSchema(sptr, fmt.Sprintf("cannot resolve $ref %q: only references within the schema are supported", ref))
+		}
+		tokens, err := parsePointer(u.Fragment)
+		if err != nil {
+			return nil, "", invalidSchema(sptr, fmt.Sprintf("invalid $ref %q: %v", ref, err))
+		}
+		target = resource + formatPointer(tokens)
+	default:
+		var ok bool
+		if target, ok = sv.anchors[doc+"#"+u.Fragment]; !ok {
+			return nil, "", invalidSchema(sptr, fmt.Sprintf("cannot resolve $ref %q: no such anchor", ref))
+		}
+	}
+	tokens, _ := parsePointer(target)
+	s, _, ok := pointerGet(sv.root, tokens)
+	if !ok {
+		return nil, "", invalidSchema(sptr, fmt.Sprintf("cannot resolve $ref %q: no schema at %s", ref, target))
+	}
+	return s, target, nil
+}
+
+// baseAt returns the base URI in effect at a schema pointer: the nearest indexed
+// ancestor's.
+func (sv *schemaValidator) baseAt(sptr string) string {
+	for {
+		if b, ok := sv.bases[sptr]; ok {
+			return b
+		}
+		i := strings.LastIndexByte(sptr, '/')
+		if i < 0 {
+			return schemaRootBase
+		}
+		sptr = sptr[:i]
+	}
+}
+
+func (sv *schemaValidator) regexp(pattern, sptr string) (*regexp.Regexp, error) {
+	if re, ok := sv.regexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, invalidSchema(sptr, fmt.Sprintf("unsupported regular expression %q: %v", pattern, err))
+	}
+	sv.regexps[pattern] = re
+	return re, nil
+}
+
+// validate applies the schema s (at sptr) to the instance inst (at iptr). keyword names
+// the keyword that applied s, for the message when s is false.
+func (sv *schemaValidator) validate(s interface{}, sptr string, inst interface{}, iptr, keyword string) (*schemaResult, error) {
+	if sv.steps++; sv.steps%1024 == 0 {
+		if err := checkContext(sv.ctx); err != nil {
+			return nil, err
+		}
+	}
+	res := &schemaResult{}
+	if b, ok := s.(bool); ok {
+		if !b {
+			res.fail(iptr, sptr, schemaFalseKeyword(keyword), schemaFalseMessage(keyword, iptr))
+		}
+		return res, nil
+	}
+	m, ok := s.(map[string]interface{})
+	if !ok {
+		return nil, invalidSchema(sptr, "a schema must be an object or a boolean")
+	}
+
+	for _, kw := range []string{"$ref", "$dynamicRef"} {
+		ref, ok := m[kw]
+		if !ok {
+			continue
+		}
+		refStr, ok := ref.(string)
+		if !ok {
+			return nil, invalidSchema(pointerJoin(sptr, kw), "must be a string")
+		}
+		target, tptr, err := sv.resolveRef(refStr, pointerJoin(sptr, kw))
+		if err != nil {
+			return nil, err
+		}
+		key := tptr + "\x00" + iptr
+		if sv.active[key] {
+			return nil, invalidSchema(pointerJoin(sptr, kw), fmt.Sprintf("$ref %q loops without consuming the instance", refStr))
+		}
+		sv.active[key] = true
+		sub, err := sv.validate(target, tptr, inst, iptr, kw)
+		delete(sv.active, key)
+		if err != nil {
+			return nil, err
+		}
+		res.errs = append(res.errs, sub.errs...)
+		res.merge(sub)
+		if sv.draft == "draft-07" && kw == "$ref" {
+			return res, nil
+		}
+	}
+
+	steps := []func(map[string]interface{}, string, interface{}, string, *schemaResult) error{
+		sv.checkType, sv.checkEnum, sv.checkNumber, sv.checkString, sv.checkFormat,
+		sv.checkArray, sv.checkObject, sv.checkCombinators, sv.checkConditional,
+		sv.checkUnevaluated,
+	}
+	for _, step := range steps {
+		if err := step(m, sptr, inst, iptr, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func schemaFalseKeyword(keyword string) string {
+	if keyword == "" || keyword == "$ref" || keyword == "$dynamicRef" {
+		return "false"
+	}
+	return keyword
+}
+
+func schemaFalseMessage(keyword, iptr string) string {
+	name := iptr[strings.LastIndexByte(iptr, '/')+1:]
+	name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+	switch keyword {
+	case "additionalProperties", "unevaluatedProperties", "properties", "patternProperties":
+		return fmt.Sprintf("property %q is not allowed", name)
+	case "items", "prefixItems", "additionalItems", "unevaluatedItems":
+		return fmt.Sprintf("item %s is not allowed", name)
+	}
+	return "no value is allowed here"
+}
+
+// schemaType returns the JSON Schema type of a decoded value; numbers with an integer
+// value are "integer".
+func schemaType(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if r := schemaRat(x); r != nil && r.IsInt() {
+			return "integer"
+		}
+		if f, err := x.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// schemaRat returns a number as an exact rational, or nil when its exponent is too large
+// to expand (comparisons then fall back to float64).
+func schemaRat(n json.Number) *big.Rat {
+	s := string(n)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(s[i+1:]); err != nil || exp > 400 || exp < -400 {
+			return nil
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// schemaCompare compares two numbers.
+func schemaCompare(a, b json.Number) int {
+	if ra, rb := schemaRat(a), schemaRat(b); ra != nil && rb != nil {
+		return ra.Cmp(rb)
+	}
+	fa, _ := a.Float64()
+	fb, _ := b.Float64()
+	switch {
+	case fa < fb:
+		return -1
+	case fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// schemaKey returns a canonical form of v in which equal JSON values (including 1 and
+// 1.0) are equal strings. It accepts ordered objects (*jqObject) too.
+func schemaKey(v interface{}) string {
+	switch x := v.(type) {
+	case json.Number:
+		if r := schemaRat(x); r != nil {
+			return "n" + r.RatString()
+		}
+		f, _ := x.Float64()
+		return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			parts[i] = schemaKey(e)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	case map[string]interface{}:
+		keys := sortedKeys(x)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = strconv.Quote(k) + ":" + schemaKey(x[k])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case *jqObject:
+		keys := x.sortedKeys()
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = strconv.Quote(k) + ":" + schemaKey(x.vals[k])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// schemaDump returns v as compact JSON for messages.
+func schemaDump(v interface{}) string {
+	out, _ := json.Marshal(v)
+	if len(out) > 80 {
+		return string(out[:77]) + "..."
+	}
+	return string(out)
+}
+
+// schemaCount reads a non-negative integer keyword value.
+func schemaCount(m map[string]interface{}, kw, sptr string) (int, bool, error) {
+	v, ok := m[kw]
+	if !ok {
+		return 0, false, nil
+	}
+	n, isNum := v.(json.Number)
+	f, err := n.Float64()
+	if !isNum || err != nil || f < 0 || f != math.Trunc(f) {
+		return 0, false, invalidSchema(pointerJoin(sptr, kw), "must be a non-negative integer")
+	}
+	if f > math.MaxInt32 {
+		f = math.MaxInt32
+	}
+	return int(f), true, nil
+}
+
+func (sv *schemaValidator) checkType(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	t, ok := m["type"]
+	if !ok {
+		return nil
+	}
+	var types []string
+	switch x := t.(type) {
+	case string:
+		types = []string{x}
+	case []interface{}:
+		for _, e := range x {
+			s, ok := e.(string)
+			if !ok {
+				return invalidSchema(pointerJoin(sptr, "type"), "must be a string or an array of strings")
+			}
+			types = append(types, s)
+		}
+	default:
+		return invalidSchema(pointerJoin(sptr, "type"), "must be a string or an array of strings")
+	}
+	got := schemaType(inst)
+	for _, want := range types {
+		if want == got || (want == "number" && got == "integer") {
+			return nil
+		}
+	}
+	if got == "integer" {
+		got = "number"
+	}
+	res.fail(iptr, pointerJoin(sptr, "type"), "type", fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), got))
+	return nil
+}
+
+func (sv *schemaValidator) checkEnum(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	if c, ok := m["const"]; ok && schemaKey(c) != schemaKey(inst) {
+		res.fail(iptr, pointerJoin(sptr, "const"), "const", "must be equal to "+schemaDump(c))
+	}
+	e, ok := m["enum"]
+	if !ok {
+		return nil
+	}
+	values, ok := e.([]interface{})
+	if !ok {
+		return invalidSchema(pointerJoin(sptr, "enum"), "must be an array")
+	}
+	key := schemaKey(inst)
+	for _, v := range values {
+		if schemaKey(v) == key {
+			return nil
+		}
+	}
+	res.fail(iptr, pointerJoin(sptr, "enum"), "enum", "must be one of "+schemaDump(values))
+	return nil
+}
+
+func (sv *schemaValidator) checkNumber(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	n, ok := inst.(json.Number)
+	if !ok {
+		return nil
+	}
+	bounds := []struct {
+		kw   string
+		fail func(c int) bool
+		op   string
+	}{
+		{"maximum", func(c int) bool { return c > 0 }, "<="},
+		{"exclusiveMaximum", func(c int) bool { return c >= 0 }, "<"},
+		{"minimum", func(c int) bool { return c < 0 }, ">="},
+		{"exclusiveMinimum", func(c int) bool { return c <= 0 }, ">"},
+	}
+	for _, b := range bounds {
+		v, ok := m[b.kw]
+		if !ok {
+			continue
+		}
+		if _, isBool := v.(bool); isBool {
+			continue // draft-04 boolean form, not part of either dialect
+		}
+		limit, ok := v.(json.Number)
+		if !ok {
+			return invalidSchema(pointerJoin(sptr, b.kw), "must be a number")
+		}
+		if b.fail(schemaCompare(n, limit)) {
+			res.fail(iptr, pointerJoin(sptr, b.kw), b.kw, fmt.Sprintf("must be %s %s", b.op, limit))
+		}
+	}
+	if v, ok := m["multipleOf"]; ok {
+		div, ok := v.(json.Number)
+		if !ok || schemaCompare(div, "0") <= 0 {
+			return invalidSchema(pointerJoin(sptr, "multipleOf"), "must be a number greater than 0")
+		}
+		if !schemaMultiple(n, div) {
+			res.fail(iptr, pointerJoin(sptr, "multipleOf"), "multipleOf", fmt.Sprintf("must be a multiple of %s", div))
+		}
+	}
+	return nil
+}
+
+// schemaMultiple reports whether n is an integer multiple of div, exactly when both
+// numbers can be expanded.
+func schemaMultiple(n, div json.Number) bool {
+	if rn, rd := schemaRat(n), schemaRat(div); rn != nil && rd != nil {
+		return new(big.Rat).Quo(rn, rd).IsInt()
+	}
+	fn, _ := n.Float64()
+	fd, _ := div.Float64()
+	q := fn / fd
+	return !math.IsInf(q, 0) && q == math.Trunc(q)
+}
+
+func (sv *schemaValidator) checkString(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	s, ok := inst.(string)
+	if !ok {
+		return nil
+	}
+	length := utf8.RuneCountInString(s)
+	if max, ok, err := schemaCount(m, "maxLength", sptr); err != nil {
+		return err
+	} else if ok && length > max {
+		res.fail(iptr, pointerJoin(sptr, "maxLength"), "maxLength", fmt.Sprintf("must be at most %d characters long", max))
+	}
+	if min, ok, err := schemaCount(m, "minLength", sptr); err != nil {
+		return err
+	} else if ok && length < min {
+		res.fail(iptr, pointerJoin(sptr, "minLength"), "minLength", fmt.Sprintf("must be at least %d characters long", min))
+	}
+	if p, ok := m["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return invalidSchema(pointerJoin(sptr, "pattern"), "must be a string")
+		}
+		re, err := sv.regexp(pattern, pointerJoin(sptr, "pattern"))
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(s) {
+			res.fail(iptr, pointerJoin(sptr, "pattern"), "pattern", fmt.Sprintf("must match pattern %q", pattern))
+		}
+	}
+	return nil
+}
+
+// schemaFormats checks the format keyword for strings. Unknown formats are accepted.
+var schemaFormats = map[string]func(string) bool{
+	"date-time": func(s string) bool {
+		_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+		return err == nil && len(s) >= len("2006-01-02T15:04:05Z")
+	},
+	"date": func(s string) bool {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	},
+	"time": func(s string) bool {
+		_, err := time.Parse("15:04:05.999999999Z07:00", strings.ToUpper(s))
+		return err == nil
+	},
+	"email": func(s string) bool {
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Name == "" && addr.Address == s
+	},
+	"hostname": schemaHostname,
+	"ipv4": func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	},
+	"ipv6": func(s string) bool {
+		return strings.Contains(s, ":") && net.ParseIP(s) != nil
+	},
+	"uri": func(s string) bool {
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != "" && !strings.ContainsAny(s, " \\")
+	},
+	"uri-reference": func(s string) bool {
+		_, err := url.Parse(s)
+		return err == nil && !strings.ContainsAny(s, " \\")
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"regex": func(s string) bool {
+		_, err := regexp.Compile(s)
+		return err == nil
+	},
+	"json-pointer": func(s string) bool {
+		if strings.HasPrefix(s, "#") {
+			return false
+		}
+		_, err := parsePointer(s)
+		return err == nil
+	},
+}
+
+// schemaHostname checks an RFC 1123 host name: dot-separated labels of letters, digits
+// and hyphens, each at most 63 characters and not starting or ending with a hyphen.
+func schemaHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (sv *schemaValidator) checkFormat(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	format, ok := m["format"].(string)
+	s, isString := inst.(string)
+	if !ok || !isString {
+		return nil
+	}
+	if check := schemaFormats[format]; check != nil && !check(s) {
+		res.fail(iptr, pointerJoin(sptr, "format"), "format", fmt.Sprintf("must be a valid %s", format))
+	}
+	return nil
+}
+
+func (sv *schemaValidator) checkArray(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	arr, ok := inst.([]interface{})
+	if !ok {
+		return nil
+	}
+	if max, ok, err := schemaCount(m, "maxItems", sptr); err != nil {
+		return err
+	} else if ok && len(arr) > max {
+		res.fail(iptr, pointerJoin(sptr, "maxItems"), "maxItems", fmt.Sprintf("must have at most %d items", max))
+	}
+	if min, ok, err := schemaCount(m, "minItems", sptr); err != nil {
+		return err
+	} else if ok && len(arr) < min {
+		res.fail(iptr, pointerJoin(sptr, "minItems"), "minItems", fmt.Sprintf("must have at least %d items", min))
+	}
+	if unique, _ := m["uniqueItems"].(bool); unique {
+		seen := make(map[string]int, len(arr))
+		for i, v := range arr {
+			key := schemaKey(v)
+			if j, dup := seen[key]; dup {
+				res.fail(iptr, pointerJoin(sptr, "uniqueItems"), "uniqueItems", fmt.Sprintf("items %d and %d are equal", j, i))
+				break
+			}
+			seen[key] = i
+		}
+	}
+
+	// Tuple validation: prefixItems (2020-12) or an items array (draft-07), followed by
+	// items (2020-12) or additionalItems (draft-07) for the rest.
+	prefixKw, restKw := "prefixItems", "items"
+	prefix, _ := m["prefixItems"].([]interface{})
+	if list, ok := m["items"].([]interface{}); ok {
+		prefixKw, restKw, prefix = "items", "additionalItems", list
+	}
+	for i, sub := range prefix {
+		if i >= len(arr) {
+			break
+		}
+		r, err := sv.validate(sub, pointerJoin(sptr, prefixKw)+"/"+strconv.Itoa(i), arr[i], iptr+"/"+strconv.Itoa(i), prefixKw)
+		if err != nil {
+			return err
+		}
+		res.errs = append(res.errs, r.errs...)
+		res.evaluatedItem(i)
+	}
+	if rest, ok := m[restKw]; ok {
+		for i := len(prefix); i < len(arr); i++ {
+			r, err := sv.validate(rest, pointerJoin(sptr, restKw), arr[i], iptr+"/"+strconv.Itoa(i), restKw)
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+		}
+		res.allItems = true
+	}
+
+	if contains, ok := m["contains"]; ok {
+		matched := 0
+		for i, v := range arr {
+			r, err := sv.validate(contains, pointerJoin(sptr, "contains"), v, iptr+"/"+strconv.Itoa(i), "contains")
+			if err != nil {
+				return err
+			}
+			if len(r.errs) == 0 {
+				matched++
+				res.evaluatedItem(i)
+			}
+		}
+		min, hasMin, err := schemaCount(m, "minContains", sptr)
+		if err != nil {
+			return err
+		}
+		if !hasMin {
+			min = 1
+		}
+		if matched < min {
+			res.fail(iptr, pointerJoin(sptr, "contains"), "contains", fmt.Sprintf("must contain at least %d matching items, found %d", min, matched))
+		}
+		if max, ok, err := schemaCount(m, "maxContains", sptr); err != nil {
+			return err
+		} else if ok && matched > max {
+			res.fail(iptr, pointerJoin(sptr, "maxContains"), "maxContains", fmt.Sprintf("must contain at most %d matching items, found %d", max, matched))
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) checkObject(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	obj, ok := inst.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	keys := sortedKeys(obj)
+	if max, ok, err := schemaCount(m, "maxProperties", sptr); err != nil {
+		return err
+	} else if ok && len(obj) > max {
+		res.fail(iptr, pointerJoin(sptr, "maxProperties"), "maxProperties", fmt.Sprintf("must have at most %d properties", max))
+	}
+	if min, ok, err := schemaCount(m, "minProperties", sptr); err != nil {
+		return err
+	} else if ok && len(obj) < min {
+		res.fail(iptr, pointerJoin(sptr, "minProperties"), "minProperties", fmt.Sprintf("must have at least %d properties", min))
+	}
+	if req, ok := m["required"]; ok {
+		names, err := schemaStrings(req, pointerJoin(sptr, "required"))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if _, ok := obj[name]; !ok {
+				res.fail(iptr, pointerJoin(sptr, "required"), "required", fmt.Sprintf("missing required property %q", name))
+			}
+		}
+	}
+
+	// dependentRequired and dependentSchemas (2020-12), or dependencies (draft-07) which
+	// holds either form.
+	for _, kw := range []string{"dependentRequired", "dependentSchemas", "dependencies"} {
+		deps, ok := m[kw].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range sortedKeys(deps) {
+			if _, present := obj[name]; !present {
+				continue
+			}
+			dptr := pointerJoin(pointerJoin(sptr, kw), name)
+			if list, isList := deps[name].([]interface{}); isList && kw != "dependentSchemas" {
+				names, err := schemaStrings(list, dptr)
+				if err != nil {
+					return err
+				}
+				for _, dep := range names {
+					if _, ok := obj[dep]; !ok {
+						res.fail(iptr, dptr, kw, fmt.Sprintf("property %q is required when %q is present", dep, name))
+					}
+				}
+				continue
+			}
+			r, err := sv.validate(deps[name], dptr, inst, iptr, kw)
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+			res.merge(r)
+		}
+	}
+
+	props, _ := m["properties"].(map[string]interface{})
+	patterns, _ := m["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := m["additionalProperties"]
+	for _, k := range keys {
+		kptr := pointerJoin(iptr, k)
+		matched := false
+		if sub, ok := props[k]; ok {
+			matched = true
+			r, err := sv.validate(sub, pointerJoin(pointerJoin(sptr, "properties"), k), obj[k], kptr, "properties")
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+		}
+		for _, pattern := range sortedKeys(patterns) {
+			pptr := pointerJoin(pointerJoin(sptr, "patternProperties"), pattern)
+			re, err := sv.regexp(pattern, pptr)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(k) {
+				continue
+			}
+			matched = true
+			r, err := sv.validate(patterns[pattern], pptr, obj[k], kptr, "patternProperties")
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+		}
+		if !matched && hasAdditional {
+			matched = true
+			r, err := sv.validate(additional, pointerJoin(sptr, "additionalProperties"), obj[k], kptr, "additionalProperties")
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+		}
+		if matched {
+			res.evaluatedProp(k)
+		}
+	}
+
+	if names, ok := m["propertyNames"]; ok {
+		for _, k := range keys {
+			r, err := sv.validate(names, pointerJoin(sptr, "propertyNames"), k, pointerJoin(iptr, k), "propertyNames")
+			if err != nil {
+				return err
+			}
+			if len(r.errs) > 0 {
+				res.fail(pointerJoin(iptr, k), pointerJoin(sptr, "propertyNames"), "propertyNames", fmt.Sprintf("invalid property name %q", k), r.errs...)
+			}
+		}
+	}
+	return nil
+}
+
+func schemaStrings(v interface{}, sptr string) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, invalidSchema(sptr, "must be an array of strings")
+	}
+	out := make([]string, len(list))
+	for i, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, invalidSchema(sptr, "must be an array of strings")
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// schemaList reads the subschemas of allOf, anyOf or oneOf.
+func schemaList(m map[string]interface{}, kw, sptr string) ([]interface{}, bool, error) {
+	v, ok := m[kw]
+	if !ok {
+		return nil, false, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, false, invalidSchema(pointerJoin(sptr, kw), "must be a non-empty array of schemas")
+	}
+	return list, true, nil
+}
+
+// checkCombinators applies allOf, anyOf, oneOf and not. allOf reports the violations of
+// each failing subschema as they are; a failed anyOf or oneOf is one violation with the
+// violations of every alternative as causes.
+func (sv *schemaValidator) checkCombinators(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	if list, ok, err := schemaList(m, "allOf", sptr); err != nil {
+		return err
+	} else if ok {
+		for i, sub := range list {
+			r, err := sv.validate(sub, pointerJoin(sptr, "allOf")+"/"+strconv.Itoa(i), inst, iptr, "allOf")
+			if err != nil {
+				return err
+			}
+			res.errs = append(res.errs, r.errs...)
+			res.merge(r)
+		}
+	}
+	for _, kw := range []string{"anyOf", "oneOf"} {
+		list, ok, err := schemaList(m, kw, sptr)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		var causes []SchemaViolation
+		var passed []string
+		for i, sub := range list {
+			r, err := sv.validate(sub, pointerJoin(sptr, kw)+"/"+strconv.Itoa(i), inst, iptr, kw)
+			if err != nil {
+				return err
+			}
+			if len(r.errs) == 0 {
+				passed = append(passed, strconv.Itoa(i))
+				if kw == "anyOf" || len(passed) == 1 {
+					res.merge(r)
+				}
+			}
+			causes = append(causes, r.errs...)
+		}
+		switch {
+		case len(passed) == 0 && kw == "anyOf":
+			res.fail(iptr, pointerJoin(sptr, kw), kw, fmt.Sprintf("must match at least one of the %d schemas in anyOf", len(list)), causes...)
+		case len(passed) == 0:
+			res.fail(iptr, pointerJoin(sptr, kw), kw, fmt.Sprintf("must match exactly one of the %d schemas in oneOf, but matches none", len(list)), causes...)
+		case kw == "oneOf" && len(passed) > 1:
+			res.fail(iptr, pointerJoin(sptr, kw), kw, fmt.Sprintf("must match exactly one schema in oneOf, but matches schemas %s", strings.Join(passed, ", ")))
+		}
+	}
+	if sub, ok := m["not"]; ok {
+		r, err := sv.validate(sub, pointerJoin(sptr, "not"), inst, iptr, "not")
+		if err != nil {
+			return err
+		}
+		if len(r.errs) == 0 {
+			res.fail(iptr, pointerJoin(sptr, "not"), "not", "must not match the schema in not")
+		}
+	}
+	return nil
+}
+
+// checkConditional applies if/then/else: then when the instance matches if, else when
+// it does not. The if schema's own violations are not reported.
+func (sv *schemaValidator) checkConditional(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	cond, ok := m["if"]
+	if !ok {
+		return nil
+	}
+	r, err := sv.validate(cond, pointerJoin(sptr, "if"), inst, iptr, "if")
+	if err != nil {
+		return err
+	}
+	branch := "else"
+	if len(r.errs) == 0 {
+		branch = "then"
+		res.merge(r)
+	}
+	sub, ok := m[branch]
+	if !ok {
+		return nil
+	}
+	r, err = sv.validate(sub, pointerJoin(sptr, branch), inst, iptr, branch)
+	if err != nil {
+		return err
+	}
+	res.errs = append(res.errs, r.errs...)
+	res.merge(r)
+	return nil
+}
+
+// checkUnevaluated applies unevaluatedItems and unevaluatedProperties to whatever no
+// other keyword of this schema (or a successful subschema of it) evaluated.
+func (sv *schemaValidator) checkUnevaluated(m map[string]interface{}, sptr string, inst interface{}, iptr string, res *schemaResult) error {
+	if sub, ok := m["unevaluatedItems"]; ok {
+		if arr, isArray := inst.([]interface{}); isArray && !res.allItems {
+			for i, v := range arr {
+				if res.items[i] {
+					continue
+				}
+				r, err := sv.validate(sub, pointerJoin(sptr, "unevaluatedItems"), v, iptr+"/"+strconv.Itoa(i), "unevaluatedItems")
+				if err != nil {
+					return err
+				}
+				res.errs = append(res.errs, r.errs...)
+			}
+			res.allItems = true
+		}
+	}
+	if sub, ok := m["unevaluatedProperties"]; ok {
+		if obj, isObject := inst.(map[string]interface{}); isObject {
+			for _, k := range sortedKeys(obj) {
+				if res.props[k] {
+					continue
+				}
+				r, err := sv.validate(sub, pointerJoin(sptr, "unevaluatedProperties"), obj[k], pointerJoin(iptr, k), "unevaluatedProperties")
+				if err != nil {
+					return err
+				}
+				res.errs = append(res.errs, r.errs...)
+				res.evaluatedProp(k)
+			}
+		}
+	}
+	return nil
+}
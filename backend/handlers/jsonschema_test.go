@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchemaJSON(t *testing.T) {
+	person := `{
+	  "type": "object",
+	  "required": ["name", "age"],
+	  "properties": {
+	    "name": {"type": "string", "minLength": 1},
+	    "age": {"type": "integer", "minimum": 0},
+	    "email": {"type": "string", "format": "email"},
+	    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
+	  },
+	  "additionalProperties": false
+	}`
+	cases := []struct {
+		name   string
+		schema string
+		value  string
+		draft  string
+		want   []SchemaViolation // nil when valid
+	}{
+		{"valid", person, `{"name": "ann", "age": 31, "tags": ["a", "b"]}`, "", nil},
+		{"every violation", person, `{"name": "", "age": -1.5, "email": "nope", "tags": ["a", "a"], "x": 1}`, "", []SchemaViolation{
+			{InstancePath: "/age", SchemaPath: "/properties/age/type", Keyword: "type", Message: "expected integer, got number"},
+			{InstancePath: "/age", SchemaPath: "/properties/age/minimum", Keyword: "minimum", Message: "must be >= 0"},
+			{InstancePath: "/email", SchemaPath: "/properties/email/format", Keyword: "format", Message: "must be a valid email"},
+			{InstancePath: "/name", SchemaPath: "/properties/name/minLength", Keyword: "minLength", Message: "must be at least 1 characters long"},
+			{InstancePath: "/tags", SchemaPath: "/properties/tags/uniqueItems", Keyword: "uniqueItems", Message: "items 0 and 1 are equal"},
+			{InstancePath: "/x", SchemaPath: "/additionalProperties", Keyword: "additionalProperties", Message: `property "x" is not allowed`},
+		}},
+		{"required", person, `{}`, "", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "/required", Keyword: "required", Message: `missing required property "name"`},
+			{InstancePath: "", SchemaPath: "/required", Keyword: "required", Message: `missing required property "age"`},
+		}},
+		{"ref to defs", `{"$defs": {"pos": {"type": "integer", "exclusiveMinimum": 0}}, "items": {"$ref": "#/$defs/pos"}}`,
+			`[1, 0]`, "", []SchemaViolation{
+				{InstancePath: "/1", SchemaPath: "/$defs/pos/exclusiveMinimum", Keyword: "exclusiveMinimum", Message: "must be > 0"},
+			}},
+		{"recursive ref", `{"type": "object", "properties": {"child": {"$ref": "#"}, "n": {"type": "number"}}}`,
+			`{"child": {"child": {"n": "x"}}}`, "", []SchemaViolation{
+				{InstancePath: "/child/child/n", SchemaPath: "/properties/n/type", Keyword: "type", Message: "expected number, got string"},
+			}},
+		{"anchor", `{"$defs": {"s": {"$anchor": "str", "type": "string"}}, "$ref": "#str"}`, `"ok"`, "", nil},
+		{"id ref", `{"$id": "https://example.com/root", "$defs": {"s": {"$id": "s.json", "type": "string"}}, "$ref": "s.json"}`,
+			`1`, "", []SchemaViolation{
+				{InstancePath: "", SchemaPath: "/$defs/s/type", Keyword: "type", Message: "expected string, got number"},
+			}},
+		{"anyOf causes", `{"anyOf": [{"type": "string"}, {"type": "integer", "maximum": 3}]}`, `5`, "", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "/anyOf", Keyword: "anyOf", Message: "must match at least one of the 2 schemas in anyOf", Causes: []SchemaViolation{
+				{InstancePath: "", SchemaPath: "/anyOf/0/type", Keyword: "type", Message: "expected string, got number"},
+				{InstancePath: "", SchemaPath: "/anyOf/1/maximum", Keyword: "maximum", Message: "must be <= 3"},
+			}},
+		}},
+		{"oneOf too many", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `2`, "", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "/oneOf", Keyword: "oneOf", Message: "must match exactly one schema in oneOf, but matches schemas 0, 1"},
+		}},
+		{"oneOf one", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `2.5`, "", nil},
+		{"allOf", `{"allOf": [{"minLength": 2}, {"pattern": "^a"}]}`, `"b"`, "", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "/allOf/0/minLength", Keyword: "minLength", Message: "must be at least 2 characters long"},
+			{InstancePath: "", SchemaPath: "/allOf/1/pattern", Keyword: "pattern", Message: `must match pattern "^a"`},
+		}},
+		{"if then else", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`,
+			`{"kind": "a"}`, "", []SchemaViolation{
+				{InstancePath: "", SchemaPath: "/then/required", Keyword: "required", Message: `missing required property "a"`},
+			}},
+		{"unevaluatedProperties", `{"allOf": [{"properties": {"a": true}}], "properties": {"b": true}, "unevaluatedProperties": false}`,
+			`{"a": 1, "b": 2, "c": 3}`, "", []SchemaViolation{
+				{InstancePath: "/c", SchemaPath: "/unevaluatedProperties", Keyword: "unevaluatedProperties", Message: `property "c" is not allowed`},
+			}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}], "items": false}`, `["a", 1]`, "", []SchemaViolation{
+			{InstancePath: "/1", SchemaPath: "/items", Keyword: "items", Message: "item 1 is not allowed"},
+		}},
+		{"draft-07 tuple", `{"$schema": "http://json-schema.org/draft-07/schema#", "items": [{"type": "string"}], "additionalItems": false}`,
+			`["a", 1]`, "", []SchemaViolation{
+				{InstancePath: "/1", SchemaPath: "/additionalItems", Keyword: "additionalItems", Message: "item 1 is not allowed"},
+			}},
+		{"draft-07 ref siblings ignored", `{"definitions": {"s": {"type": "string"}}, "$ref": "#/definitions/s", "minLength": 5}`,
+			`"ab"`, "draft-07", nil},
+		{"2020-12 ref siblings applied", `{"$defs": {"s": {"type": "string"}}, "$ref": "#/$defs/s", "minLength": 5}`,
+			`"ab"`, "", []SchemaViolation{
+				{InstancePath: "", SchemaPath: "/minLength", Keyword: "minLength", Message: "must be at least 5 characters long"},
+			}},
+		{"draft-07 dependencies", `{"dependencies": {"a": ["b"]}}`, `{"a": 1}`, "draft-07", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "/dependencies/a", Keyword: "dependencies", Message: `property "b" is required when "a" is present`},
+		}},
+		{"false schema", `false`, `1`, "", []SchemaViolation{
+			{InstancePath: "", SchemaPath: "", Keyword: "false", Message: "no value is allowed here"},
+		}},
+		{"precise numbers", `{"multipleOf": 0.01, "enum": [1.10, 2]}`, `1.1`, "", nil},
+		{"formats", `{"type": "array", "prefixItems": [{"format": "date-time"}, {"format": "ipv4"}, {"format": "uuid"}, {"format": "hostname"}]}`,
+			`["2024-01-02T03:04:05Z", "256.1.1.1", "123e4567-e89b-12d3-a456-426614174000", "-bad.example"]`, "", []SchemaViolation{
+				{InstancePath: "/1", SchemaPath: "/prefixItems/1/format", Keyword: "format", Message: "must be a valid ipv4"},
+				{InstancePath: "/3", SchemaPath: "/prefixItems/3/format", Keyword: "format", Message: "must be a valid hostname"},
+			}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := validateSchemaJSON(context.Background(), SchemaRequest{Value: tc.value, Schema: tc.schema, Draft: tc.draft})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Valid != (tc.want == nil) {
+				t.Errorf("valid = %v, want %v", resp.Valid, tc.want == nil)
+			}
+			want := tc.want
+			if want == nil {
+				want = []SchemaViolation{}
+			}
+			if !reflect.DeepEqual(resp.Violations, want) {
+				t.Errorf("violations = %+v\nwant %+v", resp.Violations, want)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaJSONErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		req       SchemaRequest
+		wantCode  string
+		wantField string
+	}{
+		{"bad schema json", SchemaRequest{Value: `1`, Schema: `{`}, CodeInvalidJSON, "schema"},
+		{"bad value json", SchemaRequest{Value: `{`, Schema: `{}`}, CodeInvalidJSON, "value"},
+		{"unknown draft", SchemaRequest{Value: `1`, Schema: `{}`, Draft: "draft-04"}, CodeInvalidOption, "draft"},
+		{"schema not object", SchemaRequest{Value: `1`, Schema: `[]`}, CodeInvalidValue, "schema"},
+		{"bad type", SchemaRequest{Value: `1`, Schema: `{"type": 3}`}, CodeInvalidValue, "schema"},
+		{"unresolved ref", SchemaRequest{Value: `1`, Schema: `{"$ref": "#/$defs/missing"}`}, CodeInvalidValue, "schema"},
+		{"remote ref", SchemaRequest{Value: `1`, Schema: `{"$ref": "https://example.com/other.json"}`}, CodeInvalidValue, "schema"},
+		{"ref loop", SchemaRequest{Value: `1`, Schema: `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`}, CodeInvalidValue, "schema"},
+		{"bad pattern", SchemaRequest{Value: `"x"`, Schema: `{"pattern": "(?<=a)"}`}, CodeInvalidValue, "schema"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validateSchemaJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.wantCode || ae.Field != tc.wantField {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.wantCode, tc.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+// LoremLimits caps the count accepted by each lorem ipsum tool.
+type LoremLimits struct {
+	Words          int `json:"words"`
+	Sentences      int `json:"sentences"`
+	Paragraphs     int `json:"paragraphs"`
+	Characters     int `json:"characters"`
+	Bytes          int `json:"bytes"`
+	TitleWords     int `json:"titleWords"`
+	SlugWords      int `json:"slugWords"`
+	CamelWords     int `json:"camelWords"`
+	ListItems      int `json:"listItems"`
+	Headings       int `json:"headings"`
+	HTMLBlocks     int `json:"htmlBlocks"`
+	MarkdownBlocks int `json:"markdownBlocks"`
+	JSONKeys       int `json:"jsonKeys"`
+}
+
+// PipelineLimits caps pipeline requests.
+type PipelineLimits struct {
+	Steps int `json:"steps"`
+}
+
+// JSONLimits caps the work done by the JSON tools.
+type JSONLimits struct {
+	PathMatches int `json:"pathMatches"` // nodes a JSONPath segment may select
+	JQSteps     int `json:"jqSteps"`     // evaluation steps a jq filter may take
+	JQValueSize int `json:"jqValueSize"` // bytes in a string, or items in an array, a jq filter may build
+}
+
+// YAMLLimits caps the work done by the YAML tools.
+type YAMLLimits struct {
+	Values int `json:"values"` // values a YAML stream may expand to once aliases are resolved
+}
+
+// Limits holds the per-tool limits. Every value must be positive.
+type Limits struct {
+	Lorem    LoremLimits    `json:"lorem"`
+	Pipeline PipelineLimits `json:"pipeline"`
+	JSON     JSONLimits     `json:"json"`
+	YAML     YAMLLimits     `json:"yaml"`
+}
+
+// DefaultLimits returns the built-in limits.
+func DefaultLimits() Limits {
+	return Limits{
+		Lorem: LoremLimits{
+			Words:          1000,
+			Sentences:      100,
+			Paragraphs:     50,
+			Characters:     10000,
+			Bytes:          10000,
+			TitleWords:     20,
+			SlugWords:      20,
+			CamelWords:     20,
+			ListItems:      50,
+			Headings:       20,
+			HTMLBlocks:     20,
+			MarkdownBlocks: 20,
+			JSONKeys:       10,
+		},
+		Pipeline: PipelineLimits{Steps: 20},
+		JSON:     JSONLimits{PathMatches: 10000, JQSteps: 5000000, JQValueSize: 5242880},
+		YAML:     YAMLLimits{Values: 1000000},
+	}
+}
+
+// limits is read by the transforms; set it once at startup with SetLimits.
+var limits = DefaultLimits()
+
+// SetLimits replaces the limits used by all tools. Call it before serving requests.
+func SetLimits(l Limits) {
+	limits = l
+}
+
+// CurrentLimits returns the limits in effect.
+func CurrentLimits() Limits {
+	return limits
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"strconv"
@@ -10,39 +11,26 @@ import (
 )
 
 const (
-	maxWords       = 1000
-	maxSentences   = 100
-	maxParagraphs  = 50
-	maxCharacters  = 10000
-	maxBytes       = 10000
-	maxTitleWords  = 20
-	maxSlugWords   = 20
-	maxCamelWords  = 20
-	maxListItems   = 50
-	maxHeadings    = 20
-	maxHtmlBlocks  = 20
-	maxMarkdownBlocks = 20
-	maxJsonKeys    = 10
-	minCount       = 1
-	classicLatin   = "Lorem ipsum dolor sit amet, consectetur adipiscing elit."
+	minCount     = 1
+	classicLatin = "Lorem ipsum dolor sit amet, consectetur adipiscing elit."
 )
 
 // LoremOptions holds tool-specific options.
 type LoremOptions struct {
 	StartWithClassic bool     `json:"startWithClassic"`
-	Type             string   `json:"type"`   // words, sentences, paragraphs (generator)
-	Vocabulary       string   `json:"vocabulary"` // default, bacon, hipster
+	Type             string   `json:"type" enum:"words,sentences,paragraphs"`   // words, sentences, paragraphs (generator)
+	Vocabulary       string   `json:"vocabulary" enum:"default,bacon,hipster"` // default, bacon, hipster
 	WholeWordsOnly   bool     `json:"wholeWordsOnly"`
-	ListStyle        string   `json:"listStyle"`   // bullet, numbered
-	HeadingStyle     string   `json:"headingStyle"` // plain, markdown
-	Format           string   `json:"format"`   // paragraphs, list, headings (html/markdown)
+	ListStyle        string   `json:"listStyle" enum:"bullet,numbered"`   // bullet, numbered
+	HeadingStyle     string   `json:"headingStyle" enum:"plain,markdown"` // plain, markdown
+	Format           string   `json:"format" enum:"paragraphs,list,headings"`   // paragraphs, list, headings (html/markdown)
 	Keys             []string `json:"keys"`    // json keys
 }
 
 // LoremRequest is the JSON body for the lorem ipsum generator.
 type LoremRequest struct {
-	Tool    string       `json:"tool"`    // generator, characters, bytes, title, slug, camelCase, list, headings, html, markdown, json
-	Type    string       `json:"type"`    // legacy: words, sentences, paragraphs
+	Tool    string       `json:"tool" enum:"generator,characters,bytes,title,slug,camelCase,list,headings,html,markdown,json"`    // generator, characters, bytes, title, slug, camelCase, list, headings, html, markdown, json
+	Type    string       `json:"type" enum:"words,sentences,paragraphs"`    // legacy: words, sentences, paragraphs
 	Count   int          `json:"count"`
 	Options *LoremOptions `json:"options"`
 }
@@ -86,15 +74,10 @@ func wordsForVocabulary(v string) []string {
 
 // LoremIpsum handles POST /api/lorem-ipsum/generate. Dispatches by tool and returns { result: string }.
 func LoremIpsum(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req LoremRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
+	serve(w, r, typedTransform(loremIpsum))
+}
+
+func loremIpsum(req LoremRequest) (StringResponse, error) {
 	// Backward compatibility: type + count without tool -> generator
 	tool := strings.TrimSpace(strings.ToLower(req.Tool))
 	if tool == "" {
@@ -102,8 +85,7 @@ func LoremIpsum(w http.ResponseWriter, r *http.Request) {
 		if req.Type == "words" || req.Type == "sentences" || req.Type == "paragraphs" {
 			tool = "generator"
 		} else {
-			http.Error(w, "invalid type: must be words, sentences, or paragraphs", http.StatusBadRequest)
-			return
+			return StringResponse{}, invalidOption("type", "invalid type: must be words, sentences, or paragraphs", enumTag(LoremRequest{}, "Type")...)
 		}
 	}
 	if req.Options == nil {
@@ -123,42 +105,40 @@ func LoremIpsum(w http.ResponseWriter, r *http.Request) {
 	opts.Vocabulary = strings.TrimSpace(strings.ToLower(opts.Vocabulary))
 
 	var result string
-	var errMsg string
+	var err error
 	switch tool {
 	case "generator":
-		result, errMsg = runGenerator(req.Count, opts)
+		result, err = runGenerator(req.Count, opts)
 	case "characters":
-		result, errMsg = runCharacters(req.Count, opts)
+		result, err = runCharacters(req.Count, opts)
 	case "bytes":
-		result, errMsg = runBytes(req.Count, opts)
+		result, err = runBytes(req.Count, opts)
 	case "title":
-		result, errMsg = runTitle(req.Count, opts)
+		result, err = runTitle(req.Count, opts)
 	case "slug":
-		result, errMsg = runSlug(req.Count, opts)
+		result, err = runSlug(req.Count, opts)
 	case "camelcase":
-		result, errMsg = runCamelCase(req.Count, opts)
+		result, err = runCamelCase(req.Count, opts)
 	case "list":
-		result, errMsg = runList(req.Count, opts)
+		result, err = runList(req.Count, opts)
 	case "headings":
-		result, errMsg = runHeadings(req.Count, opts)
+		result, err = runHeadings(req.Count, opts)
 	case "html":
-		result, errMsg = runHTML(req.Count, opts)
+		result, err = runHTML(req.Count, opts)
 	case "markdown":
-		result, errMsg = runMarkdown(req.Count, opts)
+		result, err = runMarkdown(req.Count, opts)
 	case "json":
-		result, errMsg = runJSON(req.Count, opts)
+		result, err = runJSON(req.Count, opts)
 	default:
-		http.Error(w, "invalid tool", http.StatusBadRequest)
-		return
+		return StringResponse{}, invalidOption("tool", "invalid tool", enumTag(LoremRequest{}, "Tool")...)
 	}
-	if errMsg != "" {
-		http.Error(w, errMsg, http.StatusBadRequest)
-		return
+	if err != nil {
+		return StringResponse{}, err
 	}
-	writeJSON(w, StringResponse{Result: result})
+	return StringResponse{Result: result}, nil
 }
 
-func runGenerator(count int, opts *LoremOptions) (string, string) {
+func runGenerator(count int, opts *LoremOptions) (string, error) {
 	typ := opts.Type
 	if typ == "" {
 		typ = "paragraphs"
@@ -166,20 +146,20 @@ func runGenerator(count int, opts *LoremOptions) (string, string) {
 	var max int
 	switch typ {
 	case "words":
-		max = maxWords
+		max = limits.Lorem.Words
 	case "sentences":
-		max = maxSentences
+		max = limits.Lorem.Sentences
 	case "paragraphs":
-		max = maxParagraphs
+		max = limits.Lorem.Paragraphs
 	default:
-		return "", "invalid type: must be words, sentences, or paragraphs"
+		return "", invalidOption("options.type", "invalid type: must be words, sentences, or paragraphs", enumTag(LoremOptions{}, "Type")...)
 	}
 	if count < minCount || count > max {
-		return "", "count out of range"
+		return "", outOfRange("count", minCount, max)
 	}
 	voc := wordsForVocabulary(opts.Vocabulary)
 	out := generateLoremWithVocab(typ, count, voc, opts.StartWithClassic)
-	return out, ""
+	return out, nil
 }
 
 func generateLoremWithVocab(typ string, count int, words []string, startWithClassic bool) string {
@@ -226,9 +206,9 @@ func generateLoremWithVocab(typ string, count int, words []string, startWithClas
 	}
 }
 
-func runCharacters(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxCharacters {
-		return "", "count out of range"
+func runCharacters(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.Characters {
+		return "", outOfRange("count", minCount, limits.Lorem.Characters)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -250,17 +230,17 @@ func runCharacters(count int, opts *LoremOptions) (string, string) {
 				s = s[:count]
 			}
 		}
-		return s, ""
+		return s, nil
 	}
 	if len(s) > count {
 		s = s[:count]
 	}
-	return s, ""
+	return s, nil
 }
 
-func runBytes(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxBytes {
-		return "", "count out of range"
+func runBytes(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.Bytes {
+		return "", outOfRange("count", minCount, limits.Lorem.Bytes)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -285,17 +265,17 @@ func runBytes(count int, opts *LoremOptions) (string, string) {
 				bs = bs[:count]
 			}
 		}
-		return string(bs), ""
+		return string(bs), nil
 	}
 	if len(bs) > count {
-		return string(bs[:count]), ""
+		return string(bs[:count]), nil
 	}
-	return string(bs), ""
+	return string(bs), nil
 }
 
-func runTitle(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxTitleWords {
-		return "", "count out of range"
+func runTitle(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.TitleWords {
+		return "", outOfRange("count", minCount, limits.Lorem.TitleWords)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -307,12 +287,12 @@ func runTitle(count int, opts *LoremOptions) (string, string) {
 		w := words[rand.Intn(nw)]
 		b.WriteString(capitalize(w))
 	}
-	return b.String(), ""
+	return b.String(), nil
 }
 
-func runSlug(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxSlugWords {
-		return "", "count out of range"
+func runSlug(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.SlugWords {
+		return "", outOfRange("count", minCount, limits.Lorem.SlugWords)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -320,12 +300,12 @@ func runSlug(count int, opts *LoremOptions) (string, string) {
 	for i := 0; i < count; i++ {
 		parts[i] = strings.ToLower(words[rand.Intn(nw)])
 	}
-	return strings.Join(parts, "-"), ""
+	return strings.Join(parts, "-"), nil
 }
 
-func runCamelCase(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxCamelWords {
-		return "", "count out of range"
+func runCamelCase(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.CamelWords {
+		return "", outOfRange("count", minCount, limits.Lorem.CamelWords)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -338,12 +318,12 @@ func runCamelCase(count int, opts *LoremOptions) (string, string) {
 			b.WriteString(capitalize(w))
 		}
 	}
-	return b.String(), ""
+	return b.String(), nil
 }
 
-func runList(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxListItems {
-		return "", "count out of range"
+func runList(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.ListItems {
+		return "", outOfRange("count", minCount, limits.Lorem.ListItems)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -363,12 +343,12 @@ func runList(count int, opts *LoremOptions) (string, string) {
 		b.WriteString(phrase)
 		b.WriteByte('\n')
 	}
-	return strings.TrimSuffix(b.String(), "\n"), ""
+	return strings.TrimSuffix(b.String(), "\n"), nil
 }
 
-func runHeadings(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxHeadings {
-		return "", "count out of range"
+func runHeadings(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.Headings {
+		return "", outOfRange("count", minCount, limits.Lorem.Headings)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -385,12 +365,12 @@ func runHeadings(count int, opts *LoremOptions) (string, string) {
 		b.WriteString(phrase)
 		b.WriteByte('\n')
 	}
-	return strings.TrimSuffix(b.String(), "\n"), ""
+	return strings.TrimSuffix(b.String(), "\n"), nil
 }
 
-func runHTML(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxHtmlBlocks {
-		return "", "count out of range"
+func runHTML(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.HTMLBlocks {
+		return "", outOfRange("count", minCount, limits.Lorem.HTMLBlocks)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -414,12 +394,12 @@ func runHTML(count int, opts *LoremOptions) (string, string) {
 		}
 		b.WriteString("</ul>")
 	}
-	return strings.TrimSuffix(b.String(), "\n"), ""
+	return strings.TrimSuffix(b.String(), "\n"), nil
 }
 
-func runMarkdown(count int, opts *LoremOptions) (string, string) {
-	if count < minCount || count > maxMarkdownBlocks {
-		return "", "count out of range"
+func runMarkdown(count int, opts *LoremOptions) (string, error) {
+	if count < minCount || count > limits.Lorem.MarkdownBlocks {
+		return "", outOfRange("count", minCount, limits.Lorem.MarkdownBlocks)
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -450,16 +430,18 @@ func runMarkdown(count int, opts *LoremOptions) (string, string) {
 			b.WriteString("\n")
 		}
 	}
-	return strings.TrimSpace(b.String()), ""
+	return strings.TrimSpace(b.String()), nil
 }
 
-func runJSON(count int, opts *LoremOptions) (string, string) {
+func runJSON(count int, opts *LoremOptions) (string, error) {
 	keys := opts.Keys
 	if len(keys) == 0 {
 		keys = []string{"title", "body", "summary"}
 	}
-	if len(keys) > maxJsonKeys {
-		return "", "too many keys"
+	if len(keys) > limits.Lorem.JSONKeys {
+		e := newError(CodeTooMany, "options.keys", "too many keys")
+		e.Details = map[string]interface{}{"max": limits.Lorem.JSONKeys}
+		return "", e
 	}
 	words := wordsForVocabulary(opts.Vocabulary)
 	nw := len(words)
@@ -473,9 +455,9 @@ func runJSON(count int, opts *LoremOptions) (string, string) {
 	}
 	bs, err := json.MarshalIndent(obj, "", "  ")
 	if err != nil {
-		return "", "json marshal failed"
+		return "", fmt.Errorf("json marshal failed: %w", err)
 	}
-	return string(bs), ""
+	return string(bs), nil
 }
 
 func makeShortPhrase(nw int, words []string, minW, maxW int) string {
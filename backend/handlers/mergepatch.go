@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+// This file implements RFC 7386 JSON Merge Patch: generating a merge patch from two
+// documents and applying one. A merge patch is a partial document in which null
+// deletes a member and anything that is not an object replaces the target whole.
+
+// MergeDiffRequest is the JSON body for the merge patch generation endpoint.
+type MergeDiffRequest struct {
+	ValueA string `json:"valueA"`
+	ValueB string `json:"valueB"`
+}
+
+// MergeWarning marks a change that a merge patch cannot express exactly, at a JSON
+// Pointer into ValueB.
+type MergeWarning struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// MergeDiffResponse is the JSON response for the merge patch generation endpoint.
+// Result is the merge patch, pretty-printed.
+type MergeDiffResponse struct {
+	Result   string         `json:"result"`
+	Warnings []MergeWarning `json:"warnings"`
+}
+
+func (r MergeDiffResponse) resultText() string { return r.Result }
+
+// MergePatchRequest is the JSON body for the merge patch endpoint.
+type MergePatchRequest struct {
+	Value string `json:"value"`
+	Patch string `json:"patch"`
+}
+
+// MergeDiffJSON returns the merge patch that turns one JSON value into another.
+func MergeDiffJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(mergeDiffJSON))
+}
+
+func mergeDiffJSON(ctx context.Context, req MergeDiffRequest) (MergeDiffResponse, error) {
+	a, err := decodeOrderedValue("valueA", req.ValueA)
+	if err != nil {
+		return MergeDiffResponse{}, err
+	}
+	b, err := decodeOrderedValue("valueB", req.ValueB)
+	if err != nil {
+		return MergeDiffResponse{}, err
+	}
+	md := &mergeDiff{ctx: ctx, warnings: []MergeWarning{}}
+	patch, changed, err := md.diff(a, b, "")
+	if err != nil {
+		return MergeDiffResponse{}, err
+	}
+	if !changed {
+		// {} only leaves an object alone; any other target is kept by patching it with
+		// itself, since a non-object patch replaces the target.
+		patch = b
+		if _, ok := b.(*jqObject); ok {
+			patch = newJQObject(0)
+		}
+	}
+	out, err := (&jqEval{}).encodeString(patch, "  ")
+	if err != nil {
+		return MergeDiffResponse{}, err
+	}
+	return MergeDiffResponse{Result: out, Warnings: md.warnings}, nil
+}
+
+// MergePatchJSON applies an RFC 7386 merge patch to a document.
+func MergePatchJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(mergePatchJSON))
+}
+
+func mergePatchJSON(ctx context.Context, req MergePatchRequest) (StringResponse, error) {
+	doc, err := decodeOrderedValue("value", req.Value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	patch, err := decodeOrderedValue("patch", req.Patch)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	steps := 0
+	out, err := mergeApply(ctx, doc, patch, &steps)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	text, err := (&jqEval{}).encodeString(out, "  ")
+	if err != nil {
+		return StringResponse{}, err
+	}
+	return StringResponse{Result: text}, nil
+}
+
+// mergeApply is MergePatch from RFC 7386, section 2: an object patch is merged member by
+// member (null removes the member) and any other patch replaces target.
+func mergeApply(ctx context.Context, target, patch interface{}, steps *int) (interface{}, error) {
+	if *steps++; *steps%1024 == 0 {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+	p, ok := patch.(*jqObject)
+	if !ok {
+		return patch, nil
+	}
+	t, ok := target.(*jqObject)
+	if ok {
+		t = t.copy()
+	} else {
+		t = newJQObject(p.len())
+	}
+	for _, k := range p.keys {
+		pv := p.vals[k]
+		if pv == nil {
+			t = t.without(k)
+			continue
+		}
+		tv, _ := t.get(k)
+		v, err := mergeApply(ctx, tv, pv, steps)
+		if err != nil {
+			return nil, err
+		}
+		t.put(k, v)
+	}
+	return t, nil
+}
+
+// mergeDiff generates a merge patch and collects warnings for the changes it cannot
+// express.
+type mergeDiff struct {
+	ctx      context.Context
+	steps    int
+	warnings []MergeWarning
+}
+
+// diff returns the merge patch from a to b at pointer, and false when they are equal.
+// Members of objects on both sides are patched one by one; any other change replaces
+// the value with b.
+func (md *mergeDiff) diff(a, b interface{}, pointer string) (interface{}, bool, error) {
+	if md.steps++; md.steps%1024 == 0 {
+		if err := checkContext(md.ctx); err != nil {
+			return nil, false, err
+		}
+	}
+	if jsonEqual(a, b) {
+		return nil, false, nil
+	}
+	x, xok := a.(*jqObject)
+	y, yok := b.(*jqObject)
+	if !xok || !yok {
+		md.replaced(a, b, pointer)
+		return b, true, nil
+	}
+	patch := newJQObject(0)
+	for _, k := range x.keys {
+		yv, ok := y.get(k)
+		if !ok {
+			patch.put(k, nil)
+			continue
+		}
+		sub, changed, err := md.diff(x.vals[k], yv, pointerJoin(pointer, k))
+		if err != nil {
+			return nil, false, err
+		}
+		if changed {
+			patch.put(k, sub)
+		}
+	}
+	for _, k := range y.keys {
+		if _, ok := x.get(k); !ok {
+			md.replaced(nil, y.vals[k], pointerJoin(pointer, k))
+			patch.put(k, y.vals[k])
+		}
+	}
+	return patch, true, nil
+}
+
+// replaced records the warnings for writing b whole where a was.
+func (md *mergeDiff) replaced(a, b interface{}, pointer string) {
+	_, aArr := a.([]interface{})
+	if _, bArr := b.([]interface{}); aArr && bArr {
+		md.warn(pointer, "the array is replaced as a whole; a merge patch cannot change single elements")
+	}
+	if b == nil && pointer != "" {
+		md.warn(pointer, "a merge patch cannot set null; applying it removes the member")
+	}
+	md.nulls(b, pointer)
+}
+
+// nulls warns about the null members of the objects in v, which a merge patch would
+// remove instead of setting. Arrays are written whole, so nulls inside them are kept.
+func (md *mergeDiff) nulls(v interface{}, pointer string) {
+	obj, ok := v.(*jqObject)
+	if !ok {
+		return
+	}
+	for _, k := range obj.keys {
+		p := pointerJoin(pointer, k)
+		if obj.vals[k] == nil {
+			md.warn(p, "a merge patch cannot set null; applying it removes the member")
+		}
+		md.nulls(obj.vals[k], p)
+	}
+}
+
+func (md *mergeDiff) warn(pointer, msg string) {
+	md.warnings = append(md.warnings, MergeWarning{Path: pointer, Message: msg})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatchJSON(t *testing.T) {
+	// The examples from RFC 7386, appendix A.
+	cases := []struct {
+		value, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		resp, err := mergePatchJSON(context.Background(), MergePatchRequest{Value: tc.value, Patch: tc.patch})
+		if err != nil {
+			t.Fatalf("%s + %s: %v", tc.value, tc.patch, err)
+		}
+		if got := compactJSON(t, resp.Result); got != tc.want {
+			t.Errorf("%s + %s = %s, want %s", tc.value, tc.patch, got, tc.want)
+		}
+	}
+}
+
+func TestMergeDiffJSON(t *testing.T) {
+	cases := []struct {
+		name, a, b, want string
+		warnings       []MergeWarning
+	}{
+		{"equal", `{"a": 1, "b": [1]}`, `{"b": [1.0], "a": 1}`, `{}`, nil},
+		{"members", `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"], "content": "text"}`,
+			`{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "content": "text", "phoneNumber": "+01-123-456-7890"}`,
+			`{"title":"Hello!","author":{"familyName":null},"tags":["example"],"phoneNumber":"+01-123-456-7890"}`,
+			[]MergeWarning{{"/tags", "the array is replaced as a whole; a merge patch cannot change single elements"}}},
+		{"explicit null", `{"a": 1, "b": {"c": 2}}`, `{"a": null, "b": {"c": 2, "d": {"e": null}}}`, `{"a":null,"b":{"d":{"e":null}}}`,
+			[]MergeWarning{
+				{"/a", "a merge patch cannot set null; applying it removes the member"},
+				{"/b/d/e", "a merge patch cannot set null; applying it removes the member"},
+			}},
+		{"nulls in arrays are kept", `{"a": [1]}`, `{"a": [null]}`, `{"a":[null]}`,
+			[]MergeWarning{{"/a", "the array is replaced as a whole; a merge patch cannot change single elements"}}},
+		{"document replaced", `{"a": 1}`, `[1]`, `[1]`, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := mergeDiffJSON(context.Background(), MergeDiffRequest{ValueA: tc.a, ValueB: tc.b})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := compactJSON(t, resp.Result); got != tc.want {
+				t.Errorf("patch = %s, want %s", got, tc.want)
+			}
+			want := tc.warnings
+			if want == nil {
+				want = []MergeWarning{}
+			}
+			if !reflect.DeepEqual(resp.Warnings, want) {
+				t.Errorf("warnings = %+v, want %+v", resp.Warnings, want)
+			}
+		})
+	}
+}
+
+// Without warnings, applying the generated merge patch to a must give b.
+func TestMergeDiffRoundTrip(t *testing.T) {
+	pairs := [][2]string{
+		{`{"a": {"b": 1, "c": [1, 2]}, "d": "x"}`, `{"a": {"c": [2], "e": {"f": true}}, "g": 0}`},
+		{`{"a": 1}`, `{"a": {"b": {"c": 1}}}`},
+		{`[1, 2]`, `{"a": [1, 2]}`},
+		{`{"a": {"b": 1}}`, `{"a": "b"}`},
+		// Equal values: only an object may be patched with {}.
+		{`{"a": 1}`, `{"a": 1}`},
+		{`[1, 2]`, `[1, 2]`},
+		{`"b"`, `"b"`},
+		{`42`, `42`},
+		{`null`, `null`},
+	}
+	for _, p := range pairs {
+		resp, err := mergeDiffJSON(context.Background(), MergeDiffRequest{ValueA: p[0], ValueB: p[1]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := mergePatchJSON(context.Background(), MergePatchRequest{Value: p[0], Patch: resp.Result})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(decodeForTest(t, out.Result), decodeForTest(t, p[1])) {
+			t.Errorf("%s -> %s: merge patch %s gives %s", p[0], p[1], resp.Result, out.Result)
+		}
+	}
+}
+
+func TestMergePatchJSONErrors(t *testing.T) {
+	_, err := mergePatchJSON(context.Background(), MergePatchRequest{Value: `{}`, Patch: `{"a": `})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeInvalidJSON || ae.Field != "patch" {
+		t.Errorf("err = %v, want invalid_json on patch", err)
+	}
+	_, err = mergeDiffJSON(context.Background(), MergeDiffRequest{ValueA: `{}`, ValueB: ``})
+	if !errors.As(err, &ae) || ae.Code != CodeInvalidJSON || ae.Field != "valueB" {
+		t.Errorf("err = %v, want invalid_json on valueB", err)
+	}
+}
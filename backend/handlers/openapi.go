@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// apiVersion is the version of the HTTP API described by the OpenAPI document.
+const apiVersion = "1.0.0"
+
+// OpenAPI handles GET /api/openapi.json. Returns an OpenAPI 3.1 document generated from
+// the registry and the request/response types, so it always matches the mounted routes.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, openAPIDocument())
+}
+
+// openAPIDocument builds the spec: one operation per registered tool and endpoint, with
+// request/response structs as shared component schemas.
+func openAPIDocument() map[string]interface{} {
+	b := &schemaBuilder{defs: make(map[string]interface{}), refPrefix: "#/components/schemas/"}
+	paths := make(map[string]interface{})
+	catLabels := make(map[string]string)
+	var tags []interface{}
+	for _, c := range categories {
+		catLabels[c.ID] = c.Label
+		tags = append(tags, map[string]interface{}{"name": c.Label})
+	}
+	for _, t := range registry {
+		op := openAPIOperation(b, t.Name, t.Label, t.Description, t.Request, t.Response)
+		op["tags"] = []string{catLabels[t.Category]}
+		for status, name := range toolResponses[t.Key()] {
+			op["responses"].(map[string]interface{})[status] = map[string]interface{}{"$ref": "#/components/responses/" + name}
+		}
+		if t.Stream != nil {
+			ok := op["responses"].(map[string]interface{})["200"].(map[string]interface{})
+			ok["content"].(map[string]interface{})[ndjsonType] = map[string]interface{}{
+				"schema": b.schema(reflect.TypeOf(t.StreamItem)),
+			}
+		}
+		paths[t.Path] = map[string]interface{}{"post": op}
+	}
+	for _, e := range Endpoints() {
+		op := openAPIOperation(b, e.Name, e.Summary, "", e.Request, e.Response)
+		op["tags"] = []string{"API"}
+		paths[e.Path] = map[string]interface{}{strings.ToLower(e.Method): op}
+	}
+	tags = append(tags, map[string]interface{}{"name": "API"})
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "Digi Leatherman API",
+			"version":     apiVersion,
+			"description": "String tools, JSON tools and placeholder text generators.",
+		},
+		"tags":  tags,
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.defs,
+			"responses": map[string]interface{}{
+				"BadRequest":       errorResponse(b, "The request body or one of its values is invalid; error.code says why."),
+				"MethodNotAllowed": errorResponse(b, "The endpoint does not accept this HTTP method."),
+				"PayloadTooLarge":  errorResponse(b, "The request body is over the size limit for the route (error.code body_too_large, error.details.max bytes)."),
+				"InternalError":    errorResponse(b, "Unexpected server error."),
+				"TestFailed":       errorResponse(b, "A test operation did not match the document (error.code test_failed); error.details hold the operation index, op and path."),
+				"Timeout":          errorResponse(b, "The request ran past the server's request timeout or was canceled (error.code timeout); long-running tools stop at that point."),
+			},
+		},
+	}
+}
+
+// toolResponses lists, by tool key, the error responses a tool has beyond the ones every
+// body route shares.
+var toolResponses = map[string]map[string]string{
+	"json/patch": {"409": "TestFailed"},
+}
+
+// openAPIOperation describes one operation. A nil req means the operation takes no body;
+// a nil resp means any JSON value.
+func openAPIOperation(b *schemaBuilder, id, summary, description string, req, resp interface{}) map[string]interface{} {
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{
+			"description": "OK",
+			"content":     jsonContent(b.schema(reflect.TypeOf(resp))),
+		},
+		"405": map[string]interface{}{"$ref": "#/components/responses/MethodNotAllowed"},
+	}
+	op := map[string]interface{}{
+		"operationId": id,
+		"summary":     summary,
+		"responses":   responses,
+	}
+	if description != "" {
+		op["description"] = description
+	}
+	if req != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(b.schema(reflect.TypeOf(req))),
+		}
+		responses["400"] = map[string]interface{}{"$ref": "#/components/responses/BadRequest"}
+		responses["413"] = map[string]interface{}{"$ref": "#/components/responses/PayloadTooLarge"}
+		responses["500"] = map[string]interface{}{"$ref": "#/components/responses/InternalError"}
+		responses["503"] = map[string]interface{}{"$ref": "#/components/responses/Timeout"}
+	}
+	return op
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// errorResponse describes a response carrying an ErrorResponse envelope.
+func errorResponse(b *schemaBuilder, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content":     jsonContent(b.schema(reflect.TypeOf(ErrorResponse{}))),
+	}
+}
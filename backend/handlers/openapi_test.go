@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestOpenAPIGolden(t *testing.T) {
+	got, err := json.MarshalIndent(openAPIDocument(), "", "  ")
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got = append(got, '\n')
+	golden := filepath.Join("testdata", "openapi.json")
+	if *updateGolden {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden (run go test ./handlers -run OpenAPI -update): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("OpenAPI document differs from %s; review the API change and run go test ./handlers -run OpenAPI -update", golden)
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc := openAPIDocument()
+	paths := doc["paths"].(map[string]interface{})
+	for _, tool := range Tools() {
+		item, ok := paths[tool.Path].(map[string]interface{})
+		if !ok || item["post"] == nil {
+			t.Errorf("spec is missing POST %s", tool.Path)
+		}
+	}
+	for _, e := range Endpoints() {
+		item, ok := paths[e.Path].(map[string]interface{})
+		if !ok || item[strings.ToLower(e.Method)] == nil {
+			t.Errorf("spec is missing %s %s", e.Method, e.Path)
+		}
+	}
+	if len(paths) != len(Tools())+len(Endpoints()) {
+		t.Errorf("spec has %d paths, want %d", len(paths), len(Tools())+len(Endpoints()))
+	}
+}
+
+// openAPIResponses returns the responses of the operation for method and path.
+func openAPIResponses(t *testing.T, method, path string) map[string]interface{} {
+	t.Helper()
+	item, ok := openAPIDocument()["paths"].(map[string]interface{})[path].(map[string]interface{})
+	if !ok || item[method] == nil {
+		t.Fatalf("spec is missing %s %s", method, path)
+	}
+	return item[method].(map[string]interface{})["responses"].(map[string]interface{})
+}
+
+// Every route that reads a body sits behind the body size limit and the request timeout.
+func TestOpenAPIErrorResponses(t *testing.T) {
+	for _, tool := range Tools() {
+		responses := openAPIResponses(t, "post", tool.Path)
+		for _, status := range []string{"413", "503"} {
+			if responses[status] == nil {
+				t.Errorf("POST %s does not document %s", tool.Path, status)
+			}
+		}
+	}
+	if openAPIResponses(t, "post", "/api/json/patch")["409"] == nil {
+		t.Errorf("POST /api/json/patch does not document 409 test_failed")
+	}
+	for _, e := range Endpoints() {
+		responses := openAPIResponses(t, strings.ToLower(e.Method), e.Path)
+		for _, status := range []string{"413", "503"} {
+			if (e.Request != nil) != (responses[status] != nil) {
+				t.Errorf("%s %s: %s documented = %v, want %v", e.Method, e.Path, status, responses[status] != nil, e.Request != nil)
+			}
+		}
+	}
+}
+
+// enumValues returns the enum of property prop in component schema name.
+func enumValues(t *testing.T, name string, prop string) []string {
+	t.Helper()
+	schemas := openAPIDocument()["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	s, ok := schemas[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no component schema %s", name)
+	}
+	p := s["properties"].(map[string]interface{})[prop].(map[string]interface{})
+	enum, _ := p["enum"].([]string)
+	if len(enum) == 0 {
+		t.Fatalf("%s.%s has no enum", name, prop)
+	}
+	return enum
+}
+
+// The enums are declared in struct tags; these tests fail if a handler stops accepting a
+// documented value.
+func TestOpenAPIEnumsAccepted(t *testing.T) {
+	for _, tool := range enumValues(t, "LoremRequest", "tool") {
+		if _, err := loremIpsum(LoremRequest{Tool: tool, Count: 1}); err != nil {
+			t.Errorf("documented lorem tool %q rejected: %v", tool, err)
+		}
+	}
+	if _, err := loremIpsum(LoremRequest{Tool: "nope", Count: 1}); err == nil {
+		t.Errorf("undocumented lorem tool should be rejected")
+	}
+	for _, typ := range enumValues(t, "LoremOptions", "type") {
+		if _, err := loremIpsum(LoremRequest{Tool: "generator", Count: 1, Options: &LoremOptions{Type: typ}}); err != nil {
+			t.Errorf("documented generator type %q rejected: %v", typ, err)
+		}
+	}
+	for _, typ := range enumValues(t, "LoremRequest", "type") {
+		if _, err := loremIpsum(LoremRequest{Type: typ, Count: 1}); err != nil {
+			t.Errorf("documented legacy type %q rejected: %v", typ, err)
+		}
+	}
+	for _, v := range enumValues(t, "LoremOptions", "vocabulary") {
+		words := wordsForVocabulary(v)
+		if v != "default" && &words[0] == &loremWords[0] {
+			t.Errorf("vocabulary %q falls back to the default word list", v)
+		}
+	}
+	for _, a := range enumValues(t, "SpellOutRequest", "alphabet") {
+		if _, err := spellOut(SpellOutRequest{Value: "a", Alphabet: a}); err != nil {
+			t.Errorf("documented alphabet %q rejected: %v", a, err)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	status, _ := runHandler(t, OpenAPI, "POST", "")
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	req := httptest.NewRequest("GET", "http://test/api/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	OpenAPI(rec, req)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v, want 3.1.0", doc["openapi"])
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// PipelineStep is one step of a pipeline: a registered tool (by name such as "FormatJSON"
+// or key such as "json/format") plus the options to send alongside the piped value.
+type PipelineStep struct {
+	Tool    string                     `json:"tool"`
+	Options map[string]json.RawMessage `json:"options,omitempty"`
+}
+
+// PipelineRequest is the JSON body for the pipeline endpoint.
+type PipelineRequest struct {
+	Input string         `json:"input"`
+	Steps []PipelineStep `json:"steps"`
+}
+
+// PipelineStepResult records the output (or error) of one executed step. Output is
+// always sent, so an empty output reads as "" rather than as a missing field.
+type PipelineStepResult struct {
+	Tool   string    `json:"tool"`
+	Output string    `json:"output"`
+	Error  *APIError `json:"error,omitempty"`
+}
+
+// PipelineResponse is the JSON response for the pipeline endpoint. FailedStep is the
+// zero-based index of the step that failed, omitted when every step succeeded.
+type PipelineResponse struct {
+	Result     string               `json:"result"`
+	Steps      []PipelineStepResult `json:"steps"`
+	FailedStep *int                 `json:"failedStep,omitempty"`
+}
+
+// Pipeline handles POST /api/pipeline. Runs each step's tool on the previous step's output
+// (starting from input) and returns the final output with every intermediate result.
+// Example: Base64Decode -> FormatJSON -> PathQueryJSON{"path":"a.b"}.
+func Pipeline(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(runPipeline))
+}
+
+func runPipeline(ctx context.Context, req PipelineRequest) (PipelineResponse, error) {
+	if len(req.Steps) == 0 {
+		return PipelineResponse{}, newError(CodeRequired, "steps", "at least one step is required")
+	}
+	if len(req.Steps) > limits.Pipeline.Steps {
+		e := newError(CodeTooMany, "steps", fmt.Sprintf("too many steps (max %d)", limits.Pipeline.Steps))
+		e.Details = map[string]interface{}{"max": limits.Pipeline.Steps}
+		return PipelineResponse{}, e
+	}
+	tools := make([]*Tool, len(req.Steps))
+	for i, step := range req.Steps {
+		t, ok := lookupPipelineTool(step.Tool)
+		if !ok {
+			e := newError(CodeInvalidOption, fmt.Sprintf("steps.%d.tool", i), fmt.Sprintf("step %d: unknown tool %q", i, step.Tool))
+			e.Details = map[string]interface{}{"step": i}
+			return PipelineResponse{}, e
+		}
+		tools[i] = t
+	}
+	resp := PipelineResponse{Steps: make([]PipelineStepResult, 0, len(req.Steps))}
+	current := req.Input
+	for i, step := range req.Steps {
+		if err := checkContext(ctx); err != nil {
+			return PipelineResponse{}, err
+		}
+		out, err := runPipelineStep(ctx, tools[i], step.Options, current)
+		if err != nil {
+			// Only input errors belong to a step; timeouts and server faults fail the request.
+			var ae *APIError
+			if !errors.As(err, &ae) || ae.Code == CodeTimeout {
+				return PipelineResponse{}, err
+			}
+			failed := i
+			resp.Steps = append(resp.Steps, PipelineStepResult{Tool: tools[i].Name, Error: ae})
+			resp.FailedStep = &failed
+			resp.Result = current
+			return resp, nil
+		}
+		resp.Steps = append(resp.Steps, PipelineStepResult{Tool: tools[i].Name, Output: out})
+		current = out
+	}
+	resp.Result = current
+	return resp, nil
+}
+
+// lookupPipelineTool resolves a step's tool by Go-style name or by category/id key.
+func lookupPipelineTool(name string) (*Tool, bool) {
+	if t, ok := LookupToolName(name); ok {
+		return t, true
+	}
+	for _, t := range registry {
+		if t.Key() == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// runPipelineStep builds the tool's request from options plus the piped value, runs its
+// transform and flattens the response to a string for the next step.
+func runPipelineStep(ctx context.Context, t *Tool, options map[string]json.RawMessage, input string) (string, error) {
+	body := make(map[string]json.RawMessage, len(options)+1)
+	for k, v := range options {
+		body[k] = v
+	}
+	field := t.PipeField
+	if field == "" {
+		field = "value"
+	}
+	piped, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	body[field] = piped
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.Transform(ctx, raw)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := ResultText(resp); ok {
+		return s, nil
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return "", fmt.Errorf("marshal: %w", err)
+	}
+	return string(out), nil
+}
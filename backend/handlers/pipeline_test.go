@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func parsePipelineResponse(t *testing.T, body string) PipelineResponse {
+	t.Helper()
+	var res PipelineResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatalf("parsePipelineResponse: %v", err)
+	}
+	return res
+}
+
+func TestPipeline(t *testing.T) {
+	t.Run("method not allowed", func(t *testing.T) {
+		status, _ := runHandler(t, Pipeline, "GET", "")
+		if status != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", status, http.StatusMethodNotAllowed)
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		status, _ := runHandler(t, Pipeline, "POST", "{")
+		if status != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("no steps", func(t *testing.T) {
+		status, _ := runHandler(t, Pipeline, "POST", `{"input":"x","steps":[]}`)
+		if status != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("unknown tool", func(t *testing.T) {
+		status, _ := runHandler(t, Pipeline, "POST", `{"input":"x","steps":[{"tool":"Nope"}]}`)
+		if status != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("decode format query", func(t *testing.T) {
+		// base64 of {"a":{"b":"Hello World"}}
+		body := `{"input":"eyJhIjp7ImIiOiJIZWxsbyBXb3JsZCJ9fQ==","steps":[
+			{"tool":"Base64Decode"},
+			{"tool":"json/format"},
+			{"tool":"PathQueryJSON","options":{"path":"a.b"}},
+			{"tool":"SnakeCase"}]}`
+		status, raw := runHandler(t, Pipeline, "POST", body)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body: %s", status, http.StatusOK, raw)
+		}
+		res := parsePipelineResponse(t, raw)
+		if res.FailedStep != nil {
+			t.Fatalf("unexpected failed step %d: %+v", *res.FailedStep, res.Steps)
+		}
+		if res.Result != "hello_world" {
+			t.Errorf("result = %q, want %q", res.Result, "hello_world")
+		}
+		if len(res.Steps) != 4 {
+			t.Fatalf("got %d step results, want 4", len(res.Steps))
+		}
+		if res.Steps[0].Output != `{"a":{"b":"Hello World"}}` {
+			t.Errorf("step 0 output = %q", res.Steps[0].Output)
+		}
+		if res.Steps[1].Tool != "FormatJSON" {
+			t.Errorf("step 1 tool = %q, want FormatJSON", res.Steps[1].Tool)
+		}
+	})
+
+	t.Run("failing step", func(t *testing.T) {
+		body := `{"input":"not base64!","steps":[{"tool":"Trim"},{"tool":"Base64Decode"},{"tool":"UpperCase"}]}`
+		status, raw := runHandler(t, Pipeline, "POST", body)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body: %s", status, http.StatusOK, raw)
+		}
+		res := parsePipelineResponse(t, raw)
+		if res.FailedStep == nil || *res.FailedStep != 1 {
+			t.Fatalf("failedStep = %v, want 1", res.FailedStep)
+		}
+		if len(res.Steps) != 2 || res.Steps[1].Error == nil || res.Steps[1].Error.Code != CodeInvalidValue {
+			t.Errorf("expected error on step 1, got %+v", res.Steps)
+		}
+		if res.Result != "not base64!" {
+			t.Errorf("result should be the last successful output, got %q", res.Result)
+		}
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		_, raw := runHandler(t, Pipeline, "POST", `{"input":"   ","steps":[{"tool":"Trim"}]}`)
+		if !strings.Contains(raw, `"steps":[{"tool":"Trim","output":""}]`) {
+			t.Errorf("an empty step output should be sent as \"\", got %s", raw)
+		}
+	})
+
+	t.Run("non-string response", func(t *testing.T) {
+		body := `{"input":"{\"a\":1}","steps":[{"tool":"ValidateJSON"}]}`
+		_, raw := runHandler(t, Pipeline, "POST", body)
+		res := parsePipelineResponse(t, raw)
+		if res.Result != `{"valid":true}` {
+			t.Errorf("result = %q, want %q", res.Result, `{"valid":true}`)
+		}
+	})
+
+	t.Run("diff pipes into valueA", func(t *testing.T) {
+		body := `{"input":"{\"a\":1}","steps":[{"tool":"DiffJSON","options":{"valueB":"{\"a\":1}"}}]}`
+		_, raw := runHandler(t, Pipeline, "POST", body)
+		res := parsePipelineResponse(t, raw)
+		if res.Result != "(no differences)" {
+			t.Errorf("result = %q, want %q", res.Result, "(no differences)")
+		}
+	})
+}
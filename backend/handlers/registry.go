@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Transform is the pure function behind a tool: it takes the raw JSON request body and
+// returns the response value to encode. Invalid input is reported as an *APIError.
+// Long-running transforms stop when ctx is done (see checkContext).
+type Transform func(ctx context.Context, body json.RawMessage) (interface{}, error)
+
+// StreamTransform is a Transform that produces its response as a sequence of values,
+// passing each one to emit as soon as it is ready. It stops when emit returns an error.
+type StreamTransform func(ctx context.Context, body json.RawMessage, emit func(interface{}) error) error
+
+// Tool describes one API tool: where it is mounted, how it is labeled in the UI and the
+// transform that implements it.
+type Tool struct {
+	ID          string // unique within its category, e.g. "snake-case"
+	Name        string // Go-style name, e.g. "SnakeCase"
+	Category    string // category id, e.g. "string"
+	Group       string // optional sidebar sub-group, e.g. "Case"
+	Label       string
+	Description string
+	Path        string      // API route, e.g. "/api/string/snake-case"
+	Request     interface{} // zero value of the request body type
+	Response    interface{} // zero value of the response body type
+	Transform   Transform
+	// PipeField is the request field that receives the previous step's output in a
+	// pipeline; empty means "value".
+	PipeField string
+	// Stream, when set, serves requests that accept application/x-ndjson: one JSON line
+	// per value emitted. StreamItem is the zero value of a line.
+	Stream     StreamTransform
+	StreamItem interface{}
+}
+
+// Key returns the tool id qualified by its category, e.g. "string/snake-case".
+func (t *Tool) Key() string {
+	return t.Category + "/" + t.ID
+}
+
+// ServeHTTP runs the tool's transform on a POST body and writes the JSON response, or
+// streams newline-delimited JSON when the tool supports it and the client asks for it.
+func (t *Tool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if t.Stream != nil && strings.Contains(r.Header.Get("Accept"), ndjsonType) {
+		serveStream(w, r, t.Stream)
+		return
+	}
+	serve(w, r, t.Transform)
+}
+
+// Category groups tools in the sidebar.
+type Category struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+var categories = []Category{
+	{ID: "string", Label: "Strings"},
+	{ID: "lorem-ipsum", Label: "Lorem Ipsum"},
+	{ID: "json", Label: "JSON"},
+	{ID: "yaml", Label: "YAML"},
+}
+
+// registry lists every tool in sidebar order.
+var registry = []*Tool{
+	{ID: "url-encode", Name: "URLEncode", Category: "string", Group: "URL", Label: "URL encode",
+		Description: "Encode text for safe use in URL query strings.",
+		Path:        "/api/string/url-encode", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(urlEncode)},
+	{ID: "url-decode", Name: "URLDecode", Category: "string", Group: "URL", Label: "URL decode",
+		Description: "Decode URL-encoded text back to plain text.",
+		Path:        "/api/string/url-decode", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(urlDecode)},
+	{ID: "url-parse-params", Name: "ParseURLParams", Category: "string", Group: "URL", Label: "URL params parser",
+		Description: "Parse query parameters from a URL or query string into JSON.",
+		Path:        "/api/string/url-parse-params", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(parseURLParams)},
+	{ID: "url-param-creator", Name: "CreateURLWithParams", Category: "string", Group: "URL", Label: "URL param creator",
+		Description: "Build a URL from a base URL and key=value lines.",
+		Path:        "/api/string/url-param-creator", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(createURLWithParams)},
+	{ID: "base64-encode", Name: "Base64Encode", Category: "string", Group: "Base64", Label: "Base64 encode",
+		Description: "Encode text as Base64.",
+		Path:        "/api/string/base64-encode", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(base64Encode)},
+	{ID: "base64-decode", Name: "Base64Decode", Category: "string", Group: "Base64", Label: "Base64 decode",
+		Description: "Decode Base64 back to plain text.",
+		Path:        "/api/string/base64-decode", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(base64Decode)},
+	{ID: "upper-case", Name: "UpperCase", Category: "string", Group: "Case", Label: "Upper Case",
+		Description: "Convert all characters to uppercase.",
+		Path:        "/api/string/upper-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(upperCase)},
+	{ID: "lower-case", Name: "LowerCase", Category: "string", Group: "Case", Label: "Lower Case",
+		Description: "Convert all characters to lowercase.",
+		Path:        "/api/string/lower-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(lowerCase)},
+	{ID: "capital-case", Name: "CapitalCase", Category: "string", Group: "Case", Label: "Capital Case",
+		Description: "Capitalize the first letter of each word.",
+		Path:        "/api/string/capital-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(capitalCase)},
+	{ID: "snake-case", Name: "SnakeCase", Category: "string", Group: "Case", Label: "Snake Case",
+		Description: "Convert words to snake_case.",
+		Path:        "/api/string/snake-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(pureString(toSnake))},
+	{ID: "kebab-case", Name: "KebabCase", Category: "string", Group: "Case", Label: "Kebab Case",
+		Description: "Convert words to kebab-case.",
+		Path:        "/api/string/kebab-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(pureString(toKebab))},
+	{ID: "camel-case", Name: "CamelCase", Category: "string", Group: "Case", Label: "Camel Case",
+		Description: "Convert words to camelCase.",
+		Path:        "/api/string/camel-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(pureString(toCamel))},
+	{ID: "pascal-case", Name: "PascalCase", Category: "string", Group: "Case", Label: "Pascal Case",
+		Description: "Convert words to PascalCase.",
+		Path:        "/api/string/pascal-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(pureString(toPascal))},
+	{ID: "sentence-case", Name: "SentenceCase", Category: "string", Group: "Case", Label: "Sentence Case",
+		Description: "Uppercase the first character and lowercase the rest.",
+		Path:        "/api/string/sentence-case", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(sentenceCase)},
+	{ID: "spell-out", Name: "SpellOut", Category: "string", Group: "Spelling", Label: "Spell out",
+		Description: "Spell letters out using a phonetic alphabet (e.g. NATO).",
+		Path:        "/api/string/spell-out", Request: SpellOutRequest{}, Response: StringResponse{},
+		Transform: typedTransform(spellOut)},
+	{ID: "trim", Name: "Trim", Category: "string", Group: "Trim", Label: "Trim",
+		Description: "Remove leading and trailing whitespace.",
+		Path:        "/api/string/trim", Request: StringRequest{}, Response: StringResponse{},
+		Transform: stringTransform(trim)},
+	{ID: "generate", Name: "LoremIpsum", Category: "lorem-ipsum", Label: "Lorem Ipsum",
+		Description: "Generate placeholder text: words, sentences, titles, lists, HTML, Markdown or JSON.",
+		Path:        "/api/lorem-ipsum/generate", Request: LoremRequest{}, Response: StringResponse{},
+		Transform: typedTransform(loremIpsum)},
+	{ID: "format", Name: "FormatJSON", Category: "json", Group: "Format", Label: "Format",
+		Description: "Pretty-print JSON, keeping key order and exact numbers, with optional indent width, tabs, sorted keys and one-line scalar arrays.",
+		Path:        "/api/json/format", Request: FormatRequest{}, Response: StringResponse{},
+		Transform: typedTransform(formatJSON)},
+	{ID: "minify", Name: "MinifyJSON", Category: "json", Group: "Format", Label: "Minify",
+		Description: "Remove unnecessary whitespace from JSON, keeping key order and exact numbers.",
+		Path:        "/api/json/minify", Request: MinifyRequest{}, Response: StringResponse{},
+		Transform: typedTransform(minifyJSON)},
+	{ID: "canonicalize", Name: "CanonicalizeJSON", Category: "json", Group: "Format", Label: "Canonicalize",
+		Description: "Write JSON in RFC 8785 canonical form (JCS) for signing, optionally with its SHA-256 or SHA-512 digest.",
+		Path:        "/api/json/canonicalize", Request: CanonicalRequest{}, Response: CanonicalResponse{},
+		Transform: typedTransform(canonicalizeJSON)},
+	{ID: "repair", Name: "RepairJSON", Category: "json", Group: "Format", Label: "Repair",
+		Description: "Convert JSON5 or JSONC (comments, trailing commas, single quotes, unquoted keys) to strict JSON and list the fixes made.",
+		Path:        "/api/json/repair", Request: RepairRequest{}, Response: RepairResponse{},
+		Transform: typedTransform(repairJSON)},
+	{ID: "validate", Name: "ValidateJSON", Category: "json", Group: "Validate", Label: "Validate",
+		Description: "Check whether the input is valid JSON.",
+		Path:        "/api/json/validate", Request: StringRequest{}, Response: ValidateResponse{},
+		Transform: typedTransform(validateJSON)},
+	{ID: "validate-schema", Name: "ValidateSchemaJSON", Category: "json", Group: "Validate", Label: "Schema validate",
+		Description: "Validate JSON against a JSON Schema (2020-12 or draft-07) and list every violation.",
+		Path:        "/api/json/validate-schema", Request: SchemaRequest{}, Response: SchemaResponse{},
+		Transform: typedContextTransform(validateSchemaJSON)},
+	{ID: "path", Name: "PathQueryJSON", Category: "json", Group: "Query", Label: "Path query",
+		Description: "Select values with a JSONPath query ($.items[?@.price > 10].name) or a dot-separated path.",
+		Path:        "/api/json/path", Request: PathRequest{}, Response: PathResponse{},
+		Transform: typedContextTransform(pathQueryJSON)},
+	{ID: "pointer", Name: "PointerJSON", Category: "json", Group: "Query", Label: "JSON Pointer",
+		Description: "Return the value an RFC 6901 JSON Pointer (/items/0/name) refers to.",
+		Path:        "/api/json/pointer", Request: PointerRequest{}, Response: StringResponse{},
+		Transform: typedTransform(pointerJSON)},
+	{ID: "jq", Name: "JQJSON", Category: "json", Group: "Query", Label: "jq",
+		Description: "Reshape JSON with a jq filter (map, select, object construction, sort_by, group_by, ...).",
+		Path:        "/api/json/jq", Request: JQRequest{}, Response: JQResponse{},
+		Transform: typedContextTransform(jqJSON), Stream: streamJQ, StreamItem: JQOutput{}},
+	{ID: "diff", Name: "DiffJSON", Category: "json", Group: "Compare", Label: "Diff",
+		Description: "Compare two JSON values and list what was added, removed, moved or changed, or produce an RFC 6902 JSON Patch.",
+		Path:        "/api/json/diff", Request: DiffRequest{}, Response: DiffResponse{},
+		Transform: typedContextTransform(diffJSON), PipeField: "valueA"},
+	{ID: "patch", Name: "PatchJSON", Category: "json", Group: "Compare", Label: "Patch",
+		Description: "Apply an RFC 6902 JSON Patch (add, remove, replace, move, copy, test) to a document.",
+		Path:        "/api/json/patch", Request: PatchRequest{}, Response: StringResponse{},
+		Transform: typedContextTransform(patchJSON)},
+	{ID: "merge-diff", Name: "MergeDiffJSON", Category: "json", Group: "Compare", Label: "Merge patch diff",
+		Description: "Compute the RFC 7386 JSON Merge Patch that turns one JSON value into another.",
+		Path:        "/api/json/merge-diff", Request: MergeDiffRequest{}, Response: MergeDiffResponse{},
+		Transform: typedContextTransform(mergeDiffJSON), PipeField: "valueA"},
+	{ID: "merge-patch", Name: "MergePatchJSON", Category: "json", Group: "Compare", Label: "Merge patch",
+		Description: "Apply an RFC 7386 JSON Merge Patch to a document.",
+		Path:        "/api/json/merge-patch", Request: MergePatchRequest{}, Response: StringResponse{},
+		Transform: typedContextTransform(mergePatchJSON)},
+	{ID: "infer-schema", Name: "InferSchemaJSON", Category: "json", Group: "Generate", Label: "Infer schema",
+		Description: "Infer a JSON Schema from one or more sample documents, with required fields, enums and formats.",
+		Path:        "/api/json/infer-schema", Request: InferSchemaRequest{}, Response: StringResponse{},
+		Transform: typedContextTransform(inferSchemaJSON)},
+	{ID: "go-struct", Name: "GoStructJSON", Category: "json", Group: "Generate", Label: "Go structs",
+		Description: "Generate Go struct declarations with json tags from one or more sample documents.",
+		Path:        "/api/json/go-struct", Request: GoStructRequest{}, Response: StringResponse{},
+		Transform: typedContextTransform(goStructJSON)},
+	{ID: "typescript", Name: "TypeScriptJSON", Category: "json", Group: "Generate", Label: "TypeScript",
+		Description: "Generate TypeScript interfaces, and optionally Zod schemas, from sample JSON or a JSON Schema.",
+		Path:        "/api/json/typescript", Request: TypeScriptRequest{}, Response: StringResponse{},
+		Transform: typedContextTransform(typeScriptJSON)},
+	{ID: "to-json", Name: "YAMLToJSON", Category: "yaml", Group: "Convert", Label: "YAML to JSON",
+		Description: "Convert a YAML stream to JSON, expanding anchors and merge keys, with YAML 1.1 or 1.2 typing of yes/no/on/off and octals.",
+		Path:        "/api/yaml/to-json", Request: YAMLToJSONRequest{}, Response: YAMLToJSONResponse{},
+		Transform: typedContextTransform(yamlToJSON)},
+	{ID: "from-json", Name: "JSONToYAML", Category: "yaml", Group: "Convert", Label: "JSON to YAML",
+		Description: "Convert JSON to YAML, keeping key order and exact numbers, optionally as one document per array element.",
+		Path:        "/api/yaml/from-json", Request: JSONToYAMLRequest{}, Response: StringResponse{},
+		Transform: typedTransform(jsonToYAML)},
+	{ID: "format", Name: "FormatYAML", Category: "yaml", Group: "Format", Label: "Format",
+		Description: "Re-indent a YAML stream, keeping comments, anchors, tags and quoting.",
+		Path:        "/api/yaml/format", Request: FormatYAMLRequest{}, Response: StringResponse{},
+		Transform: typedTransform(formatYAML)},
+	{ID: "validate", Name: "ValidateYAML", Category: "yaml", Group: "Validate", Label: "Validate",
+		Description: "Check that a YAML stream parses and converts to JSON, with the line of the first problem and YAML 1.1/1.2 differences.",
+		Path:        "/api/yaml/validate", Request: ValidateYAMLRequest{}, Response: YAMLValidateResponse{},
+		Transform: typedContextTransform(validateYAML)},
+}
+
+// Tools returns every registered tool in sidebar order.
+func Tools() []*Tool {
+	return registry
+}
+
+// LookupTool finds a tool by category and id (e.g. "string", "snake-case").
+func LookupTool(category, id string) (*Tool, bool) {
+	for _, t := range registry {
+		if t.Category == category && t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// LookupToolName finds a tool by its Go-style name (e.g. "SnakeCase").
+func LookupToolName(name string) (*Tool, bool) {
+	for _, t := range registry {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Endpoint is an API route that is not a tool: the catalogue, pipelines, the spec and
+// the version and health probes.
+type Endpoint struct {
+	Name     string // operation id, e.g. "ListTools"
+	Method   string
+	Path     string
+	Summary  string
+	Request  interface{} // zero value of the request body type; nil for GET
+	Response interface{} // zero value of the response body type; nil for any JSON
+	Handler  http.HandlerFunc
+}
+
+// Endpoints returns the non-tool API routes.
+func Endpoints() []Endpoint {
+	return []Endpoint{
+		{Name: "ListTools", Method: http.MethodGet, Path: "/api/tools", Summary: "List tool categories and tools",
+			Response: ToolsResponse{}, Handler: ListTools},
+		{Name: "Pipeline", Method: http.MethodPost, Path: "/api/pipeline", Summary: "Run several tools in sequence",
+			Request: PipelineRequest{}, Response: PipelineResponse{}, Handler: Pipeline},
+		{Name: "OpenAPI", Method: http.MethodGet, Path: "/api/openapi.json", Summary: "OpenAPI 3 description of this API",
+			Handler: OpenAPI},
+		{Name: "Version", Method: http.MethodGet, Path: "/api/version", Summary: "Build version, commit and Go version",
+			Response: VersionResponse{}, Handler: Version},
+		{Name: "Healthz", Method: http.MethodGet, Path: "/healthz", Summary: "Liveness probe",
+			Response: HealthResponse{}, Handler: Healthz},
+		{Name: "Readyz", Method: http.MethodGet, Path: "/readyz", Summary: "Readiness probe; 503 while starting or shutting down",
+			Response: HealthResponse{}, Handler: Readyz},
+	}
+}
+
+// ToolInfo is the catalogue entry for one tool returned by GET /api/tools.
+type ToolInfo struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Category    string      `json:"category"`
+	Group       string      `json:"group,omitempty"`
+	Label       string      `json:"label"`
+	Description string      `json:"description"`
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	Input       interface{} `json:"input"`
+	Output      interface{} `json:"output"`
+}
+
+// ToolsResponse is the JSON response for the tools catalogue endpoint.
+type ToolsResponse struct {
+	Categories []Category `json:"categories"`
+	Tools      []ToolInfo `json:"tools"`
+}
+
+// ListTools handles GET /api/tools. Returns the categories and tools so the frontend
+// sidebar and command palette can be built from the backend.
+func ListTools(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	resp := ToolsResponse{Categories: categories, Tools: make([]ToolInfo, 0, len(registry))}
+	for _, t := range registry {
+		resp.Tools = append(resp.Tools, ToolInfo{
+			ID:          t.ID,
+			Name:        t.Name,
+			Category:    t.Category,
+			Group:       t.Group,
+			Label:       t.Label,
+			Description: t.Description,
+			Method:      http.MethodPost,
+			Path:        t.Path,
+			Input:       schemaOf(reflect.TypeOf(t.Request)),
+			Output:      schemaOf(reflect.TypeOf(t.Response)),
+		})
+	}
+	writeJSON(w, resp)
+}
+
+// textResult is implemented by responses whose main output is the text in a result field:
+// StringResponse and richer responses that extend it, such as PathResponse.
+type textResult interface {
+	resultText() string
+}
+
+// ResultText returns the result text of a tool response, so pipelines and the command
+// line can pass it on; ok is false for responses without one (e.g. ValidateResponse).
+func ResultText(resp interface{}) (string, bool) {
+	if r, ok := resp.(textResult); ok {
+		return r.resultText(), true
+	}
+	return "", false
+}
+
+// serve checks the method, reads the body, runs fn and writes either the result or the error.
+func serve(w http.ResponseWriter, r *http.Request, fn Transform) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	resp, err := fn(r.Context(), body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// ndjsonType is the media type of streamed responses: one JSON value per line.
+const ndjsonType = "application/x-ndjson"
+
+// serveStream is serve for a StreamTransform. Each emitted value is written as a line and
+// flushed. Errors before the first line get the usual error response; after that the
+// status is already sent, so the error is written as a final {"error": ...} line.
+func serveStream(w http.ResponseWriter, r *http.Request, fn StreamTransform) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	started := false
+	start := func() {
+		w.Header().Set("Content-Type", ndjsonType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+	err := fn(r.Context(), body, func(v interface{}) error {
+		if !started {
+			start()
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// Writers that cannot flush (ErrNotSupported) still get every line, just later.
+		_ = rc.Flush()
+		return nil
+	})
+	switch {
+	case err == nil && !started:
+		start()
+	case err != nil && !started:
+		writeError(w, err)
+	case err != nil:
+		_ = enc.Encode(ErrorResponse{Error: asAPIError(err)})
+	}
+}
+
+// readBody checks that r is a POST and reads its body, writing the error response and
+// returning false when it cannot.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return nil, false
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, bodyTooLarge(tooLarge.Limit))
+			return nil, false
+		}
+		writeError(w, newError(CodeInvalidRequest, "", "could not read request body"))
+		return nil, false
+	}
+	return body, true
+}
+
+// typedTransform adapts fn to a Transform by decoding the body into Req.
+func typedTransform[Req any, Resp any](fn func(Req) (Resp, error)) Transform {
+	return typedContextTransform(func(_ context.Context, req Req) (Resp, error) {
+		return fn(req)
+	})
+}
+
+// typedContextTransform is typedTransform for transforms that honor the request deadline.
+func typedContextTransform[Req any, Resp any](fn func(context.Context, Req) (Resp, error)) Transform {
+	return func(ctx context.Context, body json.RawMessage) (interface{}, error) {
+		var req Req
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, invalidRequest(body, err)
+		}
+		return fn(ctx, req)
+	}
+}
+
+// stringTransform adapts a string -> string function to a Transform over StringRequest/StringResponse.
+func stringTransform(fn func(string) (string, error)) Transform {
+	return typedTransform(func(req StringRequest) (StringResponse, error) {
+		result, err := fn(req.Value)
+		if err != nil {
+			return StringResponse{}, err
+		}
+		return StringResponse{Result: result}, nil
+	})
+}
+
+// pureString lifts an infallible string function (e.g. toSnake) to the fallible form.
+func pureString(fn func(string) string) func(string) (string, error) {
+	return func(s string) (string, error) {
+		return fn(s), nil
+	}
+}
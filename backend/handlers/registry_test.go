@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistryUnique(t *testing.T) {
+	keys := make(map[string]bool)
+	names := make(map[string]bool)
+	paths := make(map[string]bool)
+	cats := make(map[string]bool)
+	for _, c := range categories {
+		cats[c.ID] = true
+	}
+	for _, tool := range Tools() {
+		if keys[tool.Key()] {
+			t.Errorf("duplicate tool key %q", tool.Key())
+		}
+		if names[tool.Name] {
+			t.Errorf("duplicate tool name %q", tool.Name)
+		}
+		if paths[tool.Path] {
+			t.Errorf("duplicate tool path %q", tool.Path)
+		}
+		if !cats[tool.Category] {
+			t.Errorf("tool %q has unknown category %q", tool.Key(), tool.Category)
+		}
+		if tool.Transform == nil || tool.Request == nil || tool.Response == nil {
+			t.Errorf("tool %q is missing its transform or request/response types", tool.Key())
+		}
+		keys[tool.Key()] = true
+		names[tool.Name] = true
+		paths[tool.Path] = true
+	}
+}
+
+func TestLookupTool(t *testing.T) {
+	tool, ok := LookupTool("string", "snake-case")
+	if !ok || tool.Name != "SnakeCase" {
+		t.Fatalf("LookupTool(string, snake-case) = %v, %v", tool, ok)
+	}
+	if _, ok := LookupTool("json", "snake-case"); ok {
+		t.Errorf("LookupTool(json, snake-case) should not be found")
+	}
+	tool, ok = LookupToolName("FormatJSON")
+	if !ok || tool.Path != "/api/json/format" {
+		t.Fatalf("LookupToolName(FormatJSON) = %v, %v", tool, ok)
+	}
+}
+
+func TestToolServeHTTP(t *testing.T) {
+	tool, _ := LookupTool("string", "snake-case")
+	status, body := runHandler(t, tool.ServeHTTP, "POST", `{"value":"Hello World"}`)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body: %s", status, http.StatusOK, body)
+	}
+	if got := parseResult(t, body); got != "hello_world" {
+		t.Errorf("result = %q, want %q", got, "hello_world")
+	}
+	status, _ = runHandler(t, tool.ServeHTTP, "GET", "")
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestListTools(t *testing.T) {
+	t.Run("method not allowed", func(t *testing.T) {
+		status, _ := runHandler(t, ListTools, "POST", "")
+		if status != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want %d", status, http.StatusMethodNotAllowed)
+		}
+	})
+
+	t.Run("catalogue", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://test/api/tools", nil)
+		rec := httptest.NewRecorder()
+		ListTools(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var resp ToolsResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(resp.Tools) != len(Tools()) {
+			t.Errorf("got %d tools, want %d", len(resp.Tools), len(Tools()))
+		}
+		if len(resp.Categories) == 0 {
+			t.Errorf("expected categories")
+		}
+		if !strings.Contains(rec.Body.String(), `"path":"/api/json/diff"`) {
+			t.Errorf("catalogue should list the diff tool, got %s", rec.Body.String())
+		}
+	})
+}
+
+// frontendRegistry is the TypeScript module the frontend builds its sidebar, routes and
+// command palette from; it must list the same categories and tools as the registry.
+var frontendRegistry = filepath.Join("..", "..", "frontend", "src", "config", "registry.generated.ts")
+
+func TestFrontendRegistryInSync(t *testing.T) {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go test ./handlers -run FrontendRegistry -update from the backend\n")
+	b.WriteString("// tool registry (GET /api/tools). DO NOT EDIT.\n\n")
+	b.WriteString("export const registryCategories = [\n")
+	for _, c := range categories {
+		fmt.Fprintf(&b, "  { id: '%s', label: '%s' },\n", quote(c.ID), quote(c.Label))
+	}
+	b.WriteString("] as const;\n\nexport const registryTools = [\n")
+	for _, tool := range Tools() {
+		fmt.Fprintf(&b, "  { id: '%s', category: '%s', group: '%s', label: '%s' },\n",
+			quote(tool.ID), quote(tool.Category), quote(tool.Group), quote(tool.Label))
+	}
+	b.WriteString("] as const;\n")
+	got := b.Bytes()
+	if *updateGolden {
+		if err := os.WriteFile(frontendRegistry, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(frontendRegistry)
+	if os.IsNotExist(err) {
+		t.Skipf("no frontend checkout at %s", frontendRegistry)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date with the registry; run go test ./handlers -run FrontendRegistry -update", frontendRegistry)
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	got := schemaOf(reflect.TypeOf(PathRequest{}))
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"value":      map[string]interface{}{"type": "string"},
+			"path":       map[string]interface{}{"type": "string"},
+			"syntax":     map[string]interface{}{"type": "string", "enum": []string{"dot", "jsonpath", "pointer"}},
+			"pathFormat": map[string]interface{}{"type": "string", "enum": []string{"normalized", "pointer"}},
+			"lenient":    map[string]interface{}{"type": "boolean"},
+		},
+		"required": []string{"value", "path"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("schemaOf(PathRequest) = %v, want %v", got, want)
+	}
+	lorem := schemaOf(reflect.TypeOf(LoremRequest{}))
+	props := lorem["properties"].(map[string]interface{})
+	opts := props["options"].(map[string]interface{})
+	if opts["type"] != "object" {
+		t.Errorf("options should be an object schema, got %v", opts)
+	}
+}
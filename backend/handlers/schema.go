@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// schemaOf builds a JSON Schema object describing t from its json struct tags, with
+// nested structs inlined. See schemaBuilder for the supported shapes.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	return (&schemaBuilder{}).schema(t)
+}
+
+// schemaBuilder converts Go types to JSON Schema. Only the shapes used by request/response
+// types are supported: structs, strings, numbers, booleans, slices, maps, pointers and
+// interface{} or json.RawMessage (any value). An `enum:"a,b"` struct tag lists the allowed
+// values of a string field.
+//
+// When defs is non-nil, named structs are emitted once into defs and referenced with
+// "$ref": refPrefix+Name (used for OpenAPI components); otherwise they are inlined, and a
+// struct that contains itself is described as a plain object where it recurs.
+type schemaBuilder struct {
+	defs      map[string]interface{}
+	refPrefix string
+	inlining  map[reflect.Type]bool
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	if t == nil || t == rawMessageType {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if b.defs != nil && t.Name() != "" {
+			if _, done := b.defs[t.Name()]; !done {
+				b.defs[t.Name()] = nil // reserve the name so recursive types terminate
+				b.defs[t.Name()] = b.structSchema(t)
+			}
+			return map[string]interface{}{"$ref": b.refPrefix + t.Name()}
+		}
+		if b.inlining[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		if b.inlining == nil {
+			b.inlining = make(map[reflect.Type]bool)
+		}
+		b.inlining[t] = true
+		defer delete(b.inlining, t)
+		return b.structSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes a struct; fields without omitempty are listed as required.
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := JSONFieldName(f)
+		if !ok {
+			continue
+		}
+		s := b.schema(f.Type)
+		if enum := f.Tag.Get("enum"); enum != "" {
+			s["enum"] = strings.Split(enum, ",")
+		}
+		props[name] = s
+		if _, opts, _ := strings.Cut(f.Tag.Get("json"), ","); !slices.Contains(strings.Split(opts, ","), "omitempty") {
+			required = append(required, name)
+		}
+	}
+	out := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+// JSONFieldName returns the JSON property name for f, or false if the field is not
+// encoded. The CLI uses it to map flags onto request fields.
+func JSONFieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
+
+// enumTag returns the allowed values declared by the enum tag on field of struct v, so
+// error details and the OpenAPI document share one list.
+func enumTag(v interface{}, field string) []string {
+	f, ok := reflect.TypeOf(v).FieldByName(field)
+	if !ok || f.Tag.Get("enum") == "" {
+		return nil
+	}
+	return strings.Split(f.Tag.Get("enum"), ",")
+}
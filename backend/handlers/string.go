@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -23,46 +24,36 @@ type StringResponse struct {
 	Result string `json:"result"`
 }
 
+func (r StringResponse) resultText() string { return r.Result }
+
 // SpellOutRequest is the JSON body for the spell-out endpoint.
 type SpellOutRequest struct {
 	Value    string `json:"value"`
-	Alphabet string `json:"alphabet"`
+	Alphabet string `json:"alphabet" enum:"nato"`
 }
 
 // URLEncode encodes the request value for safe use in URL query strings (spaces become +, special chars percent-encoded).
 // Example: "hello world" -> "hello+world".
 func URLEncode(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	result := url.QueryEscape(req.Value)
-	writeJSON(w, StringResponse{Result: result})
+	serve(w, r, stringTransform(urlEncode))
+}
+
+func urlEncode(s string) (string, error) {
+	return url.QueryEscape(s), nil
 }
 
 // URLDecode decodes URL-encoded text back to plain text.
 // Example: "hello+world" -> "hello world".
 func URLDecode(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-	result, err := url.QueryUnescape(req.Value)
+	serve(w, r, stringTransform(urlDecode))
+}
+
+func urlDecode(s string) (string, error) {
+	result, err := url.QueryUnescape(s)
 	if err != nil {
-		http.Error(w, "invalid encoded value", http.StatusBadRequest)
-		return
+		return "", newError(CodeInvalidValue, "value", "invalid encoded value")
 	}
-	writeJSON(w, StringResponse{Result: result})
+	return result, nil
 }
 
 func writeJSON(w http.ResponseWriter, v interface{}) {
@@ -72,6 +63,17 @@ func writeJSON(w http.ResponseWriter, v interface{}) {
 
 var nonWordRe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
 
+// camelBoundaryRe finds the word boundaries inside camelCase and PascalCase text: a
+// lowercase letter or digit followed by a capital ("userId"), and the last capital of a
+// run followed by a lowercase letter ("HTTPServer").
+var camelBoundaryRe = regexp.MustCompile(`([a-z0-9])([A-Z])|([A-Z])([A-Z][a-z])`)
+
+// splitCamel puts a space at each camelCase word boundary in s, so that wordsFrom sees
+// "userId" as two words.
+func splitCamel(s string) string {
+	return camelBoundaryRe.ReplaceAllString(s, "$1$3 $2$4")
+}
+
 // wordsFrom splits s on non-alphanumeric runs and returns non-empty tokens (lowercased for consistency).
 func wordsFrom(s string) []string {
 	parts := nonWordRe.Split(strings.TrimSpace(s), -1)
@@ -107,171 +109,138 @@ func toCamel(s string) string {
 }
 
 func toPascal(s string) string {
-	words := wordsFrom(s)
-	if len(words) == 0 {
-		return ""
-	}
+	return strings.Join(titleWords(wordsFrom(s)), "")
+}
+
+// titleWords capitalizes each word in place, as toPascal does, and returns words.
+func titleWords(words []string) []string {
 	caser := cases.Title(language.English)
 	for i := range words {
 		words[i] = caser.String(words[i])
 	}
-	return strings.Join(words, "")
-}
-
-func decodeBody(w http.ResponseWriter, r *http.Request) (StringRequest, bool) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return StringRequest{}, false
-	}
-	var req StringRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return StringRequest{}, false
-	}
-	return req, true
+	return words
 }
 
 // Base64Encode encodes the request value as Base64.
 // Example: "Hi" -> "SGk=".
 func Base64Encode(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	result := base64.StdEncoding.EncodeToString([]byte(req.Value))
-	writeJSON(w, StringResponse{Result: result})
+	serve(w, r, stringTransform(base64Encode))
+}
+
+func base64Encode(s string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
 }
 
 // Base64Decode decodes Base64 back to plain text.
 // Example: "SGk=" -> "Hi".
 func Base64Decode(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	result, err := base64.StdEncoding.DecodeString(req.Value)
+	serve(w, r, stringTransform(base64Decode))
+}
+
+func base64Decode(s string) (string, error) {
+	result, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
-		http.Error(w, "invalid base64 value", http.StatusBadRequest)
-		return
+		return "", newError(CodeInvalidValue, "value", "invalid base64 value")
 	}
-	writeJSON(w, StringResponse{Result: string(result)})
+	return string(result), nil
 }
 
 // Trim removes leading and trailing whitespace from the request value.
 // Example: "  hello world  " -> "hello world".
 func Trim(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: strings.TrimSpace(req.Value)})
+	serve(w, r, stringTransform(trim))
+}
+
+func trim(s string) (string, error) {
+	return strings.TrimSpace(s), nil
 }
 
 // UpperCase converts all characters in the request value to uppercase.
 // Example: "Hello World" -> "HELLO WORLD".
 func UpperCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: strings.ToUpper(req.Value)})
+	serve(w, r, stringTransform(upperCase))
+}
+
+func upperCase(s string) (string, error) {
+	return strings.ToUpper(s), nil
 }
 
 // LowerCase converts all characters in the request value to lowercase.
 // Example: "Hello World" -> "hello world".
 func LowerCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: strings.ToLower(req.Value)})
+	serve(w, r, stringTransform(lowerCase))
+}
+
+func lowerCase(s string) (string, error) {
+	return strings.ToLower(s), nil
 }
 
 // CapitalCase converts the request value to title case (first letter of each word uppercase).
 // Example: "hello world" -> "Hello World".
 func CapitalCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
+	serve(w, r, stringTransform(capitalCase))
+}
+
+func capitalCase(s string) (string, error) {
 	caser := cases.Title(language.English)
-	writeJSON(w, StringResponse{Result: caser.String(req.Value)})
+	return caser.String(s), nil
 }
 
 // SnakeCase converts words in the request value to snake_case (lowercase with underscores).
 // Example: "hello world" -> "hello_world".
 func SnakeCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: toSnake(req.Value)})
+	serve(w, r, stringTransform(pureString(toSnake)))
 }
 
 // KebabCase converts words in the request value to kebab-case (lowercase with hyphens).
 // Example: "hello world" -> "hello-world".
 func KebabCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: toKebab(req.Value)})
+	serve(w, r, stringTransform(pureString(toKebab)))
 }
 
 // CamelCase converts words in the request value to camelCase (first word lowercase, rest capitalized).
 // Example: "hello world" -> "helloWorld".
 func CamelCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: toCamel(req.Value)})
+	serve(w, r, stringTransform(pureString(toCamel)))
 }
 
 // PascalCase converts words in the request value to PascalCase (each word capitalized).
 // Example: "hello world" -> "HelloWorld".
 func PascalCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	writeJSON(w, StringResponse{Result: toPascal(req.Value)})
+	serve(w, r, stringTransform(pureString(toPascal)))
 }
 
 // SentenceCase converts the request value to sentence case (first character uppercase, rest lowercase).
 // Example: "HELLO WORLD" -> "Hello world".
 func SentenceCase(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	s := req.Value
+	serve(w, r, stringTransform(sentenceCase))
+}
+
+func sentenceCase(s string) (string, error) {
 	if s == "" {
-		writeJSON(w, StringResponse{Result: ""})
-		return
+		return "", nil
 	}
 	runes := []rune(s)
 	runes[0] = unicode.ToUpper(runes[0])
 	for i := 1; i < len(runes); i++ {
 		runes[i] = unicode.ToLower(runes[i])
 	}
-	writeJSON(w, StringResponse{Result: string(runes)})
+	return string(runes), nil
 }
 
 // ParseURLParams parses query parameters from a full URL or raw query string and returns them as JSON.
 // Input can be e.g. "https://example.com?foo=bar&baz=qux" or "foo=bar&baz=qux".
 func ParseURLParams(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	raw := strings.TrimSpace(req.Value)
+	serve(w, r, stringTransform(parseURLParams))
+}
+
+func parseURLParams(s string) (string, error) {
+	raw := strings.TrimSpace(s)
 	var query string
 	if strings.Contains(raw, "?") {
 		u, err := url.Parse(raw)
 		if err != nil {
-			http.Error(w, "invalid URL", http.StatusBadRequest)
-			return
+			return "", newError(CodeInvalidValue, "value", "invalid URL")
 		}
 		query = u.RawQuery
 	} else {
@@ -283,40 +252,35 @@ func ParseURLParams(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if query == "" {
-		writeJSON(w, StringResponse{Result: "{}"})
-		return
+		return "{}", nil
 	}
 	vals, err := url.ParseQuery(query)
 	if err != nil {
-		http.Error(w, "invalid query string", http.StatusBadRequest)
-		return
+		return "", newError(CodeInvalidValue, "value", "invalid query string")
 	}
 	// url.Values is map[string][]string; marshal as JSON for readable output
 	out, err := json.Marshal(vals)
 	if err != nil {
-		http.Error(w, "failed to format result", http.StatusInternalServerError)
-		return
+		return "", fmt.Errorf("failed to format result: %w", err)
 	}
-	writeJSON(w, StringResponse{Result: string(out)})
+	return string(out), nil
 }
 
 // CreateURLWithParams builds a URL from a base URL (first line) and key=value params (remaining lines).
 // Empty lines are ignored. Supports key=value or key: value.
 func CreateURLWithParams(w http.ResponseWriter, r *http.Request) {
-	req, ok := decodeBody(w, r)
-	if !ok {
-		return
-	}
-	lines := strings.Split(req.Value, "\n")
+	serve(w, r, stringTransform(createURLWithParams))
+}
+
+func createURLWithParams(s string) (string, error) {
+	lines := strings.Split(s, "\n")
 	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
-		http.Error(w, "base URL required (first line)", http.StatusBadRequest)
-		return
+		return "", newError(CodeRequired, "value", "base URL required (first line)")
 	}
 	baseRaw := strings.TrimSpace(lines[0])
 	u, err := url.Parse(baseRaw)
 	if err != nil {
-		http.Error(w, "invalid base URL", http.StatusBadRequest)
-		return
+		return "", newError(CodeInvalidValue, "value", "invalid base URL")
 	}
 	u.RawQuery = "" // strip existing query
 	vals := make(url.Values)
@@ -338,7 +302,7 @@ func CreateURLWithParams(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	u.RawQuery = vals.Encode()
-	writeJSON(w, StringResponse{Result: u.String()})
+	return u.String(), nil
 }
 
 // natoPhonetic maps A–Z (uppercase rune) to NATO phonetic words.
@@ -367,15 +331,10 @@ func spellOutLetters(s string, letterToWord map[rune]string) string {
 // SpellOut converts the request value to "X for Word" spelling using the chosen alphabet (e.g. nato).
 // Example: "AB" -> "A for Alpha, B for Bravo".
 func SpellOut(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	var req SpellOutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
+	serve(w, r, typedTransform(spellOut))
+}
+
+func spellOut(req SpellOutRequest) (StringResponse, error) {
 	alphabet := req.Alphabet
 	if alphabet == "" {
 		alphabet = "nato"
@@ -385,9 +344,7 @@ func SpellOut(w http.ResponseWriter, r *http.Request) {
 	case "nato":
 		letterToWord = natoPhonetic
 	default:
-		http.Error(w, "unknown alphabet", http.StatusBadRequest)
-		return
+		return StringResponse{}, invalidOption("alphabet", "unknown alphabet", enumTag(SpellOutRequest{}, "Alphabet")...)
 	}
-	result := spellOutLetters(req.Value, letterToWord)
-	writeJSON(w, StringResponse{Result: result})
+	return StringResponse{Result: spellOutLetters(req.Value, letterToWord)}, nil
 }
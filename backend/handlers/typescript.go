@@ -0,0 +1,566 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// This file generates TypeScript interfaces, and optionally Zod schemas, from sample
+// JSON or from a JSON Schema. Both are first turned into a tree of tsShapes.
+
+// TypeScriptRequest is the JSON body for the TypeScript endpoint. Value holds sample
+// documents (read as for InferSchemaRequest) or, with Source "schema", one JSON Schema
+// such as infer-schema returns. With Source "auto" (the default) a single object with a
+// $schema keyword is read as a schema. Name is the root type (default "Root"); Zod adds
+// a Zod schema for every interface.
+type TypeScriptRequest struct {
+	Value    string `json:"value"`
+	Source   string `json:"source,omitempty" enum:"auto,sample,schema"`
+	Name     string `json:"name,omitempty"`
+	EachItem bool   `json:"eachItem,omitempty"`
+	Zod      bool   `json:"zod,omitempty"`
+}
+
+// TypeScriptJSON generates TypeScript interfaces (and Zod schemas) from JSON.
+func TypeScriptJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(typeScriptJSON))
+}
+
+func typeScriptJSON(ctx context.Context, req TypeScriptRequest) (StringResponse, error) {
+	name := "Root"
+	if strings.TrimSpace(req.Name) != "" {
+		if name = toPascal(splitCamel(req.Name)); !tsIdentRe.MatchString(name) {
+			return StringResponse{}, newError(CodeInvalidValue, "name", fmt.Sprintf("cannot make a TypeScript type name from %q", req.Name))
+		}
+	}
+	source := req.Source
+	switch source {
+	case "", "auto", "sample", "schema":
+	default:
+		return StringResponse{}, invalidOption("source", fmt.Sprintf("unknown source %q", req.Source), enumTag(req, "Source")...)
+	}
+	docs, err := decodeJQInputs("value", req.Value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	if source == "" || source == "auto" {
+		source = "sample"
+		if len(docs) == 1 {
+			if obj, ok := docs[0].(*jqObject); ok {
+				if _, ok := obj.get("$schema"); ok {
+					source = "schema"
+				}
+			}
+		}
+	}
+
+	var root *tsShape
+	if source == "schema" {
+		if len(docs) != 1 {
+			return StringResponse{}, newError(CodeInvalidValue, "value", fmt.Sprintf("value must hold exactly one schema, found %d documents", len(docs)))
+		}
+		sc := &tsSchemaReader{root: docs[0], refs: make(map[string]*tsShape)}
+		if root, err = sc.ref(""); err != nil {
+			return StringResponse{}, err
+		}
+	} else {
+		inf := &inferrer{ctx: ctx, enumMax: -1}
+		n, err := inf.samples(req.Value, req.EachItem)
+		if err != nil {
+			return StringResponse{}, err
+		}
+		root = tsFromSample(n)
+	}
+
+	g := &tsGen{names: make(typeNames)}
+	var b strings.Builder
+	if req.Zod {
+		b.WriteString("import { z } from \"zod\";\n\n")
+	}
+	if root.isObject() {
+		g.declare(root, name, "")
+	} else {
+		g.names[name] = true
+		fmt.Fprintf(&b, "export type %s = %s;\n\n", name, g.tsType(root, name, ""))
+	}
+	for i := 0; i < len(g.decls); i++ {
+		g.writeInterface(&b, g.decls[i])
+	}
+	if req.Zod {
+		g.writeZod(&b, root, name)
+	}
+	return StringResponse{Result: strings.TrimSuffix(b.String(), "\n")}, nil
+}
+
+// tsShape describes the values at one location: the JSON types it may have (none means
+// any value) and their details. literals, when set, are the only values allowed;
+// variants and allOf combine other shapes as a union and an intersection.
+type tsShape struct {
+	kinds    []string // "object", "array", "string", "integer", "number", "boolean", "null"
+	props    []tsProp
+	items    *tsShape // nil: elements of any type
+	literals []interface{}
+	format   string
+	variants []*tsShape
+	allOf    []*tsShape
+	never    bool   // the false schema
+	hint     string // preferred interface name, e.g. from a $defs key
+	name     string // interface name, once declared
+}
+
+type tsProp struct {
+	key      string
+	shape    *tsShape
+	optional bool
+}
+
+// isObject reports whether s is a plain object shape, which becomes an interface.
+func (s *tsShape) isObject() bool {
+	return len(s.kinds) == 1 && s.kinds[0] == "object" && s.literals == nil && s.variants == nil && s.allOf == nil
+}
+
+// tsFromSample converts an inferred sample tree. Integers are plain numbers here: a
+// sample cannot tell that a field only ever holds integers.
+func tsFromSample(n *inferNode) *tsShape {
+	s := &tsShape{}
+	for _, t := range inferTypes {
+		if !n.types[t] || (t == "integer" && n.types["number"]) {
+			continue
+		}
+		if t == "integer" {
+			t = "number"
+		}
+		s.kinds = append(s.kinds, t)
+	}
+	for _, k := range n.propOrder {
+		p := n.props[k]
+		s.props = append(s.props, tsProp{key: k, shape: tsFromSample(p), optional: p.count < n.objects})
+	}
+	if n.items != nil && n.items.count > 0 {
+		s.items = tsFromSample(n.items)
+	}
+	return s
+}
+
+// tsSchemaReader converts a JSON Schema (decoded with key order) to shapes. $ref may
+// point anywhere in the same document; every $ref to one location shares its shape, so
+// a recursive schema becomes a recursive type.
+type tsSchemaReader struct {
+	root interface{}
+	refs map[string]*tsShape // by schema pointer
+}
+
+// ref returns the shared shape of the schema at pointer sptr.
+func (sc *tsSchemaReader) ref(sptr string) (*tsShape, error) {
+	if s, ok := sc.refs[sptr]; ok {
+		return s, nil
+	}
+	tokens, _ := parsePointer(sptr)
+	sub, ok := tsPointerGet(sc.root, tokens)
+	if !ok {
+		return nil, nil
+	}
+	s := &tsShape{}
+	sc.refs[sptr] = s
+	built, err := sc.shape(sub, sptr)
+	if err != nil {
+		return nil, err
+	}
+	*s = *built
+	if len(tokens) >= 2 && (tokens[len(tokens)-2] == "$defs" || tokens[len(tokens)-2] == "definitions") {
+		s.hint = toPascal(splitCamel(tokens[len(tokens)-1]))
+	}
+	return s, nil
+}
+
+func (sc *tsSchemaReader) shape(v interface{}, sptr string) (*tsShape, error) {
+	if b, ok := v.(bool); ok {
+		return &tsShape{never: !b}, nil
+	}
+	m, ok := v.(*jqObject)
+	if !ok {
+		return nil, invalidSchema(sptr, "a schema must be an object or a boolean")
+	}
+	if ref, ok := m.get("$ref"); ok {
+		target, ok := ref.(string)
+		if !ok || !strings.HasPrefix(target, "#") {
+			return nil, invalidSchema(pointerJoin(sptr, "$ref"), fmt.Sprintf("only references within the schema are supported, got %s", jqDump(ref)))
+		}
+		tokens, err := parsePointer(target)
+		if err != nil {
+			return nil, invalidSchema(pointerJoin(sptr, "$ref"), fmt.Sprintf("invalid $ref %q: %v", target, err))
+		}
+		s, err := sc.ref(formatPointer(tokens))
+		if err == nil && s == nil {
+			err = invalidSchema(pointerJoin(sptr, "$ref"), fmt.Sprintf("cannot resolve $ref %q", target))
+		}
+		return s, err
+	}
+
+	s := &tsShape{}
+	switch t := m.vals["type"].(type) {
+	case string:
+		s.kinds = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			if k, ok := e.(string); ok {
+				s.kinds = append(s.kinds, k)
+			}
+		}
+	}
+	if c, ok := m.get("const"); ok {
+		s.literals = []interface{}{c}
+	} else if e, ok := m.vals["enum"].([]interface{}); ok {
+		s.literals = e
+	}
+	if f, ok := m.vals["format"].(string); ok {
+		s.format = f
+	}
+
+	if props, ok := m.vals["properties"].(*jqObject); ok {
+		if len(s.kinds) == 0 {
+			s.kinds = []string{"object"}
+		}
+		required := make(map[string]bool)
+		if req, ok := m.vals["required"].([]interface{}); ok {
+			for _, r := range req {
+				if k, ok := r.(string); ok {
+					required[k] = true
+				}
+			}
+		}
+		for _, k := range props.keys {
+			ps, err := sc.shape(props.vals[k], pointerJoin(pointerJoin(sptr, "properties"), k))
+			if err != nil {
+				return nil, err
+			}
+			s.props = append(s.props, tsProp{key: k, shape: ps, optional: !required[k]})
+		}
+	}
+	if items, ok := m.get("items"); ok {
+		if len(s.kinds) == 0 {
+			s.kinds = []string{"array"}
+		}
+		if _, isList := items.([]interface{}); !isList {
+			is, err := sc.shape(items, pointerJoin(sptr, "items"))
+			if err != nil {
+				return nil, err
+			}
+			s.items = is
+		}
+	}
+	for _, kw := range []string{"anyOf", "oneOf", "allOf"} {
+		list, ok := m.vals[kw].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, sub := range list {
+			vs, err := sc.shape(sub, fmt.Sprintf("%s/%d", pointerJoin(sptr, kw), i))
+			if err != nil {
+				return nil, err
+			}
+			if kw == "allOf" {
+				s.allOf = append(s.allOf, vs)
+			} else {
+				s.variants = append(s.variants, vs)
+			}
+		}
+	}
+	return s, nil
+}
+
+// tsPointerGet resolves pointer tokens in a document decoded with key order.
+func tsPointerGet(v interface{}, tokens []string) (interface{}, bool) {
+	for _, tok := range tokens {
+		switch x := v.(type) {
+		case *jqObject:
+			var ok bool
+			if v, ok = x.get(tok); !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, ok := pointerIndex(tok)
+			if !ok || i >= len(x) {
+				return nil, false
+			}
+			v = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// tsIdentRe matches property names that need no quotes, and valid type names.
+var tsIdentRe = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func tsKey(key string) string {
+	if tsIdentRe.MatchString(key) {
+		return key
+	}
+	out, _ := json.Marshal(key)
+	return string(out)
+}
+
+// tsGen collects the interfaces of one generated file.
+type tsGen struct {
+	decls []*tsShape
+	names typeNames
+
+	zodDone map[*tsShape]bool // interfaces whose Zod schema is written
+	lazy    bool              // the Zod schema being written refers to a later one
+}
+
+func (g *tsGen) declare(s *tsShape, hint, parent string) string {
+	if s.hint != "" {
+		hint, parent = s.hint, ""
+	}
+	if hint != "" && !tsIdentRe.MatchString(hint) {
+		hint = "Type" + hint // e.g. a key starting with a digit
+	}
+	s.name = g.names.claim(hint, parent)
+	g.decls = append(g.decls, s)
+	return s.name
+}
+
+// tsType returns the TypeScript type of s, declaring interfaces for object shapes. hint
+// names an interface and parent is the enclosing one.
+func (g *tsGen) tsType(s *tsShape, hint, parent string) string {
+	switch {
+	case s.never:
+		return "never"
+	case s.literals != nil:
+		var parts []string
+		for _, l := range s.literals {
+			parts = append(parts, jqDump(l))
+		}
+		return tsUnion(parts)
+	case s.variants != nil || s.allOf != nil:
+		var parts []string
+		for _, v := range s.variants {
+			parts = append(parts, g.tsType(v, hint, parent))
+		}
+		union := tsUnion(parts)
+		if s.allOf == nil {
+			return union
+		}
+		inter := make([]string, 0, len(s.allOf)+1)
+		if union != "" {
+			inter = append(inter, tsParen(union))
+		}
+		for _, a := range s.allOf {
+			inter = append(inter, tsParen(g.tsType(a, hint, parent)))
+		}
+		return strings.Join(inter, " & ")
+	case len(s.kinds) == 0:
+		return "unknown"
+	}
+	var parts []string
+	for _, k := range s.kinds {
+		switch k {
+		case "object":
+			switch {
+			case s.name != "":
+				parts = append(parts, s.name)
+			case len(s.props) > 0:
+				parts = append(parts, g.declare(s, hint, parent))
+			default:
+				parts = append(parts, "Record<string, unknown>")
+			}
+		case "array":
+			elem := "unknown"
+			if s.items != nil {
+				elem = g.tsType(s.items, singularName(hint), parent)
+			}
+			parts = append(parts, tsParen(elem)+"[]")
+		case "integer", "number":
+			parts = append(parts, "number")
+		default:
+			parts = append(parts, k)
+		}
+	}
+	return tsUnion(parts)
+}
+
+// tsUnion joins distinct types with |.
+func tsUnion(parts []string) string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, " | ")
+}
+
+// tsParen wraps a union or intersection in parentheses, for use before [] or &.
+func tsParen(t string) string {
+	if strings.Contains(t, " | ") || strings.Contains(t, " & ") {
+		return "(" + t + ")"
+	}
+	return t
+}
+
+func (g *tsGen) writeInterface(b *strings.Builder, s *tsShape) {
+	fmt.Fprintf(b, "export interface %s {\n", s.name)
+	for _, p := range s.props {
+		opt := ""
+		if p.optional {
+			opt = "?"
+		}
+		fmt.Fprintf(b, "  %s%s: %s;\n", tsKey(p.key), opt, g.tsType(p.shape, toPascal(splitCamel(p.key)), s.name))
+	}
+	b.WriteString("}\n\n")
+}
+
+// writeZod writes a Zod schema named <Interface>Schema for every interface, dependencies
+// first, and one for the root when it is not an interface. References to a schema that
+// is not written yet (in recursive types) use z.lazy, and the referring schema is
+// annotated with its interface, which TypeScript needs to type a recursive constant.
+func (g *tsGen) writeZod(b *strings.Builder, root *tsShape, name string) {
+	g.zodDone = make(map[*tsShape]bool)
+	for i := len(g.decls) - 1; i >= 0; i-- {
+		s := g.decls[i]
+		g.lazy = false
+		obj := g.zodObject(s)
+		if g.lazy {
+			fmt.Fprintf(b, "export const %sSchema: z.ZodType<%s> = %s;\n\n", s.name, s.name, obj)
+		} else {
+			fmt.Fprintf(b, "export const %sSchema = %s;\n\n", s.name, obj)
+		}
+		g.zodDone[s] = true
+	}
+	if !root.isObject() {
+		fmt.Fprintf(b, "export const %sSchema = %s;\n\n", name, g.zod(root))
+	}
+}
+
+func (g *tsGen) zodObject(s *tsShape) string {
+	if len(s.props) == 0 {
+		return "z.object({})"
+	}
+	var b strings.Builder
+	b.WriteString("z.object({\n")
+	for _, p := range s.props {
+		v := g.zod(p.shape)
+		if p.optional {
+			v += ".optional()"
+		}
+		fmt.Fprintf(&b, "  %s: %s,\n", tsKey(p.key), v)
+	}
+	b.WriteString("})")
+	return b.String()
+}
+
+// zod returns the Zod schema expression for s. Object shapes refer to the schema
+// constant of their interface.
+func (g *tsGen) zod(s *tsShape) string {
+	switch {
+	case s.never:
+		return "z.never()"
+	case s.literals != nil:
+		allStrings := true
+		var parts []string
+		for _, l := range s.literals {
+			_, isString := l.(string)
+			allStrings = allStrings && isString
+			parts = append(parts, jqDump(l))
+		}
+		if allStrings {
+			return "z.enum([" + strings.Join(parts, ", ") + "])"
+		}
+		for i, p := range parts {
+			parts[i] = "z.literal(" + p + ")"
+		}
+		return zodUnion(parts)
+	case s.variants != nil || s.allOf != nil:
+		var parts []string
+		for _, v := range s.variants {
+			parts = append(parts, g.zod(v))
+		}
+		out := zodUnion(parts)
+		for _, a := range s.allOf {
+			if out == "" {
+				out = g.zod(a)
+			} else {
+				out += ".and(" + g.zod(a) + ")"
+			}
+		}
+		return out
+	case len(s.kinds) == 0:
+		return "z.unknown()"
+	}
+	var parts []string
+	nullable := false
+	for _, k := range s.kinds {
+		switch k {
+		case "object":
+			switch {
+			case s.name != "" && g.zodDone[s]:
+				parts = append(parts, s.name+"Schema")
+			case s.name != "":
+				g.lazy = true
+				parts = append(parts, "z.lazy(() => "+s.name+"Schema)")
+			default:
+				parts = append(parts, "z.record(z.unknown())")
+			}
+		case "array":
+			elem := "z.unknown()"
+			if s.items != nil {
+				elem = g.zod(s.items)
+			}
+			parts = append(parts, "z.array("+elem+")")
+		case "string":
+			parts = append(parts, "z.string()"+zodFormats[s.format])
+		case "integer":
+			parts = append(parts, "z.number().int()")
+		case "number":
+			parts = append(parts, "z.number()")
+		case "boolean":
+			parts = append(parts, "z.boolean()")
+		case "null":
+			nullable = true
+		}
+	}
+	if len(parts) == 0 {
+		return "z.null()"
+	}
+	out := zodUnion(parts)
+	if nullable {
+		out += ".nullable()"
+	}
+	return out
+}
+
+// zodFormats maps JSON Schema formats to Zod string checks.
+var zodFormats = map[string]string{
+	"date-time": ".datetime({ offset: true })",
+	"date":      ".date()",
+	"time":      ".time()",
+	"email":     ".email()",
+	"uri":       ".url()",
+	"uuid":      ".uuid()",
+	"ipv4":      ".ip({ version: \"v4\" })",
+	"ipv6":      ".ip({ version: \"v6\" })",
+}
+
+func zodUnion(parts []string) string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	if len(out) == 1 {
+		return out[0]
+	}
+	return "z.union([" + strings.Join(out, ", ") + "])"
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTypeScriptJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		req  TypeScriptRequest
+		want string
+	}{
+		{"samples", TypeScriptRequest{Value: `
+{"id": 1, "userName": "ann", "note": null, "tags": ["a"], "lineItems": [{"sku": "a", "qty": 1}, {"sku": "b"}], "mixed": [1, "x"], "shipping-address": {"zip": "1000"}}
+{"id": 2, "userName": "bob", "note": "late", "tags": [], "lineItems": [], "mixed": [], "shipping-address": {"zip": "2000"}, "extra": true}`}, `export interface Root {
+  id: number;
+  userName: string;
+  note: string | null;
+  tags: string[];
+  lineItems: LineItem[];
+  mixed: (string | number)[];
+  "shipping-address": ShippingAddress;
+  extra?: boolean;
+}
+
+export interface LineItem {
+  sku: string;
+  qty?: number;
+}
+
+export interface ShippingAddress {
+  zip: string;
+}
+`},
+		{"zod", TypeScriptRequest{Value: `[{"id": 1, "tags": ["a"], "owner": {"name": "ann"}}, {"id": 2.5, "owner": null}]`, Name: "item list", Zod: true}, `import { z } from "zod";
+
+export type ItemList = ItemListItem[];
+
+export interface ItemListItem {
+  id: number;
+  tags?: string[];
+  owner: Owner | null;
+}
+
+export interface Owner {
+  name: string;
+}
+
+export const OwnerSchema = z.object({
+  name: z.string(),
+});
+
+export const ItemListItemSchema = z.object({
+  id: z.number(),
+  tags: z.array(z.string()).optional(),
+  owner: OwnerSchema.nullable(),
+});
+
+export const ItemListSchema = z.array(ItemListItemSchema);
+`},
+		{"inferred schema", TypeScriptRequest{Value: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "status": {"type": "string", "enum": ["open", "closed"]},
+    "at": {"type": "string", "format": "date-time"},
+    "count": {"type": "integer"},
+    "value": {"anyOf": [{"type": "string"}, {"type": "number"}]}
+  },
+  "required": ["status", "at"]
+}`, Zod: true}, `import { z } from "zod";
+
+export interface Root {
+  status: "open" | "closed";
+  at: string;
+  count?: number;
+  value?: string | number;
+}
+
+export const RootSchema = z.object({
+  status: z.enum(["open", "closed"]),
+  at: z.string().datetime({ offset: true }),
+  count: z.number().int().optional(),
+  value: z.union([z.string(), z.number()]).optional(),
+});
+`},
+		{"recursive schema", TypeScriptRequest{Value: `{
+  "$defs": {"node": {"type": "object", "properties": {"name": {"type": "string"}, "children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}, "required": ["name"]}},
+  "type": "object",
+  "properties": {"tree": {"$ref": "#/$defs/node"}}
+}`, Source: "schema", Zod: true}, `import { z } from "zod";
+
+export interface Root {
+  tree?: Node;
+}
+
+export interface Node {
+  name: string;
+  children?: Node[];
+}
+
+export const NodeSchema: z.ZodType<Node> = z.object({
+  name: z.string(),
+  children: z.array(z.lazy(() => NodeSchema)).optional(),
+});
+
+export const RootSchema = z.object({
+  tree: NodeSchema.optional(),
+});
+`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := typeScriptJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestTypeScriptJSONErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		req   TypeScriptRequest
+		code  string
+		field string
+	}{
+		{"no samples", TypeScriptRequest{Value: " "}, CodeRequired, "value"},
+		{"unknown source", TypeScriptRequest{Value: `{}`, Source: "yaml"}, CodeInvalidOption, "source"},
+		{"bad name", TypeScriptRequest{Value: `{}`, Name: "1x"}, CodeInvalidValue, "name"},
+		{"several schemas", TypeScriptRequest{Value: `{} {}`, Source: "schema"}, CodeInvalidValue, "value"},
+		{"remote ref", TypeScriptRequest{Value: `{"$ref": "other.json"}`, Source: "schema"}, CodeInvalidValue, "schema"},
+		{"missing ref", TypeScriptRequest{Value: `{"$ref": "#/$defs/x"}`, Source: "schema"}, CodeInvalidValue, "schema"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := typeScriptJSON(context.Background(), tc.req)
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if ae.Code != tc.code || ae.Field != tc.field {
+				t.Errorf("code = %s on %q, want %s on %q", ae.Code, ae.Field, tc.code, tc.field)
+			}
+		})
+	}
+}
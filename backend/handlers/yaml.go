@@ -0,0 +1,665 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the YAML tools. Parsing is left to yaml.v3, which keeps the node
+// tree with its styles, comments and anchors; turning nodes into JSON values is done
+// here so that plain scalars resolve under the YAML 1.2 core schema or the YAML 1.1
+// types (yes/no/on/off booleans, 0755 octals, 1:30 sexagesimals) as asked, mapping keys
+// keep their order and integers keep every digit.
+
+// YAMLToJSONRequest is the JSON body for the to-json endpoint. Version picks how plain
+// scalars are typed ("1.2" when empty). Documents says how a stream is written: "auto"
+// (the default) gives the value of a single document and an array for several, "array"
+// always gives an array and "ndjson" gives one compact line per document.
+type YAMLToJSONRequest struct {
+	Value     string `json:"value"`
+	Version   string `json:"version,omitempty" enum:"1.2,1.1"`
+	Documents string `json:"documents,omitempty" enum:"auto,array,ndjson"`
+}
+
+// YAMLToJSONResponse is the JSON response for the to-json endpoint. Documents is the
+// number of documents in the stream.
+type YAMLToJSONResponse struct {
+	Result    string        `json:"result"`
+	Documents int           `json:"documents"`
+	Warnings  []YAMLWarning `json:"warnings,omitempty"`
+}
+
+func (r YAMLToJSONResponse) resultText() string { return r.Result }
+
+// YAMLWarning is something in a YAML document that converts but that other parsers may
+// read differently: a plain scalar typed differently by YAML 1.1 and 1.2, an infinity or
+// NaN (written as null) or a custom tag that was dropped. Path is the JSON Pointer of
+// the value within its document.
+type YAMLWarning struct {
+	Code    string `json:"code" enum:"version_difference,non_finite,custom_tag"`
+	Message string `json:"message"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+// JSONToYAMLRequest is the JSON body for the from-json endpoint. Indent is the number of
+// spaces per level (2 when zero). Stream writes each element of a top-level array as its
+// own document. Lenient accepts JSON5 and JSONC input.
+type JSONToYAMLRequest struct {
+	Value   string `json:"value"`
+	Indent  int    `json:"indent,omitempty"`
+	Stream  bool   `json:"stream,omitempty"`
+	Lenient bool   `json:"lenient,omitempty"`
+}
+
+// FormatYAMLRequest is the JSON body for the YAML format endpoint. Indent is the number
+// of spaces per level (2 when zero).
+type FormatYAMLRequest struct {
+	Value  string `json:"value"`
+	Indent int    `json:"indent,omitempty"`
+}
+
+// ValidateYAMLRequest is the JSON body for the YAML validate endpoint.
+type ValidateYAMLRequest struct {
+	Value   string `json:"value"`
+	Version string `json:"version,omitempty" enum:"1.2,1.1"`
+}
+
+// YAMLValidateResponse is the JSON response for the YAML validate endpoint. Line and
+// Column locate Error (Column is 0 when the parser does not report one).
+type YAMLValidateResponse struct {
+	Valid     bool          `json:"valid"`
+	Error     string        `json:"error,omitempty"`
+	Line      int           `json:"line,omitempty"`
+	Column    int           `json:"column,omitempty"`
+	Documents int           `json:"documents"`
+	Warnings  []YAMLWarning `json:"warnings,omitempty"`
+}
+
+// YAMLToJSON converts a YAML stream to JSON.
+func YAMLToJSON(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(yamlToJSON))
+}
+
+func yamlToJSON(ctx context.Context, req YAMLToJSONRequest) (YAMLToJSONResponse, error) {
+	switch req.Documents {
+	case "", "auto", "array", "ndjson":
+	default:
+		return YAMLToJSONResponse{}, invalidOption("documents", fmt.Sprintf("unknown documents mode %q", req.Documents), enumTag(req, "Documents")...)
+	}
+	yaml11, err := yamlVersion(req.Version, enumTag(req, "Version"))
+	if err != nil {
+		return YAMLToJSONResponse{}, err
+	}
+	docs, warnings, err := convertYAML(ctx, "value", req.Value, yaml11)
+	if err != nil {
+		return YAMLToJSONResponse{}, err
+	}
+	resp := YAMLToJSONResponse{Documents: len(docs), Warnings: warnings}
+	ev := &jqEval{}
+	switch {
+	case req.Documents == "ndjson":
+		lines := make([]string, len(docs))
+		for i, d := range docs {
+			if lines[i], err = ev.encodeString(d, ""); err != nil {
+				return YAMLToJSONResponse{}, err
+			}
+		}
+		resp.Result = strings.Join(lines, "\n")
+		return resp, nil
+	case req.Documents == "array" || len(docs) > 1:
+		resp.Result, err = ev.encodeString(docs, "  ")
+	case len(docs) == 1:
+		resp.Result, err = ev.encodeString(docs[0], "  ")
+	default:
+		resp.Result = "null"
+	}
+	return resp, err
+}
+
+// JSONToYAML converts JSON to YAML, keeping key order and number literals.
+func JSONToYAML(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(jsonToYAML))
+}
+
+func jsonToYAML(req JSONToYAMLRequest) (StringResponse, error) {
+	indent, err := yamlIndent(req.Indent)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	value, err := strictJSON("value", req.Value, req.Lenient)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	v, err := decodeOrderedValue("value", value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	docs := []interface{}{v}
+	if arr, ok := v.([]interface{}); ok && req.Stream {
+		docs = arr
+	}
+	nodes := make([]*yaml.Node, len(docs))
+	for i, d := range docs {
+		nodes[i] = yamlNodeOf(d)
+	}
+	out, err := encodeYAML(nodes, indent)
+	return StringResponse{Result: out}, err
+}
+
+// FormatYAML re-indents a YAML stream, keeping comments, anchors, tags and quoting.
+func FormatYAML(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedTransform(formatYAML))
+}
+
+func formatYAML(req FormatYAMLRequest) (StringResponse, error) {
+	indent, err := yamlIndent(req.Indent)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	docs, err := parseYAMLStream("value", req.Value)
+	if err != nil {
+		return StringResponse{}, err
+	}
+	out, err := encodeYAML(docs, indent)
+	return StringResponse{Result: out}, err
+}
+
+// ValidateYAML checks that a YAML stream parses and converts to JSON, reporting the
+// line of the first problem and any warnings.
+func ValidateYAML(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, typedContextTransform(validateYAML))
+}
+
+func validateYAML(ctx context.Context, req ValidateYAMLRequest) (YAMLValidateResponse, error) {
+	yaml11, err := yamlVersion(req.Version, enumTag(req, "Version"))
+	if err != nil {
+		return YAMLValidateResponse{}, err
+	}
+	docs, warnings, err := convertYAML(ctx, "value", req.Value, yaml11)
+	var ae *APIError
+	if errors.As(err, &ae) && ae.Code == CodeInvalidYAML {
+		resp := YAMLValidateResponse{Error: strings.TrimPrefix(ae.Message, "invalid YAML: ")}
+		resp.Line, _ = ae.Details["line"].(int)
+		resp.Column, _ = ae.Details["column"].(int)
+		return resp, nil
+	}
+	if err != nil {
+		return YAMLValidateResponse{}, err
+	}
+	return YAMLValidateResponse{Valid: true, Documents: len(docs), Warnings: warnings}, nil
+}
+
+// yamlVersion reports whether version asks for YAML 1.1 typing.
+func yamlVersion(version string, allowed []string) (bool, error) {
+	switch version {
+	case "", "1.2":
+		return false, nil
+	case "1.1":
+		return true, nil
+	}
+	return false, invalidOption("version", fmt.Sprintf("unknown YAML version %q", version), allowed...)
+}
+
+// yamlIndent checks an indent option; yaml.v3 cannot indent by less than 2 spaces.
+func yamlIndent(indent int) (int, error) {
+	return formatIndent(indent, 2)
+}
+
+// parseYAMLStream parses every document in the YAML text in field.
+func parseYAMLStream(field, s string) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(s))
+	var docs []*yaml.Node
+	for {
+		n := new(yaml.Node)
+		err := dec.Decode(n)
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, yamlSyntaxError(field, s, err)
+		}
+		docs = append(docs, n)
+	}
+}
+
+var (
+	yamlErrorLine     = regexp.MustCompile(`^yaml: line (\d+): `)
+	yamlUnknownAnchor = regexp.MustCompile(`^yaml: unknown anchor '(.*)' referenced$`)
+)
+
+// yamlSyntaxError turns a yaml.v3 parse error into an invalid_yaml error. yaml.v3 leaves
+// the line out of errors on the first line and of unknown anchor errors; the latter is
+// found by looking for the alias in src.
+func yamlSyntaxError(field, src string, err error) *APIError {
+	msg, line := strings.TrimPrefix(err.Error(), "yaml: "), 1
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = err.Error()[len(m[0]):]
+	} else if m := yamlUnknownAnchor.FindStringSubmatch(err.Error()); m != nil {
+		if i := strings.Index(src, "*"+m[1]); i >= 0 {
+			l, col := lineColumn([]byte(src), i)
+			return invalidYAML(field, l, col, msg)
+		}
+	}
+	return invalidYAML(field, line, 0, msg)
+}
+
+// encodeYAML writes docs as a YAML stream, separated by "---".
+func encodeYAML(docs []*yaml.Node, indent int) (string, error) {
+	var out strings.Builder
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(indent)
+	for _, d := range docs {
+		if err := enc.Encode(d); err != nil {
+			return "", newError(CodeInvalidValue, "value", fmt.Sprintf("cannot write YAML: %v", err))
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return "", newError(CodeInvalidValue, "value", fmt.Sprintf("cannot write YAML: %v", err))
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
+
+// yamlNodeOf builds the YAML node for a decoded JSON value. Strings that either YAML
+// version would read as another type, or as a merge key, are double-quoted, and
+// exponents are written so that YAML 1.1 reads them as numbers (1e3 becomes 1.0e+3).
+func yamlNodeOf(v interface{}) *yaml.Node {
+	switch v := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case json.Number:
+		s := string(v)
+		if !strings.ContainsAny(s, ".eE") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: s}
+		}
+		if i := strings.IndexAny(s, "eE"); i >= 0 {
+			// YAML 1.1 floats need a decimal point and a signed exponent.
+			mant, exp := s[:i], s[i+1:]
+			if !strings.Contains(mant, ".") {
+				mant += ".0"
+			}
+			if exp[0] != '-' && exp[0] != '+' {
+				exp = "+" + exp
+			}
+			s = mant + s[i:i+1] + exp
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: s}
+	case string:
+		n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+		t11, _ := resolveYAMLScalar(v, true)
+		t12, _ := resolveYAMLScalar(v, false)
+		if t11 != "!!str" || t12 != "!!str" || v == "<<" {
+			n.Style = yaml.DoubleQuotedStyle
+		}
+		return n
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, e := range v {
+			n.Content = append(n.Content, yamlNodeOf(e))
+		}
+		return n
+	case *jqObject:
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range v.keys {
+			n.Content = append(n.Content, yamlNodeOf(k), yamlNodeOf(v.vals[k]))
+		}
+		return n
+	}
+	panic(fmt.Sprintf("yamlNodeOf: unexpected %T", v))
+}
+
+// convertYAML parses the YAML stream in field and converts each document to a JSON
+// value (nil, bool, json.Number, string, []interface{} or *jqObject).
+func convertYAML(ctx context.Context, field, s string, yaml11 bool) ([]interface{}, []YAMLWarning, error) {
+	docs, err := parseYAMLStream(field, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &yamlConverter{ctx: ctx, field: field, yaml11: yaml11, maxValues: limits.YAML.Values, expanding: make(map[*yaml.Node]bool)}
+	values := make([]interface{}, len(docs))
+	for i, d := range docs {
+		if values[i], err = c.value(d, ""); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, c.warnings, nil
+}
+
+// yamlConverter turns yaml.v3 nodes into JSON values, expanding aliases and merge keys.
+type yamlConverter struct {
+	ctx       context.Context
+	field     string
+	yaml11    bool
+	values    int
+	maxValues int
+	expanding map[*yaml.Node]bool // anchored nodes being expanded, to catch cycles
+	warnings  []YAMLWarning
+}
+
+func (c *yamlConverter) errorf(n *yaml.Node, format string, args ...interface{}) *APIError {
+	return invalidYAML(c.field, n.Line, n.Column, fmt.Sprintf(format, args...))
+}
+
+func (c *yamlConverter) warn(n *yaml.Node, code, path, message string) {
+	c.warnings = append(c.warnings, YAMLWarning{Code: code, Message: message, Path: path, Line: n.Line, Column: n.Column})
+}
+
+func (c *yamlConverter) value(n *yaml.Node, path string) (interface{}, error) {
+	c.values++
+	if c.maxValues > 0 && c.values > c.maxValues {
+		e := newError(CodeTooMany, c.field, fmt.Sprintf("the YAML expands to more than %d values", c.maxValues))
+		e.Details = map[string]interface{}{"max": c.maxValues}
+		return nil, e
+	}
+	if c.values%1024 == 0 {
+		if err := checkContext(c.ctx); err != nil {
+			return nil, err
+		}
+	}
+	if n.Style&yaml.TaggedStyle != 0 && !strings.HasPrefix(n.ShortTag(), "!!") {
+		c.warn(n, "custom_tag", path, fmt.Sprintf("the tag %s has no JSON equivalent and was dropped", n.Tag))
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return c.value(n.Content[0], path)
+	case yaml.AliasNode:
+		if c.expanding[n.Alias] {
+			return nil, c.errorf(n, "alias *%s refers to a node that contains it", n.Value)
+		}
+		c.expanding[n.Alias] = true
+		defer delete(c.expanding, n.Alias)
+		return c.value(n.Alias, path)
+	case yaml.SequenceNode:
+		arr := make([]interface{}, len(n.Content))
+		for i, e := range n.Content {
+			v, err := c.value(e, pointerJoin(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	case yaml.MappingNode:
+		return c.mapping(n, path)
+	}
+	return c.scalar(n, path)
+}
+
+// mapping converts a mapping. Keys explicitly written in it win over keys brought in by
+// merge keys (<<), and earlier merged mappings win over later ones.
+func (c *yamlConverter) mapping(n *yaml.Node, path string) (interface{}, error) {
+	explicit := make(map[string]*yaml.Node)
+	for i := 0; i < len(n.Content); i += 2 {
+		k := n.Content[i]
+		if k.ShortTag() == "!!merge" {
+			continue
+		}
+		key, err := c.key(k, path)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := explicit[key]; ok {
+			return nil, c.errorf(k, "duplicate key %q (first defined on line %d)", key, prev.Line)
+		}
+		explicit[key] = k
+	}
+	obj := newJQObject(len(explicit))
+	for i := 0; i < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.ShortTag() != "!!merge" {
+			key := k.Value
+			if k.Kind == yaml.AliasNode {
+				key = k.Alias.Value
+			}
+			val, err := c.value(v, pointerJoin(path, key))
+			if err != nil {
+				return nil, err
+			}
+			obj.put(key, val)
+			continue
+		}
+		sources := []*yaml.Node{v}
+		if v.Kind == yaml.SequenceNode {
+			sources = v.Content
+		}
+		for _, src := range sources {
+			merged, err := c.value(src, path)
+			if err != nil {
+				return nil, err
+			}
+			m, ok := merged.(*jqObject)
+			if !ok {
+				return nil, c.errorf(src, "the merge key << needs a mapping or a list of mappings")
+			}
+			for _, key := range m.keys {
+				if _, ok := explicit[key]; ok {
+					continue
+				}
+				if _, ok := obj.get(key); !ok {
+					obj.put(key, m.vals[key])
+				}
+			}
+		}
+	}
+	return obj, nil
+}
+
+// key returns a mapping key as a JSON member name. Keys are kept as written, even those
+// a schema would type as a number or boolean.
+func (c *yamlConverter) key(k *yaml.Node, path string) (string, error) {
+	if k.Kind == yaml.AliasNode {
+		k = k.Alias
+	}
+	if k.Kind != yaml.ScalarNode {
+		return "", c.errorf(k, "a %s cannot be a key in JSON", yamlKindName(k.Kind))
+	}
+	if k.Style == 0 {
+		c.versionDifference(k, pointerJoin(path, k.Value))
+	}
+	return k.Value, nil
+}
+
+func yamlKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.MappingNode:
+		return "mapping"
+	}
+	return "node"
+}
+
+// scalar types a scalar by its explicit tag, or for plain scalars by the schema.
+func (c *yamlConverter) scalar(n *yaml.Node, path string) (interface{}, error) {
+	tag := ""
+	if n.Style&yaml.TaggedStyle != 0 {
+		tag = n.ShortTag()
+	}
+	plain := n.Style&^yaml.TaggedStyle == 0
+	switch tag {
+	case "!!str", "!!binary", "!!timestamp":
+		return n.Value, nil
+	case "!!null":
+		return nil, nil
+	case "!!bool", "!!int", "!!float":
+		t, v := resolveYAMLScalar(n.Value, c.yaml11)
+		if t != tag && !(tag == "!!float" && t == "!!int") {
+			return nil, c.errorf(n, "%q is not a valid %s", n.Value, tag)
+		}
+		return c.number(n, path, v)
+	}
+	if !plain {
+		return n.Value, nil
+	}
+	c.versionDifference(n, path)
+	_, v := resolveYAMLScalar(n.Value, c.yaml11)
+	return c.number(n, path, v)
+}
+
+// number passes v through, writing a non-finite float as null with a warning.
+func (c *yamlConverter) number(n *yaml.Node, path string, v interface{}) (interface{}, error) {
+	if _, ok := v.(float64); ok {
+		c.warn(n, "non_finite", path, fmt.Sprintf("%s has no JSON equivalent and was written as null", n.Value))
+		return nil, nil
+	}
+	return v, nil
+}
+
+// versionDifference warns when YAML 1.1 and 1.2 read the plain scalar n differently.
+func (c *yamlConverter) versionDifference(n *yaml.Node, path string) {
+	t11, v11 := resolveYAMLScalar(n.Value, true)
+	t12, v12 := resolveYAMLScalar(n.Value, false)
+	d11, d12 := describeYAMLScalar(t11, v11), describeYAMLScalar(t12, v12)
+	if d11 != d12 {
+		c.warn(n, "version_difference", path, fmt.Sprintf("%s is %s in YAML 1.1 but %s in YAML 1.2", n.Value, d11, d12))
+	}
+}
+
+func describeYAMLScalar(tag string, v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return "a non-finite number"
+	case nil:
+		return "null"
+	}
+	if tag == "!!bool" {
+		return fmt.Sprint(v)
+	}
+	return fmt.Sprintf("the number %v", v)
+}
+
+// Plain scalar patterns of the YAML 1.2 core schema and of the YAML 1.1 types.
+var (
+	yaml12Null  = regexp.MustCompile(`^(~|null|Null|NULL|)$`)
+	yaml12Bool  = regexp.MustCompile(`^(true|True|TRUE|false|False|FALSE)$`)
+	yaml12Int   = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yaml12Float = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yaml11Bool  = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|FALSE|on|On|ON|off|Off|OFF)$`)
+	yaml11Int   = regexp.MustCompile(`^[-+]?(0b[01_]+|0[0-7_]+|0|[1-9][0-9_]*|0x[0-9a-fA-F_]+|[1-9][0-9_]*(:[0-5]?[0-9])+)$`)
+	yaml11Float = regexp.MustCompile(`^[-+]?([0-9][0-9_]*\.[0-9_]*([eE][-+][0-9]+)?|\.[0-9][0-9_]*([eE][-+][0-9]+)?|[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*)$`)
+	yamlInf     = regexp.MustCompile(`^[-+]?\.(inf|Inf|INF)$`)
+	yamlNaN     = regexp.MustCompile(`^\.(nan|NaN|NAN)$`)
+)
+
+// resolveYAMLScalar types the plain scalar s and returns its tag and JSON value: nil,
+// a bool, a json.Number, a string, or a float64 for infinities and NaN.
+func resolveYAMLScalar(s string, yaml11 bool) (string, interface{}) {
+	switch {
+	case yaml12Null.MatchString(s):
+		return "!!null", nil
+	case yamlInf.MatchString(s):
+		return "!!float", math.Inf(1)
+	case yamlNaN.MatchString(s):
+		return "!!float", math.NaN()
+	}
+	if yaml11 {
+		switch {
+		case yaml11Bool.MatchString(s):
+			switch strings.ToLower(s) {
+			case "y", "yes", "true", "on":
+				return "!!bool", true
+			}
+			return "!!bool", false
+		case yaml11Int.MatchString(s):
+			return "!!int", yamlInt(strings.ReplaceAll(s, "_", ""), true)
+		case yaml11Float.MatchString(s):
+			return "!!float", yamlFloat(strings.ReplaceAll(s, "_", ""))
+		}
+		return "!!str", s
+	}
+	switch {
+	case yaml12Bool.MatchString(s):
+		return "!!bool", s[0] == 't' || s[0] == 'T'
+	case yaml12Int.MatchString(s):
+		return "!!int", yamlInt(s, false)
+	case yaml12Float.MatchString(s):
+		return "!!float", yamlFloat(s)
+	}
+	return "!!str", s
+}
+
+// yamlInt converts an integer that matched one of the int patterns to decimal. A
+// leading 0 means octal in YAML 1.1 only.
+func yamlInt(s string, yaml11 bool) json.Number {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	n := new(big.Int)
+	switch {
+	case strings.Contains(s, ":"):
+		for _, part := range strings.Split(s, ":") {
+			d, _ := strconv.Atoi(part)
+			n.Mul(n, big.NewInt(60)).Add(n, big.NewInt(int64(d)))
+		}
+	case strings.HasPrefix(s, "0x"):
+		n.SetString(s[2:], 16)
+	case strings.HasPrefix(s, "0o"):
+		n.SetString(s[2:], 8)
+	case strings.HasPrefix(s, "0b"):
+		n.SetString(s[2:], 2)
+	case yaml11 && len(s) > 1 && s[0] == '0':
+		n.SetString(s[1:], 8)
+	default:
+		n.SetString(s, 10)
+	}
+	if neg {
+		n.Neg(n)
+	}
+	return json.Number(n.String())
+}
+
+// yamlFloat rewrites a float that matched one of the float patterns as a JSON number,
+// keeping its digits: the sign "+", leading zeros and a bare "." are not valid JSON.
+func yamlFloat(s string) json.Number {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if strings.Contains(s, ":") {
+		// A YAML 1.1 sexagesimal float such as 1:30.5.
+		f := 0.0
+		parts := strings.Split(s, ":")
+		for _, part := range parts[:len(parts)-1] {
+			d, _ := strconv.Atoi(part)
+			f = f*60 + float64(d)
+		}
+		last, _ := strconv.ParseFloat(parts[len(parts)-1], 64)
+		s = strconv.FormatFloat(f*60+last, 'g', -1, 64)
+	} else {
+		mant, exp := s, ""
+		if i := strings.IndexAny(s, "eE"); i >= 0 {
+			mant, exp = s[:i], s[i:]
+		}
+		whole, frac, _ := strings.Cut(mant, ".")
+		whole = strings.TrimLeft(whole, "0")
+		if whole == "" {
+			whole = "0"
+		}
+		s = whole
+		if frac != "" {
+			s += "." + frac
+		}
+		s += exp
+	}
+	if neg {
+		s = "-" + s
+	}
+	return json.Number(s)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		req  YAMLToJSONRequest
+		want string
+	}{
+		{"key order and scalars", YAMLToJSONRequest{Value: "z: 1\na: [true, ~, 1.5, 'x', \"2\"]\nm: {k: v}\n"},
+			"{\n  \"z\": 1,\n  \"a\": [\n    true,\n    null,\n    1.5,\n    \"x\",\n    \"2\"\n  ],\n  \"m\": {\n    \"k\": \"v\"\n  }\n}"},
+		{"big integers keep their digits", YAMLToJSONRequest{Value: "[9007199254740993, 0x1F, 0o17, +12, 007, .5, 1., -1.0e+3]"},
+			`[9007199254740993,31,15,12,7,0.5,1,-1.0e+3]`},
+		{"yaml 1.2 booleans", YAMLToJSONRequest{Value: "[yes, no, on, off, y, True]"},
+			`["yes","no","on","off","y",true]`},
+		{"yaml 1.1 booleans", YAMLToJSONRequest{Value: "[yes, No, ON, off, y, True]", Version: "1.1"},
+			`[true,false,true,false,true,true]`},
+		{"yaml 1.1 numbers", YAMLToJSONRequest{Value: "[0755, 1_000, 1:30, 0b101, 1e3, 1:30.5, 09]", Version: "1.1"},
+			`[493,1000,90,5,"1e3",90.5,"09"]`},
+		{"explicit tags", YAMLToJSONRequest{Value: "[!!str 12, !!float 1, !!int '7', !!null x, !Ref name]"},
+			`["12",1,7,null,"name"]`},
+		{"block scalars", YAMLToJSONRequest{Value: "a: |\n  one\n  two\nb: >-\n  folded\n  line\n"},
+			"{\n  \"a\": \"one\\ntwo\\n\",\n  \"b\": \"folded line\"\n}"},
+		{"anchors and aliases", YAMLToJSONRequest{Value: "base: &b {x: 1}\nuse: *b\nlist: [*b]\n"},
+			"{\n  \"base\": {\n    \"x\": 1\n  },\n  \"use\": {\n    \"x\": 1\n  },\n  \"list\": [\n    {\n      \"x\": 1\n    }\n  ]\n}"},
+		{"merge keys", YAMLToJSONRequest{Value: "a: &a {x: 1, y: 1}\nb: &b {y: 2, z: 2}\nc:\n  z: 3\n  <<: [*a, *b]\n  w: 3\n"},
+			"{\n  \"a\": {\n    \"x\": 1,\n    \"y\": 1\n  },\n  \"b\": {\n    \"y\": 2,\n    \"z\": 2\n  },\n  \"c\": {\n    \"z\": 3,\n    \"x\": 1,\n    \"y\": 1,\n    \"w\": 3\n  }\n}"},
+		{"stream", YAMLToJSONRequest{Value: "a: 1\n---\n- 2\n---\n"}, "[\n  {\n    \"a\": 1\n  },\n  [\n    2\n  ],\n  null\n]"},
+		{"stream as ndjson", YAMLToJSONRequest{Value: "a: 1\n---\n- 2\n", Documents: "ndjson"}, "{\"a\":1}\n[2]"},
+		{"single document as array", YAMLToJSONRequest{Value: "1", Documents: "array"}, "[\n  1\n]"},
+		{"empty stream", YAMLToJSONRequest{Value: "# nothing\n"}, "null"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := yamlToJSON(context.Background(), tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := resp.Result
+			if !strings.Contains(tc.want, "\n") {
+				// One-line expectations are compared minified.
+				min, _ := minifyJSON(MinifyRequest{Value: got})
+				got = min.Result
+			}
+			if got != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONWarnings(t *testing.T) {
+	resp, err := yamlToJSON(context.Background(), YAMLToJSONRequest{Value: "on:\n  push: yes\n  mode: 0644\n  ok: \"no\"\nlimit: .inf\nref: !Ref x\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []YAMLWarning{
+		{Code: "version_difference", Message: `on is true in YAML 1.1 but "on" in YAML 1.2`, Path: "/on", Line: 1, Column: 1},
+		{Code: "version_difference", Message: `yes is true in YAML 1.1 but "yes" in YAML 1.2`, Path: "/on/push", Line: 2, Column: 9},
+		{Code: "version_difference", Message: `0644 is the number 420 in YAML 1.1 but the number 644 in YAML 1.2`, Path: "/on/mode", Line: 3, Column: 9},
+		{Code: "non_finite", Message: ".inf has no JSON equivalent and was written as null", Path: "/limit", Line: 5, Column: 8},
+		{Code: "custom_tag", Message: "the tag !Ref has no JSON equivalent and was dropped", Path: "/ref", Line: 6, Column: 6},
+	}
+	if !reflect.DeepEqual(resp.Warnings, want) {
+		t.Errorf("warnings = %+v, want %+v", resp.Warnings, want)
+	}
+}
+
+func TestYAMLToJSONErrors(t *testing.T) {
+	cases := []struct {
+		name, value  string
+		line, column int
+	}{
+		{"syntax on the first line", "a: b: c", 1, 0},
+		{"syntax", "a: 1\nb: [1,\n", 2, 0},
+		{"duplicate key", "a: 1\nb: 2\na: 3\n", 3, 1},
+		{"unknown anchor", "a: 1\nb: *nope\n", 2, 4},
+		{"recursive alias", "a: &a [1, *a]\n", 1, 11},
+		{"mapping key", "? [1]\n: x\n", 1, 3},
+		{"bad merge", "a: 1\nb:\n  <<: *a\n", 3, 7},
+		{"bad tagged int", "a: !!int 1.5\n", 1, 4},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := yamlToJSON(context.Background(), YAMLToJSONRequest{Value: tc.value})
+			var ae *APIError
+			if !errors.As(err, &ae) || ae.Code != CodeInvalidYAML || ae.Field != "value" {
+				t.Fatalf("err = %v, want invalid_yaml on value", err)
+			}
+			if ae.Details["line"] != tc.line || tc.column > 0 && ae.Details["column"] != tc.column {
+				t.Errorf("details = %v, want line %d column %d (%s)", ae.Details, tc.line, tc.column, ae.Message)
+			}
+		})
+	}
+}
+
+func TestYAMLAliasExpansionLimit(t *testing.T) {
+	defer SetLimits(limits)
+	l := DefaultLimits()
+	l.YAML.Values = 100
+	SetLimits(l)
+	value := "a: &a [1, 1, 1, 1, 1, 1, 1, 1, 1, 1]\nb: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]\n"
+	_, err := yamlToJSON(context.Background(), YAMLToJSONRequest{Value: value})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeTooMany {
+		t.Fatalf("err = %v, want too_many_items", err)
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	cases := []struct {
+		name string
+		req  JSONToYAMLRequest
+		want string
+	}{
+		{"key order and numbers", JSONToYAMLRequest{Value: `{"z": 9007199254740993, "a": [1.50, null, true], "e": {}}`},
+			"z: 9007199254740993\na:\n  - 1.50\n  - null\n  - true\ne: {}"},
+		{"strings that read as other types", JSONToYAMLRequest{Value: `["yes", "on", "0755", "1.0", "null", "", "a: b", "x"]`},
+			"- \"yes\"\n- \"on\"\n- \"0755\"\n- \"1.0\"\n- \"null\"\n- \"\"\n- 'a: b'\n- x"},
+		{"multiline strings", JSONToYAMLRequest{Value: `{"s": "one\ntwo\n"}`}, "s: |\n  one\n  two"},
+		{"indent", JSONToYAMLRequest{Value: `{"a": {"b": [1]}}`, Indent: 4}, "a:\n    b:\n        - 1"},
+		{"exponents", JSONToYAMLRequest{Value: `[1e3, 2.5E-7, -1e+2]`}, "- 1.0e+3\n- 2.5E-7\n- -1.0e+2"},
+		{"stream", JSONToYAMLRequest{Value: `[{"kind": "A"}, {"kind": "B"}]`, Stream: true}, "kind: A\n---\nkind: B"},
+		{"lenient", JSONToYAMLRequest{Value: `{a: 1, /* c */ b: 'x',}`, Lenient: true}, "a: 1\nb: x"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := jsonToYAML(tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Result != tc.want {
+				t.Errorf("result =\n%s\nwant\n%s", resp.Result, tc.want)
+			}
+		})
+	}
+}
+
+// Converting JSON to YAML and back gives the same JSON under both YAML versions.
+func TestYAMLRoundTrip(t *testing.T) {
+	const value = `{"b": ["yes", "on", "0644", "1_000", "1:30", "~", "", "1e3", 1.0e+3, -2.5E-7, 12, -0.5, false], "a": {"<<": "x"}}`
+	y, err := jsonToYAML(JSONToYAMLRequest{Value: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := minifyJSON(MinifyRequest{Value: value})
+	for _, version := range []string{"1.2", "1.1"} {
+		resp, err := yamlToJSON(context.Background(), YAMLToJSONRequest{Value: y.Result, Version: version})
+		if err != nil {
+			t.Fatalf("%s: %v", version, err)
+		}
+		got, _ := minifyJSON(MinifyRequest{Value: resp.Result})
+		if got.Result != want.Result {
+			t.Errorf("YAML %s round trip = %s, want %s\nYAML:\n%s", version, got.Result, want.Result, y.Result)
+		}
+	}
+}
+
+func TestFormatYAML(t *testing.T) {
+	resp, err := formatYAML(FormatYAMLRequest{Value: "# config\nbase:   &b\n    x: 1   # one\nuse: *b\nlist:\n- 'yes'\n- 0755\n---\nnext: doc\n", Indent: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# config\nbase: &b\n    x: 1 # one\nuse: *b\nlist:\n    - 'yes'\n    - 0755\n---\nnext: doc"
+	if resp.Result != want {
+		t.Errorf("result =\n%s\nwant\n%s", resp.Result, want)
+	}
+	for _, indent := range []int{-1, 1, maxFormatIndent + 1} {
+		_, err := formatYAML(FormatYAMLRequest{Value: "a: 1", Indent: indent})
+		var ae *APIError
+		if !errors.As(err, &ae) || ae.Code != CodeOutOfRange || ae.Field != "indent" {
+			t.Fatalf("indent %d: err = %v, want count_out_of_range on indent", indent, err)
+		}
+		if ae.Details["min"] != 2 || ae.Details["max"] != maxFormatIndent || ae.Details["default"] != 2 {
+			t.Errorf("indent %d: details = %v, want min 2, max %d, default 2", indent, ae.Details, maxFormatIndent)
+		}
+	}
+}
+
+func TestValidateYAML(t *testing.T) {
+	resp, err := validateYAML(context.Background(), ValidateYAMLRequest{Value: "a: 1\n---\nb: on\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Valid || resp.Documents != 2 || len(resp.Warnings) != 1 {
+		t.Errorf("resp = %+v, want 2 valid documents and a warning", resp)
+	}
+	resp, err = validateYAML(context.Background(), ValidateYAMLRequest{Value: "a: 1\nb:\n  c: 2\n  c: 3\n", Version: "1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := YAMLValidateResponse{Error: `line 4: duplicate key "c" (first defined on line 3)`, Line: 4, Column: 3}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("resp = %+v, want %+v", resp, want)
+	}
+	_, err = validateYAML(context.Background(), ValidateYAMLRequest{Value: "a: 1", Version: "1.0"})
+	var ae *APIError
+	if !errors.As(err, &ae) || ae.Code != CodeInvalidOption || ae.Field != "version" {
+		t.Errorf("err = %v, want invalid_option on version", err)
+	}
+}
@@ -1,64 +1,126 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"digi-leatherman/backend/cli"
+	"digi-leatherman/backend/config"
 	"digi-leatherman/backend/handlers"
 	"digi-leatherman/backend/middleware"
+	"digi-leatherman/backend/version"
+	"digi-leatherman/backend/web"
 )
 
 func main() {
-	mux := http.NewServeMux()
+	// Any subcommand (e.g. "string snake-case") runs a tool from the command line instead of serving.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(cli.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
 
-	mux.HandleFunc("/api/string/url-encode", cors(handlers.URLEncode))
-	mux.HandleFunc("/api/string/url-decode", cors(handlers.URLDecode))
-	mux.HandleFunc("/api/string/url-parse-params", cors(handlers.ParseURLParams))
-	mux.HandleFunc("/api/string/url-param-creator", cors(handlers.CreateURLWithParams))
-	mux.HandleFunc("/api/string/base64-encode", cors(handlers.Base64Encode))
-	mux.HandleFunc("/api/string/base64-decode", cors(handlers.Base64Decode))
-	mux.HandleFunc("/api/string/trim", cors(handlers.Trim))
-	mux.HandleFunc("/api/string/upper-case", cors(handlers.UpperCase))
-	mux.HandleFunc("/api/string/lower-case", cors(handlers.LowerCase))
-	mux.HandleFunc("/api/string/capital-case", cors(handlers.CapitalCase))
-	mux.HandleFunc("/api/string/snake-case", cors(handlers.SnakeCase))
-	mux.HandleFunc("/api/string/kebab-case", cors(handlers.KebabCase))
-	mux.HandleFunc("/api/string/camel-case", cors(handlers.CamelCase))
-	mux.HandleFunc("/api/string/pascal-case", cors(handlers.PascalCase))
-	mux.HandleFunc("/api/string/sentence-case", cors(handlers.SentenceCase))
-	mux.HandleFunc("/api/string/spell-out", cors(handlers.SpellOut))
-	mux.HandleFunc("/api/lorem-ipsum/generate", cors(handlers.LoremIpsum))
-	mux.HandleFunc("/api/json/format", cors(handlers.FormatJSON))
-	mux.HandleFunc("/api/json/minify", cors(handlers.MinifyJSON))
-	mux.HandleFunc("/api/json/validate", cors(handlers.ValidateJSON))
-	mux.HandleFunc("/api/json/path", cors(handlers.PathQueryJSON))
-	mux.HandleFunc("/api/json/diff", cors(handlers.DiffJSON))
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	slog.SetDefault(cfg.Logger(os.Stderr))
+	handlers.SetLimits(cfg.Limits)
 
-	addr := ":8100"
-	handler := middleware.Recovery(middleware.Logging(mux))
-	slog.Info("server listening", "addr", addr)
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatal(err)
+	metrics := middleware.NewMetrics()
+	mux := apiMux(cfg, metrics)
+	if assets, ok := web.Assets(); ok {
+		ui, err := web.Handler(assets)
+		if err != nil {
+			log.Fatalf("embedded UI: %v", err)
+		}
+		mux.Handle("/", metrics.Instrument("ui")(ui))
+		slog.Info("serving embedded UI")
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           middleware.Recovery(middleware.Logging(middleware.Timeout(time.Duration(cfg.Timeouts.Request))(mux))),
+		ReadHeaderTimeout: time.Duration(cfg.Timeouts.ReadHeader),
+		ReadTimeout:       time.Duration(cfg.Timeouts.Read),
+		WriteTimeout:      time.Duration(cfg.Timeouts.Write),
+		IdleTimeout:       time.Duration(cfg.Timeouts.Idle),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	if err := serve(srv, time.Duration(cfg.Timeouts.Shutdown)); err != nil {
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
 	}
 }
 
-// cors wraps a handler to add CORS headers for the frontend dev server.
-// In development, the request Origin is reflected so any localhost port works.
-func cors(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
-		// Allow any localhost origin in development (e.g. 5173, 5273, 3000).
-		if origin != "" && (strings.HasPrefix(origin, "http://localhost:") || strings.HasPrefix(origin, "http://127.0.0.1:")) {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-		}
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next(w, r)
+// apiMux mounts every tool and endpoint under /api, plus /metrics. CORS wraps the body
+// limit so that an early 413 still carries the CORS headers a browser needs to read it.
+func apiMux(cfg config.Config, metrics *middleware.Metrics) *http.ServeMux {
+	mux := http.NewServeMux()
+	cors := middleware.CORS(cfg.CORSOrigins)
+	// route mounts an API handler; label names it in /metrics (the tool id for tools).
+	route := func(path, label string, h http.HandlerFunc) {
+		limit := middleware.BodyLimit(cfg.BodyLimit(path), handlers.BodyTooLarge)
+		mux.Handle(path, metrics.Instrument(label)(cors(limit(h).ServeHTTP)))
+	}
+	for _, t := range handlers.Tools() {
+		route(t.Path, t.Key(), t.ServeHTTP)
+	}
+	for _, e := range handlers.Endpoints() {
+		route(e.Path, strings.TrimPrefix(strings.TrimPrefix(e.Path, "/api"), "/"), e.Handler)
+	}
+	route("/api/", "not-found", handlers.NotFound)
+	mux.Handle("/metrics", metrics)
+	return mux
+}
+
+// serve runs srv until SIGINT or SIGTERM, then marks the server not ready, stops accepting
+// connections and waits up to drain for in-flight requests to finish.
+func serve(srv *http.Server, drain time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	handlers.SetReady(true)
+	slog.Info("server listening", "addr", ln.Addr().String(), "version", version.Get().Version)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process immediately
+	handlers.SetReady(false)
+	slog.Info("shutting down", "drain", drain.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("drain: %w", err)
 	}
+	slog.Info("server stopped")
+	return nil
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"digi-leatherman/backend/config"
+	"digi-leatherman/backend/middleware"
+)
+
+func TestAPIMuxBodyTooLargeHasCORS(t *testing.T) {
+	cfg, _, err := config.Load([]string{"--max-body-bytes", "16"}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := apiMux(cfg, middleware.NewMetrics())
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "http://test/api/string/trim", strings.NewReader(`{"value":"more than sixteen bytes"}`))
+		if chunked {
+			req.ContentLength = -1 // caught while reading rather than up front
+		}
+		req.Header.Set("Origin", "http://localhost:5173")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked %v: status = %d, want %d", chunked, rec.Code, http.StatusRequestEntityTooLarge)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+			t.Errorf("chunked %v: Access-Control-Allow-Origin = %q, want the request origin", chunked, got)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// BodyLimit wraps next so request bodies larger than n bytes are refused. A declared
+// Content-Length over n is rejected up front by calling tooLarge; otherwise reading past n
+// fails with *http.MaxBytesError, which handlers report the same way.
+func BodyLimit(n int64, tooLarge func(w http.ResponseWriter, limit int64)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				tooLarge(w, n)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
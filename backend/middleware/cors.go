@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+)
+
+// DefaultCORSOrigins allows the frontend dev server on any localhost port (e.g. 5173, 5273, 3000).
+var DefaultCORSOrigins = []string{"http://localhost:*", "http://127.0.0.1:*"}
+
+// CORS wraps a handler to add CORS headers when the request Origin matches one of origins.
+// Patterns use path.Match syntax, so "http://localhost:*" matches any port and "*" any origin.
+// The matching Origin is reflected back rather than answering with a wildcard.
+func CORS(origins []string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin != "" && OriginAllowed(origin, origins) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+// OriginAllowed reports whether origin matches any of the patterns.
+func OriginAllowed(origin string, patterns []string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == origin {
+			return true
+		}
+		if ok, err := path.Match(p, origin); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
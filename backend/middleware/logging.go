@@ -10,6 +10,7 @@ import (
 type responseRecorder struct {
 	http.ResponseWriter
 	status int
+	bytes  int64
 }
 
 func (r *responseRecorder) WriteHeader(code int) {
@@ -19,12 +20,26 @@ func (r *responseRecorder) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController can reach its Flush.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // Logging wraps next with request logging: method, path, remote, status, duration.
 // Uses slog: Info for 2xx/3xx, Warn for 4xx, Error for 5xx.
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		rec := &responseRecorder{ResponseWriter: w}
 		next.ServeHTTP(rec, r)
 		if rec.status == 0 {
 			rec.status = http.StatusOK
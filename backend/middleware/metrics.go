@@ -0,0 +1,245 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"digi-leatherman/backend/version"
+)
+
+// Histogram bucket upper bounds (the +Inf bucket is implicit).
+var (
+	latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	sizeBuckets    = []float64{64, 256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+)
+
+// Metrics aggregates per-tool request statistics and serves them in the Prometheus text
+// exposition format. Wrap each route with Instrument and mount the Metrics itself at
+// /metrics; no external service or client library is needed.
+type Metrics struct {
+	mu       sync.Mutex
+	tools    map[string]*toolMetrics
+	inFlight atomic.Int64
+}
+
+type toolMetrics struct {
+	requests     uint64
+	errors       map[string]uint64 // by status class, e.g. "4xx"
+	duration     *histogram
+	requestSize  *histogram
+	responseSize *histogram
+}
+
+// NewMetrics returns an empty registry.
+func NewMetrics() *Metrics {
+	return &Metrics{tools: make(map[string]*toolMetrics)}
+}
+
+// Instrument wraps next so its requests are recorded under the tool label (a tool id such
+// as "json/diff", or a name for other routes). The series exist with zero values from the
+// moment the route is instrumented, so unused tools show up too.
+func (m *Metrics) Instrument(tool string) func(http.Handler) http.Handler {
+	m.mu.Lock()
+	if _, ok := m.tools[tool]; !ok {
+		m.tools[tool] = &toolMetrics{
+			errors:       make(map[string]uint64),
+			duration:     newHistogram(latencyBuckets),
+			requestSize:  newHistogram(sizeBuckets),
+			responseSize: newHistogram(sizeBuckets),
+		}
+	}
+	tm := m.tools[tool]
+	m.mu.Unlock()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.inFlight.Add(1)
+			defer m.inFlight.Add(-1)
+			start := time.Now()
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			elapsed := time.Since(start)
+
+			reqSize := body.n
+			if r.ContentLength > reqSize {
+				reqSize = r.ContentLength // body refused or only partly read
+			}
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			tm.requests++
+			if status >= 400 {
+				tm.errors[strconv.Itoa(status/100)+"xx"]++
+			}
+			tm.duration.observe(elapsed.Seconds())
+			tm.requestSize.observe(float64(reqSize))
+			tm.responseSize.observe(float64(rec.bytes))
+		})
+	}
+}
+
+// ServeHTTP handles GET /metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text format, with series sorted by label
+// so the output is stable.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	p := &promWriter{w: w}
+	info := version.Get()
+	p.header("dl_build_info", "gauge", "Build information; the value is always 1.")
+	p.sample("dl_build_info", labels("version", info.Version, "commit", info.Commit, "goversion", info.GoVersion), 1)
+	p.header("dl_http_requests_in_flight", "gauge", "Requests currently being served.")
+	p.sample("dl_http_requests_in_flight", "", float64(m.inFlight.Load()))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.tools))
+	for name := range m.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	p.header("dl_http_requests_total", "counter", "Requests handled, by tool.")
+	for _, name := range names {
+		p.sample("dl_http_requests_total", labels("tool", name), float64(m.tools[name].requests))
+	}
+	p.header("dl_http_request_errors_total", "counter", "Requests answered with a 4xx or 5xx status, by tool and status class.")
+	for _, name := range names {
+		tm := m.tools[name]
+		for _, class := range []string{"4xx", "5xx"} {
+			p.sample("dl_http_request_errors_total", labels("tool", name, "class", class), float64(tm.errors[class]))
+		}
+	}
+	histograms := []struct {
+		name, help string
+		get        func(*toolMetrics) *histogram
+	}{
+		{"dl_http_request_duration_seconds", "Time to serve a request, by tool.", func(t *toolMetrics) *histogram { return t.duration }},
+		{"dl_http_request_size_bytes", "Request body size, by tool.", func(t *toolMetrics) *histogram { return t.requestSize }},
+		{"dl_http_response_size_bytes", "Response body size, by tool.", func(t *toolMetrics) *histogram { return t.responseSize }},
+	}
+	for _, h := range histograms {
+		p.header(h.name, "histogram", h.help)
+		for _, name := range names {
+			p.histogram(h.name, name, h.get(m.tools[name]))
+		}
+	}
+	return p.n, p.err
+}
+
+// histogram is a Prometheus histogram: counts[i] holds observations <= bounds[i] that
+// were not counted in an earlier bucket; the last element is the +Inf bucket.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // first bound >= v
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// countingReader counts the request body bytes read by the handler.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// promWriter writes exposition lines, remembering the first error.
+type promWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *promWriter) header(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) sample(name, labels string, v float64) {
+	p.printf("%s%s %s\n", name, labels, formatValue(v))
+}
+
+func (p *promWriter) histogram(name, tool string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		p.sample(name+"_bucket", labels("tool", tool, "le", formatValue(bound)), float64(cumulative))
+	}
+	p.sample(name+"_bucket", labels("tool", tool, "le", "+Inf"), float64(h.count))
+	p.sample(name+"_sum", labels("tool", tool), h.sum)
+	p.sample(name+"_count", labels("tool", tool), float64(h.count))
+}
+
+// labels formats name/value pairs as {a="1",b="2"}, escaping values.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	echo := m.Instrument("string/trim")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) == "bad" {
+			http.Error(w, "nope", http.StatusBadRequest)
+			return
+		}
+		if string(b) == "boom" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	}))
+	m.Instrument("json/diff") // registered but never called
+
+	for _, body := range []string{"hello", strings.Repeat("x", 300), "bad", "boom"} {
+		echo.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/string/trim", strings.NewReader(body)))
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	out := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE dl_http_requests_total counter\n",
+		`dl_http_requests_total{tool="string/trim"} 4` + "\n",
+		`dl_http_requests_total{tool="json/diff"} 0` + "\n",
+		`dl_http_request_errors_total{tool="string/trim",class="4xx"} 1` + "\n",
+		`dl_http_request_errors_total{tool="string/trim",class="5xx"} 1` + "\n",
+		`dl_http_request_errors_total{tool="json/diff",class="4xx"} 0` + "\n",
+		"# TYPE dl_http_request_duration_seconds histogram\n",
+		`dl_http_request_duration_seconds_count{tool="string/trim"} 4` + "\n",
+		`dl_http_request_size_bytes_bucket{tool="string/trim",le="64"} 3` + "\n",
+		`dl_http_request_size_bytes_bucket{tool="string/trim",le="256"} 3` + "\n",
+		`dl_http_request_size_bytes_bucket{tool="string/trim",le="1024"} 4` + "\n",
+		`dl_http_request_size_bytes_bucket{tool="string/trim",le="+Inf"} 4` + "\n",
+		`dl_http_request_size_bytes_sum{tool="string/trim"} 312` + "\n",
+		// "hello" + 300 x's + "nope\n"; the 500 has no body.
+		`dl_http_response_size_bytes_sum{tool="string/trim"} 310` + "\n",
+		"dl_http_requests_in_flight 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+	// json/diff sorts before string/trim.
+	if strings.Index(out, `tool="json/diff"`) > strings.Index(out, `tool="string/trim"`) {
+		t.Errorf("series are not sorted by tool")
+	}
+}
+
+func TestLabelsEscape(t *testing.T) {
+	got := labels("tool", "a\"b\\c\nd")
+	want := `{tool="a\"b\\c\nd"}`
+	if got != want {
+		t.Errorf("labels = %s, want %s", got, want)
+	}
+}
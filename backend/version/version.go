@@ -0,0 +1,42 @@
+// Package version holds build metadata. Version and Commit are set at link time:
+//
+//	go build -ldflags "-X digi-leatherman/backend/version.Version=1.2.0 -X digi-leatherman/backend/version.Commit=abc1234"
+//
+// `make build` does this from git describe.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is the release version, e.g. "1.2.0"; "dev" for local builds.
+	Version = "dev"
+	// Commit is the git commit the binary was built from. When not set by ldflags it falls
+	// back to the VCS revision recorded by the go command, if any.
+	Commit = ""
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build metadata of the running binary.
+func Get() Info {
+	commit := Commit
+	if commit == "" {
+		commit = "unknown"
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				if s.Key == "vcs.revision" {
+					commit = s.Value
+				}
+			}
+		}
+	}
+	return Info{Version: Version, Commit: commit, GoVersion: runtime.Version()}
+}
@@ -0,0 +1,22 @@
+//go:build embedui
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is a copy of frontend/dist made by `make build`.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Assets returns the embedded UI.
+func Assets() (fs.FS, bool) {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil, false
+	}
+	return sub, true
+}
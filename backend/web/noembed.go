@@ -0,0 +1,11 @@
+//go:build !embedui
+
+package web
+
+import "io/fs"
+
+// Assets reports false: this binary was built without the embedui tag, so the frontend
+// runs separately (npm run dev) and the server only exposes the API.
+func Assets() (fs.FS, bool) {
+	return nil, false
+}
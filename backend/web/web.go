@@ -0,0 +1,164 @@
+// Package web serves the built React frontend (frontend/dist) from the Go binary.
+//
+// Build with `make build` (or copy frontend/dist to backend/web/dist and run
+// `go build -tags embedui`) to embed the UI; without the tag Assets reports no UI and the
+// server only exposes the API.
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexFile is served for "/" and as the fallback for client-side routes such as /tools/....
+const indexFile = "index.html"
+
+// Cache-Control values. Vite content-hashes everything under assets/, so those files never
+// change; index.html and the unhashed public files must be revalidated (by ETag).
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+)
+
+// encodings lists the precompressed variants in order of preference, by file suffix.
+var encodings = []struct{ name, suffix string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// asset is one file of the UI with its precompressed variants (keyed by encoding name).
+type asset struct {
+	data     []byte
+	etag     string
+	variants map[string]*asset
+}
+
+// Handler serves the single-page app in fsys (the contents of frontend/dist). Files are
+// loaded into memory once; requests for paths without a file extension that match no
+// file get index.html so the client-side router can handle them.
+func Handler(fsys fs.FS) (http.Handler, error) {
+	assets := make(map[string]*asset)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		assets[name] = &asset{data: data, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := assets[indexFile]; !ok {
+		return nil, fs.ErrNotExist
+	}
+	// Attach each .br/.gz file to its original; the variants are not served on their own.
+	for name, a := range assets {
+		for _, enc := range encodings {
+			if v, ok := assets[name+enc.suffix]; ok {
+				if a.variants == nil {
+					a.variants = make(map[string]*asset)
+				}
+				a.variants[enc.name] = v
+				delete(assets, name+enc.suffix)
+			}
+		}
+	}
+	return &spa{assets: assets}, nil
+}
+
+type spa struct {
+	assets map[string]*asset
+}
+
+func (s *spa) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = indexFile
+	}
+	a, ok := s.assets[name]
+	if !ok {
+		// Missing files (e.g. an old hashed bundle) are real 404s; anything else is a route.
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name, a = indexFile, s.assets[indexFile]
+	}
+
+	h := w.Header()
+	if strings.HasPrefix(name, "assets/") {
+		h.Set("Cache-Control", cacheImmutable)
+	} else {
+		h.Set("Cache-Control", cacheRevalidate)
+	}
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		ctype = http.DetectContentType(a.data)
+	}
+	h.Set("Content-Type", ctype)
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	body, etag := a.data, a.etag
+	if len(a.variants) > 0 {
+		h.Add("Vary", "Accept-Encoding")
+		accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+		for _, enc := range encodings {
+			if v, ok := a.variants[enc.name]; ok && accepted(enc.name) {
+				h.Set("Content-Encoding", enc.name)
+				// A distinct validator per representation, as the bytes differ.
+				body, etag = v.data, strings.TrimSuffix(a.etag, `"`)+"-"+enc.name+`"`
+				break
+			}
+		}
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// acceptedEncodings parses an Accept-Encoding header into a predicate. Codings with q=0
+// are refused; "*" accepts any coding not listed explicitly.
+func acceptedEncodings(header string) func(string) bool {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		weight := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					weight = f
+				}
+			}
+		}
+		q[coding] = weight
+	}
+	return func(coding string) bool {
+		if w, ok := q[coding]; ok {
+			return w > 0
+		}
+		w, ok := q["*"]
+		return ok && w > 0
+	}
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+var testDist = fstest.MapFS{
+	"index.html":             {Data: []byte("<!doctype html><div id=root></div>")},
+	"index.html.gz":          {Data: []byte("gz-index")},
+	"favicon.svg":            {Data: []byte("<svg></svg>")},
+	"assets/index-abc.js":    {Data: []byte("console.log(1)")},
+	"assets/index-abc.js.br": {Data: []byte("br-js")},
+	"assets/index-abc.js.gz": {Data: []byte("gz-js")},
+}
+
+func serve(t *testing.T, method, target string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	h, err := Handler(testDist)
+	if err != nil {
+		t.Fatalf("Handler: %v", err)
+	}
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		method       string
+		target       string
+		encoding     string
+		wantStatus   int
+		wantBody     string
+		wantType     string
+		wantCache    string
+		wantEncoding string
+	}{
+		{"root", "GET", "/", "", 200, "<!doctype html><div id=root></div>", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"tool route falls back to index", "GET", "/tools/string/snake-case", "", 200, "<!doctype html><div id=root></div>", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"fallback gzip", "GET", "/tools/json/format", "gzip, deflate", 200, "gz-index", "text/html; charset=utf-8", cacheRevalidate, "gzip"},
+		{"hashed asset", "GET", "/assets/index-abc.js", "", 200, "console.log(1)", "text/javascript; charset=utf-8", cacheImmutable, ""},
+		{"brotli preferred", "GET", "/assets/index-abc.js", "gzip, br", 200, "br-js", "text/javascript; charset=utf-8", cacheImmutable, "br"},
+		{"brotli refused", "GET", "/assets/index-abc.js", "br;q=0, gzip", 200, "gz-js", "text/javascript; charset=utf-8", cacheImmutable, "gzip"},
+		{"wildcard encoding", "GET", "/assets/index-abc.js", "*", 200, "br-js", "text/javascript; charset=utf-8", cacheImmutable, "br"},
+		{"public file", "GET", "/favicon.svg", "br", 200, "<svg></svg>", "image/svg+xml", cacheRevalidate, ""},
+		{"missing asset", "GET", "/assets/index-old.js", "", 404, "", "", "", ""},
+		{"variant not served directly", "GET", "/assets/index-abc.js.br", "", 404, "", "", "", ""},
+		{"traversal stays inside", "GET", "/../index.html", "", 200, "<!doctype html><div id=root></div>", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"head", "HEAD", "/", "", 200, "", "text/html; charset=utf-8", cacheRevalidate, ""},
+		{"post", "POST", "/", "", 405, "", "", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, tc.method, tc.target, map[string]string{"Accept-Encoding": tc.encoding})
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantStatus != 200 {
+				return
+			}
+			if got := rec.Body.String(); got != tc.wantBody {
+				t.Errorf("body = %q, want %q", got, tc.wantBody)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tc.wantType)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != tc.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tc.wantCache)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tc.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	first := serve(t, "GET", "/assets/index-abc.js", map[string]string{"Accept-Encoding": "br"})
+	etag := first.Header().Get("ETag")
+	if etag == "" || first.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("ETag = %q, Vary = %q", etag, first.Header().Get("Vary"))
+	}
+	plain := serve(t, "GET", "/assets/index-abc.js", nil)
+	if plain.Header().Get("ETag") == etag {
+		t.Errorf("identity and brotli responses share ETag %s", etag)
+	}
+	again := serve(t, "GET", "/assets/index-abc.js", map[string]string{"Accept-Encoding": "br", "If-None-Match": etag})
+	if again.Code != http.StatusNotModified {
+		t.Errorf("status = %d, want 304", again.Code)
+	}
+}
+
+func TestHandlerRequiresIndex(t *testing.T) {
+	if _, err := Handler(fstest.MapFS{"app.js": {Data: []byte("x")}}); err == nil {
+		t.Errorf("expected an error without index.html")
+	}
+}
@@ -0,0 +1,51 @@
+/**
+ * Writes .gz and .br siblings for the text assets in dist/ so the Go server can serve
+ * them precompressed. Runs after `vite build` (see the "build" script).
+ * Run: node scripts/compress-dist.mjs
+ */
+import fs from "fs";
+import path from "path";
+import zlib from "zlib";
+import { fileURLToPath } from "url";
+
+const __dirname = path.dirname(fileURLToPath(import.meta.url));
+const distDir = path.join(__dirname, "..", "dist");
+
+const compressible = new Set([".html", ".js", ".mjs", ".css", ".svg", ".json", ".txt", ".map", ".ico", ".webmanifest"]);
+// Below this size the encoding overhead outweighs the savings.
+const minSize = 1024;
+
+function* walk(dir) {
+  for (const entry of fs.readdirSync(dir, { withFileTypes: true })) {
+    const full = path.join(dir, entry.name);
+    if (entry.isDirectory()) {
+      yield* walk(full);
+    } else {
+      yield full;
+    }
+  }
+}
+
+let written = 0;
+for (const file of walk(distDir)) {
+  if (!compressible.has(path.extname(file))) continue;
+  const data = fs.readFileSync(file);
+  if (data.length < minSize) continue;
+  const variants = {
+    ".gz": zlib.gzipSync(data, { level: 9 }),
+    ".br": zlib.brotliCompressSync(data, {
+      params: {
+        [zlib.constants.BROTLI_PARAM_QUALITY]: zlib.constants.BROTLI_MAX_QUALITY,
+        [zlib.constants.BROTLI_PARAM_SIZE_HINT]: data.length,
+      },
+    }),
+  };
+  for (const [ext, compressed] of Object.entries(variants)) {
+    // Only keep a variant that is actually smaller than the original.
+    if (compressed.length < data.length) {
+      fs.writeFileSync(file + ext, compressed);
+      written++;
+    }
+  }
+}
+console.log(`compress-dist: wrote ${written} precompressed files`);
@@ -0,0 +1,4 @@
+// In development the Vite dev server and the Go API run on different ports. Production
+// builds are served by the Go binary itself, so API calls default to the same origin.
+export const API_BASE: string =
+  import.meta.env.VITE_API_URL ?? (import.meta.env.DEV ? 'http://localhost:8100' : '');
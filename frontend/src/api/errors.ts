@@ -0,0 +1,38 @@
+/** Error envelope returned by the backend for every non-2xx response. */
+export interface ApiErrorBody {
+  code: string;
+  message: string;
+  field?: string;
+  details?: Record<string, unknown>;
+}
+
+/** Error thrown by the API helpers; `code` is machine-readable (e.g. `count_out_of_range`). */
+export class ApiError extends Error {
+  code: string;
+  field?: string;
+  details?: Record<string, unknown>;
+  status: number;
+
+  constructor(status: number, body: ApiErrorBody) {
+    super(body.message);
+    this.name = 'ApiError';
+    this.status = status;
+    this.code = body.code;
+    this.field = body.field;
+    this.details = body.details;
+  }
+}
+
+/** Builds an ApiError from a failed response, falling back to the raw text for non-JSON bodies. */
+export async function errorFromResponse(res: Response): Promise<Error> {
+  const text = await res.text();
+  try {
+    const parsed = JSON.parse(text) as { error?: ApiErrorBody };
+    if (parsed.error?.code) {
+      return new ApiError(res.status, parsed.error);
+    }
+  } catch {
+    // not JSON; use the text below
+  }
+  return new Error(text || `HTTP ${res.status}`);
+}
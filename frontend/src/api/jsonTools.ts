@@ -1,12 +1,57 @@
-const API_BASE = import.meta.env.VITE_API_URL ?? 'http://localhost:8100';
+import { API_BASE } from './base';
+import { errorFromResponse } from './errors';
 
 export interface JsonResult {
   result: string;
 }
 
+export interface PathMatch {
+  path: string;
+  value: unknown;
+}
+
+export interface PathResult extends JsonResult {
+  matches: PathMatch[];
+}
+
+export type PathSyntax = 'dot' | 'jsonpath' | 'pointer';
+
+export interface SyntaxDetail {
+  message: string;
+  offset: number;
+  line: number;
+  column: number;
+  snippet: string;
+  expected?: string;
+}
+
+export interface LintWarning {
+  code: 'duplicate_key' | 'imprecise_number' | 'lone_surrogate' | 'bom' | 'trailing_data';
+  message: string;
+  path: string;
+  offset: number;
+  line: number;
+  column: number;
+}
+
 export interface ValidateResult {
   valid: boolean;
   error?: string;
+  syntax?: SyntaxDetail;
+  warnings?: LintWarning[];
+}
+
+export interface SchemaViolation {
+  instancePath: string;
+  schemaPath: string;
+  keyword: string;
+  message: string;
+  causes?: SchemaViolation[];
+}
+
+export interface SchemaResult {
+  valid: boolean;
+  violations: SchemaViolation[];
 }
 
 async function postJson(path: string, body: object): Promise<Response> {
@@ -17,20 +62,60 @@ async function postJson(path: string, body: object): Promise<Response> {
   });
 }
 
-export async function formatJson(value: string): Promise<JsonResult> {
-  const res = await postJson('/api/json/format', { value });
+export interface FormatOptions {
+  indent?: number;
+  tabs?: boolean;
+  sortKeys?: boolean;
+  compactArrays?: boolean;
+  lenient?: boolean;
+}
+
+export async function formatJson(value: string, options: FormatOptions = {}): Promise<JsonResult> {
+  const res = await postJson('/api/json/format', { value, ...options });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
 
-export async function minifyJson(value: string): Promise<JsonResult> {
-  const res = await postJson('/api/json/minify', { value });
+export async function minifyJson(value: string, lenient?: boolean): Promise<JsonResult> {
+  const res = await postJson('/api/json/minify', { value, lenient });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export type CanonicalHash = 'sha256' | 'sha512';
+
+export interface CanonicalResult extends JsonResult {
+  digest?: string;
+}
+
+export async function canonicalizeJson(value: string, hash?: CanonicalHash): Promise<CanonicalResult> {
+  const res = await postJson('/api/json/canonicalize', { value, hash });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export interface RepairFix {
+  kind: string;
+  message: string;
+  offset: number;
+  line: number;
+  column: number;
+}
+
+export interface RepairResult extends JsonResult {
+  fixes: RepairFix[];
+}
+
+export async function repairJson(value: string): Promise<RepairResult> {
+  const res = await postJson('/api/json/repair', { value });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
@@ -38,26 +123,134 @@ export async function minifyJson(value: string): Promise<JsonResult> {
 export async function validateJson(value: string): Promise<ValidateResult> {
   const res = await postJson('/api/json/validate', { value });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function validateSchemaJson(value: string, schema: string): Promise<SchemaResult> {
+  const res = await postJson('/api/json/validate-schema', { value, schema });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function pathQueryJson(
+  value: string,
+  path: string,
+  syntax?: PathSyntax,
+  pathFormat?: 'normalized' | 'pointer',
+  lenient?: boolean,
+): Promise<PathResult> {
+  const res = await postJson('/api/json/path', { value, path, syntax, pathFormat, lenient });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function pointerJson(value: string, pointer: string): Promise<JsonResult> {
+  const res = await postJson('/api/json/pointer', { value, pointer });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export interface JqOptions {
+  slurp?: boolean;
+  nullInput?: boolean;
+  raw?: boolean;
+  compact?: boolean;
+}
+
+export interface JqResult extends JsonResult {
+  outputs: unknown[];
+}
+
+export async function jqJson(value: string, filter: string, options: JqOptions = {}): Promise<JqResult> {
+  const res = await postJson('/api/json/jq', { value, filter, ...options });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function inferSchemaJson(value: string): Promise<JsonResult> {
+  const res = await postJson('/api/json/infer-schema', { value });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function goStructJson(value: string, name?: string): Promise<JsonResult> {
+  const res = await postJson('/api/json/go-struct', { value, name });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function typeScriptJson(value: string, zod = false): Promise<JsonResult> {
+  const res = await postJson('/api/json/typescript', { value, zod });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export type DiffArrayMode = 'index' | 'lcs' | 'key' | 'set';
+
+export interface DiffOptions {
+  pathFormat?: 'dot' | 'pointer';
+  output?: 'text' | 'patch';
+  arrayMode?: DiffArrayMode;
+  arrayKey?: string;
+  ignore?: string[];
+  tolerance?: number;
+  lenient?: boolean;
+}
+
+export async function diffJson(valueA: string, valueB: string, options: DiffOptions = {}): Promise<JsonResult> {
+  const res = await postJson('/api/json/diff', { valueA, valueB, ...options });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export interface MergeWarning {
+  path: string;
+  message: string;
+}
+
+export interface MergeDiffResult {
+  result: string;
+  warnings: MergeWarning[];
+}
+
+export async function mergeDiffJson(valueA: string, valueB: string): Promise<MergeDiffResult> {
+  const res = await postJson('/api/json/merge-diff', { valueA, valueB });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
 
-export async function pathQueryJson(value: string, path: string): Promise<JsonResult> {
-  const res = await postJson('/api/json/path', { value, path });
+export async function mergePatchJson(value: string, patch: string): Promise<JsonResult> {
+  const res = await postJson('/api/json/merge-patch', { value, patch });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
 
-export async function diffJson(valueA: string, valueB: string): Promise<JsonResult> {
-  const res = await postJson('/api/json/diff', { valueA, valueB });
+export async function patchJson(value: string, patch: string): Promise<JsonResult> {
+  const res = await postJson('/api/json/patch', { value, patch });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
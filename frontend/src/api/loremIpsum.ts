@@ -1,4 +1,5 @@
-const API_BASE = import.meta.env.VITE_API_URL ?? 'http://localhost:8100';
+import { API_BASE } from './base';
+import { errorFromResponse } from './errors';
 
 export interface StringResult {
   result: string;
@@ -43,8 +44,7 @@ export async function generate(request: LoremRequest): Promise<StringResult> {
     body: JSON.stringify(request),
   });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
@@ -1,4 +1,5 @@
-const API_BASE = import.meta.env.VITE_API_URL ?? 'http://localhost:8100';
+import { API_BASE } from './base';
+import { errorFromResponse } from './errors';
 
 export interface StringResult {
   result: string;
@@ -11,8 +12,7 @@ async function postString(path: string, value: string): Promise<StringResult> {
     body: JSON.stringify({ value }),
   });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
@@ -84,8 +84,7 @@ export async function spellOut(value: string, alphabet?: string): Promise<String
     body: JSON.stringify({ value, alphabet: alphabet ?? 'nato' }),
   });
   if (!res.ok) {
-    const text = await res.text();
-    throw new Error(text || `HTTP ${res.status}`);
+    throw await errorFromResponse(res);
   }
   return res.json();
 }
@@ -0,0 +1,78 @@
+import { API_BASE } from './base';
+import { errorFromResponse } from './errors';
+
+export type YamlVersion = '1.2' | '1.1';
+
+export type YamlDocuments = 'auto' | 'array' | 'ndjson';
+
+export interface YamlResult {
+  result: string;
+}
+
+export interface YamlWarning {
+  code: 'version_difference' | 'non_finite' | 'custom_tag';
+  message: string;
+  path: string;
+  line: number;
+  column: number;
+}
+
+export interface YamlToJsonResult extends YamlResult {
+  documents: number;
+  warnings?: YamlWarning[];
+}
+
+export interface YamlValidateResult {
+  valid: boolean;
+  error?: string;
+  line?: number;
+  column?: number;
+  documents: number;
+  warnings?: YamlWarning[];
+}
+
+async function postYaml(path: string, body: object): Promise<Response> {
+  return fetch(`${API_BASE}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+}
+
+export async function yamlToJson(value: string, version?: YamlVersion, documents?: YamlDocuments): Promise<YamlToJsonResult> {
+  const res = await postYaml('/api/yaml/to-json', { value, version, documents });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export interface JsonToYamlOptions {
+  indent?: number;
+  stream?: boolean;
+  lenient?: boolean;
+}
+
+export async function jsonToYaml(value: string, options: JsonToYamlOptions = {}): Promise<YamlResult> {
+  const res = await postYaml('/api/yaml/from-json', { value, ...options });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function formatYaml(value: string, indent?: number): Promise<YamlResult> {
+  const res = await postYaml('/api/yaml/format', { value, indent });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
+
+export async function validateYaml(value: string, version?: YamlVersion): Promise<YamlValidateResult> {
+  const res = await postYaml('/api/yaml/validate', { value, version });
+  if (!res.ok) {
+    throw await errorFromResponse(res);
+  }
+  return res.json();
+}
@@ -4,13 +4,26 @@ import { Button } from '@/components/ui/button';
 import {
   formatJson,
   minifyJson,
+  canonicalizeJson,
+  repairJson,
   validateJson,
+  validateSchemaJson,
   pathQueryJson,
+  pointerJson,
+  jqJson,
   diffJson,
+  patchJson,
+  mergeDiffJson,
+  mergePatchJson,
+  inferSchemaJson,
+  goStructJson,
+  typeScriptJson,
 } from '../api/jsonTools';
-import type { JsonResult, ValidateResult } from '../api/jsonTools';
+import type { CanonicalHash, CanonicalResult, DiffArrayMode, JsonResult, RepairResult, MergeDiffResult, SchemaResult, SchemaViolation, ValidateResult } from '../api/jsonTools';
+import type { RegistryToolId } from '../config/sidebarConfig';
 
-export type JsonToolId = 'format' | 'minify' | 'validate' | 'path' | 'diff';
+/** Tools this page renders: the registry's JSON tools. */
+export type JsonToolId = RegistryToolId<'json'>;
 
 type ToolConfig = {
   id: JsonToolId;
@@ -24,11 +37,17 @@ type ToolConfig = {
   buttonLabel: string;
 };
 
+// Tools that take a second JSON text (valueB) alongside the input.
+const TWO_INPUT_TOOLS: JsonToolId[] = ['validate-schema', 'diff', 'patch', 'merge-diff', 'merge-patch'];
+
+// Tools that accept JSON5 and JSONC input with "lenient": true.
+const LENIENT_TOOLS: JsonToolId[] = ['format', 'minify', 'path', 'diff'];
+
 const TOOL_CONFIG: ToolConfig[] = [
   {
     id: 'format',
     label: 'Format',
-    description: 'Pretty-print JSON with 2-space indentation.',
+    description: 'Pretty-print JSON, keeping key order and exact numbers.',
     example: { input: '{"a":1,"b":2}', output: '{\n  "a": 1,\n  "b": 2\n}' },
     placeholder: 'Paste JSON…',
     buttonLabel: 'Format',
@@ -41,6 +60,22 @@ const TOOL_CONFIG: ToolConfig[] = [
     placeholder: 'Paste JSON…',
     buttonLabel: 'Minify',
   },
+  {
+    id: 'canonicalize',
+    label: 'Canonicalize',
+    description: 'Write JSON in RFC 8785 canonical form (sorted keys, normalized numbers) for signing, optionally with its digest.',
+    example: { input: '{"b": 2.50, "a": 1e3}', output: '{"a":1000,"b":2.5}' },
+    placeholder: 'Paste JSON…',
+    buttonLabel: 'Canonicalize',
+  },
+  {
+    id: 'repair',
+    label: 'Repair',
+    description: 'Convert JSON5 or JSONC (comments, trailing commas, single quotes, unquoted keys) to strict JSON and list the fixes.',
+    example: { input: "{name: 'dl', // tool\n tags: [1, 2,],}", output: '{\n  "name": "dl",\n  "tags": [\n    1,\n    2\n  ]\n}' },
+    placeholder: 'Paste JSON5 or JSONC…',
+    buttonLabel: 'Repair',
+  },
   {
     id: 'validate',
     label: 'Validate',
@@ -49,15 +84,43 @@ const TOOL_CONFIG: ToolConfig[] = [
     placeholder: 'Paste JSON…',
     buttonLabel: 'Validate',
   },
+  {
+    id: 'validate-schema',
+    label: 'Schema validate',
+    description: 'Validate JSON against a JSON Schema (2020-12 or draft-07) and list every violation.',
+    example: { input: '{"age":-1}', output: '/age: must be >= 0 (minimum at /properties/age/minimum)' },
+    exampleB: '{"type":"object","properties":{"age":{"type":"integer","minimum":0}}}',
+    placeholder: 'Paste JSON…',
+    placeholderB: 'Paste JSON Schema…',
+    buttonLabel: 'Validate',
+  },
   {
     id: 'path',
     label: 'Path query',
-    description: 'Extract a value at a dot-separated path (e.g. "a.b" or "items.0.name").',
+    description: 'Extract a value at a dot-separated path (e.g. "a.b" or "items.0.name"), or query with RFC 9535 JSONPath (e.g. "$..book[?@.price < 10].title").',
     example: { input: '{"a":{"b":42}}', path: 'a.b', output: '42' },
     placeholder: 'Paste JSON…',
-    placeholderPath: 'e.g. a.b or items.0',
+    placeholderPath: 'e.g. a.b, items.0 or $.items[*].name',
     buttonLabel: 'Query',
   },
+  {
+    id: 'pointer',
+    label: 'JSON Pointer',
+    description: 'Return the value an RFC 6901 JSON Pointer refers to (e.g. "/items/0/name"; escape "~" as "~0" and "/" as "~1").',
+    example: { input: '{"a/b":{"c":[1,2]}}', path: '/a~1b/c/1', output: '2' },
+    placeholder: 'Paste JSON…',
+    placeholderPath: 'e.g. /items/0/name',
+    buttonLabel: 'Resolve',
+  },
+  {
+    id: 'jq',
+    label: 'jq',
+    description: 'Reshape JSON with a jq filter (map, select, object construction, sort_by, group_by, ...).',
+    example: { input: '[{"name":"ann","age":31},{"name":"bob","age":25}]', path: 'map(select(.age > 30) | .name)', output: '[\n  "ann"\n]' },
+    placeholder: 'Paste JSON…',
+    placeholderPath: 'e.g. .items[] | {name, id}',
+    buttonLabel: 'Run',
+  },
   {
     id: 'diff',
     label: 'Diff',
@@ -68,6 +131,60 @@ const TOOL_CONFIG: ToolConfig[] = [
     placeholderB: 'Second JSON…',
     buttonLabel: 'Diff',
   },
+  {
+    id: 'patch',
+    label: 'Patch',
+    description: 'Apply an RFC 6902 JSON Patch to a document. Nothing is applied if any operation fails.',
+    example: { input: '{"a":1,"b":[1]}', output: '{\n  "a": 2,\n  "b": [\n    1,\n    3\n  ]\n}' },
+    exampleB: '[{"op":"replace","path":"/a","value":2},{"op":"add","path":"/b/-","value":3}]',
+    placeholder: 'Paste JSON…',
+    placeholderB: 'Paste a JSON Patch…',
+    buttonLabel: 'Apply',
+  },
+  {
+    id: 'merge-diff',
+    label: 'Merge patch diff',
+    description: 'Compute the RFC 7386 JSON Merge Patch that turns the first value into the second.',
+    example: { input: '{"a":1,"b":2}', output: '{\n  "a": 3,\n  "b": null\n}' },
+    exampleB: '{"a":3}',
+    placeholder: 'First JSON…',
+    placeholderB: 'Second JSON…',
+    buttonLabel: 'Diff',
+  },
+  {
+    id: 'merge-patch',
+    label: 'Merge patch',
+    description: 'Apply an RFC 7386 JSON Merge Patch to a document: null removes a member, objects merge and anything else replaces.',
+    example: { input: '{"a":1,"b":{"c":2}}', output: '{\n  "b": {\n    "c": 2,\n    "d": 4\n  }\n}' },
+    exampleB: '{"a":null,"b":{"d":4}}',
+    placeholder: 'Paste JSON…',
+    placeholderB: 'Paste a merge patch…',
+    buttonLabel: 'Apply',
+  },
+  {
+    id: 'infer-schema',
+    label: 'Infer schema',
+    description: 'Infer a JSON Schema from one or more sample documents (one per line), with required fields, enums and formats.',
+    example: { input: '{"id":1,"email":"a@example.com"}\n{"id":2}', output: '{ "type": "object", "required": ["id"], ... }' },
+    placeholder: 'Paste sample JSON documents…',
+    buttonLabel: 'Infer',
+  },
+  {
+    id: 'go-struct',
+    label: 'Go structs',
+    description: 'Generate Go struct declarations with json tags from one or more sample documents.',
+    example: { input: '{"user_id":1,"avatarURL":"https://example.com/a.png"}', output: 'type Root struct {\n\tUserID    int64  `json:"user_id"`\n\tAvatarURL string `json:"avatarURL"`\n}' },
+    placeholder: 'Paste sample JSON documents…',
+    buttonLabel: 'Generate',
+  },
+  {
+    id: 'typescript',
+    label: 'TypeScript',
+    description: 'Generate TypeScript interfaces, and optionally Zod schemas, from sample JSON or a JSON Schema.',
+    example: { input: '[{"id":1,"tags":["a"]},{"id":2}]', output: 'export type Root = RootItem[];\n\nexport interface RootItem {\n  id: number;\n  tags?: string[];\n}' },
+    placeholder: 'Paste sample JSON or a JSON Schema…',
+    buttonLabel: 'Generate',
+  },
 ];
 
 /** Map of tool id to description for command palette search. */
@@ -77,6 +194,16 @@ export const JSON_TOOL_DESCRIPTIONS: Record<string, string> = Object.fromEntries
 
 const TOOL_MAP = Object.fromEntries(TOOL_CONFIG.map((c) => [c.id, c])) as Record<JsonToolId, ToolConfig>;
 
+/** Renders schema violations one per line, with anyOf/oneOf causes indented below. */
+function formatViolations(violations: SchemaViolation[], indent = ''): string {
+  return violations
+    .map((v) => {
+      const line = `${indent}${v.instancePath || '(root)'}: ${v.message} (${v.keyword} at ${v.schemaPath || '#'})`;
+      return v.causes?.length ? `${line}\n${formatViolations(v.causes, indent + '  ')}` : line;
+    })
+    .join('\n');
+}
+
 type JsonToolsProps = {
   tool?: JsonToolId;
 };
@@ -86,6 +213,15 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
   const [input, setInput] = useState('');
   const [pathInput, setPathInput] = useState('');
   const [valueB, setValueB] = useState('');
+  const [zod, setZod] = useState(false);
+  const [indent, setIndent] = useState('2');
+  const [sortKeys, setSortKeys] = useState(false);
+  const [compactArrays, setCompactArrays] = useState(false);
+  const [hash, setHash] = useState<CanonicalHash | ''>('');
+  const [lenient, setLenient] = useState(false);
+  const [asPatch, setAsPatch] = useState(false);
+  const [arrayMode, setArrayMode] = useState<DiffArrayMode>('index');
+  const [arrayKey, setArrayKey] = useState('id');
   const [output, setOutput] = useState('');
   const [error, setError] = useState<string | null>(null);
   const [loading, setLoading] = useState(false);
@@ -107,23 +243,74 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
     setLoading(true);
     try {
       if (tool === 'format') {
-        const res: JsonResult = await formatJson(input);
+        const res: JsonResult = await formatJson(input, {
+          indent: indent === 'tab' ? undefined : Number(indent),
+          tabs: indent === 'tab',
+          sortKeys,
+          compactArrays,
+          lenient,
+        });
         setOutput(res.result);
       } else if (tool === 'minify') {
-        const res: JsonResult = await minifyJson(input);
+        const res: JsonResult = await minifyJson(input, lenient);
         setOutput(res.result);
+      } else if (tool === 'repair') {
+        const res: RepairResult = await repairJson(input);
+        const fixes = res.fixes.map((f) => `Line ${f.line}, column ${f.column}: ${f.message}`);
+        setOutput(fixes.length ? `${res.result}\n\n${fixes.join('\n')}` : res.result);
+      } else if (tool === 'canonicalize') {
+        const res: CanonicalResult = await canonicalizeJson(input, hash || undefined);
+        setOutput(res.digest ? `${res.result}\n\n${hash}: ${res.digest}` : res.result);
       } else if (tool === 'validate') {
         const res: ValidateResult = await validateJson(input);
-        setOutput(res.valid ? 'Valid' : `Invalid: ${res.error ?? 'syntax error'}`);
+        const warnings = (res.warnings ?? []).map((w) => `Warning: line ${w.line}, column ${w.column}: ${w.message}`);
+        const verdict = res.valid
+          ? 'Valid'
+          : `Invalid: ${res.error ?? 'syntax error'}${res.syntax ? `\n\n${res.syntax.snippet}` : ''}`;
+        setOutput([verdict, ...warnings].join('\n'));
+      } else if (tool === 'validate-schema') {
+        const res: SchemaResult = await validateSchemaJson(input, valueB);
+        setOutput(res.valid ? 'Valid' : formatViolations(res.violations));
       } else if (tool === 'path') {
         if (!pathInput.trim()) {
           setError('Enter a path');
           return;
         }
-        const res: JsonResult = await pathQueryJson(input, pathInput);
+        const res: JsonResult = await pathQueryJson(input, pathInput, undefined, undefined, lenient);
+        setOutput(res.result);
+      } else if (tool === 'pointer') {
+        const res: JsonResult = await pointerJson(input, pathInput);
+        setOutput(res.result);
+      } else if (tool === 'jq') {
+        const res: JsonResult = await jqJson(input, pathInput);
         setOutput(res.result);
       } else if (tool === 'diff') {
-        const res: JsonResult = await diffJson(input, valueB);
+        const res: JsonResult = await diffJson(input, valueB, {
+          output: asPatch ? 'patch' : 'text',
+          // A JSON Patch always pairs array elements itself.
+          arrayMode: asPatch ? undefined : arrayMode,
+          arrayKey: !asPatch && arrayMode === 'key' ? arrayKey : undefined,
+          lenient,
+        });
+        setOutput(res.result);
+      } else if (tool === 'patch') {
+        const res: JsonResult = await patchJson(input, valueB);
+        setOutput(res.result);
+      } else if (tool === 'merge-diff') {
+        const res: MergeDiffResult = await mergeDiffJson(input, valueB);
+        const warnings = res.warnings.map((w) => `Warning: ${w.path}: ${w.message}`);
+        setOutput([res.result, ...warnings].join('\n'));
+      } else if (tool === 'merge-patch') {
+        const res: JsonResult = await mergePatchJson(input, valueB);
+        setOutput(res.result);
+      } else if (tool === 'infer-schema') {
+        const res: JsonResult = await inferSchemaJson(input);
+        setOutput(res.result);
+      } else if (tool === 'go-struct') {
+        const res: JsonResult = await goStructJson(input);
+        setOutput(res.result);
+      } else if (tool === 'typescript') {
+        const res: JsonResult = await typeScriptJson(input, zod);
         setOutput(res.result);
       }
     } catch (e) {
@@ -141,7 +328,8 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
 
   const canRun =
     tool === 'path' ? input.trim() && pathInput.trim() :
-    tool === 'diff' ? input.trim() && valueB.trim() :
+    tool === 'pointer' || tool === 'jq' ? input.trim().length > 0 :
+    TWO_INPUT_TOOLS.includes(tool) ? input.trim() && valueB.trim() :
     input.trim().length > 0;
 
   const handleCopy = async () => {
@@ -193,7 +381,7 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
       </p>
       <div className="flex flex-col gap-3 max-w-2xl">
         <label htmlFor="json-input" className="font-medium">
-          {tool === 'diff' ? 'JSON A' : 'Input'}
+          {tool === 'diff' || tool === 'merge-diff' ? 'JSON A' : 'Input'}
         </label>
         <textarea
           id="json-input"
@@ -203,9 +391,9 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
           placeholder={config.placeholder}
           rows={4}
         />
-        {tool === 'path' && (
+        {(tool === 'path' || tool === 'pointer' || tool === 'jq') && (
           <>
-            <label htmlFor="json-path" className="font-medium">Path</label>
+            <label htmlFor="json-path" className="font-medium">{tool === 'pointer' ? 'Pointer' : tool === 'jq' ? 'Filter' : 'Path'}</label>
             <input
               id="json-path"
               type="text"
@@ -216,9 +404,11 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
             />
           </>
         )}
-        {tool === 'diff' && (
+        {TWO_INPUT_TOOLS.includes(tool) && (
           <>
-            <label htmlFor="json-input-b" className="font-medium">JSON B</label>
+            <label htmlFor="json-input-b" className="font-medium">
+              {tool === 'diff' || tool === 'merge-diff' ? 'JSON B' : tool === 'validate-schema' ? 'Schema' : 'Patch'}
+            </label>
             <textarea
               id="json-input-b"
               className={textareaClass}
@@ -229,6 +419,87 @@ export function JsonTools({ tool: toolProp }: JsonToolsProps) {
             />
           </>
         )}
+        {tool === 'diff' && (
+          <>
+            <label className="flex items-center gap-2">
+              Match array elements
+              <select
+                className="p-1.5 rounded border border-border bg-bg text-text"
+                value={arrayMode}
+                disabled={asPatch}
+                onChange={(e) => setArrayMode(e.target.value as DiffArrayMode)}
+              >
+                <option value="index">by position</option>
+                <option value="lcs">by longest common subsequence</option>
+                <option value="key">by key</option>
+                <option value="set">ignoring order</option>
+              </select>
+              {arrayMode === 'key' && (
+                <input
+                  type="text"
+                  aria-label="Array key"
+                  className="p-1.5 font-mono text-sm rounded border border-border bg-bg text-text"
+                  value={arrayKey}
+                  onChange={(e) => setArrayKey(e.target.value)}
+                />
+              )}
+            </label>
+            <label className="flex items-center gap-2">
+              <input type="checkbox" checked={asPatch} onChange={(e) => setAsPatch(e.target.checked)} />
+              Output as JSON Patch (RFC 6902)
+            </label>
+          </>
+        )}
+        {tool === 'format' && (
+          <>
+            <label className="flex items-center gap-2">
+              Indent
+              <select
+                className="p-1.5 rounded border border-border bg-bg text-text"
+                value={indent}
+                onChange={(e) => setIndent(e.target.value)}
+              >
+                <option value="2">2 spaces</option>
+                <option value="4">4 spaces</option>
+                <option value="tab">Tabs</option>
+              </select>
+            </label>
+            <label className="flex items-center gap-2">
+              <input type="checkbox" checked={sortKeys} onChange={(e) => setSortKeys(e.target.checked)} />
+              Sort keys
+            </label>
+            <label className="flex items-center gap-2">
+              <input type="checkbox" checked={compactArrays} onChange={(e) => setCompactArrays(e.target.checked)} />
+              Keep arrays of scalars on one line
+            </label>
+          </>
+        )}
+        {LENIENT_TOOLS.includes(tool) && (
+          <label className="flex items-center gap-2">
+            <input type="checkbox" checked={lenient} onChange={(e) => setLenient(e.target.checked)} />
+            Allow JSON5 / JSONC (comments, trailing commas, single quotes)
+          </label>
+        )}
+        {tool === 'canonicalize' && (
+          <label className="flex items-center gap-2">
+            Digest
+            <select
+              className="p-1.5 rounded border border-border bg-bg text-text"
+              value={hash}
+              onChange={(e) => setHash(e.target.value as CanonicalHash | '')}
+            >
+              <option value="">None</option>
+              <option value="sha256">SHA-256</option>
+              <option value="sha512">SHA-512</option>
+            </select>
+          </label>
+        )}
+        {tool === 'typescript' && (
+          <label className="flex items-center gap-2">
+            <input type="checkbox" checked={zod} onChange={(e) => setZod(e.target.checked)} />
+            Also generate Zod schemas
+          </label>
+        )}
         <button type="button" onClick={run} disabled={loading || !canRun}>
           {loading ? '…' : config.buttonLabel}
         </button>
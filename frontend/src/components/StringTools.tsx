@@ -20,24 +20,10 @@ import {
   spellOut,
 } from '../api/stringTools';
 import type { StringResult } from '../api/stringTools';
+import type { RegistryToolId } from '../config/sidebarConfig';
 
-export type StringToolId =
-  | 'url-encode'
-  | 'url-decode'
-  | 'url-parse-params'
-  | 'url-param-creator'
-  | 'base64-encode'
-  | 'base64-decode'
-  | 'trim'
-  | 'upper-case'
-  | 'lower-case'
-  | 'capital-case'
-  | 'snake-case'
-  | 'kebab-case'
-  | 'camel-case'
-  | 'pascal-case'
-  | 'sentence-case'
-  | 'spell-out';
+/** Tools this page renders: the registry's string tools. */
+export type StringToolId = RegistryToolId<'string'>;
 
 type ToolConfig = {
   id: StringToolId;
@@ -0,0 +1,268 @@
+import { useRef, useState } from 'react';
+import { Copy } from 'lucide-react';
+import { Button } from '@/components/ui/button';
+import { yamlToJson, jsonToYaml, formatYaml, validateYaml } from '../api/yamlTools';
+import type { YamlDocuments, YamlResult, YamlToJsonResult, YamlValidateResult, YamlVersion, YamlWarning } from '../api/yamlTools';
+import type { RegistryToolId } from '../config/sidebarConfig';
+
+/** Tools this page renders: the registry's YAML tools. */
+export type YamlToolId = RegistryToolId<'yaml'>;
+
+type ToolConfig = {
+  id: YamlToolId;
+  label: string;
+  description: string;
+  example: { input: string; output?: string };
+  placeholder: string;
+  buttonLabel: string;
+};
+
+const TOOL_CONFIG: ToolConfig[] = [
+  {
+    id: 'to-json',
+    label: 'YAML to JSON',
+    description: 'Convert a YAML stream to JSON, expanding anchors and merge keys, with YAML 1.1 or 1.2 typing of yes/no/on/off and octals.',
+    example: { input: 'base: &b {replicas: 2}\nprod:\n  <<: *b\n  enabled: on', output: '{"base": {"replicas": 2}, "prod": {"replicas": 2, "enabled": "on"}}' },
+    placeholder: 'Paste YAML (several documents separated by ---)…',
+    buttonLabel: 'Convert',
+  },
+  {
+    id: 'from-json',
+    label: 'JSON to YAML',
+    description: 'Convert JSON to YAML, keeping key order and exact numbers, optionally as one document per array element.',
+    example: { input: '{"kind":"Service","on":"yes"}', output: 'kind: Service\n"on": "yes"' },
+    placeholder: 'Paste JSON…',
+    buttonLabel: 'Convert',
+  },
+  {
+    id: 'format',
+    label: 'Format',
+    description: 'Re-indent a YAML stream, keeping comments, anchors, tags and quoting.',
+    example: { input: 'a:   1 # one\nb:\n      - x', output: 'a: 1 # one\nb:\n  - x' },
+    placeholder: 'Paste YAML…',
+    buttonLabel: 'Format',
+  },
+  {
+    id: 'validate',
+    label: 'Validate',
+    description: 'Check that a YAML stream parses and converts to JSON, with the line of the first problem and YAML 1.1/1.2 differences.',
+    example: { input: 'a: 1\nb: 2\na: 3', output: 'Invalid: line 3: duplicate key "a" (first defined on line 1)' },
+    placeholder: 'Paste YAML…',
+    buttonLabel: 'Validate',
+  },
+];
+
+/** Map of tool id to description for command palette search. */
+export const YAML_TOOL_DESCRIPTIONS: Record<string, string> = Object.fromEntries(
+  TOOL_CONFIG.map((c) => [c.id, c.description])
+);
+
+const TOOL_MAP = Object.fromEntries(TOOL_CONFIG.map((c) => [c.id, c])) as Record<YamlToolId, ToolConfig>;
+
+function formatWarnings(warnings: YamlWarning[] = []): string[] {
+  return warnings.map((w) => `Warning: line ${w.line}, column ${w.column}: ${w.message}`);
+}
+
+type YamlToolsProps = {
+  tool?: YamlToolId;
+};
+
+export function YamlTools({ tool: toolProp }: YamlToolsProps) {
+  const [tab, setTab] = useState<YamlToolId>(toolProp ?? 'to-json');
+  const [input, setInput] = useState('');
+  const [version, setVersion] = useState<YamlVersion>('1.2');
+  const [documents, setDocuments] = useState<YamlDocuments>('auto');
+  const [indent, setIndent] = useState('2');
+  const [stream, setStream] = useState(false);
+  const [lenient, setLenient] = useState(false);
+  const [output, setOutput] = useState('');
+  const [error, setError] = useState<string | null>(null);
+  const [loading, setLoading] = useState(false);
+  const [copied, setCopied] = useState(false);
+  const copyTimeoutRef = useRef<ReturnType<typeof setTimeout> | null>(null);
+
+  const tool = toolProp ?? tab;
+  const showTabs = toolProp == null;
+  const config = TOOL_MAP[tool];
+
+  const run = async () => {
+    setError(null);
+    setOutput('');
+    if (copyTimeoutRef.current) {
+      clearTimeout(copyTimeoutRef.current);
+      copyTimeoutRef.current = null;
+    }
+    setCopied(false);
+    setLoading(true);
+    try {
+      if (tool === 'to-json') {
+        const res: YamlToJsonResult = await yamlToJson(input, version, documents);
+        const warnings = formatWarnings(res.warnings);
+        setOutput(warnings.length ? `${res.result}\n\n${warnings.join('\n')}` : res.result);
+      } else if (tool === 'from-json') {
+        const res: YamlResult = await jsonToYaml(input, { indent: Number(indent), stream, lenient });
+        setOutput(res.result);
+      } else if (tool === 'format') {
+        const res: YamlResult = await formatYaml(input, Number(indent));
+        setOutput(res.result);
+      } else if (tool === 'validate') {
+        const res: YamlValidateResult = await validateYaml(input, version);
+        const verdict = res.valid
+          ? `Valid (${res.documents} ${res.documents === 1 ? 'document' : 'documents'})`
+          : `Invalid: ${res.error ?? 'syntax error'}`;
+        setOutput([verdict, ...formatWarnings(res.warnings)].join('\n'));
+      }
+    } catch (e) {
+      setError(e instanceof Error ? e.message : 'Request failed');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const useExample = () => {
+    setInput(config.example.input);
+  };
+
+  const canRun = input.trim().length > 0;
+
+  const handleCopy = async () => {
+    if (!output) return;
+    if (copyTimeoutRef.current) clearTimeout(copyTimeoutRef.current);
+    try {
+      await navigator.clipboard.writeText(output);
+      setCopied(true);
+      copyTimeoutRef.current = setTimeout(() => {
+        copyTimeoutRef.current = null;
+        setCopied(false);
+      }, 1500);
+    } catch {
+      // Permission denied or unsupported
+    }
+  };
+
+  const textareaClass = 'w-full min-h-24 p-2.5 font-mono text-sm rounded-lg border border-border bg-bg text-text resize-y';
+
+  return (
+    <section className="text-left mt-6">
+      <h2 className="mb-3 text-2xl text-text">YAML</h2>
+      {showTabs && (
+        <nav className="flex gap-2 mb-4 flex-wrap" aria-label="YAML tools">
+          {TOOL_CONFIG.map((c) => (
+            <button
+              key={c.id}
+              type="button"
+              className={`py-2 px-4 rounded ${tab === c.id ? 'border border-accent bg-sidebar-active' : ''}`}
+              onClick={() => setTab(c.id)}
+            >
+              {c.label}
+            </button>
+          ))}
+        </nav>
+      )}
+      <p className="mb-2 text-text-secondary max-w-2xl">{config.description}</p>
+      <p className="mb-4 text-sm text-text-secondary max-w-2xl">
+        Example: <code className="px-1 rounded bg-bg-elevated font-mono text-xs">{config.example.input}</code>
+        {config.example.output != null && (
+          <> → <code className="px-1 rounded bg-bg-elevated font-mono text-xs">{config.example.output}</code></>
+        )}
+        <button type="button" onClick={useExample} className="ml-2 text-accent hover:underline">
+          Use example
+        </button>
+      </p>
+      <div className="flex flex-col gap-3 max-w-2xl">
+        <label htmlFor="yaml-input" className="font-medium">
+          Input
+        </label>
+        <textarea
+          id="yaml-input"
+          className={textareaClass}
+          value={input}
+          onChange={(e) => setInput(e.target.value)}
+          placeholder={config.placeholder}
+          rows={6}
+        />
+        {(tool === 'to-json' || tool === 'validate') && (
+          <label className="flex items-center gap-2">
+            YAML version
+            <select
+              className="p-1.5 rounded border border-border bg-bg text-text"
+              value={version}
+              onChange={(e) => setVersion(e.target.value as YamlVersion)}
+            >
+              <option value="1.2">1.2 (yes/on are strings)</option>
+              <option value="1.1">1.1 (yes/on are booleans, 0755 is octal)</option>
+            </select>
+          </label>
+        )}
+        {tool === 'to-json' && (
+          <label className="flex items-center gap-2">
+            Documents
+            <select
+              className="p-1.5 rounded border border-border bg-bg text-text"
+              value={documents}
+              onChange={(e) => setDocuments(e.target.value as YamlDocuments)}
+            >
+              <option value="auto">Array only for several documents</option>
+              <option value="array">Always an array</option>
+              <option value="ndjson">One line per document (NDJSON)</option>
+            </select>
+          </label>
+        )}
+        {(tool === 'from-json' || tool === 'format') && (
+          <label className="flex items-center gap-2">
+            Indent
+            <select
+              className="p-1.5 rounded border border-border bg-bg text-text"
+              value={indent}
+              onChange={(e) => setIndent(e.target.value)}
+            >
+              <option value="2">2 spaces</option>
+              <option value="4">4 spaces</option>
+            </select>
+          </label>
+        )}
+        {tool === 'from-json' && (
+          <>
+            <label className="flex items-center gap-2">
+              <input type="checkbox" checked={stream} onChange={(e) => setStream(e.target.checked)} />
+              Write each element of a top-level array as its own document
+            </label>
+            <label className="flex items-center gap-2">
+              <input type="checkbox" checked={lenient} onChange={(e) => setLenient(e.target.checked)} />
+              Allow JSON5 / JSONC (comments, trailing commas, single quotes)
+            </label>
+          </>
+        )}
+        <button type="button" onClick={run} disabled={loading || !canRun}>
+          {loading ? '…' : config.buttonLabel}
+        </button>
+        {error && (
+          <p className="text-red-500 m-0" role="alert">
+            {error}
+          </p>
+        )}
+        {output && (
+          <div className="mt-2">
+            <div className="flex items-center justify-between gap-2 mb-1">
+              <label className="font-medium">Output</label>
+              <Button
+                type="button"
+                variant="outline"
+                size="sm"
+                onClick={handleCopy}
+                className="shrink-0"
+                aria-label={copied ? 'Copied' : 'Copy to clipboard'}
+              >
+                <Copy className="size-3.5" aria-hidden />
+                {copied ? 'Copied!' : 'Copy'}
+              </Button>
+            </div>
+            <pre className="m-0 p-3 bg-bg-elevated rounded-lg overflow-x-auto whitespace-pre-wrap break-all font-mono text-sm">
+              {output}
+            </pre>
+          </div>
+        )}
+      </div>
+    </section>
+  );
+}
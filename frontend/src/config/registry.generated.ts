@@ -0,0 +1,49 @@
+// Code generated by go test ./handlers -run FrontendRegistry -update from the backend
+// tool registry (GET /api/tools). DO NOT EDIT.
+
+export const registryCategories = [
+  { id: 'string', label: 'Strings' },
+  { id: 'lorem-ipsum', label: 'Lorem Ipsum' },
+  { id: 'json', label: 'JSON' },
+  { id: 'yaml', label: 'YAML' },
+] as const;
+
+export const registryTools = [
+  { id: 'url-encode', category: 'string', group: 'URL', label: 'URL encode' },
+  { id: 'url-decode', category: 'string', group: 'URL', label: 'URL decode' },
+  { id: 'url-parse-params', category: 'string', group: 'URL', label: 'URL params parser' },
+  { id: 'url-param-creator', category: 'string', group: 'URL', label: 'URL param creator' },
+  { id: 'base64-encode', category: 'string', group: 'Base64', label: 'Base64 encode' },
+  { id: 'base64-decode', category: 'string', group: 'Base64', label: 'Base64 decode' },
+  { id: 'upper-case', category: 'string', group: 'Case', label: 'Upper Case' },
+  { id: 'lower-case', category: 'string', group: 'Case', label: 'Lower Case' },
+  { id: 'capital-case', category: 'string', group: 'Case', label: 'Capital Case' },
+  { id: 'snake-case', category: 'string', group: 'Case', label: 'Snake Case' },
+  { id: 'kebab-case', category: 'string', group: 'Case', label: 'Kebab Case' },
+  { id: 'camel-case', category: 'string', group: 'Case', label: 'Camel Case' },
+  { id: 'pascal-case', category: 'string', group: 'Case', label: 'Pascal Case' },
+  { id: 'sentence-case', category: 'string', group: 'Case', label: 'Sentence Case' },
+  { id: 'spell-out', category: 'string', group: 'Spelling', label: 'Spell out' },
+  { id: 'trim', category: 'string', group: 'Trim', label: 'Trim' },
+  { id: 'generate', category: 'lorem-ipsum', group: '', label: 'Lorem Ipsum' },
+  { id: 'format', category: 'json', group: 'Format', label: 'Format' },
+  { id: 'minify', category: 'json', group: 'Format', label: 'Minify' },
+  { id: 'canonicalize', category: 'json', group: 'Format', label: 'Canonicalize' },
+  { id: 'repair', category: 'json', group: 'Format', label: 'Repair' },
+  { id: 'validate', category: 'json', group: 'Validate', label: 'Validate' },
+  { id: 'validate-schema', category: 'json', group: 'Validate', label: 'Schema validate' },
+  { id: 'path', category: 'json', group: 'Query', label: 'Path query' },
+  { id: 'pointer', category: 'json', group: 'Query', label: 'JSON Pointer' },
+  { id: 'jq', category: 'json', group: 'Query', label: 'jq' },
+  { id: 'diff', category: 'json', group: 'Compare', label: 'Diff' },
+  { id: 'patch', category: 'json', group: 'Compare', label: 'Patch' },
+  { id: 'merge-diff', category: 'json', group: 'Compare', label: 'Merge patch diff' },
+  { id: 'merge-patch', category: 'json', group: 'Compare', label: 'Merge patch' },
+  { id: 'infer-schema', category: 'json', group: 'Generate', label: 'Infer schema' },
+  { id: 'go-struct', category: 'json', group: 'Generate', label: 'Go structs' },
+  { id: 'typescript', category: 'json', group: 'Generate', label: 'TypeScript' },
+  { id: 'to-json', category: 'yaml', group: 'Convert', label: 'YAML to JSON' },
+  { id: 'from-json', category: 'yaml', group: 'Convert', label: 'JSON to YAML' },
+  { id: 'format', category: 'yaml', group: 'Format', label: 'Format' },
+  { id: 'validate', category: 'yaml', group: 'Validate', label: 'Validate' },
+] as const;
@@ -2,6 +2,7 @@ import { sidebarConfig } from '@/config/sidebarConfig';
 import { JSON_TOOL_DESCRIPTIONS } from '@/components/JsonTools';
 import { LOREM_TOOL_DESCRIPTIONS } from '@/components/LoremTools';
 import { TOOL_DESCRIPTIONS } from '@/components/StringTools';
+import { YAML_TOOL_DESCRIPTIONS } from '@/components/YamlTools';
 
 export interface ToolForSearch {
   id: string;
@@ -22,7 +23,10 @@ export function getToolsForSearch(): ToolForSearch[] {
       result.push({
         id: item.id,
         label: item.label,
-        description: TOOL_DESCRIPTIONS[item.id] ?? LOREM_TOOL_DESCRIPTIONS[item.id] ?? JSON_TOOL_DESCRIPTIONS[item.id] ?? '',
+        description:
+          category.id === 'yaml'
+            ? YAML_TOOL_DESCRIPTIONS[item.id] ?? ''
+            : TOOL_DESCRIPTIONS[item.id] ?? LOREM_TOOL_DESCRIPTIONS[item.id] ?? JSON_TOOL_DESCRIPTIONS[item.id] ?? '',
         path: item.path,
         categoryLabel: category.label,
       });